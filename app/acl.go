@@ -0,0 +1,1067 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valery-barysok/gredisd/app/cmd"
+	"github.com/valery-barysok/resp"
+)
+
+var (
+	errACLSyntax          = errors.New("Syntax error")
+	errACLUnknownCommand  = errors.New("Unknown command or category name in ACL")
+	errACLNoSuchPassword  = errors.New("The password you are trying to remove from the user does not exist")
+	errACLPasswordHash    = errors.New("The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+	errACLPatternAfterAll = errors.New("Adding a pattern after the * pattern (or the 'allkeys' flag) is not valid and does not have any effect. Try 'resetkeys' to start with an empty list of patterns")
+	errACLUserName        = errors.New("ERR Usernames can't contain spaces or null characters")
+	errACLDefaultUser     = errors.New("ERR The 'default' user cannot be removed")
+	errACLNoFile          = errors.New("ERR This instance is not configured to use an ACL file")
+	errNoPermKey          = errors.New("NOPERM No permissions to access a key")
+)
+
+// aclCategories are command categories in order reported by ACL CAT
+var aclCategories = []string{
+	"keyspace", "read", "write", "set", "sortedset", "list", "hash", "string",
+	"fast", "slow", "admin", "dangerous", "connection", "blocking", "pubsub",
+}
+
+const (
+	// aclLogGroupTime is period during which equal denials are counted by one ACL LOG entry
+	aclLogGroupTime = 60 * time.Second
+
+	aclReasonCommand = "command"
+	aclReasonKey     = "key"
+	aclReasonAuth    = "auth"
+)
+
+// CommandSpec describes categories and key arguments of command checked by ACL
+type CommandSpec struct {
+	Categories []string
+
+	// First, Last and Step are positions of key arguments counted from 1, Last
+	// is counted from the end of arguments when negative
+	First int
+	Last  int
+	Step  int
+
+	// NumKeys is position of argument holding number of key arguments following it, 0 if none
+	NumKeys int
+
+	// DenyOOM is set for commands which may take more memory, they are rejected
+	// once maxmemory is reached and keys can not be evicted
+	DenyOOM bool
+}
+
+// keys returns key arguments of command
+func (spec *CommandSpec) keys(args []*resp.Message) []string {
+	var keys []string
+	n := len(args)
+	if spec.First > 0 {
+		last := spec.Last
+		if last < 0 {
+			last = n + 1 + last
+		}
+		step := spec.Step
+		if step <= 0 {
+			step = 1
+		}
+		for i := spec.First; i <= last && i <= n; i += step {
+			keys = append(keys, string(args[i-1].BulkString()))
+		}
+	}
+	if spec.NumKeys > 0 && spec.NumKeys <= n {
+		num, err := strconv.Atoi(string(args[spec.NumKeys-1].BulkString()))
+		if err == nil {
+			for i := spec.NumKeys + 1; i <= spec.NumKeys+num && i <= n; i++ {
+				keys = append(keys, string(args[i-1].BulkString()))
+			}
+		}
+	}
+	return keys
+}
+
+// InCategory reports whether command belongs to ACL category
+func (spec *CommandSpec) InCategory(category string) bool {
+	for _, c := range spec.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// aclUser is never modified once it is stored by acl, changes are made to copy
+type aclUser struct {
+	name    string
+	enabled bool
+	nopass  bool
+
+	// passwords are hex encoded SHA-256 hashes of passwords
+	passwords []string
+
+	// commands are rules like +get, -@write or +config|get applied in order,
+	// the last rule matching command decides whether it is allowed
+	commands []string
+
+	// keys are key pattern rules like ~cache:* or %R~config:*
+	allKeys bool
+	keys    []string
+
+	allChannels bool
+	channels    []string
+}
+
+func newACLUser(name string) *aclUser {
+	return &aclUser{name: name}
+}
+
+// defaultACLUser returns user connected clients are authenticated as at first
+func defaultACLUser() *aclUser {
+	return &aclUser{
+		name:        DefaultUser,
+		enabled:     true,
+		nopass:      true,
+		commands:    []string{"+@all"},
+		allKeys:     true,
+		allChannels: true,
+	}
+}
+
+func (user *aclUser) clone() *aclUser {
+	c := *user
+	c.passwords = append([]string(nil), user.passwords...)
+	c.commands = append([]string(nil), user.commands...)
+	c.keys = append([]string(nil), user.keys...)
+	c.channels = append([]string(nil), user.channels...)
+	return &c
+}
+
+func hashPassword(pass string) string {
+	sum := sha256.Sum256([]byte(pass))
+	return hex.EncodeToString(sum[:])
+}
+
+func isPasswordHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func removeString(list []string, s string) ([]string, bool) {
+	for i, v := range list {
+		if v == s {
+			return append(list[:i], list[i+1:]...), true
+		}
+	}
+	return list, false
+}
+
+// checkPassword compares hash of password with every password of user in constant time
+func (user *aclUser) checkPassword(pass string) bool {
+	if user.nopass {
+		return true
+	}
+
+	hash := []byte(hashPassword(pass))
+	ok := false
+	for _, p := range user.passwords {
+		if subtle.ConstantTimeCompare(hash, []byte(p)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
+
+// setRule applies single ACL SETUSER rule to user
+func (user *aclUser) setRule(rule string, commandExists func(name string) bool) error {
+	lower := strings.ToLower(rule)
+	switch lower {
+	case "on":
+		user.enabled = true
+	case "off":
+		user.enabled = false
+	case "nopass":
+		user.nopass = true
+		user.passwords = nil
+	case "resetpass":
+		user.nopass = false
+		user.passwords = nil
+	case "allkeys":
+		user.allKeys = true
+		user.keys = nil
+	case "resetkeys":
+		user.allKeys = false
+		user.keys = nil
+	case "allchannels":
+		user.allChannels = true
+		user.channels = nil
+	case "resetchannels":
+		user.allChannels = false
+		user.channels = nil
+	case "allcommands":
+		user.commands = []string{"+@all"}
+	case "nocommands":
+		user.commands = []string{"-@all"}
+	case "reset":
+		*user = *newACLUser(user.name)
+	default:
+		if rule == "" {
+			return errACLSyntax
+		}
+		return user.setPatternRule(rule, commandExists)
+	}
+	return nil
+}
+
+func (user *aclUser) setPatternRule(rule string, commandExists func(name string) bool) error {
+	value := rule[1:]
+	switch rule[0] {
+	case '>':
+		hash := hashPassword(value)
+		user.passwords, _ = removeString(user.passwords, hash)
+		user.passwords = append(user.passwords, hash)
+		user.nopass = false
+	case '#':
+		if !isPasswordHash(value) {
+			return errACLPasswordHash
+		}
+		user.passwords, _ = removeString(user.passwords, value)
+		user.passwords = append(user.passwords, value)
+		user.nopass = false
+	case '<', '!':
+		hash := value
+		if rule[0] == '<' {
+			hash = hashPassword(value)
+		} else if !isPasswordHash(value) {
+			return errACLPasswordHash
+		}
+		var ok bool
+		if user.passwords, ok = removeString(user.passwords, hash); !ok {
+			return errACLNoSuchPassword
+		}
+	case '~':
+		if value == "*" {
+			user.allKeys = true
+			user.keys = nil
+		} else if user.allKeys {
+			return errACLPatternAfterAll
+		} else if value != "" {
+			user.keys, _ = removeString(user.keys, rule)
+			user.keys = append(user.keys, rule)
+		}
+	case '%':
+		read, write, pattern, err := parseKeyPermissions(rule)
+		if err != nil {
+			return err
+		}
+		if read && write {
+			return user.setPatternRule("~"+pattern, commandExists)
+		}
+		if user.allKeys {
+			return errACLPatternAfterAll
+		}
+		rule = keyPatternRule(read, write, pattern)
+		user.keys, _ = removeString(user.keys, rule)
+		user.keys = append(user.keys, rule)
+	case '&':
+		if value == "*" {
+			user.allChannels = true
+			user.channels = nil
+		} else if user.allChannels {
+			return errACLPatternAfterAll
+		} else if value != "" {
+			user.channels, _ = removeString(user.channels, value)
+			user.channels = append(user.channels, value)
+		}
+	case '+', '-':
+		return user.setCommandRule(strings.ToLower(rule), commandExists)
+	default:
+		return errACLSyntax
+	}
+	return nil
+}
+
+// parseKeyPermissions parses %R~pattern, %W~pattern and %RW~pattern rules
+func parseKeyPermissions(rule string) (bool, bool, string, error) {
+	end := strings.IndexByte(rule, '~')
+	if end < 2 || end == len(rule)-1 {
+		return false, false, "", errACLSyntax
+	}
+
+	read, write := false, false
+	for _, c := range strings.ToUpper(rule[1:end]) {
+		switch c {
+		case 'R':
+			read = true
+		case 'W':
+			write = true
+		default:
+			return false, false, "", errACLSyntax
+		}
+	}
+	return read, write, rule[end+1:], nil
+}
+
+// keyPatternRule returns rule of pattern allowing given access
+func keyPatternRule(read bool, write bool, pattern string) string {
+	switch {
+	case read && write:
+		return "~" + pattern
+	case read:
+		return "%R~" + pattern
+	}
+	return "%W~" + pattern
+}
+
+func (user *aclUser) setCommandRule(rule string, commandExists func(name string) bool) error {
+	target := rule[1:]
+	switch {
+	case target == "@all":
+		user.commands = []string{rule}
+		return nil
+	case strings.HasPrefix(target, "@"):
+		known := false
+		for _, category := range aclCategories {
+			if category == target[1:] {
+				known = true
+			}
+		}
+		if !known {
+			return errACLUnknownCommand
+		}
+	default:
+		name := target
+		if i := strings.IndexByte(target, '|'); i >= 0 {
+			name = target[:i]
+			if i == len(target)-1 {
+				return errACLSyntax
+			}
+		}
+		if !commandExists(name) {
+			return errACLUnknownCommand
+		}
+	}
+
+	// earlier rules of the same target have no effect once rule is appended
+	for _, prefix := range []string{"+", "-"} {
+		user.commands, _ = removeString(user.commands, prefix+target)
+	}
+	user.commands = append(user.commands, rule)
+	return nil
+}
+
+// canRun checks command rules of user against command and its subcommand
+func (user *aclUser) canRun(name string, sub string, spec *CommandSpec) bool {
+	allowed := false
+	for _, rule := range user.commands {
+		target := rule[1:]
+		var match bool
+		switch {
+		case target == "@all":
+			match = true
+		case target[0] == '@':
+			match = spec != nil && spec.InCategory(target[1:])
+		case strings.IndexByte(target, '|') >= 0:
+			match = target == name+"|"+sub
+		default:
+			match = target == name
+		}
+		if match {
+			allowed = rule[0] == '+'
+		}
+	}
+	return allowed
+}
+
+// canAccess checks key against key patterns of user. Commands of @read category
+// need read access, commands of @write category need write access and other
+// commands need both
+func (user *aclUser) canAccess(key string, spec *CommandSpec) bool {
+	if user.allKeys {
+		return true
+	}
+
+	needRead, needWrite := spec.InCategory("read"), spec.InCategory("write")
+	if !needRead && !needWrite {
+		needRead, needWrite = true, true
+	}
+
+	canRead, canWrite := false, false
+	for _, rule := range user.keys {
+		read, write, pattern := true, true, rule[1:]
+		if rule[0] == '%' {
+			read, write, pattern, _ = parseKeyPermissions(rule)
+		}
+		if globMatch(pattern, key) {
+			canRead = canRead || read
+			canWrite = canWrite || write
+		}
+	}
+	return (canRead || !needRead) && (canWrite || !needWrite)
+}
+
+func (user *aclUser) flags() []interface{} {
+	flags := make([]interface{}, 0, 2)
+	if user.enabled {
+		flags = append(flags, []byte("on"))
+	} else {
+		flags = append(flags, []byte("off"))
+	}
+	if user.nopass {
+		flags = append(flags, []byte("nopass"))
+	}
+	return flags
+}
+
+func (user *aclUser) describeCommands() string {
+	if len(user.commands) == 0 {
+		return "-@all"
+	}
+	return strings.Join(user.commands, " ")
+}
+
+func (user *aclUser) describeKeys() string {
+	if user.allKeys {
+		return "~*"
+	}
+	return strings.Join(user.keys, " ")
+}
+
+func (user *aclUser) describeChannels() string {
+	if user.allChannels {
+		return "&*"
+	}
+	if len(user.channels) == 0 {
+		return "resetchannels"
+	}
+	patterns := make([]string, 0, len(user.channels))
+	for _, channel := range user.channels {
+		patterns = append(patterns, "&"+channel)
+	}
+	return strings.Join(patterns, " ")
+}
+
+// describe returns rules creating the same user, it is line of ACL LIST and ACL file
+func (user *aclUser) describe() string {
+	parts := []string{"user", user.name}
+	if user.enabled {
+		parts = append(parts, "on")
+	} else {
+		parts = append(parts, "off")
+	}
+	if user.nopass {
+		parts = append(parts, "nopass")
+	}
+	for _, hash := range user.passwords {
+		parts = append(parts, "#"+hash)
+	}
+	if keys := user.describeKeys(); keys != "" {
+		parts = append(parts, keys)
+	}
+	parts = append(parts, user.describeChannels(), user.describeCommands())
+	return strings.Join(parts, " ")
+}
+
+// aclLogEntry is denied command, key access or authentication reported by ACL LOG
+type aclLogEntry struct {
+	id       int64
+	count    int
+	reason   string
+	context  string
+	object   string
+	username string
+	client   string
+	created  time.Time
+	updated  time.Time
+}
+
+type acl struct {
+	mu    sync.RWMutex
+	users map[string]*aclUser
+
+	// specs are set on bind so map is not modified while serving
+	specs map[string]*CommandSpec
+
+	// logMaxLen is updated atomically
+	logMaxLen int64
+	logMu     sync.Mutex
+	log       *list.List
+	logID     int64
+}
+
+func newACL() *acl {
+	return &acl{
+		users: map[string]*aclUser{DefaultUser: defaultACLUser()},
+		specs: make(map[string]*CommandSpec),
+		log:   list.New(),
+	}
+}
+
+func (acl *acl) user(name string) *aclUser {
+	acl.mu.RLock()
+	defer acl.mu.RUnlock()
+
+	return acl.users[name]
+}
+
+// spec returns spec of subcommand if it is described or spec of command
+func (acl *acl) spec(name string, sub string) *CommandSpec {
+	if sub != "" {
+		if spec, ok := acl.specs[name+"|"+sub]; ok {
+			return spec
+		}
+	}
+	return acl.specs[name]
+}
+
+// setDefaultPassword makes password the only password of default user, empty
+// password makes default user nopass
+func (acl *acl) setDefaultPassword(pass string) {
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+
+	user := acl.users[DefaultUser].clone()
+	if pass == "" {
+		user.nopass = true
+		user.passwords = nil
+	} else {
+		user.nopass = false
+		user.passwords = []string{hashPassword(pass)}
+	}
+	acl.users[DefaultUser] = user
+}
+
+func (acl *acl) record(reason string, object string, username string, client string) {
+	maxLen := int(atomic.LoadInt64(&acl.logMaxLen))
+	now := time.Now()
+
+	acl.logMu.Lock()
+	defer acl.logMu.Unlock()
+
+	for it := acl.log.Front(); it != nil; it = it.Next() {
+		entry := it.Value.(*aclLogEntry)
+		if entry.reason == reason && entry.object == object && entry.username == username &&
+			now.Sub(entry.updated) < aclLogGroupTime {
+			entry.count++
+			entry.updated = now
+			entry.client = client
+			acl.log.MoveToFront(it)
+			return
+		}
+	}
+
+	acl.log.PushFront(&aclLogEntry{
+		id:       acl.logID,
+		count:    1,
+		reason:   reason,
+		context:  "toplevel",
+		object:   object,
+		username: username,
+		client:   client,
+		created:  now,
+		updated:  now,
+	})
+	acl.logID++
+	for acl.log.Len() > maxLen {
+		acl.log.Remove(acl.log.Back())
+	}
+}
+
+// SetACLLogMaxLen changes maximum number of ACL LOG entries
+func (app *App) SetACLLogMaxLen(maxLen int) {
+	atomic.StoreInt64(&app.acl.logMaxLen, int64(maxLen))
+
+	app.acl.logMu.Lock()
+	for app.acl.log.Len() > maxLen {
+		app.acl.log.Remove(app.acl.log.Back())
+	}
+	app.acl.logMu.Unlock()
+}
+
+// DescribeCommand sets categories and key arguments of command or of
+// subcommand named command|subcommand, it must be called before serving
+func (app *App) DescribeCommand(name string, spec CommandSpec) {
+	app.acl.specs[name] = &spec
+}
+
+func (app *App) commandExists(name string) bool {
+	_, ok := app.router.routes[name]
+	return ok
+}
+
+// RequireAuth reports whether new clients must authenticate, it is so unless
+// default user is enabled and has no password
+func (app *App) RequireAuth() bool {
+	user := app.acl.user(DefaultUser)
+	return !user.enabled || !user.nopass
+}
+
+// Authenticate checks password of enabled user
+func (app *App) Authenticate(username string, pass string) bool {
+	user := app.acl.user(username)
+	return user != nil && user.enabled && user.checkPassword(pass)
+}
+
+// Auth authenticates client as user. Failed attempt is reported by ACL LOG
+// and client must authenticate again
+func (context *ClientContext) Auth(username string, pass string) bool {
+	if !context.App.Authenticate(username, pass) {
+		context.RequireAuth = true
+		context.App.acl.record(aclReasonAuth, "AUTH", username, context.clientInfo())
+		return false
+	}
+
+	context.RequireAuth = false
+	context.user = username
+	if context.client != nil {
+		context.client.setUser(username)
+	}
+	return true
+}
+
+// User returns name of user client is authenticated as
+func (context *ClientContext) User() string {
+	return context.user
+}
+
+func (context *ClientContext) clientInfo() string {
+	if context.client == nil {
+		return ""
+	}
+	return strings.TrimSuffix(context.client.info(time.Now()), "\n")
+}
+
+// CheckACL checks whether user of client may run command and access its keys.
+// Denied command is reported by ACL LOG and NOPERM error is returned
+func (context *ClientContext) CheckACL(cmd *cmd.Command) error {
+	if !context.App.commandExists(cmd.Cmd) {
+		return nil
+	}
+
+	acl := context.App.acl
+	user := acl.user(context.user)
+	if user == nil {
+		return fmt.Errorf("NOPERM User %s has no permissions to run the '%s' command", context.user, cmd.Cmd)
+	}
+
+	var sub string
+	if len(cmd.Args) > 0 {
+		sub = strings.ToLower(string(cmd.Args[0].BulkString()))
+	}
+	spec := acl.spec(cmd.Cmd, sub)
+	if !user.canRun(cmd.Cmd, sub, spec) {
+		object := cmd.Cmd
+		if _, ok := acl.specs[cmd.Cmd+"|"+sub]; ok {
+			object += "|" + sub
+		}
+		acl.record(aclReasonCommand, object, user.name, context.clientInfo())
+		return fmt.Errorf("NOPERM User %s has no permissions to run the '%s' command", user.name, object)
+	}
+
+	if user.allKeys {
+		return nil
+	}
+	// key arguments of command without spec are unknown so it is denied
+	if spec == nil {
+		acl.record(aclReasonCommand, cmd.Cmd, user.name, context.clientInfo())
+		return fmt.Errorf("NOPERM User %s has no permissions to run the '%s' command", user.name, cmd.Cmd)
+	}
+	for _, key := range spec.keys(cmd.Args) {
+		if !user.canAccess(key, spec) {
+			acl.record(aclReasonKey, key, user.name, context.clientInfo())
+			return errNoPermKey
+		}
+	}
+	return nil
+}
+
+func validUserName(name string) bool {
+	return name != "" && strings.IndexAny(name, " \t\r\n\x00") < 0
+}
+
+// ACLSetUser creates or modifies user. Either all rules are applied or none
+// of them if any rule is invalid
+func (app *App) ACLSetUser(name string, rules ...string) error {
+	if !validUserName(name) {
+		return errACLUserName
+	}
+
+	app.acl.mu.Lock()
+	defer app.acl.mu.Unlock()
+
+	user := newACLUser(name)
+	if current, ok := app.acl.users[name]; ok {
+		user = current.clone()
+	}
+	for _, rule := range rules {
+		if err := user.setRule(rule, app.commandExists); err != nil {
+			return fmt.Errorf("ERR Error in ACL SETUSER modifier '%s': %s", rule, err)
+		}
+	}
+	app.acl.users[name] = user
+	return nil
+}
+
+// ACLGetUser returns rules of user or nil if user does not exist
+func (app *App) ACLGetUser(name string) Map {
+	user := app.acl.user(name)
+	if user == nil {
+		return nil
+	}
+
+	passwords := make([]interface{}, 0, len(user.passwords))
+	for _, hash := range user.passwords {
+		passwords = append(passwords, []byte(hash))
+	}
+	return Map{
+		[]byte("flags"), user.flags(),
+		[]byte("passwords"), passwords,
+		[]byte("commands"), []byte(user.describeCommands()),
+		[]byte("keys"), []byte(user.describeKeys()),
+		[]byte("channels"), []byte(user.describeChannels()),
+	}
+}
+
+// ACLDelUser deletes users and disconnects clients authenticated as them. It
+// returns number of deleted users
+func (app *App) ACLDelUser(context *ClientContext, names ...string) (int, error) {
+	for _, name := range names {
+		if name == DefaultUser {
+			return 0, errACLDefaultUser
+		}
+	}
+
+	app.acl.mu.Lock()
+	deleted := make(map[string]bool)
+	for _, name := range names {
+		if _, ok := app.acl.users[name]; ok {
+			delete(app.acl.users, name)
+			deleted[name] = true
+		}
+	}
+	app.acl.mu.Unlock()
+
+	app.killUsers(context, func(user string) bool { return deleted[user] })
+	return len(deleted), nil
+}
+
+// killUsers disconnects clients authenticated as users matching filter
+func (app *App) killUsers(context *ClientContext, filter func(user string) bool) {
+	for _, client := range app.clients() {
+		if filter(client.user()) {
+			context.killClient(client)
+		}
+	}
+}
+
+func (app *App) sortedUsers() []*aclUser {
+	app.acl.mu.RLock()
+	defer app.acl.mu.RUnlock()
+
+	names := make([]string, 0, len(app.acl.users))
+	for name := range app.acl.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	users := make([]*aclUser, 0, len(names))
+	for _, name := range names {
+		users = append(users, app.acl.users[name])
+	}
+	return users
+}
+
+// ACLList returns rules of every user in format of ACL file
+func (app *App) ACLList() []interface{} {
+	users := app.sortedUsers()
+	lines := make([]interface{}, 0, len(users))
+	for _, user := range users {
+		lines = append(lines, []byte(user.describe()))
+	}
+	return lines
+}
+
+// ACLUsers returns names of users
+func (app *App) ACLUsers() []interface{} {
+	users := app.sortedUsers()
+	names := make([]interface{}, 0, len(users))
+	for _, user := range users {
+		names = append(names, []byte(user.name))
+	}
+	return names
+}
+
+// ACLCat returns command categories or commands of category
+func (app *App) ACLCat(category ...string) ([]interface{}, error) {
+	if len(category) == 0 {
+		categories := make([]interface{}, 0, len(aclCategories))
+		for _, c := range aclCategories {
+			categories = append(categories, []byte(c))
+		}
+		return categories, nil
+	}
+
+	name := strings.ToLower(category[0])
+	known := false
+	for _, c := range aclCategories {
+		if c == name {
+			known = true
+		}
+	}
+	if !known {
+		return nil, fmt.Errorf("ERR Unknown category '%s'", category[0])
+	}
+
+	names := make([]string, 0)
+	for cmd, spec := range app.acl.specs {
+		if spec.InCategory(name) {
+			names = append(names, cmd)
+		}
+	}
+	sort.Strings(names)
+
+	cmds := make([]interface{}, 0, len(names))
+	for _, cmd := range names {
+		cmds = append(cmds, []byte(cmd))
+	}
+	return cmds, nil
+}
+
+// ACLLog returns up to count the most recent entries of ACL LOG, all entries if count is negative
+func (app *App) ACLLog(count int) []interface{} {
+	app.acl.logMu.Lock()
+	defer app.acl.logMu.Unlock()
+
+	if count < 0 || count > app.acl.log.Len() {
+		count = app.acl.log.Len()
+	}
+
+	now := time.Now()
+	entries := make([]interface{}, 0, count)
+	for it := app.acl.log.Front(); it != nil && len(entries) < count; it = it.Next() {
+		entry := it.Value.(*aclLogEntry)
+		age := float64(now.Sub(entry.created)) / float64(time.Second)
+		entries = append(entries, Map{
+			[]byte("count"), entry.count,
+			[]byte("reason"), []byte(entry.reason),
+			[]byte("context"), []byte(entry.context),
+			[]byte("object"), []byte(entry.object),
+			[]byte("username"), []byte(entry.username),
+			[]byte("age-seconds"), []byte(strconv.FormatFloat(age, 'f', 3, 64)),
+			[]byte("client-info"), []byte(entry.client),
+			[]byte("entry-id"), int(entry.id),
+			[]byte("timestamp-created"), int(entry.created.UnixNano() / int64(time.Millisecond)),
+			[]byte("timestamp-last-updated"), int(entry.updated.UnixNano() / int64(time.Millisecond)),
+		})
+	}
+	return entries
+}
+
+// ACLLogReset removes all entries of ACL LOG
+func (app *App) ACLLogReset() {
+	app.acl.logMu.Lock()
+	app.acl.log.Init()
+	app.acl.logMu.Unlock()
+}
+
+// readACLFile parses ACL file, every line is user followed by name and rules
+// of ACL SETUSER, lines starting with # are comments
+func (app *App) readACLFile(file string) (map[string]*aclUser, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[string]*aclUser)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		args, err := splitConfigLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", file, n, err)
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if strings.ToLower(args[0]) != "user" || len(args) < 2 {
+			return nil, fmt.Errorf("%s:%d: line should start with user keyword followed by username", file, n)
+		}
+		if !validUserName(args[1]) {
+			return nil, fmt.Errorf("%s:%d: %s", file, n, configReason(errACLUserName))
+		}
+		if _, ok := users[args[1]]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate user '%s'", file, n, args[1])
+		}
+
+		user := newACLUser(args[1])
+		for _, rule := range args[2:] {
+			if err := user.setRule(rule, app.commandExists); err != nil {
+				return nil, fmt.Errorf("%s:%d: error in rule '%s': %s", file, n, rule, err)
+			}
+		}
+		users[user.name] = user
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ACLLoad replaces users by users of ACL file. Default user is kept unless
+// the file defines it. Clients authenticated as removed users are disconnected
+func (app *App) ACLLoad(context *ClientContext) error {
+	file := app.options().ACLFile
+	if file == "" {
+		return errACLNoFile
+	}
+
+	users, err := app.readACLFile(file)
+	if err != nil {
+		return fmt.Errorf("ERR Error loading ACL file: %s", err)
+	}
+
+	app.acl.mu.Lock()
+	if _, ok := users[DefaultUser]; !ok {
+		users[DefaultUser] = app.acl.users[DefaultUser]
+	}
+	app.acl.users = users
+	app.acl.mu.Unlock()
+
+	if context != nil {
+		app.killUsers(context, func(user string) bool { return users[user] == nil })
+	}
+	return nil
+}
+
+// ACLSave writes users to ACL file
+func (app *App) ACLSave() error {
+	file := app.options().ACLFile
+	if file == "" {
+		return errACLNoFile
+	}
+
+	var buf bytes.Buffer
+	for _, user := range app.sortedUsers() {
+		buf.WriteString(user.describe())
+		buf.WriteByte('\n')
+	}
+
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("ERR Error saving ACL file: %s", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("ERR Error saving ACL file: %s", err)
+	}
+	return nil
+}
+
+// globMatch matches string against glob-style pattern supporting *, ?, [...]
+// character classes with ranges and negation by ^, and \ escapes
+func globMatch(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			end := classEnd(pattern)
+			if end < 0 {
+				return pattern == s
+			}
+			class := pattern[1 : end+1]
+			negate := len(class) > 0 && class[0] == '^'
+			if negate {
+				class = class[1:]
+			}
+			match := false
+			for i := 0; i < len(class); i++ {
+				c := class[i]
+				if c == '\\' && i+1 < len(class) {
+					i++
+					c = class[i]
+				}
+				if i+2 < len(class) && class[i+1] == '-' {
+					if s[0] >= c && s[0] <= class[i+2] {
+						match = true
+					}
+					i += 2
+				} else if s[0] == c {
+					match = true
+				}
+			}
+			if match == negate {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[end+2:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// classEnd returns position of ] closing character class of pattern starting
+// with [ counted from the second byte of pattern, escaped ] does not close it
+func classEnd(pattern string) int {
+	for i := 1; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case ']':
+			return i - 1
+		}
+	}
+	return -1
+}
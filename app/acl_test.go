@@ -0,0 +1,306 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valery-barysok/gredisd/app/cmd"
+	"github.com/valery-barysok/resp"
+
+	. "github.com/onsi/gomega"
+)
+
+func nopHandler(context *ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return nil
+}
+
+// newACLTestApp returns app serving few described commands and undescribed one
+func newACLTestApp() *App {
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+	specs := map[string]CommandSpec{
+		"get":        {Categories: []string{"read", "string", "fast"}, First: 1, Last: 1, Step: 1},
+		"set":        {Categories: []string{"write", "string", "slow"}, First: 1, Last: 1, Step: 1},
+		"del":        {Categories: []string{"keyspace", "write", "slow"}, First: 1, Last: -1, Step: 1},
+		"type":       {Categories: []string{"keyspace", "fast"}, First: 1, Last: 1, Step: 1},
+		"lmove":      {Categories: []string{"write", "list", "slow"}, First: 1, Last: 2, Step: 1},
+		"zunion":     {Categories: []string{"read", "sortedset", "slow"}, NumKeys: 1},
+		"config":     {Categories: []string{"admin", "slow", "dangerous"}},
+		"config|get": {Categories: []string{"admin", "slow"}},
+	}
+	for name, spec := range specs {
+		app.DescribeCommand(name, spec)
+	}
+	for _, name := range []string{"get", "set", "del", "type", "lmove", "zunion", "config", "undescribed"} {
+		app.Bind(name, nopHandler)
+	}
+	return app
+}
+
+func TestACLSetRule(t *testing.T) {
+	RegisterTestingT(t)
+
+	app := newACLTestApp()
+	hash := hashPassword("secret")
+
+	tests := []struct {
+		rules []string
+		want  string
+		err   error
+	}{
+		{nil, "user u off resetchannels -@all", nil},
+		{[]string{"on", "nopass", "~*", "&*", "+@all"}, "user u on nopass ~* &* +@all", nil},
+		{[]string{"ON", "OFF"}, "user u off resetchannels -@all", nil},
+		{[]string{">secret"}, "user u off #" + hash + " resetchannels -@all", nil},
+		{[]string{">secret", ">secret"}, "user u off #" + hash + " resetchannels -@all", nil},
+		{[]string{">secret", "<secret"}, "user u off resetchannels -@all", nil},
+		{[]string{"#" + hash}, "user u off #" + hash + " resetchannels -@all", nil},
+		{[]string{"#" + hash, "!" + hash}, "user u off resetchannels -@all", nil},
+		{[]string{">secret", "nopass"}, "user u off nopass resetchannels -@all", nil},
+		{[]string{"nopass", ">secret"}, "user u off #" + hash + " resetchannels -@all", nil},
+		{[]string{">secret", "resetpass"}, "user u off resetchannels -@all", nil},
+		{[]string{"<missing"}, "", errACLNoSuchPassword},
+		{[]string{"#abc"}, "", errACLPasswordHash},
+		{[]string{"!abc"}, "", errACLPasswordHash},
+		{[]string{"~cache:*", "%R~config:*", "%W~log:*", "%RW~all:*"}, "user u off ~cache:* %R~config:* %W~log:* ~all:* resetchannels -@all", nil},
+		{[]string{"%wr~all:*", "%r~all:*"}, "user u off ~all:* %R~all:* resetchannels -@all", nil},
+		{[]string{"~a", "~a"}, "user u off ~a resetchannels -@all", nil},
+		{[]string{"~a", "allkeys"}, "user u off ~* resetchannels -@all", nil},
+		{[]string{"~a", "resetkeys"}, "user u off resetchannels -@all", nil},
+		{[]string{"%X~a"}, "", errACLSyntax},
+		{[]string{"%R~"}, "", errACLSyntax},
+		{[]string{"%~a"}, "", errACLSyntax},
+		{[]string{"~*", "~a"}, "", errACLPatternAfterAll},
+		{[]string{"allkeys", "%R~a"}, "", errACLPatternAfterAll},
+		{[]string{"&news.*", "&news.*"}, "user u off &news.* -@all", nil},
+		{[]string{"allchannels", "&news.*"}, "", errACLPatternAfterAll},
+		{[]string{"+@read", "-get", "+config|get"}, "user u off resetchannels +@read -get +config|get", nil},
+		{[]string{"+get", "-get"}, "user u off resetchannels -get", nil},
+		{[]string{"+GET", "+@all"}, "user u off resetchannels +@all", nil},
+		{[]string{"allcommands", "-set"}, "user u off resetchannels +@all -set", nil},
+		{[]string{"+@all", "nocommands"}, "user u off resetchannels -@all", nil},
+		{[]string{"+@nosuch"}, "", errACLUnknownCommand},
+		{[]string{"+nosuch"}, "", errACLUnknownCommand},
+		{[]string{"+config|"}, "", errACLSyntax},
+		{[]string{"on", ">secret", "~a", "&*", "+@all", "reset"}, "user u off resetchannels -@all", nil},
+		{[]string{"bogus"}, "", errACLSyntax},
+		{[]string{""}, "", errACLSyntax},
+	}
+
+	for _, test := range tests {
+		user := newACLUser("u")
+		var err error
+		for _, rule := range test.rules {
+			if err = user.setRule(rule, app.commandExists); err != nil {
+				break
+			}
+		}
+		if test.err != nil {
+			Expect(err).To(Equal(test.err), "%v", test.rules)
+			continue
+		}
+		Expect(err).ToNot(HaveOccurred(), "%v", test.rules)
+		Expect(user.describe()).To(Equal(test.want), "%v", test.rules)
+	}
+}
+
+func TestACLCanRun(t *testing.T) {
+	RegisterTestingT(t)
+
+	app := newACLTestApp()
+
+	tests := []struct {
+		rules   []string
+		name    string
+		sub     string
+		allowed bool
+	}{
+		{nil, "get", "", false},
+		{[]string{"+@all"}, "get", "", true},
+		{[]string{"+@all"}, "undescribed", "", true},
+		{[]string{"+@read"}, "get", "", true},
+		{[]string{"+@read"}, "set", "", false},
+		{[]string{"+@read"}, "undescribed", "", false},
+		{[]string{"+@all", "-@write"}, "set", "", false},
+		{[]string{"+@all", "-@write"}, "get", "", true},
+		{[]string{"-@all", "+set"}, "set", "", true},
+		{[]string{"+set", "-@all"}, "set", "", false},
+		{[]string{"+@write", "-set"}, "del", "", true},
+		{[]string{"+@write", "-set"}, "set", "", false},
+		{[]string{"+@all", "-config", "+config|get"}, "config", "get", true},
+		{[]string{"+@all", "-config", "+config|get"}, "config", "set", false},
+		{[]string{"+config|get"}, "config", "get", true},
+		{[]string{"+config|get"}, "config", "set", false},
+		{[]string{"+config"}, "config", "set", true},
+		{[]string{"+@admin", "-@dangerous"}, "config", "get", true},
+		{[]string{"+@admin", "-@dangerous"}, "config", "set", false},
+	}
+
+	for _, test := range tests {
+		user := newACLUser("u")
+		for _, rule := range test.rules {
+			Expect(user.setRule(rule, app.commandExists)).To(Succeed())
+		}
+		spec := app.acl.spec(test.name, test.sub)
+		Expect(user.canRun(test.name, test.sub, spec)).To(Equal(test.allowed), "%v %s %s", test.rules, test.name, test.sub)
+	}
+}
+
+func TestACLCanAccess(t *testing.T) {
+	RegisterTestingT(t)
+
+	app := newACLTestApp()
+	user := newACLUser("u")
+	for _, rule := range []string{"~cache:*", "%R~config:*", "%W~log:*", "%R~both:*", "%W~both:*"} {
+		Expect(user.setRule(rule, app.commandExists)).To(Succeed())
+	}
+
+	tests := []struct {
+		key     string
+		cmd     string
+		allowed bool
+	}{
+		{"cache:1", "get", true},
+		{"cache:1", "set", true},
+		{"cache:1", "type", true},
+		{"config:1", "get", true},
+		{"config:1", "set", false},
+		{"config:1", "type", false},
+		{"log:1", "get", false},
+		{"log:1", "set", true},
+		{"both:1", "type", true},
+		{"other", "get", false},
+	}
+
+	for _, test := range tests {
+		spec := app.acl.spec(test.cmd, "")
+		Expect(user.canAccess(test.key, spec)).To(Equal(test.allowed), "%s %s", test.cmd, test.key)
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"cache:*", "cache:1", true},
+		{"cache:*", "cache", false},
+		{"*:1", "cache:1", true},
+		{"a**b", "axxb", true},
+		{"a*b*c", "abxbc", true},
+		{"a*b*c", "abxb", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{`h[\]]llo`, "h]llo", true},
+		{"key", "key", true},
+		{"key", "keys", false},
+		{"", "", true},
+	}
+
+	for _, test := range tests {
+		Expect(globMatch(test.pattern, test.s)).To(Equal(test.match), "%q %q", test.pattern, test.s)
+	}
+}
+
+func TestCheckACL(t *testing.T) {
+	RegisterTestingT(t)
+
+	app := newACLTestApp()
+	Expect(app.ACLSetUser("u", "on", ">secret", "~cache:*", "+@all", "-config", "+config|get")).To(Succeed())
+	context := &ClientContext{App: app, user: "u"}
+
+	Expect(context.CheckACL(command("get", "cache:1"))).To(Succeed())
+	Expect(context.CheckACL(command("lmove", "cache:1", "cache:2", "LEFT", "LEFT"))).To(Succeed())
+	Expect(context.CheckACL(command("zunion", "2", "cache:1", "cache:2"))).To(Succeed())
+	Expect(context.CheckACL(command("config", "get", "maxclients"))).To(Succeed())
+	Expect(context.CheckACL(command("nosuch"))).To(Succeed())
+
+	Expect(context.CheckACL(command("get", "other"))).To(Equal(errNoPermKey))
+	Expect(context.CheckACL(command("lmove", "cache:1", "other", "LEFT", "LEFT"))).To(Equal(errNoPermKey))
+	Expect(context.CheckACL(command("zunion", "2", "cache:1", "other"))).To(Equal(errNoPermKey))
+	Expect(context.CheckACL(command("config", "set", "maxclients", "1"))).To(MatchError("NOPERM User u has no permissions to run the 'config' command"))
+	Expect(context.CheckACL(command("config", "resetstat"))).To(MatchError("NOPERM User u has no permissions to run the 'config' command"))
+
+	// keys of undescribed command are unknown, so it is denied unless all keys are allowed
+	Expect(context.CheckACL(command("undescribed", "other"))).To(MatchError("NOPERM User u has no permissions to run the 'undescribed' command"))
+	context.user = DefaultUser
+	Expect(context.CheckACL(command("undescribed", "other"))).To(Succeed())
+
+	context.user = "deleted"
+	Expect(context.CheckACL(command("get", "cache:1"))).To(MatchError("NOPERM User deleted has no permissions to run the 'get' command"))
+
+	log := app.ACLLog(-1)
+	Expect(log).To(HaveLen(3))
+	Expect(log[0].(Map)[:4]).To(Equal(Map{[]byte("count"), 1, []byte("reason"), []byte("command")}))
+
+	Expect(app.Authenticate("u", "secret")).To(BeTrue())
+	Expect(app.Authenticate("u", "wrong")).To(BeFalse())
+	Expect(app.RequireAuth()).To(BeFalse())
+	app.acl.setDefaultPassword("secret")
+	Expect(app.RequireAuth()).To(BeTrue())
+}
+
+func TestACLFile(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "gredis-acl")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	app := newACLTestApp()
+	Expect(app.ACLSave()).To(Equal(errACLNoFile))
+	Expect(app.ACLLoad(nil)).To(Equal(errACLNoFile))
+
+	file := filepath.Join(dir, "users.acl")
+	app.opts.ACLFile = file
+	Expect(app.ACLSetUser("cache", "on", ">secret", "~cache:*", "%R~config:*", "&news.*", "+@read", "-get", "+config|get")).To(Succeed())
+	Expect(app.ACLSetUser("disabled", "+@all")).To(Succeed())
+	saved := app.ACLList()
+
+	Expect(app.ACLSave()).To(Succeed())
+	info, err := os.Stat(file)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+	Expect(app.ACLDelUser(nil, "cache")).To(Equal(1))
+	Expect(app.ACLSetUser("extra", "on")).To(Succeed())
+	Expect(app.ACLLoad(nil)).To(Succeed())
+	Expect(app.ACLList()).To(Equal(saved))
+	Expect(app.Authenticate("cache", "secret")).To(BeTrue())
+
+	// default user is kept unless file defines it
+	Expect(ioutil.WriteFile(file, []byte("# users\n\nuser cache on nopass ~* +@all\n"), 0600)).To(Succeed())
+	Expect(app.ACLLoad(nil)).To(Succeed())
+	Expect(app.ACLUsers()).To(Equal([]interface{}{[]byte("cache"), []byte("default")}))
+
+	invalid := map[string]string{
+		"cache on\n":                      "line should start with user keyword followed by username",
+		"user cache on\nuser cache off\n": "duplicate user 'cache'",
+		"user cache on +nosuch\n":         "error in rule '+nosuch': Unknown command or category name in ACL",
+		"user cache on \"unbalanced\n":    "unbalanced quotes",
+		"user cache on ~* ~cache:*\n":     "error in rule '~cache:*': Adding a pattern",
+	}
+	for content, reason := range invalid {
+		Expect(ioutil.WriteFile(file, []byte(content), 0600)).To(Succeed())
+		err := app.ACLLoad(nil)
+		Expect(err).To(HaveOccurred(), content)
+		Expect(err.Error()).To(ContainSubstring(reason), content)
+	}
+	// failed load keeps users
+	Expect(app.ACLUsers()).To(Equal([]interface{}{[]byte("cache"), []byte("default")}))
+}
@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"net"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/valery-barysok/gredisd/app/cmd"
 	"github.com/valery-barysok/gredisd/app/model"
@@ -34,14 +38,62 @@ type Options struct {
 	Auth          string `json:"-"`
 	Databases     int    `json:"databases"`
 	TraceProtocol bool   `json:"trace_protocol"`
+	MetricsAddr   string `json:"metrics_addr"`
+
+	// Timeout is number of seconds client may be idle before it is disconnected, 0 means never
+	Timeout      int `json:"timeout"`
+	MaxClients   int `json:"maxclients"`
+	TCPKeepAlive int `json:"tcp_keepalive"`
+
+	// ConfigFile is path of config file the options were loaded from, CONFIG REWRITE writes to it
+	ConfigFile string `json:"-"`
+
+	ClientOutputBufferLimit map[string]OutputBufferLimit `json:"client_output_buffer_limit"`
+	ClientQueryBufferLimit  int64                        `json:"client_query_buffer_limit"`
+	ProtoMaxBulkLen         int64                        `json:"proto_max_bulk_len"`
+
+	SlowLogSlowerThan int64 `json:"slowlog_log_slower_than"`
+	SlowLogMaxLen     int   `json:"slowlog_max_len"`
+
+	// LatencyMonitorThreshold is minimum latency in milliseconds of recorded events, 0 disables latency monitor
+	LatencyMonitorThreshold int64 `json:"latency_monitor_threshold"`
+
+	HashMaxListpackEntries int `json:"hash_max_listpack_entries"`
+	HashMaxListpackValue   int `json:"hash_max_listpack_value"`
+	ListMaxListpackSize    int `json:"list_max_listpack_size"`
+	SetMaxIntsetEntries    int `json:"set_max_intset_entries"`
+
+	MaxMemory        int64  `json:"maxmemory"`
+	MaxMemoryPolicy  string `json:"maxmemory_policy"`
+	MaxMemorySamples int    `json:"maxmemory_samples"`
+
+	// ACLFile is path of file with users loaded at startup and by ACL LOAD
+	ACLFile      string `json:"aclfile"`
+	ACLLogMaxLen int    `json:"acllog_max_len"`
 }
 
 type App struct {
+	// blockedClients is number of clients blocked by blocking commands, it is updated atomically
+	blockedClients int64
+	net            netStats
+	pause          pauseState
+	limits         clientLimits
+	acl            *acl
+
 	info   Info
 	opts   *Options
 	server *server.Server
 	router *router
 	model  *model.AppModel
+	quit   chan struct{}
+
+	// configMu protects opts changed by CONFIG SET
+	configMu sync.RWMutex
+	// rewriteMu serializes CONFIG REWRITE
+	rewriteMu sync.Mutex
+
+	// metrics is listener of metrics endpoint if it is enabled
+	metrics net.Listener
 }
 
 func NewApp(opts *Options) *App {
@@ -58,7 +110,16 @@ func NewApp(opts *Options) *App {
 		info:   info,
 		opts:   opts,
 		router: newRouter(),
-		model:  model.NewAppModel(opts.Databases),
+		model:  model.NewAppModel(opts.Databases, opts.encodings()),
+		quit:   make(chan struct{}),
+		acl:    newACL(),
+	}
+
+	// runtime parameters are applied the same way as by CONFIG SET
+	for _, param := range configParams {
+		if param.apply != nil {
+			param.apply(app)
+		}
 	}
 
 	return app
@@ -82,8 +143,16 @@ func (app *App) Run() error {
 	log.Printf("GRedis version %s\n", app.info.Version)
 
 	opts := server.Options{
-		Host: app.opts.Host,
-		Port: app.opts.Port,
+		Host:       app.opts.Host,
+		Port:       app.opts.Port,
+		MaxClients: app.opts.MaxClients,
+		KeepAlive:  time.Duration(app.opts.TCPKeepAlive) * time.Second,
+	}
+
+	if app.opts.ACLFile != "" {
+		if err := app.ACLLoad(nil); err != nil {
+			return errors.New(configReason(err))
+		}
 	}
 
 	if app.RequireAuth() {
@@ -91,6 +160,16 @@ func (app *App) Run() error {
 	}
 
 	app.server = server.NewServer(&opts, NewClientProvider(app))
+
+	if app.opts.MetricsAddr != "" {
+		if err := app.serveMetrics(app.opts.MetricsAddr); err != nil {
+			return err
+		}
+	}
+
+	go app.expireLoop()
+	go app.timeoutLoop()
+
 	app.server.Start()
 
 	return nil
@@ -98,11 +177,34 @@ func (app *App) Run() error {
 
 func (app *App) Shutdown() {
 	go func() {
+		close(app.quit)
+		if app.metrics != nil {
+			app.metrics.Close()
+		}
 		app.server.Shutdown()
 		os.Exit(0)
 	}()
 }
 
+func (app *App) expireLoop() {
+	ticker := time.NewTicker(DefaultExpireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// keys must not change while writes are paused
+			if !app.pause.active(true) {
+				start := time.Now()
+				app.model.ActiveExpire()
+				app.router.latency.record(latencyExpireCycle, time.Since(start))
+			}
+		case <-app.quit:
+			return
+		}
+	}
+}
+
 func (app *App) Commands() []interface{} {
 	return app.model.Commands()
 }
@@ -124,14 +226,6 @@ func (app *App) BindError(errorHandler ErrorHandler) ErrorHandler {
 	return app.router.bindError(errorHandler)
 }
 
-func (app *App) RequireAuth() bool {
-	return len(app.opts.Auth) > 0
-}
-
-func (app *App) Auth(pass string) bool {
-	return !app.RequireAuth() || app.opts.Auth == pass
-}
-
 func (app *App) Select(index string) (*model.DBModel, error) {
 	return app.model.Select(index)
 }
@@ -140,6 +234,66 @@ func (app *App) SelectIndex(index int) (*model.DBModel, error) {
 	return app.model.SelectIndex(index)
 }
 
+func (app *App) FlushAll(args ...[]byte) error {
+	return app.model.FlushAll(args...)
+}
+
+func (app *App) SwapDB(index1 string, index2 string) error {
+	return app.model.SwapDB(index1, index2)
+}
+
+// FreeMemory evicts keys if used memory exceeds maxmemory. It returns error
+// if memory can not be freed and command which may take memory must be rejected
+func (app *App) FreeMemory() error {
+	start := time.Now()
+	err := app.model.FreeMemory()
+	app.router.latency.record(latencyEvictionCycle, time.Since(start))
+	return err
+}
+
+func (app *App) MemoryStats() Map {
+	stats := app.model.MemoryStats()
+	// statistics of every database are nested map
+	for i := 1; i < len(stats); i += 2 {
+		if db, ok := stats[i].([]interface{}); ok {
+			stats[i] = Map(db)
+		}
+	}
+	return Map(stats)
+}
+
+// ParseMemory parses memory amount in bytes with optional k, kb, m, mb, g or gb unit
+func ParseMemory(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		mul    int64
+	}{
+		{"kb", 1024},
+		{"mb", 1024 * 1024},
+		{"gb", 1024 * 1024 * 1024},
+		{"k", 1000},
+		{"m", 1000 * 1000},
+		{"g", 1000 * 1000 * 1000},
+		{"b", 1},
+	}
+
+	num := strings.ToLower(s)
+	mul := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(num, unit.suffix) {
+			num = strings.TrimSuffix(num, unit.suffix)
+			mul = unit.mul
+			break
+		}
+	}
+
+	v, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid memory amount %q", s)
+	}
+	return v * mul, nil
+}
+
 func normalizeOptions(opts *Options) {
 	if opts.Host == "" {
 		opts.Host = DefaultHost
@@ -150,4 +304,72 @@ func normalizeOptions(opts *Options) {
 	if opts.Databases <= 0 {
 		opts.Databases = DefaultDatabases
 	}
+	if opts.Timeout < 0 {
+		opts.Timeout = 0
+	}
+	if opts.MaxClients <= 0 {
+		opts.MaxClients = DefaultMaxClients
+	}
+	if opts.TCPKeepAlive < 0 {
+		opts.TCPKeepAlive = 0
+	}
+	if opts.SlowLogMaxLen < 0 {
+		opts.SlowLogMaxLen = 0
+	}
+	if opts.ACLLogMaxLen < 0 {
+		opts.ACLLogMaxLen = 0
+	}
+	if opts.LatencyMonitorThreshold < 0 {
+		opts.LatencyMonitorThreshold = 0
+	}
+	if opts.ClientQueryBufferLimit <= 0 {
+		opts.ClientQueryBufferLimit = DefaultClientQueryBufferLimit
+	}
+	if opts.ProtoMaxBulkLen <= 0 {
+		opts.ProtoMaxBulkLen = DefaultProtoMaxBulkLen
+	}
+	if opts.ClientOutputBufferLimit == nil {
+		opts.ClientOutputBufferLimit = make(map[string]OutputBufferLimit)
+	}
+	for class, limit := range defaultOutputBufferLimits {
+		if _, ok := opts.ClientOutputBufferLimit[class]; !ok {
+			opts.ClientOutputBufferLimit[class] = limit
+		}
+	}
+
+	defaults := model.DefaultEncodings()
+	if opts.HashMaxListpackEntries <= 0 {
+		opts.HashMaxListpackEntries = defaults.HashMaxListpackEntries
+	}
+	if opts.HashMaxListpackValue <= 0 {
+		opts.HashMaxListpackValue = defaults.HashMaxListpackValue
+	}
+	if opts.ListMaxListpackSize == 0 || opts.ListMaxListpackSize < -5 {
+		opts.ListMaxListpackSize = defaults.ListMaxListpackSize
+	}
+	if opts.SetMaxIntsetEntries <= 0 {
+		opts.SetMaxIntsetEntries = defaults.SetMaxIntsetEntries
+	}
+
+	if opts.MaxMemory < 0 {
+		opts.MaxMemory = 0
+	}
+	if _, err := model.ParseMaxMemoryPolicy(opts.MaxMemoryPolicy); err != nil {
+		if opts.MaxMemoryPolicy != "" {
+			log.Printf("Unknown maxmemory policy %q, %s is used\n", opts.MaxMemoryPolicy, DefaultMaxMemoryPolicy)
+		}
+		opts.MaxMemoryPolicy = DefaultMaxMemoryPolicy
+	}
+	if opts.MaxMemorySamples <= 0 {
+		opts.MaxMemorySamples = model.DefaultMaxMemorySamples
+	}
+}
+
+func (opts *Options) encodings() model.Encodings {
+	return model.Encodings{
+		HashMaxListpackEntries: opts.HashMaxListpackEntries,
+		HashMaxListpackValue:   opts.HashMaxListpackValue,
+		ListMaxListpackSize:    opts.ListMaxListpackSize,
+		SetMaxIntsetEntries:    opts.SetMaxIntsetEntries,
+	}
 }
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// Units of sizes reported for each type by MEMORY BIGKEYS
+var bigKeysUnits = map[string]string{
+	"string": "bytes",
+	"list":   "items",
+	"hash":   "fields",
+	"set":    "members",
+	"zset":   "members",
+}
+
+// KeysReport prints the biggest keys of each type of databases from 0 up to
+// databases-1 by number of elements or by memory if mem is set
+func KeysReport(out io.Writer, host string, port int, auth string, databases int, mem bool) error {
+	if host == "" || host == "0.0.0.0" {
+		host = "127.0.0.1"
+	}
+
+	c, err := Dial(net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if auth != "" {
+		if _, err := c.Do("AUTH", auth); err != nil {
+			return err
+		}
+	}
+
+	subcommand := "BIGKEYS"
+	if mem {
+		subcommand = "MEMKEYS"
+	}
+
+	for db := 0; db < databases; db++ {
+		if _, err := c.Do("SELECT", strconv.Itoa(db)); err != nil {
+			// server has less databases
+			break
+		}
+
+		size, err := c.Do("DBSIZE")
+		if err != nil {
+			return err
+		}
+		if size == int64(0) {
+			continue
+		}
+
+		reply, err := c.Do("MEMORY", subcommand)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "\n# Database %d, %d keys\n\n", db, size)
+		if err := printKeysReport(out, reply, mem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printKeysReport(out io.Writer, reply interface{}, mem bool) error {
+	types, ok := reply.([]interface{})
+	if !ok {
+		return errProtocol
+	}
+
+	type typeReport struct {
+		name    string
+		keys    int64
+		total   int64
+		biggest []byte
+		size    int64
+	}
+
+	reports := make([]typeReport, 0, len(types))
+	for _, t := range types {
+		fields, ok := t.([]interface{})
+		if !ok || len(fields) != 5 {
+			return errProtocol
+		}
+
+		name, _ := fields[0].([]byte)
+		keys, _ := fields[1].(int64)
+		total, _ := fields[2].(int64)
+		biggest, _ := fields[3].([]byte)
+		size, _ := fields[4].(int64)
+		reports = append(reports, typeReport{string(name), keys, total, biggest, size})
+	}
+
+	for _, r := range reports {
+		if r.keys > 0 {
+			fmt.Fprintf(out, "Biggest %6s found %q has %d %s\n", r.name, r.biggest, r.size, unit(r.name, mem))
+		}
+	}
+
+	fmt.Fprintln(out)
+	for _, r := range reports {
+		avg := float64(0)
+		if r.keys > 0 {
+			avg = float64(r.total) / float64(r.keys)
+		}
+		fmt.Fprintf(out, "%d %ss with %d %s (avg size %.2f)\n", r.keys, r.name, r.total, unit(r.name, mem), avg)
+	}
+	return nil
+}
+
+func unit(typeName string, mem bool) string {
+	if mem {
+		return "bytes"
+	}
+	return bigKeysUnits[typeName]
+}
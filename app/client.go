@@ -5,6 +5,7 @@ import (
 	"net"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/valery-barysok/gredisd/app/model"
@@ -27,7 +28,7 @@ func NewClientProvider(app *App) server.ClientProvider {
 
 	return &clientProvider{
 		app:    app,
-		looper: newLooper(protocol, app.router),
+		looper: newLooper(protocol, app.router, &app.net, &app.limits),
 	}
 }
 
@@ -39,6 +40,27 @@ type ClientContext struct {
 	App         *App
 	DB          *model.DBModel
 	RequireAuth bool
+
+	client *client
+
+	// user is name of ACL user client is authenticated as
+	user string
+
+	// protocol is version of protocol selected by HELLO
+	protocol int
+
+	// blocked is set once command blocks client
+	blocked bool
+
+	// closeAfterReply is set when client must be disconnected once reply is sent
+	closeAfterReply bool
+
+	// in and out count traffic of client
+	in  *countReader
+	out *countWriter
+
+	// query checks framing of commands read by client
+	query *queryReader
 }
 
 type client struct {
@@ -52,6 +74,23 @@ type client struct {
 	last      time.Time
 	looper    *looper
 	context   *ClientContext
+
+	// fields below are reported by CLIENT LIST and CLIENT INFO
+	addr     string
+	laddr    string
+	name     string
+	db       int
+	lastCmd  string
+	username string
+	resp     int
+	qbuf     int
+	obl      int
+	blocked  bool
+	monitor  bool
+	noEvict  bool
+
+	// closed is closed once connection is closed
+	closed chan struct{}
 }
 
 func newClientContext(app *App) *ClientContext {
@@ -60,6 +99,8 @@ func newClientContext(app *App) *ClientContext {
 		App:         app,
 		DB:          db,
 		RequireAuth: app.RequireAuth(),
+		user:        DefaultUser,
+		protocol:    RESP2,
 	}
 	return &context
 }
@@ -73,8 +114,16 @@ func newClient(server *server.Server, conn net.Conn, cp *clientProvider) *client
 		bufWriter: bufio.NewWriter(conn),
 		startTime: time.Now(),
 		looper:    cp.looper,
+		addr:      conn.RemoteAddr().String(),
+		laddr:     conn.LocalAddr().String(),
 		context:   newClientContext(cp.app),
+		username:  DefaultUser,
+		resp:      RESP2,
+		closed:    make(chan struct{}),
 	}
+	client.context.client = client
+	client.last = client.startTime
+	client.looper.wrap(client.context, client.bufReader, client.bufWriter, conn.SetWriteDeadline)
 
 	return client
 }
@@ -86,15 +135,13 @@ func (client *client) ID() uint64 {
 func (client *client) Loop() {
 	client.mu.Lock()
 	conn := client.conn
-	br := client.bufReader
-	bw := client.bufWriter
 	client.mu.Unlock()
 
 	if conn == nil {
 		return
 	}
 
-	client.looper.loop(client.context, br, bw)
+	client.looper.loop(client.context)
 }
 
 func (client *client) CloseConnection() {
@@ -107,7 +154,79 @@ func (client *client) CloseConnection() {
 	client.clearConnection()
 
 	client.conn = nil
+	close(client.closed)
+	client.mu.Unlock()
+}
+
+// Block prepares client for blocking command. Returned channel is closed when
+// client disconnects or its connection is closed while command is blocked.
+// Returned function must be called once command is not blocked anymore
+func (context *ClientContext) Block() (<-chan struct{}, func()) {
+	if context.client == nil {
+		return nil, func() {}
+	}
+
+	context.blocked = true
+	atomic.AddInt64(&context.App.blockedClients, 1)
+	cancel, stop := context.client.block()
+	return cancel, func() {
+		stop()
+		atomic.AddInt64(&context.App.blockedClients, -1)
+	}
+}
+
+func (client *client) block() (<-chan struct{}, func()) {
+	client.setBlocked(true)
+	cancel, stop := client.watch()
+	return cancel, func() {
+		stop()
+		client.setBlocked(false)
+	}
+}
+
+// watch watches connection which is not read while client is busy. Returned
+// channel is closed once connection is closed. Returned function stops watching
+func (client *client) watch() (<-chan struct{}, func()) {
+	client.mu.Lock()
+	conn := client.conn
+	br := client.bufReader
 	client.mu.Unlock()
+
+	cancel := make(chan struct{})
+	if conn == nil {
+		close(cancel)
+		return cancel, func() {}
+	}
+
+	// connection is not read while command is blocked so peek it to find
+	// out whether client has gone
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		_, err := br.Peek(1)
+		if err == nil {
+			// client has sent next command already
+			select {
+			case <-client.closed:
+				close(cancel)
+			case <-done:
+			}
+			return
+		}
+
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			close(cancel)
+		}
+	}()
+
+	return cancel, func() {
+		close(done)
+		conn.SetReadDeadline(time.Now())
+		<-stopped
+		conn.SetReadDeadline(time.Time{})
+	}
 }
 
 func (client *client) clearConnection() {
@@ -0,0 +1,492 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valery-barysok/gredisd/app/model"
+)
+
+// DefaultUser is user clients are authenticated as once connected
+const DefaultUser = "default"
+
+var (
+	errClientName      = errors.New("ERR Client names cannot contain spaces, newlines or special characters.")
+	errNoSuchClient    = errors.New("ERR No such client")
+	errClientSyntax    = errors.New("ERR syntax error")
+	errClientID        = errors.New("ERR client-id should be greater than 0")
+	errInvalidClientID = errors.New("ERR Invalid client ID")
+	errPauseTimeout    = errors.New("ERR timeout is not an integer or out of range")
+	errSkipMe          = errors.New("ERR syntax error: SKIPME should be yes or no")
+)
+
+// normalClientType is type of every client, there are no replicas and pubsub clients
+const normalClientType = "normal"
+
+var clientTypes = map[string]bool{
+	normalClientType: true,
+	"master":         true,
+	"replica":        true,
+	"slave":          true,
+	"pubsub":         true,
+}
+
+func (client *client) touch(cmd string) {
+	client.mu.Lock()
+	client.last = time.Now()
+	client.lastCmd = cmd
+	client.qbuf = client.bufReader.Buffered()
+	client.obl = client.bufWriter.Buffered()
+	client.mu.Unlock()
+}
+
+// identity returns address and name of client
+func (client *client) identity() (string, string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.addr, client.name
+}
+
+func (client *client) setBlocked(blocked bool) {
+	client.mu.Lock()
+	client.blocked = blocked
+	client.mu.Unlock()
+}
+
+func (client *client) setUser(user string) {
+	client.mu.Lock()
+	client.username = user
+	client.mu.Unlock()
+}
+
+func (client *client) user() string {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	return client.username
+}
+
+func (client *client) setMonitor(monitor bool) {
+	client.mu.Lock()
+	client.monitor = monitor
+	client.mu.Unlock()
+}
+
+// kill closes connection of client, client is removed by its own goroutine
+// once it fails to read next command
+func (client *client) kill() {
+	client.mu.Lock()
+	if client.conn != nil {
+		client.conn.Close()
+	}
+	client.mu.Unlock()
+}
+
+// idle returns time since client sent the last command, blocked clients and
+// monitors are never idle
+func (client *client) idle(now time.Time) time.Duration {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.blocked || client.monitor {
+		return 0
+	}
+	return now.Sub(client.last)
+}
+
+func (client *client) info(now time.Time) string {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	flags := ""
+	if client.blocked {
+		flags += "b"
+	}
+	if client.monitor {
+		flags += "O"
+	}
+	if client.noEvict {
+		flags += "e"
+	}
+	if flags == "" {
+		flags = "N"
+	}
+
+	var in, out int64
+	if client.context.in != nil {
+		in = atomic.LoadInt64(&client.context.in.n)
+	}
+	if client.context.out != nil {
+		out = atomic.LoadInt64(&client.context.out.n)
+	}
+
+	return fmt.Sprintf("id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=%d qbuf=%d obl=%d tot-net-in=%d tot-net-out=%d cmd=%s user=%s resp=%d\n",
+		client.id, client.addr, client.laddr, client.name,
+		int64(now.Sub(client.startTime)/time.Second), int64(now.Sub(client.last)/time.Second),
+		flags, client.db, client.qbuf, client.obl, in, out, client.lastCmd, client.username, client.resp)
+}
+
+// clients returns connected clients ordered by id
+func (app *App) clients() []*client {
+	if app.server == nil {
+		return nil
+	}
+
+	list := make([]*client, 0)
+	for _, c := range app.server.Clients() {
+		if client, ok := c.(*client); ok {
+			list = append(list, client)
+		}
+	}
+	sort.Sort(clientsByID(list))
+	return list
+}
+
+type clientsByID []*client
+
+func (c clientsByID) Len() int           { return len(c) }
+func (c clientsByID) Less(i, j int) bool { return c[i].id < c[j].id }
+func (c clientsByID) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+
+// ClientList reports connected clients, it accepts TYPE type and ID id [id ...] filters
+func (app *App) ClientList(args ...[]byte) (string, error) {
+	var ids map[uint64]bool
+	for i := 0; i < len(args); i++ {
+		switch strings.ToLower(string(args[i])) {
+		case "type":
+			if i+1 >= len(args) {
+				return "", errClientSyntax
+			}
+			i++
+			typ := strings.ToLower(string(args[i]))
+			if !clientTypes[typ] {
+				return "", fmt.Errorf("ERR Unknown client type '%s'", args[i])
+			}
+			if typ != normalClientType {
+				return "", nil
+			}
+		case "id":
+			if i+1 >= len(args) {
+				return "", errClientSyntax
+			}
+			ids = make(map[uint64]bool)
+			for i++; i < len(args); i++ {
+				id, err := strconv.ParseUint(string(args[i]), 10, 64)
+				if err != nil || id == 0 {
+					return "", errInvalidClientID
+				}
+				ids[id] = true
+			}
+		default:
+			return "", errClientSyntax
+		}
+	}
+
+	return formatClients(app.clients(), ids, time.Now()), nil
+}
+
+// formatClients reports clients in format of CLIENT LIST, all of them if ids is nil
+func formatClients(clients []*client, ids map[uint64]bool, now time.Time) string {
+	var buf bytes.Buffer
+	for _, client := range clients {
+		if ids == nil || ids[client.id] {
+			buf.WriteString(client.info(now))
+		}
+	}
+	return buf.String()
+}
+
+// timeoutLoop disconnects clients which are idle longer than timeout, timeout
+// is read on every tick as it may be changed by CONFIG SET
+func (app *App) timeoutLoop() {
+	ticker := time.NewTicker(DefaultClientsCronInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// paused clients are waiting rather than idle
+			timeout := time.Duration(app.options().Timeout) * time.Second
+			if timeout > 0 && !app.pause.active(true) {
+				closeIdleClients(app.clients(), timeout, time.Now())
+			}
+		case <-app.quit:
+			return
+		}
+	}
+}
+
+// closeIdleClients disconnects clients which are idle longer than timeout
+func closeIdleClients(clients []*client, timeout time.Duration, now time.Time) {
+	for _, client := range clients {
+		if client.idle(now) > timeout {
+			log.Printf("Client %d on %s closed after %d seconds of idle time", client.id, client.addr, timeout/time.Second)
+			client.kill()
+		}
+	}
+}
+
+// ID returns id of client
+func (context *ClientContext) ID() uint64 {
+	if context.client == nil {
+		return 0
+	}
+	return context.client.id
+}
+
+// SetDB selects database of client
+func (context *ClientContext) SetDB(db *model.DBModel) {
+	context.DB = db
+	if context.client != nil {
+		context.client.mu.Lock()
+		context.client.db = db.Index()
+		context.client.mu.Unlock()
+	}
+}
+
+// ClientInfo reports client in format of CLIENT LIST
+func (context *ClientContext) ClientInfo() string {
+	if context.client == nil {
+		return ""
+	}
+	return context.client.info(time.Now())
+}
+
+// Name returns name of client set by CLIENT SETNAME
+func (context *ClientContext) Name() string {
+	if context.client == nil {
+		return ""
+	}
+
+	context.client.mu.Lock()
+	defer context.client.mu.Unlock()
+	return context.client.name
+}
+
+// SetName sets name of client, empty name removes it
+func (context *ClientContext) SetName(name string) error {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return errClientName
+		}
+	}
+
+	if context.client != nil {
+		context.client.mu.Lock()
+		context.client.name = name
+		context.client.mu.Unlock()
+	}
+	return nil
+}
+
+// SetNoEvict sets no-evict flag of client
+func (context *ClientContext) SetNoEvict(noEvict bool) {
+	if context.client != nil {
+		context.client.mu.Lock()
+		context.client.noEvict = noEvict
+		context.client.mu.Unlock()
+	}
+}
+
+// KillAddr disconnects client connected from addr
+func (context *ClientContext) KillAddr(addr string) error {
+	for _, client := range context.App.clients() {
+		if client.addr == addr {
+			context.killClient(client)
+			return nil
+		}
+	}
+	return errNoSuchClient
+}
+
+// clientFilter selects clients of CLIENT KILL, zero fields match any client
+type clientFilter struct {
+	id     uint64
+	addr   string
+	laddr  string
+	user   string
+	typ    string
+	skipMe bool
+}
+
+// parseClientFilter parses filters of CLIENT KILL given as name value pairs
+func parseClientFilter(args [][]byte) (*clientFilter, error) {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, errClientSyntax
+	}
+
+	filter := &clientFilter{skipMe: true}
+	for i := 0; i < len(args); i += 2 {
+		value := string(args[i+1])
+		switch strings.ToLower(string(args[i])) {
+		case "id":
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || n == 0 {
+				return nil, errClientID
+			}
+			filter.id = n
+		case "addr":
+			filter.addr = value
+		case "laddr":
+			filter.laddr = value
+		case "user":
+			filter.user = value
+		case "type":
+			filter.typ = strings.ToLower(value)
+			if !clientTypes[filter.typ] {
+				return nil, fmt.Errorf("ERR Unknown client type '%s'", value)
+			}
+		case "skipme":
+			switch strings.ToLower(value) {
+			case "yes":
+				filter.skipMe = true
+			case "no":
+				filter.skipMe = false
+			default:
+				return nil, errSkipMe
+			}
+		default:
+			return nil, errClientSyntax
+		}
+	}
+	return filter, nil
+}
+
+// match reports whether client matches all filters, self is client running CLIENT KILL
+func (filter *clientFilter) match(client *client, self *client) bool {
+	switch {
+	case filter.id != 0 && client.id != filter.id:
+	case filter.addr != "" && client.addr != filter.addr:
+	case filter.laddr != "" && client.laddr != filter.laddr:
+	case filter.user != "" && filter.user != client.user():
+	case filter.typ != "" && filter.typ != normalClientType:
+	case filter.skipMe && client == self:
+	default:
+		return true
+	}
+	return false
+}
+
+// KillClients disconnects clients matching all filters ID, ADDR, LADDR, USER,
+// TYPE and SKIPME and returns number of disconnected clients
+func (context *ClientContext) KillClients(args ...[]byte) (int, error) {
+	filter, err := parseClientFilter(args)
+	if err != nil {
+		return 0, err
+	}
+
+	killed := 0
+	for _, client := range context.App.clients() {
+		if filter.match(client, context.client) {
+			context.killClient(client)
+			killed++
+		}
+	}
+	return killed, nil
+}
+
+// killClient disconnects client, client itself is disconnected once reply is sent
+func (context *ClientContext) killClient(client *client) {
+	if client == context.client {
+		context.closeAfterReply = true
+	} else {
+		client.kill()
+	}
+}
+
+// pauseState keeps state of CLIENT PAUSE
+type pauseState struct {
+	mu      sync.Mutex
+	until   time.Time
+	all     bool
+	resumed chan struct{}
+}
+
+// active reports whether command is paused, write tells whether command
+// changes keys
+func (pause *pauseState) active(write bool) bool {
+	pause.mu.Lock()
+	defer pause.mu.Unlock()
+
+	return pause.resumed != nil && time.Now().Before(pause.until) && (pause.all || write)
+}
+
+// Pause suspends commands of clients for timeout milliseconds, all commands
+// are suspended if all is set and commands changing keys otherwise. Active
+// pause is extended by longer timeout and more restrictive mode
+func (app *App) Pause(timeout string, all bool) error {
+	ms, err := strconv.ParseInt(timeout, 10, 64)
+	if err != nil || ms < 0 {
+		return errPauseTimeout
+	}
+
+	pause := &app.pause
+	pause.mu.Lock()
+	defer pause.mu.Unlock()
+
+	until := time.Now().Add(time.Duration(ms) * time.Millisecond)
+	if pause.resumed == nil || !time.Now().Before(pause.until) {
+		pause.resumed = make(chan struct{})
+		pause.until = until
+		pause.all = all
+		return nil
+	}
+
+	if until.After(pause.until) {
+		pause.until = until
+	}
+	pause.all = pause.all || all
+	return nil
+}
+
+// Unpause resumes commands suspended by Pause
+func (app *App) Unpause() {
+	pause := &app.pause
+	pause.mu.Lock()
+	defer pause.mu.Unlock()
+
+	if pause.resumed != nil {
+		close(pause.resumed)
+		pause.resumed = nil
+	}
+}
+
+// WaitUnpaused waits until command may be executed, write tells whether command changes keys
+func (app *App) WaitUnpaused(write bool) {
+	pause := &app.pause
+	for {
+		pause.mu.Lock()
+		if pause.resumed == nil || !(pause.all || write) {
+			pause.mu.Unlock()
+			return
+		}
+
+		remaining := pause.until.Sub(time.Now())
+		resumed := pause.resumed
+		if remaining <= 0 {
+			close(pause.resumed)
+			pause.resumed = nil
+			pause.mu.Unlock()
+			return
+		}
+		pause.mu.Unlock()
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-timer.C:
+		case <-resumed:
+		case <-app.quit:
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+	}
+}
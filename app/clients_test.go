@@ -0,0 +1,198 @@
+package app
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func newTestClient(id uint64, addr string, user string, now time.Time) *client {
+	return &client{
+		id:        id,
+		addr:      addr,
+		laddr:     "127.0.0.1:6379",
+		startTime: now.Add(-time.Minute),
+		last:      now.Add(-10 * time.Second),
+		context:   &ClientContext{},
+		username:  user,
+		resp:      RESP2,
+	}
+}
+
+func bytesArgs(args ...string) [][]byte {
+	values := make([][]byte, len(args))
+	for i, arg := range args {
+		values[i] = []byte(arg)
+	}
+	return values
+}
+
+func TestClientFilter(t *testing.T) {
+	RegisterTestingT(t)
+
+	now := time.Now()
+	self := newTestClient(1, "127.0.0.1:50001", DefaultUser, now)
+	other := newTestClient(2, "127.0.0.1:50002", "alice", now)
+	clients := []*client{self, other}
+
+	tests := []struct {
+		args    []string
+		matched []uint64
+	}{
+		{[]string{"id", "2"}, []uint64{2}},
+		{[]string{"ID", "1"}, nil},
+		{[]string{"id", "1", "skipme", "no"}, []uint64{1}},
+		{[]string{"id", "3"}, nil},
+		{[]string{"addr", "127.0.0.1:50002"}, []uint64{2}},
+		{[]string{"addr", "127.0.0.1:50001", "SKIPME", "No"}, []uint64{1}},
+		{[]string{"addr", "127.0.0.1:5000"}, nil},
+		{[]string{"laddr", "127.0.0.1:6379"}, []uint64{2}},
+		{[]string{"laddr", "127.0.0.1:6380"}, nil},
+		{[]string{"user", "alice"}, []uint64{2}},
+		{[]string{"user", DefaultUser, "skipme", "yes"}, nil},
+		{[]string{"type", "normal"}, []uint64{2}},
+		{[]string{"type", "NORMAL", "skipme", "no"}, []uint64{1, 2}},
+		{[]string{"type", "pubsub", "skipme", "no"}, nil},
+		{[]string{"type", "slave", "skipme", "no"}, nil},
+		{[]string{"type", "normal", "id", "2", "addr", "127.0.0.1:50001"}, nil},
+		{[]string{"type", "normal", "id", "2", "addr", "127.0.0.1:50002"}, []uint64{2}},
+	}
+	for _, test := range tests {
+		filter, err := parseClientFilter(bytesArgs(test.args...))
+		Expect(err).NotTo(HaveOccurred(), "%v", test.args)
+
+		var matched []uint64
+		for _, client := range clients {
+			if filter.match(client, self) {
+				matched = append(matched, client.id)
+			}
+		}
+		Expect(matched).To(Equal(test.matched), "%v", test.args)
+	}
+
+	invalid := []struct {
+		args []string
+		err  error
+	}{
+		{nil, errClientSyntax},
+		{[]string{"id"}, errClientSyntax},
+		{[]string{"id", "1", "addr"}, errClientSyntax},
+		{[]string{"name", "a"}, errClientSyntax},
+		{[]string{"id", "0"}, errClientID},
+		{[]string{"id", "-1"}, errClientID},
+		{[]string{"id", "a"}, errClientID},
+		{[]string{"skipme", "maybe"}, errSkipMe},
+	}
+	for _, test := range invalid {
+		_, err := parseClientFilter(bytesArgs(test.args...))
+		Expect(err).To(Equal(test.err), "%v", test.args)
+	}
+	_, err := parseClientFilter(bytesArgs("type", "unknown"))
+	Expect(err).To(MatchError("ERR Unknown client type 'unknown'"))
+}
+
+func TestClientList(t *testing.T) {
+	RegisterTestingT(t)
+
+	now := time.Now()
+	first := newTestClient(1, "127.0.0.1:50001", DefaultUser, now)
+	second := newTestClient(2, "127.0.0.1:50002", "alice", now)
+	second.name = "worker"
+	second.db = 3
+	second.lastCmd = "blpop"
+	second.blocked = true
+	second.noEvict = true
+	second.qbuf = 16
+	second.obl = 32
+	second.resp = RESP3
+	second.context.in = &countReader{n: 100}
+	second.context.out = &countWriter{n: 200}
+
+	Expect(first.info(now)).To(Equal("id=1 addr=127.0.0.1:50001 laddr=127.0.0.1:6379 name= age=60 idle=10 flags=N db=0 " +
+		"qbuf=0 obl=0 tot-net-in=0 tot-net-out=0 cmd= user=default resp=2\n"))
+	Expect(second.info(now)).To(Equal("id=2 addr=127.0.0.1:50002 laddr=127.0.0.1:6379 name=worker age=60 idle=10 flags=be db=3 " +
+		"qbuf=16 obl=32 tot-net-in=100 tot-net-out=200 cmd=blpop user=alice resp=3\n"))
+	first.monitor = true
+	Expect(first.info(now)).To(ContainSubstring(" flags=O "))
+
+	clients := []*client{first, second}
+	Expect(formatClients(clients, nil, now)).To(Equal(first.info(now) + second.info(now)))
+	Expect(formatClients(clients, map[uint64]bool{2: true, 3: true}, now)).To(Equal(second.info(now)))
+	Expect(formatClients(clients, map[uint64]bool{3: true}, now)).To(BeEmpty())
+
+	// app without server has no clients but still checks arguments
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+	for _, args := range [][]string{nil, {"type", "normal"}, {"type", "pubsub"}, {"id", "1", "2"}} {
+		list, err := app.ClientList(bytesArgs(args...)...)
+		Expect(err).NotTo(HaveOccurred(), "%v", args)
+		Expect(list).To(BeEmpty())
+	}
+
+	invalid := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"type"}, errClientSyntax.Error()},
+		{[]string{"id"}, errClientSyntax.Error()},
+		{[]string{"name", "a"}, errClientSyntax.Error()},
+		{[]string{"type", "unknown"}, "ERR Unknown client type 'unknown'"},
+		{[]string{"id", "1", "0"}, errInvalidClientID.Error()},
+		{[]string{"id", "a"}, errInvalidClientID.Error()},
+	}
+	for _, test := range invalid {
+		_, err := app.ClientList(bytesArgs(test.args...)...)
+		Expect(err).To(MatchError(test.err), "%v", test.args)
+	}
+}
+
+// closeConn is connection which only records that it is closed
+type closeConn struct {
+	net.Conn
+	closed bool
+}
+
+func (conn *closeConn) Close() error {
+	conn.closed = true
+	return nil
+}
+
+func TestCloseIdleClients(t *testing.T) {
+	RegisterTestingT(t)
+
+	now := time.Now()
+	idle := newTestClient(1, "127.0.0.1:50001", DefaultUser, now)
+	idle.last = now.Add(-11 * time.Second)
+	active := newTestClient(2, "127.0.0.1:50002", DefaultUser, now)
+	active.last = now.Add(-10 * time.Second)
+	// blocked clients and monitors are waiting rather than idle
+	blocked := newTestClient(3, "127.0.0.1:50003", DefaultUser, now)
+	blocked.last = now.Add(-time.Hour)
+	blocked.blocked = true
+	monitor := newTestClient(4, "127.0.0.1:50004", DefaultUser, now)
+	monitor.last = now.Add(-time.Hour)
+	monitor.monitor = true
+	// closed client is skipped
+	gone := newTestClient(5, "127.0.0.1:50005", DefaultUser, now)
+	gone.last = now.Add(-time.Hour)
+
+	clients := []*client{idle, active, blocked, monitor, gone}
+	conns := make([]*closeConn, len(clients))
+	for i, client := range clients[:4] {
+		conns[i] = &closeConn{}
+		client.conn = conns[i]
+	}
+
+	closeIdleClients(clients, 10*time.Second, now)
+	Expect(conns[0].closed).To(BeTrue())
+	Expect(conns[1].closed).To(BeFalse())
+	Expect(conns[2].closed).To(BeFalse())
+	Expect(conns[3].closed).To(BeFalse())
+
+	closeIdleClients(clients, 5*time.Second, now)
+	Expect(conns[1].closed).To(BeTrue())
+	Expect(conns[2].closed).To(BeFalse())
+	Expect(conns[3].closed).To(BeFalse())
+}
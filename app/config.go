@@ -0,0 +1,661 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valery-barysok/gredisd/app/model"
+)
+
+var (
+	errNoConfigFile    = errors.New("ERR The server is running without a config file")
+	errImmutableConfig = errors.New("can't set immutable config")
+	errDuplicateConfig = errors.New("duplicate parameter")
+	errUnbalancedQuote = errors.New("unbalanced quotes in configuration line")
+	errYesNo           = errors.New("argument must be 'yes' or 'no'")
+	errEmptyAuthFile   = errors.New("auth file is empty")
+)
+
+// AuthFileEnv is environment variable naming file with authorization token
+const AuthFileEnv = "GREDIS_AUTH_FILE"
+
+// envNames are environment variables of parameters not named GREDIS_<PARAMETER>
+var envNames = map[string]string{
+	"bind":        "GREDIS_HOST",
+	"requirepass": "GREDIS_AUTH",
+}
+
+// configRewriteBanner marks parameters appended to config file by CONFIG REWRITE
+const configRewriteBanner = "# Generated by CONFIG REWRITE"
+
+// configParam is parameter of config file and CONFIG command
+type configParam struct {
+	name string
+	// get formats value of parameter stored in opts
+	get func(opts *Options) string
+	// set validates value and stores it in opts
+	set func(opts *Options, value string) error
+	// apply makes value stored in options of app effective, it is nil for
+	// parameters which can't be changed at runtime
+	apply func(app *App)
+}
+
+func intParam(name string, field func(opts *Options) *int, min int, max int, apply func(app *App)) configParam {
+	return configParam{
+		name: name,
+		get: func(opts *Options) string {
+			return strconv.Itoa(*field(opts))
+		},
+		set: func(opts *Options, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return errors.New("argument couldn't be parsed into an integer")
+			}
+			if n < min || n > max {
+				return fmt.Errorf("argument must be between %d and %d inclusive", min, max)
+			}
+			*field(opts) = n
+			return nil
+		},
+		apply: apply,
+	}
+}
+
+func int64Param(name string, field func(opts *Options) *int64, min int64, memory bool, apply func(app *App)) configParam {
+	return configParam{
+		name: name,
+		get: func(opts *Options) string {
+			return strconv.FormatInt(*field(opts), 10)
+		},
+		set: func(opts *Options, value string) error {
+			var n int64
+			var err error
+			if memory {
+				n, err = ParseMemory(value)
+			} else {
+				n, err = strconv.ParseInt(value, 10, 64)
+			}
+			if err != nil {
+				return errors.New("argument couldn't be parsed into an integer")
+			}
+			if n < min {
+				return fmt.Errorf("argument must be greater than or equal to %d", min)
+			}
+			*field(opts) = n
+			return nil
+		},
+		apply: apply,
+	}
+}
+
+func stringParam(name string, field func(opts *Options) *string, validate func(value string) error, apply func(app *App)) configParam {
+	return configParam{
+		name: name,
+		get: func(opts *Options) string {
+			return *field(opts)
+		},
+		set: func(opts *Options, value string) error {
+			if validate != nil {
+				if err := validate(value); err != nil {
+					return err
+				}
+			}
+			*field(opts) = value
+			return nil
+		},
+		apply: apply,
+	}
+}
+
+func boolParam(name string, field func(opts *Options) *bool, apply func(app *App)) configParam {
+	return configParam{
+		name: name,
+		get: func(opts *Options) string {
+			if *field(opts) {
+				return "yes"
+			}
+			return "no"
+		},
+		set: func(opts *Options, value string) error {
+			switch strings.ToLower(value) {
+			case "yes":
+				*field(opts) = true
+			case "no":
+				*field(opts) = false
+			default:
+				return errYesNo
+			}
+			return nil
+		},
+		apply: apply,
+	}
+}
+
+func applyMaxClients(app *App) {
+	if app.server != nil {
+		app.server.SetMaxClients(app.opts.MaxClients)
+	}
+}
+
+func applyTCPKeepAlive(app *App) {
+	if app.server != nil {
+		app.server.SetKeepAlive(time.Duration(app.opts.TCPKeepAlive) * time.Second)
+	}
+}
+
+func applySlowLog(app *App) {
+	app.SetSlowLog(app.opts.SlowLogSlowerThan, app.opts.SlowLogMaxLen)
+}
+
+// configParams are parameters of config file and CONFIG command ordered by name
+var configParams = []configParam{
+	stringParam("aclfile", func(opts *Options) *string { return &opts.ACLFile }, nil, nil),
+	intParam("acllog-max-len", func(opts *Options) *int { return &opts.ACLLogMaxLen }, 0, 1<<30, func(app *App) {
+		app.SetACLLogMaxLen(app.opts.ACLLogMaxLen)
+	}),
+	stringParam("bind", func(opts *Options) *string { return &opts.Host }, nil, nil),
+	{
+		name: "client-output-buffer-limit",
+		get: func(opts *Options) string {
+			return strings.Join(outputBufferLimitLines(opts), " ")
+		},
+		set: func(opts *Options, value string) error {
+			limits := make(map[string]OutputBufferLimit, len(opts.ClientOutputBufferLimit))
+			for class, limit := range opts.ClientOutputBufferLimit {
+				limits[class] = limit
+			}
+			if err := ParseOutputBufferLimit(value, limits); err != nil {
+				return err
+			}
+			opts.ClientOutputBufferLimit = limits
+			return nil
+		},
+		apply: func(app *App) {
+			for class, limit := range app.opts.ClientOutputBufferLimit {
+				app.limits.setOutput(class, limit)
+			}
+		},
+	},
+	int64Param("client-query-buffer-limit", func(opts *Options) *int64 { return &opts.ClientQueryBufferLimit }, 1<<20, true, func(app *App) {
+		atomic.StoreInt64(&app.limits.queryBuffer, app.opts.ClientQueryBufferLimit)
+	}),
+	intParam("databases", func(opts *Options) *int { return &opts.Databases }, 1, 1<<20, nil),
+	intParam("hash-max-listpack-entries", func(opts *Options) *int { return &opts.HashMaxListpackEntries }, 1, 1<<30, nil),
+	intParam("hash-max-listpack-value", func(opts *Options) *int { return &opts.HashMaxListpackValue }, 1, 1<<30, nil),
+	int64Param("latency-monitor-threshold", func(opts *Options) *int64 { return &opts.LatencyMonitorThreshold }, 0, false, func(app *App) {
+		app.SetLatencyMonitorThreshold(app.opts.LatencyMonitorThreshold)
+	}),
+	intParam("list-max-listpack-size", func(opts *Options) *int { return &opts.ListMaxListpackSize }, -5, 1<<30, nil),
+	intParam("maxclients", func(opts *Options) *int { return &opts.MaxClients }, 1, 1<<30, applyMaxClients),
+	int64Param("maxmemory", func(opts *Options) *int64 { return &opts.MaxMemory }, 0, true, func(app *App) {
+		app.model.SetMaxMemory(app.opts.MaxMemory)
+	}),
+	stringParam("maxmemory-policy", func(opts *Options) *string { return &opts.MaxMemoryPolicy }, func(value string) error {
+		_, err := model.ParseMaxMemoryPolicy(value)
+		return err
+	}, func(app *App) {
+		policy, _ := model.ParseMaxMemoryPolicy(app.opts.MaxMemoryPolicy)
+		app.model.SetMaxMemoryPolicy(policy)
+	}),
+	intParam("maxmemory-samples", func(opts *Options) *int { return &opts.MaxMemorySamples }, 1, 1<<16, func(app *App) {
+		app.model.SetMaxMemorySamples(app.opts.MaxMemorySamples)
+	}),
+	stringParam("metrics-addr", func(opts *Options) *string { return &opts.MetricsAddr }, nil, nil),
+	intParam("port", func(opts *Options) *int { return &opts.Port }, 0, 65535, nil),
+	int64Param("proto-max-bulk-len", func(opts *Options) *int64 { return &opts.ProtoMaxBulkLen }, 1<<20, true, func(app *App) {
+		atomic.StoreInt64(&app.limits.protoMaxBulkLen, app.opts.ProtoMaxBulkLen)
+	}),
+	stringParam("requirepass", func(opts *Options) *string { return &opts.Auth }, nil, func(app *App) {
+		app.acl.setDefaultPassword(app.opts.Auth)
+	}),
+	intParam("set-max-intset-entries", func(opts *Options) *int { return &opts.SetMaxIntsetEntries }, 1, 1<<30, nil),
+	int64Param("slowlog-log-slower-than", func(opts *Options) *int64 { return &opts.SlowLogSlowerThan }, -1, false, applySlowLog),
+	intParam("slowlog-max-len", func(opts *Options) *int { return &opts.SlowLogMaxLen }, 0, 1<<30, applySlowLog),
+	intParam("tcp-keepalive", func(opts *Options) *int { return &opts.TCPKeepAlive }, 0, 1<<30, applyTCPKeepAlive),
+	intParam("timeout", func(opts *Options) *int { return &opts.Timeout }, 0, 1<<30, func(app *App) {}),
+	boolParam("trace-protocol", func(opts *Options) *bool { return &opts.TraceProtocol }, nil),
+}
+
+func findConfigParam(name string) *configParam {
+	name = strings.ToLower(name)
+	for i := range configParams {
+		if configParams[i].name == name {
+			return &configParams[i]
+		}
+	}
+	return nil
+}
+
+// outputBufferLimitLines formats client-output-buffer-limit per class
+func outputBufferLimitLines(opts *Options) []string {
+	lines := make([]string, 0, len(outputBufferClasses))
+	for _, class := range outputBufferClasses {
+		limit := opts.ClientOutputBufferLimit[class]
+		lines = append(lines, fmt.Sprintf("%s %d %d %d", class, limit.Hard, limit.Soft, limit.SoftSeconds))
+	}
+	return lines
+}
+
+// DefaultOptions returns options of app by default
+func DefaultOptions() Options {
+	opts := Options{
+		Host:              DefaultHost,
+		Port:              DefaultPort,
+		Databases:         DefaultDatabases,
+		MaxClients:        DefaultMaxClients,
+		TCPKeepAlive:      DefaultTCPKeepAlive,
+		MaxMemoryPolicy:   DefaultMaxMemoryPolicy,
+		SlowLogSlowerThan: DefaultSlowLogSlowerThan,
+		SlowLogMaxLen:     DefaultSlowLogMaxLen,
+		ACLLogMaxLen:      DefaultACLLogMaxLen,
+	}
+	normalizeOptions(&opts)
+	return opts
+}
+
+// EnvName returns environment variable of config parameter, it is GREDIS_
+// followed by parameter name in upper case with dashes replaced by underscores
+func EnvName(param string) string {
+	if name, ok := envNames[param]; ok {
+		return name
+	}
+	return "GREDIS_" + strings.ToUpper(strings.Replace(param, "-", "_", -1))
+}
+
+// LoadEnv reads options from environment variables of config parameters,
+// variables with empty value are ignored
+func LoadEnv(opts *Options) error {
+	for i := range configParams {
+		param := &configParams[i]
+		name := EnvName(param.name)
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if err := param.set(opts, value); err != nil {
+			return fmt.Errorf("%s: %s", name, configReason(err))
+		}
+	}
+
+	if file := os.Getenv(AuthFileEnv); file != "" {
+		name := EnvName("requirepass")
+		if os.Getenv(name) != "" {
+			return fmt.Errorf("%s and %s are mutually exclusive", name, AuthFileEnv)
+		}
+		auth, err := ReadAuthFile(file)
+		if err != nil {
+			return fmt.Errorf("%s: %s", AuthFileEnv, err)
+		}
+		opts.Auth = auth
+	}
+	return nil
+}
+
+// ReadAuthFile reads authorization token from file, trailing line breaks are trimmed
+func ReadAuthFile(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	auth := strings.TrimRight(string(data), "\r\n")
+	if auth == "" {
+		return "", fmt.Errorf("%s: %s", file, errEmptyAuthFile)
+	}
+	return auth, nil
+}
+
+// ValidateOptions checks values of options the same way as values of config
+// file, so invalid command line options are reported the same way
+func ValidateOptions(opts *Options) error {
+	check := *opts
+	for i := range configParams {
+		param := &configParams[i]
+		if err := param.set(&check, param.get(opts)); err != nil {
+			return fmt.Errorf("invalid %s: %s", param.name, configReason(err))
+		}
+	}
+	return nil
+}
+
+// PrintConfig writes options in config file format, authorization token is redacted
+func PrintConfig(w io.Writer, opts *Options) error {
+	for i := range configParams {
+		param := &configParams[i]
+		lines := configLines(param, opts)
+		if param.name == "requirepass" && opts.Auth != "" {
+			lines = []string{param.name + " (redacted)"}
+		}
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// configReason returns reason of invalid value without error prefix of reply
+func configReason(err error) string {
+	return strings.TrimPrefix(err.Error(), "ERR ")
+}
+
+// LoadConfig reads config file in format of redis.conf into opts: one parameter
+// per line followed by its value, lines starting with # are comments
+func LoadConfig(file string, opts *Options) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		args, err := splitConfigLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", file, n, err)
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		param := findConfigParam(args[0])
+		if param == nil {
+			return fmt.Errorf("%s:%d: unknown parameter '%s'", file, n, args[0])
+		}
+		if len(args) < 2 {
+			return fmt.Errorf("%s:%d: wrong number of arguments for '%s'", file, n, args[0])
+		}
+		if err := param.set(opts, strings.Join(args[1:], " ")); err != nil {
+			return fmt.Errorf("%s:%d: '%s': %s", file, n, args[0], configReason(err))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	opts.ConfigFile = file
+	return nil
+}
+
+// splitConfigLine splits line of config file into arguments. Arguments may be
+// quoted with double quotes supporting escapes or with single quotes
+func splitConfigLine(line string) ([]string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return nil, nil
+	}
+
+	var args []string
+	for i := 0; i < len(line); {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i == len(line) {
+			break
+		}
+
+		var arg []byte
+		switch line[i] {
+		case '"':
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				c := line[i]
+				if c == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						c = '\n'
+					case 'r':
+						c = '\r'
+					case 't':
+						c = '\t'
+					default:
+						c = line[i]
+					}
+				}
+				arg = append(arg, c)
+			}
+			if i == len(line) {
+				return nil, errUnbalancedQuote
+			}
+			i++
+		case '\'':
+			j := strings.IndexByte(line[i+1:], '\'')
+			if j < 0 {
+				return nil, errUnbalancedQuote
+			}
+			arg = []byte(line[i+1 : i+1+j])
+			i += j + 2
+		default:
+			for ; i < len(line) && line[i] != ' ' && line[i] != '\t'; i++ {
+				arg = append(arg, line[i])
+			}
+		}
+		args = append(args, string(arg))
+	}
+	return args, nil
+}
+
+// quoteConfigValue quotes value for config file if needed
+func quoteConfigValue(value string) string {
+	if value != "" && strings.IndexAny(value, " \t\"'\\\r\n#") < 0 {
+		return value
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// configLines returns lines of config file setting current value of parameter
+func configLines(param *configParam, opts *Options) []string {
+	if param.name == "client-output-buffer-limit" {
+		lines := outputBufferLimitLines(opts)
+		for i := range lines {
+			lines[i] = param.name + " " + lines[i]
+		}
+		return lines
+	}
+	return []string{param.name + " " + quoteConfigValue(param.get(opts))}
+}
+
+// ConfigGet returns names and values of parameters matching any of glob patterns
+func (app *App) ConfigGet(patterns ...string) Map {
+	opts := app.options()
+
+	reply := make(Map, 0)
+	for i := range configParams {
+		param := &configParams[i]
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(strings.ToLower(pattern), param.name); ok {
+				reply = append(reply, []byte(param.name), []byte(param.get(&opts)))
+				break
+			}
+		}
+	}
+	return reply
+}
+
+// ConfigSet sets parameters given as name value pairs. Either all parameters
+// are set or none of them if any value is invalid
+func (app *App) ConfigSet(pairs ...string) error {
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return errors.New("ERR wrong number of arguments for 'config|set' command")
+	}
+
+	app.configMu.Lock()
+	defer app.configMu.Unlock()
+
+	opts := *app.opts
+	params := make([]*configParam, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		param := findConfigParam(pairs[i])
+		if param == nil {
+			return fmt.Errorf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", pairs[i])
+		}
+
+		err := param.set(&opts, pairs[i+1])
+		if err == nil && param.apply == nil {
+			err = errImmutableConfig
+		}
+		for _, p := range params {
+			if p == param {
+				err = errDuplicateConfig
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("ERR CONFIG SET failed (possibly related to argument '%s') - %s", pairs[i], configReason(err))
+		}
+		params = append(params, param)
+	}
+
+	*app.opts = opts
+	for _, param := range params {
+		param.apply(app)
+	}
+	return nil
+}
+
+// ConfigResetStat resets statistics reported by INFO, LATENCY HISTOGRAM and metrics
+func (app *App) ConfigResetStat() {
+	for _, stat := range app.router.stats {
+		stat.reset()
+	}
+
+	atomic.StoreInt64(&app.net.in, 0)
+	atomic.StoreInt64(&app.net.out, 0)
+	atomic.StoreInt64(&app.limits.queryDisconnections, 0)
+	atomic.StoreInt64(&app.limits.outputDisconnections, 0)
+	app.model.ResetStats()
+	if app.server != nil {
+		app.server.ResetStats()
+	}
+}
+
+// ConfigRewrite writes current values of parameters to config file. Lines of
+// parameters are replaced in place, other lines and comments are kept and
+// parameters missing in file which differ from defaults are appended
+func (app *App) ConfigRewrite() error {
+	app.rewriteMu.Lock()
+	defer app.rewriteMu.Unlock()
+
+	opts := app.options()
+	if opts.ConfigFile == "" {
+		return errNoConfigFile
+	}
+
+	data, err := ioutil.ReadFile(opts.ConfigFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ERR Rewriting config file: %s", err)
+	}
+
+	var lines []string
+	if len(data) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+
+	written := make(map[string]bool)
+	banner := false
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.TrimSpace(line) == configRewriteBanner {
+			banner = true
+		}
+
+		args, err := splitConfigLine(line)
+		var param *configParam
+		if err == nil && len(args) > 0 {
+			param = findConfigParam(args[0])
+		}
+		if param == nil {
+			out = append(out, line)
+			continue
+		}
+
+		if !written[param.name] {
+			out = append(out, configLines(param, &opts)...)
+			written[param.name] = true
+		}
+	}
+
+	defaults := DefaultOptions()
+	var appended []string
+	for i := range configParams {
+		param := &configParams[i]
+		if !written[param.name] && param.get(&opts) != param.get(&defaults) {
+			appended = append(appended, configLines(param, &opts)...)
+		}
+	}
+	if len(appended) > 0 {
+		if !banner {
+			out = append(out, configRewriteBanner)
+		}
+		out = append(out, appended...)
+	}
+
+	content := strings.Join(out, "\n")
+	if len(out) > 0 {
+		content += "\n"
+	}
+	if err := writeFileAtomic(opts.ConfigFile, content); err != nil {
+		return fmt.Errorf("ERR Rewriting config file: %s", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces file with content atomically. Content is written to
+// temporary file next to it which is renamed then. Temporary file is created
+// with mode 0600, so config file holding requirepass is readable by owner only
+func writeFileAtomic(file string, content string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.WriteString(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// options returns copy of current options
+func (app *App) options() Options {
+	app.configMu.RLock()
+	defer app.configMu.RUnlock()
+
+	return *app.opts
+}
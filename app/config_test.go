@@ -0,0 +1,408 @@
+package app
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSplitConfigLine(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		line string
+		args []string
+		err  error
+	}{
+		{"", nil, nil},
+		{"   ", nil, nil},
+		{"# comment", nil, nil},
+		{"  # indented comment", nil, nil},
+		{"port 6380", []string{"port", "6380"}, nil},
+		{"\tport \t 6380  ", []string{"port", "6380"}, nil},
+		{`requirepass "with space"`, []string{"requirepass", "with space"}, nil},
+		{`requirepass "a\"b\\c\n"`, []string{"requirepass", "a\"b\\c\n"}, nil},
+		{`requirepass 'a "b" \n'`, []string{"requirepass", `a "b" \n`}, nil},
+		{`requirepass ""`, []string{"requirepass", ""}, nil},
+		{`requirepass "open`, nil, errUnbalancedQuote},
+		{`requirepass 'open`, nil, errUnbalancedQuote},
+	}
+	for _, test := range tests {
+		args, err := splitConfigLine(test.line)
+		if test.err != nil {
+			Expect(err).To(Equal(test.err), test.line)
+			continue
+		}
+		Expect(err).NotTo(HaveOccurred(), test.line)
+		Expect(args).To(Equal(test.args), test.line)
+	}
+}
+
+func TestQuoteConfigValue(t *testing.T) {
+	RegisterTestingT(t)
+
+	for _, value := range []string{"", "plain", "with space", "a\"b\\c", "line\r\nbreak\t", "#hash", "'single'"} {
+		quoted := quoteConfigValue(value)
+		args, err := splitConfigLine("requirepass " + quoted)
+		Expect(err).NotTo(HaveOccurred(), value)
+		Expect(args).To(Equal([]string{"requirepass", value}), value)
+	}
+	Expect(quoteConfigValue("plain")).To(Equal("plain"))
+}
+
+func writeConfigFile(dir string, content string) string {
+	file := filepath.Join(dir, "gredis.conf")
+	Expect(ioutil.WriteFile(file, []byte(content), 0644)).To(Succeed())
+	return file
+}
+
+func TestLoadConfig(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "gredis-config")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	file := writeConfigFile(dir, `# gredis config
+
+port 6380
+MaxClients 50
+requirepass "secret pass"
+maxmemory 1mb
+maxmemory-policy allkeys-lru
+trace-protocol yes
+client-output-buffer-limit pubsub 32mb 8mb 60
+`)
+	opts := DefaultOptions()
+	Expect(LoadConfig(file, &opts)).To(Succeed())
+	Expect(opts.ConfigFile).To(Equal(file))
+	Expect(opts.Port).To(Equal(6380))
+	Expect(opts.MaxClients).To(Equal(50))
+	Expect(opts.Auth).To(Equal("secret pass"))
+	Expect(opts.MaxMemory).To(Equal(int64(1 << 20)))
+	Expect(opts.MaxMemoryPolicy).To(Equal("allkeys-lru"))
+	Expect(opts.TraceProtocol).To(BeTrue())
+	Expect(opts.ClientOutputBufferLimit["pubsub"]).To(Equal(OutputBufferLimit{Hard: 32 << 20, Soft: 8 << 20, SoftSeconds: 60}))
+	Expect(opts.ClientOutputBufferLimit["normal"]).To(Equal(DefaultOptions().ClientOutputBufferLimit["normal"]))
+
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{"port 6380\nunknown 1\n", file + ":2: unknown parameter 'unknown'"},
+		{"port\n", file + ":1: wrong number of arguments for 'port'"},
+		{"# comment\nport 70000\n", file + ":2: 'port': argument must be between 0 and 65535 inclusive"},
+		{"maxclients many\n", file + ":1: 'maxclients': argument couldn't be parsed into an integer"},
+		{"trace-protocol maybe\n", file + ":1: 'trace-protocol': argument must be 'yes' or 'no'"},
+		{"\n\nrequirepass \"open\n", file + ":3: unbalanced quotes in configuration line"},
+	}
+	for _, test := range tests {
+		writeConfigFile(dir, test.content)
+		opts := DefaultOptions()
+		err := LoadConfig(file, &opts)
+		Expect(err).To(HaveOccurred(), test.content)
+		Expect(err.Error()).To(Equal(test.err))
+		Expect(opts.ConfigFile).To(BeEmpty())
+	}
+
+	opts = DefaultOptions()
+	Expect(LoadConfig(filepath.Join(dir, "missing.conf"), &opts)).NotTo(Succeed())
+}
+
+func configGetNames(reply Map) []string {
+	var names []string
+	for i := 0; i < len(reply); i += 2 {
+		names = append(names, string(reply[i].([]byte)))
+	}
+	return names
+}
+
+func TestConfigGet(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+
+	Expect(app.ConfigGet("maxclients")).To(Equal(Map{[]byte("maxclients"), []byte("10000")}))
+	Expect(app.ConfigGet("MAXCLIENTS")).To(Equal(Map{[]byte("maxclients"), []byte("10000")}))
+	Expect(app.ConfigGet("unknown")).To(BeEmpty())
+
+	Expect(configGetNames(app.ConfigGet("maxmemory*"))).To(Equal([]string{"maxmemory", "maxmemory-policy", "maxmemory-samples"}))
+	Expect(configGetNames(app.ConfigGet("*-max-len"))).To(Equal([]string{"acllog-max-len", "slowlog-max-len"}))
+	Expect(configGetNames(app.ConfigGet("p?rt", "[ab]ind"))).To(Equal([]string{"bind", "port"}))
+	// parameter matching several patterns is reported once
+	Expect(configGetNames(app.ConfigGet("port", "p*rt", "*ort"))).To(Equal([]string{"port"}))
+	Expect(app.ConfigGet("*")).To(HaveLen(2 * len(configParams)))
+}
+
+func TestConfigSet(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+
+	Expect(app.ConfigSet("maxclients", "50", "SLOWLOG-MAX-LEN", "64")).To(Succeed())
+	Expect(app.options().MaxClients).To(Equal(50))
+	Expect(app.options().SlowLogMaxLen).To(Equal(64))
+	Expect(app.ConfigGet("slowlog-max-len")).To(Equal(Map{[]byte("slowlog-max-len"), []byte("64")}))
+
+	tests := []struct {
+		pairs []string
+		err   string
+	}{
+		{nil, "ERR wrong number of arguments for 'config|set' command"},
+		{[]string{"maxclients"}, "ERR wrong number of arguments for 'config|set' command"},
+		{[]string{"maxclients", "10", "unknown", "1"},
+			"ERR Unknown option or number of arguments for CONFIG SET - 'unknown'"},
+		{[]string{"maxclients", "10", "slowlog-max-len", "many"},
+			"ERR CONFIG SET failed (possibly related to argument 'slowlog-max-len') - argument couldn't be parsed into an integer"},
+		{[]string{"maxclients", "10", "port", "6380"},
+			"ERR CONFIG SET failed (possibly related to argument 'port') - can't set immutable config"},
+		{[]string{"maxclients", "10", "MaxClients", "20"},
+			"ERR CONFIG SET failed (possibly related to argument 'MaxClients') - duplicate parameter"},
+		{[]string{"maxclients", "10", "maxmemory-policy", "never"},
+			"ERR CONFIG SET failed (possibly related to argument 'maxmemory-policy') - invalid maxmemory policy"},
+	}
+	for _, test := range tests {
+		err := app.ConfigSet(test.pairs...)
+		Expect(err).To(HaveOccurred(), "%v", test.pairs)
+		Expect(err.Error()).To(Equal(test.err))
+
+		// none of parameters is set if any of them fails
+		Expect(app.options().MaxClients).To(Equal(50))
+		Expect(app.options().SlowLogMaxLen).To(Equal(64))
+		Expect(app.options().Port).To(Equal(DefaultPort))
+	}
+}
+
+func TestConfigRewrite(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+	Expect(app.ConfigRewrite()).To(Equal(errNoConfigFile))
+
+	dir, err := ioutil.TempDir("", "gredis-config")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	// unknown parameters and comments are kept, duplicates are collapsed
+	file := writeConfigFile(dir, `# gredis config
+  # indented comment
+
+maxclients 100
+include other.conf
+MAXCLIENTS 200
+tcp-keepalive 60
+`)
+	opts = DefaultOptions()
+	opts.ConfigFile = file
+	app = NewApp(&opts)
+	Expect(app.ConfigSet("maxclients", "50", "slowlog-max-len", "64", "requirepass", "secret pass")).To(Succeed())
+
+	expected := `# gredis config
+  # indented comment
+
+maxclients 50
+include other.conf
+tcp-keepalive 300
+` + configRewriteBanner + `
+requirepass "secret pass"
+slowlog-max-len 64
+`
+	Expect(app.ConfigRewrite()).To(Succeed())
+	data, err := ioutil.ReadFile(file)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(string(data)).To(Equal(expected))
+
+	info, err := os.Stat(file)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	// temporary file is renamed to config file
+	files, err := ioutil.ReadDir(dir)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(files).To(HaveLen(1))
+
+	// rewriting again changes nothing and banner is not repeated
+	Expect(app.ConfigRewrite()).To(Succeed())
+	data, err = ioutil.ReadFile(file)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(string(data)).To(Equal(expected))
+
+	// missing config file is created
+	Expect(os.Remove(file)).To(Succeed())
+	Expect(app.ConfigRewrite()).To(Succeed())
+	data, err = ioutil.ReadFile(file)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(string(data)).To(Equal(configRewriteBanner + `
+maxclients 50
+requirepass "secret pass"
+slowlog-max-len 64
+`))
+
+	// rewritten file is loaded back to the same options
+	loaded := DefaultOptions()
+	Expect(LoadConfig(file, &loaded)).To(Succeed())
+	Expect(loaded.MaxClients).To(Equal(50))
+	Expect(loaded.SlowLogMaxLen).To(Equal(64))
+	Expect(loaded.Auth).To(Equal("secret pass"))
+
+	// concurrent rewrites do not interleave
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Expect(app.ConfigRewrite()).To(Succeed())
+		}()
+	}
+	wg.Wait()
+	rewritten, err := ioutil.ReadFile(file)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(rewritten).To(Equal(data))
+	files, err = ioutil.ReadDir(dir)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(files).To(HaveLen(1))
+}
+
+// setEnv sets environment variables and returns function restoring them
+func setEnv(vars map[string]string) func() {
+	saved := make(map[string]string, len(vars))
+	for name, value := range vars {
+		saved[name] = os.Getenv(name)
+		os.Setenv(name, value)
+	}
+	return func() {
+		for name, value := range saved {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(EnvName("port")).To(Equal("GREDIS_PORT"))
+	Expect(EnvName("maxmemory-policy")).To(Equal("GREDIS_MAXMEMORY_POLICY"))
+	Expect(EnvName("client-output-buffer-limit")).To(Equal("GREDIS_CLIENT_OUTPUT_BUFFER_LIMIT"))
+	Expect(EnvName("bind")).To(Equal("GREDIS_HOST"))
+	Expect(EnvName("requirepass")).To(Equal("GREDIS_AUTH"))
+}
+
+func TestLoadEnv(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "gredis-env")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+	authFile := filepath.Join(dir, "auth")
+	Expect(ioutil.WriteFile(authFile, []byte("file secret\r\n\n"), 0600)).To(Succeed())
+	emptyFile := filepath.Join(dir, "empty")
+	Expect(ioutil.WriteFile(emptyFile, []byte("\n"), 0600)).To(Succeed())
+
+	defer setEnv(map[string]string{
+		"GREDIS_PORT":                       "6380",
+		"GREDIS_HOST":                       "127.0.0.1",
+		"GREDIS_AUTH":                       "env secret",
+		"GREDIS_MAXMEMORY":                  "1mb",
+		"GREDIS_MAXMEMORY_POLICY":           "allkeys-lru",
+		"GREDIS_TRACE_PROTOCOL":             "yes",
+		"GREDIS_CLIENT_OUTPUT_BUFFER_LIMIT": "pubsub 1mb 512kb 10",
+		"GREDIS_TIMEOUT":                    "",
+		AuthFileEnv:                         "",
+	})()
+
+	opts := DefaultOptions()
+	Expect(LoadEnv(&opts)).To(Succeed())
+	Expect(opts.Port).To(Equal(6380))
+	Expect(opts.Host).To(Equal("127.0.0.1"))
+	Expect(opts.Auth).To(Equal("env secret"))
+	Expect(opts.MaxMemory).To(Equal(int64(1 << 20)))
+	Expect(opts.MaxMemoryPolicy).To(Equal("allkeys-lru"))
+	Expect(opts.TraceProtocol).To(BeTrue())
+	Expect(opts.ClientOutputBufferLimit["pubsub"]).To(Equal(OutputBufferLimit{Hard: 1 << 20, Soft: 512 << 10, SoftSeconds: 10}))
+	// empty variable is ignored
+	Expect(opts.Timeout).To(Equal(DefaultOptions().Timeout))
+
+	tests := []struct {
+		vars map[string]string
+		err  string
+	}{
+		{map[string]string{"GREDIS_PORT": "port"}, "GREDIS_PORT: argument couldn't be parsed into an integer"},
+		{map[string]string{"GREDIS_MAXCLIENTS": "0"}, "GREDIS_MAXCLIENTS: argument must be between 1 and 1073741824 inclusive"},
+		{map[string]string{"GREDIS_TRACE_PROTOCOL": "on"}, "GREDIS_TRACE_PROTOCOL: argument must be 'yes' or 'no'"},
+		{map[string]string{AuthFileEnv: authFile}, "GREDIS_AUTH and GREDIS_AUTH_FILE are mutually exclusive"},
+		{map[string]string{"GREDIS_AUTH": "", AuthFileEnv: filepath.Join(dir, "missing")}, "GREDIS_AUTH_FILE: open " + filepath.Join(dir, "missing")},
+		{map[string]string{"GREDIS_AUTH": "", AuthFileEnv: emptyFile}, "GREDIS_AUTH_FILE: " + emptyFile + ": auth file is empty"},
+	}
+	for _, test := range tests {
+		restore := setEnv(test.vars)
+		opts := DefaultOptions()
+		err := LoadEnv(&opts)
+		restore()
+		Expect(err).To(HaveOccurred(), "%v", test.vars)
+		Expect(err.Error()).To(HavePrefix(test.err))
+	}
+
+	defer setEnv(map[string]string{"GREDIS_AUTH": "", AuthFileEnv: authFile})()
+	opts = DefaultOptions()
+	Expect(LoadEnv(&opts)).To(Succeed())
+	Expect(opts.Auth).To(Equal("file secret"))
+}
+
+func TestValidateOptions(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	Expect(ValidateOptions(&opts)).To(Succeed())
+
+	opts.Port = 70000
+	err := ValidateOptions(&opts)
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(Equal("invalid port: argument must be between 0 and 65535 inclusive"))
+
+	opts = DefaultOptions()
+	opts.MaxMemoryPolicy = "never"
+	err = ValidateOptions(&opts)
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(Equal("invalid maxmemory-policy: invalid maxmemory policy"))
+
+	// validation does not change options
+	opts = DefaultOptions()
+	opts.MaxClients = 50
+	Expect(ValidateOptions(&opts)).To(Succeed())
+	Expect(opts.MaxClients).To(Equal(50))
+}
+
+func TestPrintConfig(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	opts.Port = 6380
+	opts.Auth = "secret"
+
+	var buf bytes.Buffer
+	Expect(PrintConfig(&buf, &opts)).To(Succeed())
+	out := buf.String()
+	Expect(out).To(ContainSubstring("\nport 6380\n"))
+	Expect(out).To(ContainSubstring("\nrequirepass (redacted)\n"))
+	Expect(out).To(ContainSubstring("\nclient-output-buffer-limit pubsub 33554432 8388608 60\n"))
+	Expect(out).NotTo(ContainSubstring("secret"))
+
+	// printed config is valid config file
+	dir, err := ioutil.TempDir("", "gredis-config")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+	opts.Auth = ""
+	buf.Reset()
+	Expect(PrintConfig(&buf, &opts)).To(Succeed())
+	loaded := DefaultOptions()
+	Expect(LoadConfig(writeConfigFile(dir, buf.String()), &loaded)).To(Succeed())
+	loaded.ConfigFile = ""
+	Expect(loaded).To(Equal(opts))
+}
@@ -6,6 +6,10 @@ const (
 	// Version is current version of app
 	Version = "0.0.1"
 
+	// RedisVersion is version of Redis which commands and replies of app are
+	// compatible with, it is reported by INFO for monitoring agents
+	RedisVersion = "7.0.0"
+
 	// DefaultPort is port for incoming connections by default
 	DefaultPort = 16379
 
@@ -17,4 +21,34 @@ const (
 
 	// DefaultFlushDeadline is timeout for writing to the client by default
 	DefaultFlushDeadline = 2 * time.Second
+
+	// DefaultExpireInterval is period of active expiration cycle by default
+	DefaultExpireInterval = 100 * time.Millisecond
+
+	// DefaultMaxClients is maximum number of connected clients by default
+	DefaultMaxClients = 10000
+
+	// DefaultTCPKeepAlive is period of TCP keepalive probes in seconds by default
+	DefaultTCPKeepAlive = 300
+
+	// DefaultClientQueryBufferLimit is maximum size of command in bytes by default
+	DefaultClientQueryBufferLimit = 1 << 30
+
+	// DefaultProtoMaxBulkLen is maximum length of command argument in bytes by default
+	DefaultProtoMaxBulkLen = 512 << 20
+
+	// DefaultSlowLogSlowerThan is threshold of slow log in microseconds by default
+	DefaultSlowLogSlowerThan = 10000
+
+	// DefaultSlowLogMaxLen is maximum number of slow log entries by default
+	DefaultSlowLogMaxLen = 128
+
+	// DefaultACLLogMaxLen is maximum number of ACL LOG entries by default
+	DefaultACLLogMaxLen = 128
+
+	// DefaultClientsCronInterval is period of checking clients for idle timeout by default
+	DefaultClientsCronInterval = 1 * time.Second
+
+	// DefaultMaxMemoryPolicy is eviction policy applied once maxmemory is reached by default
+	DefaultMaxMemoryPolicy = "noeviction"
 )
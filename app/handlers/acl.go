@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/valery-barysok/gredisd/app"
+	"github.com/valery-barysok/gredisd/app/cmd"
+	"github.com/valery-barysok/resp"
+)
+
+// List of access control commands.
+const (
+	ACLCommand = "acl"
+)
+
+// Subcommands of ACL command
+const (
+	aclSetUser = "setuser"
+	aclGetUser = "getuser"
+	aclDelUser = "deluser"
+	aclList    = "list"
+	aclUsers   = "users"
+	aclWhoAmI  = "whoami"
+	aclCat     = "cat"
+	aclLog     = "log"
+	aclLoad    = "load"
+	aclSave    = "save"
+
+	aclLogReset = "reset"
+
+	// aclLogDefaultCount is number of entries returned by ACL LOG by default
+	aclLogDefaultCount = 10
+)
+
+// keySpec returns spec of command with key arguments from first to last position
+func keySpec(first int, last int, categories ...string) app.CommandSpec {
+	return app.CommandSpec{Categories: categories, First: first, Last: last, Step: 1}
+}
+
+// numKeysSpec returns spec of command with first keys followed by number of keys at position numKeys
+func numKeysSpec(first int, numKeys int, categories ...string) app.CommandSpec {
+	return app.CommandSpec{Categories: categories, First: first, Last: first, Step: 1, NumKeys: numKeys}
+}
+
+// noKeysSpec returns spec of command without key arguments
+func noKeysSpec(categories ...string) app.CommandSpec {
+	return app.CommandSpec{Categories: categories}
+}
+
+// denyOOM marks spec of command which may take more memory
+func denyOOM(spec app.CommandSpec) app.CommandSpec {
+	spec.DenyOOM = true
+	return spec
+}
+
+// commandSpecs are ACL categories, key arguments and flags of commands, they are
+// the only list of commands by kind. Subcommands named command|subcommand
+// override spec of command
+var commandSpecs = map[string]app.CommandSpec{
+	AuthCommand:      noKeysSpec("fast", "connection"),
+	HelloCommand:     noKeysSpec("fast", "connection"),
+	SelectCommand:    noKeysSpec("fast", "connection"),
+	EchoCommand:      noKeysSpec("fast", "connection"),
+	PingCommand:      noKeysSpec("fast", "connection"),
+	ShutdownCommand:  noKeysSpec("admin", "slow", "dangerous"),
+	CommandCommand:   noKeysSpec("slow", "connection"),
+	KeysCommand:      noKeysSpec("keyspace", "read", "slow", "dangerous"),
+	ExistsCommand:    keySpec(1, -1, "keyspace", "read", "fast"),
+	ExpireCommand:    keySpec(1, 1, "keyspace", "write", "fast"),
+	ObjectCommand:    keySpec(2, 2, "keyspace", "read", "slow"),
+	TypeCommand:      keySpec(1, 1, "keyspace", "read", "fast"),
+	RenameCommand:    keySpec(1, 2, "keyspace", "write", "slow"),
+	RenameNXCommand:  keySpec(1, 2, "keyspace", "write", "fast"),
+	RandomKeyCommand: noKeysSpec("keyspace", "read", "slow"),
+	DBSizeCommand:    noKeysSpec("keyspace", "read", "fast"),
+	CopyCommand:      denyOOM(keySpec(1, 2, "keyspace", "write", "slow")),
+	TouchCommand:     keySpec(1, -1, "keyspace", "read", "fast"),
+	UnlinkCommand:    keySpec(1, -1, "keyspace", "write", "fast"),
+	FlushDBCommand:   noKeysSpec("keyspace", "write", "slow", "dangerous"),
+	FlushAllCommand:  noKeysSpec("keyspace", "write", "slow", "dangerous"),
+	MoveCommand:      keySpec(1, 1, "keyspace", "write", "fast"),
+	SwapDBCommand:    noKeysSpec("keyspace", "write", "fast", "dangerous"),
+
+	SetCommand: denyOOM(keySpec(1, 1, "write", "string", "slow")),
+	GetCommand: keySpec(1, 1, "read", "string", "fast"),
+	DelCommand: keySpec(1, -1, "keyspace", "write", "slow"),
+
+	LPushCommand:      denyOOM(keySpec(1, 1, "write", "list", "fast")),
+	RPushCommand:      denyOOM(keySpec(1, 1, "write", "list", "fast")),
+	LPushXCommand:     denyOOM(keySpec(1, 1, "write", "list", "fast")),
+	RPushXCommand:     denyOOM(keySpec(1, 1, "write", "list", "fast")),
+	LPopCommand:       keySpec(1, 1, "write", "list", "fast"),
+	RPopCommand:       keySpec(1, 1, "write", "list", "fast"),
+	LMPopCommand:      numKeysSpec(0, 1, "write", "list", "slow"),
+	LLenCommand:       keySpec(1, 1, "read", "list", "fast"),
+	LInsertCommand:    denyOOM(keySpec(1, 1, "write", "list", "slow")),
+	LIndexCommand:     keySpec(1, 1, "read", "list", "slow"),
+	LRangeCommand:     keySpec(1, 1, "read", "list", "slow"),
+	LSetCommand:       denyOOM(keySpec(1, 1, "write", "list", "slow")),
+	LRemCommand:       keySpec(1, 1, "write", "list", "slow"),
+	LTrimCommand:      keySpec(1, 1, "write", "list", "slow"),
+	LPosCommand:       keySpec(1, 1, "read", "list", "slow"),
+	LMoveCommand:      denyOOM(keySpec(1, 2, "write", "list", "slow")),
+	RPopLPushCommand:  denyOOM(keySpec(1, 2, "write", "list", "slow")),
+	BLPopCommand:      keySpec(1, -2, "write", "list", "slow", "blocking"),
+	BRPopCommand:      keySpec(1, -2, "write", "list", "slow", "blocking"),
+	BLMPopCommand:     numKeysSpec(0, 2, "write", "list", "slow", "blocking"),
+	BLMoveCommand:     denyOOM(keySpec(1, 2, "write", "list", "slow", "blocking")),
+	BRPopLPushCommand: denyOOM(keySpec(1, 2, "write", "list", "slow", "blocking")),
+
+	HSetCommand:         denyOOM(keySpec(1, 1, "write", "hash", "fast")),
+	HSetNXCommand:       denyOOM(keySpec(1, 1, "write", "hash", "fast")),
+	HMSetCommand:        denyOOM(keySpec(1, 1, "write", "hash", "fast")),
+	HGetCommand:         keySpec(1, 1, "read", "hash", "fast"),
+	HMGetCommand:        keySpec(1, 1, "read", "hash", "fast"),
+	HGetAllCommand:      keySpec(1, 1, "read", "hash", "slow"),
+	HKeysCommand:        keySpec(1, 1, "read", "hash", "slow"),
+	HValsCommand:        keySpec(1, 1, "read", "hash", "slow"),
+	HDelCommand:         keySpec(1, 1, "write", "hash", "fast"),
+	HLenCommand:         keySpec(1, 1, "read", "hash", "fast"),
+	HExistsCommand:      keySpec(1, 1, "read", "hash", "fast"),
+	HStrLenCommand:      keySpec(1, 1, "read", "hash", "fast"),
+	HIncrByCommand:      denyOOM(keySpec(1, 1, "write", "hash", "fast")),
+	HIncrByFloatCommand: denyOOM(keySpec(1, 1, "write", "hash", "fast")),
+	HRandFieldCommand:   keySpec(1, 1, "read", "hash", "slow"),
+	HExpireCommand:      keySpec(1, 1, "write", "hash", "fast"),
+	HPExpireCommand:     keySpec(1, 1, "write", "hash", "fast"),
+	HExpireAtCommand:    keySpec(1, 1, "write", "hash", "fast"),
+	HPExpireAtCommand:   keySpec(1, 1, "write", "hash", "fast"),
+	HTTLCommand:         keySpec(1, 1, "read", "hash", "fast"),
+	HPTTLCommand:        keySpec(1, 1, "read", "hash", "fast"),
+	HPersistCommand:     keySpec(1, 1, "write", "hash", "fast"),
+
+	SAddCommand:        denyOOM(keySpec(1, 1, "write", "set", "fast")),
+	SRemCommand:        keySpec(1, 1, "write", "set", "fast"),
+	SMembersCommand:    keySpec(1, 1, "read", "set", "slow"),
+	SIsMemberCommand:   keySpec(1, 1, "read", "set", "fast"),
+	SMIsMemberCommand:  keySpec(1, 1, "read", "set", "fast"),
+	SCardCommand:       keySpec(1, 1, "read", "set", "fast"),
+	SPopCommand:        keySpec(1, 1, "write", "set", "fast"),
+	SRandMemberCommand: keySpec(1, 1, "read", "set", "slow"),
+	SInterCommand:      keySpec(1, -1, "read", "set", "slow"),
+	SUnionCommand:      keySpec(1, -1, "read", "set", "slow"),
+	SDiffCommand:       keySpec(1, -1, "read", "set", "slow"),
+	SInterStoreCommand: denyOOM(keySpec(1, -1, "write", "set", "slow")),
+	SUnionStoreCommand: denyOOM(keySpec(1, -1, "write", "set", "slow")),
+	SDiffStoreCommand:  denyOOM(keySpec(1, -1, "write", "set", "slow")),
+	SInterCardCommand:  numKeysSpec(0, 1, "read", "set", "slow"),
+	SMoveCommand:       denyOOM(keySpec(1, 2, "write", "set", "fast")),
+
+	ZAddCommand:             denyOOM(keySpec(1, 1, "write", "sortedset", "fast")),
+	ZRemCommand:             keySpec(1, 1, "write", "sortedset", "fast"),
+	ZScoreCommand:           keySpec(1, 1, "read", "sortedset", "fast"),
+	ZIncrByCommand:          denyOOM(keySpec(1, 1, "write", "sortedset", "fast")),
+	ZCardCommand:            keySpec(1, 1, "read", "sortedset", "fast"),
+	ZCountCommand:           keySpec(1, 1, "read", "sortedset", "fast"),
+	ZRankCommand:            keySpec(1, 1, "read", "sortedset", "fast"),
+	ZRevRankCommand:         keySpec(1, 1, "read", "sortedset", "fast"),
+	ZRangeCommand:           keySpec(1, 1, "read", "sortedset", "slow"),
+	ZRangeStoreCommand:      denyOOM(keySpec(1, 2, "write", "sortedset", "slow")),
+	ZPopMinCommand:          keySpec(1, 1, "write", "sortedset", "fast"),
+	ZPopMaxCommand:          keySpec(1, 1, "write", "sortedset", "fast"),
+	ZRemRangeByRankCommand:  keySpec(1, 1, "write", "sortedset", "slow"),
+	ZRemRangeByScoreCommand: keySpec(1, 1, "write", "sortedset", "slow"),
+	ZRemRangeByLexCommand:   keySpec(1, 1, "write", "sortedset", "slow"),
+	ZUnionStoreCommand:      denyOOM(numKeysSpec(1, 2, "write", "sortedset", "slow")),
+	ZInterStoreCommand:      denyOOM(numKeysSpec(1, 2, "write", "sortedset", "slow")),
+
+	ClientCommand:                       noKeysSpec("slow", "connection"),
+	ClientCommand + "|" + clientList:    noKeysSpec("admin", "slow", "dangerous", "connection"),
+	ClientCommand + "|" + clientKill:    noKeysSpec("admin", "slow", "dangerous", "connection"),
+	ClientCommand + "|" + clientPause:   noKeysSpec("admin", "slow", "dangerous", "connection"),
+	ClientCommand + "|" + clientUnpause: noKeysSpec("admin", "slow", "dangerous", "connection"),
+	ClientCommand + "|" + clientNoEvict: noKeysSpec("admin", "slow", "dangerous", "connection"),
+
+	MemoryCommand:                       noKeysSpec("slow"),
+	MemoryCommand + "|" + memoryUsage:   keySpec(2, 2, "read", "slow"),
+	MemoryCommand + "|" + memoryBigKeys: noKeysSpec("keyspace", "read", "slow", "dangerous"),
+	MemoryCommand + "|" + memoryMemKeys: noKeysSpec("keyspace", "read", "slow", "dangerous"),
+
+	InfoCommand:    noKeysSpec("slow", "dangerous"),
+	SlowLogCommand: noKeysSpec("admin", "slow", "dangerous"),
+	MonitorCommand: noKeysSpec("admin", "slow", "dangerous"),
+	LatencyCommand: noKeysSpec("admin", "slow", "dangerous"),
+	ConfigCommand:  noKeysSpec("admin", "slow", "dangerous"),
+
+	ACLCommand:                   noKeysSpec("admin", "slow", "dangerous"),
+	ACLCommand + "|" + aclWhoAmI: noKeysSpec("slow"),
+	ACLCommand + "|" + aclCat:    noKeysSpec("slow"),
+}
+
+// commandsWith returns names of commands which spec matches
+func commandsWith(match func(spec *app.CommandSpec) bool) map[string]bool {
+	names := make(map[string]bool)
+	for name, spec := range commandSpecs {
+		if !strings.Contains(name, "|") && match(&spec) {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// BindAllACLHandlers describes all commands for ACL and binds ACL command
+func BindAllACLHandlers(app *app.App) {
+	for name, spec := range commandSpecs {
+		app.DescribeCommand(name, spec)
+	}
+	BindACL(app)
+}
+
+// BindACL binds ACL command that manages users and their permissions
+func BindACL(app *app.App) {
+	app.Bind(ACLCommand, aclCmd)
+}
+
+func aclCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 1 {
+		w.WriteArityError(cmd.Cmd)
+		w.Flush()
+		return nil
+	}
+
+	args := make([]string, 0, l-1)
+	for _, arg := range cmd.Args[1:] {
+		args = append(args, string(arg.BulkString()))
+	}
+
+	switch strings.ToLower(string(cmd.Args[0].BulkString())) {
+	case aclSetUser:
+		if len(args) < 1 {
+			w.WriteArityError(cmd.Cmd)
+		} else if err := context.App.ACLSetUser(args[0], args[1:]...); err != nil {
+			w.WriteErrorString(err.Error())
+		} else {
+			w.WriteOK()
+		}
+	case aclGetUser:
+		if len(args) != 1 {
+			w.WriteArityError(cmd.Cmd)
+		} else if user := context.App.ACLGetUser(args[0]); user == nil {
+			context.WriteReply(w, nil)
+		} else {
+			context.WriteReply(w, user)
+		}
+	case aclDelUser:
+		if len(args) < 1 {
+			w.WriteArityError(cmd.Cmd)
+		} else if n, err := context.App.ACLDelUser(context, args...); err != nil {
+			w.WriteErrorString(err.Error())
+		} else {
+			w.WriteInteger(n)
+		}
+	case aclList:
+		context.WriteReply(w, context.App.ACLList())
+	case aclUsers:
+		context.WriteReply(w, context.App.ACLUsers())
+	case aclWhoAmI:
+		w.WriteBulkString([]byte(context.User()))
+	case aclCat:
+		if len(args) > 1 {
+			w.WriteArityError(cmd.Cmd)
+		} else if cmds, err := context.App.ACLCat(args...); err != nil {
+			w.WriteErrorString(err.Error())
+		} else {
+			context.WriteReply(w, cmds)
+		}
+	case aclLog:
+		aclLogCmd(context, cmd, args, w)
+	case aclLoad:
+		if err := context.App.ACLLoad(context); err != nil {
+			w.WriteErrorString(err.Error())
+		} else {
+			w.WriteOK()
+		}
+	case aclSave:
+		if err := context.App.ACLSave(); err != nil {
+			w.WriteErrorString(err.Error())
+		} else {
+			w.WriteOK()
+		}
+	default:
+		w.WriteErrorString("ERR unknown subcommand or wrong number of arguments for ACL")
+	}
+	w.Flush()
+	return nil
+}
+
+func aclLogCmd(context *app.ClientContext, cmd *cmd.Command, args []string, w *resp.Writer) {
+	switch {
+	case len(args) > 1:
+		w.WriteArityError(cmd.Cmd)
+	case len(args) == 0:
+		context.WriteReply(w, context.App.ACLLog(aclLogDefaultCount))
+	case strings.ToLower(args[0]) == aclLogReset:
+		context.App.ACLLogReset()
+		w.WriteOK()
+	default:
+		count, err := strconv.Atoi(args[0])
+		if err != nil || count < 0 {
+			w.WriteErrorString("ERR value is out of range, must be positive")
+		} else {
+			context.WriteReply(w, context.App.ACLLog(count))
+		}
+	}
+}
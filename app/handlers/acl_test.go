@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCommandSets(t *testing.T) {
+	RegisterTestingT(t)
+
+	// commands taking memory change keys so they are suspended by CLIENT PAUSE WRITE as well
+	Expect(denyOOMCommands).To(HaveLen(27))
+	for name := range denyOOMCommands {
+		Expect(writeCommands).To(HaveKey(name))
+	}
+
+	for _, name := range []string{SetCommand, DelCommand, FlushAllCommand, SwapDBCommand, BLPopCommand, SPopCommand, ZRemCommand, HPersistCommand} {
+		Expect(writeCommands).To(HaveKey(name))
+	}
+	for _, name := range []string{GetCommand, ExistsCommand, TypeCommand, LRangeCommand, ClientCommand, ConfigCommand, MemoryCommand} {
+		Expect(writeCommands).NotTo(HaveKey(name))
+	}
+
+	for _, name := range []string{SetCommand, CopyCommand, LPushCommand, BLMoveCommand, HIncrByFloatCommand, SMoveCommand, ZRangeStoreCommand} {
+		Expect(denyOOMCommands).To(HaveKey(name))
+	}
+	// commands removing keys or elements never take more memory
+	for _, name := range []string{DelCommand, UnlinkCommand, FlushDBCommand, LPopCommand, BLPopCommand, SRemCommand, ZPopMinCommand, HDelCommand} {
+		Expect(denyOOMCommands).NotTo(HaveKey(name))
+	}
+}
@@ -7,4 +7,10 @@ func BindAllHanlders(app *app.App) {
 	BindAllKVHandlers(app)
 	BindAllKVListHandlers(app)
 	BindAllKVDictHandlers(app)
+	BindAllKVSetHandlers(app)
+	BindAllKVZSetHandlers(app)
+	BindAllClientHandlers(app)
+	BindAllMemoryHandlers(app)
+	BindAllServerHandlers(app)
+	BindAllACLHandlers(app)
 }
@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
 
 	"github.com/valery-barysok/gredisd/app"
 	"github.com/valery-barysok/gredisd/app/cmd"
@@ -12,20 +15,35 @@ import (
 // List of basic commands.
 const (
 	AuthCommand     = "auth"
+	HelloCommand    = "hello"
 	SelectCommand   = "select"
 	EchoCommand     = "echo"
 	PingCommand     = "ping"
 	ShutdownCommand = "shutdown"
 	// TODO: use custom name "COMMANDS" instead of "COMMAND" due to incompatibility with redis-cli
-	CommandCommand = "commands"
-	KeysCommand    = "keys"
-	ExistsCommand  = "exists"
-	ExpireCommand  = "expire"
+	CommandCommand   = "commands"
+	KeysCommand      = "keys"
+	ExistsCommand    = "exists"
+	ExpireCommand    = "expire"
+	ObjectCommand    = "object"
+	TypeCommand      = "type"
+	RenameCommand    = "rename"
+	RenameNXCommand  = "renamenx"
+	RandomKeyCommand = "randomkey"
+	DBSizeCommand    = "dbsize"
+	CopyCommand      = "copy"
+	TouchCommand     = "touch"
+	UnlinkCommand    = "unlink"
+	FlushDBCommand   = "flushdb"
+	FlushAllCommand  = "flushall"
+	MoveCommand      = "move"
+	SwapDBCommand    = "swapdb"
 )
 
 // BindAllBasicHandlers binds all basic commands at once
 func BindAllBasicHandlers(app *app.App) {
 	BindAuth(app)
+	BindHello(app)
 	BindSelect(app)
 	BindEcho(app)
 	BindPing(app)
@@ -34,17 +52,35 @@ func BindAllBasicHandlers(app *app.App) {
 	BindKeys(app)
 	BindExists(app)
 	BindExpire(app)
+	BindObject(app)
+	BindType(app)
+	BindRename(app)
+	BindRenameNX(app)
+	BindRandomKey(app)
+	BindDBSize(app)
+	BindCopy(app)
+	BindTouch(app)
+	BindUnlink(app)
+	BindFlushDB(app)
+	BindFlushAll(app)
+	BindMove(app)
+	BindSwapDB(app)
 
 	BindNotFound(app)
 	BindError(app)
 }
 
-// BindAuth binds auth command and required auth filter
+// BindAuth binds auth command and filter requiring authentication and checking ACL of user
 func BindAuth(app *app.App) {
 	app.BindFilter(authFilter)
 	app.Bind(AuthCommand, authCmd)
 }
 
+// BindHello binds Hello command that negotiates protocol, authenticates and names client at once
+func BindHello(app *app.App) {
+	app.Bind(HelloCommand, helloCmd)
+}
+
 // BindSelect binds Select command that select current database for specified client
 func BindSelect(app *app.App) {
 	app.Bind(SelectCommand, selectCmd)
@@ -82,6 +118,65 @@ func BindExpire(app *app.App) {
 	app.Bind(ExpireCommand, expireCmd)
 }
 
+// BindObject binds Object command that inspects internals of values
+func BindObject(app *app.App) {
+	app.Bind(ObjectCommand, objectCmd)
+}
+
+func BindType(app *app.App) {
+	app.Bind(TypeCommand, typeCmd)
+}
+
+func BindRename(app *app.App) {
+	app.Bind(RenameCommand, renameCmd)
+}
+
+func BindRenameNX(app *app.App) {
+	app.Bind(RenameNXCommand, renamenxCmd)
+}
+
+func BindRandomKey(app *app.App) {
+	app.Bind(RandomKeyCommand, randomkeyCmd)
+}
+
+func BindDBSize(app *app.App) {
+	app.Bind(DBSizeCommand, dbsizeCmd)
+}
+
+// BindCopy binds Copy command that copies value within database or to another database
+func BindCopy(app *app.App) {
+	app.Bind(CopyCommand, copyCmd)
+}
+
+func BindTouch(app *app.App) {
+	app.Bind(TouchCommand, touchCmd)
+}
+
+// BindUnlink binds Unlink command that removes keys like Del command does
+func BindUnlink(app *app.App) {
+	app.Bind(UnlinkCommand, unlinkCmd)
+}
+
+// BindFlushDB binds FlushDB command that removes all keys of current database
+func BindFlushDB(app *app.App) {
+	app.Bind(FlushDBCommand, flushdbCmd)
+}
+
+// BindFlushAll binds FlushAll command that removes all keys of all databases
+func BindFlushAll(app *app.App) {
+	app.Bind(FlushAllCommand, flushallCmd)
+}
+
+// BindMove binds Move command that moves key to another database
+func BindMove(app *app.App) {
+	app.Bind(MoveCommand, moveCmd)
+}
+
+// BindSwapDB binds SwapDB command that swaps two databases
+func BindSwapDB(app *app.App) {
+	app.Bind(SwapDBCommand, swapdbCmd)
+}
+
 // BindNotFound binds handler for handling all unknown commands
 func BindNotFound(appl *app.App) {
 	appl.BindNotFound(func(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
@@ -100,30 +195,126 @@ func BindError(appl *app.App) {
 }
 
 func authFilter(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) (bool, error) {
-	if cmd.Cmd != AuthCommand && context.RequireAuth {
+	if cmd.Cmd == AuthCommand || cmd.Cmd == HelloCommand {
+		return false, nil
+	}
+	if context.RequireAuth {
 		res.WriteErrorString("NOAUTH Authentication required.")
 		res.Flush()
 		return true, nil
 	}
+	if err := context.CheckACL(cmd); err != nil {
+		res.WriteErrorString(err.Error())
+		res.Flush()
+		return true, nil
+	}
 	return false, nil
 }
 
 func authCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
 	l := len(cmd.Args)
-	if l != 1 {
+	if l != 1 && l != 2 {
 		res.WriteArityError(cmd.Cmd)
 	} else {
-		context.RequireAuth = !context.App.Auth(string(cmd.Args[0].BulkString()))
-		if context.RequireAuth {
-			res.WriteErrorString("ERR invalid password")
-		} else {
+		user := app.DefaultUser
+		if l == 2 {
+			user = string(cmd.Args[0].BulkString())
+		}
+		if context.Auth(user, string(cmd.Args[l-1].BulkString())) {
 			res.WriteOK()
+		} else {
+			res.WriteErrorString(errWrongPass.Error())
 		}
 	}
 	res.Flush()
 	return nil
 }
 
+var (
+	errWrongPass   = errors.New("WRONGPASS invalid username-password pair or user is disabled.")
+	errHelloNoAuth = errors.New("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+)
+
+// helloArgs are arguments of HELLO command
+type helloArgs struct {
+	protocol   int
+	auth       bool
+	user, pass string
+	setName    bool
+	name       string
+}
+
+func parseHello(protocol int, args []*resp.Message) (*helloArgs, error) {
+	hello := &helloArgs{protocol: protocol}
+	if len(args) == 0 {
+		return hello, nil
+	}
+
+	ver, err := strconv.Atoi(string(args[0].BulkString()))
+	if err != nil {
+		return nil, errors.New("ERR Protocol version is not an integer or out of range")
+	}
+	if ver != app.RESP2 && ver != app.RESP3 {
+		return nil, errors.New("NOPROTO unsupported protocol version")
+	}
+	hello.protocol = ver
+
+	for i := 1; i < len(args); i++ {
+		opt := strings.ToLower(string(args[i].BulkString()))
+		if opt == "auth" && i+2 < len(args) {
+			hello.auth = true
+			hello.user, hello.pass = string(args[i+1].BulkString()), string(args[i+2].BulkString())
+			i += 2
+		} else if opt == "setname" && i+1 < len(args) {
+			hello.setName = true
+			hello.name = string(args[i+1].BulkString())
+			i++
+		} else {
+			return nil, fmt.Errorf("ERR Syntax error in HELLO option '%s'", args[i].BulkString())
+		}
+	}
+	return hello, nil
+}
+
+// setClientName names client if SETNAME option is given
+func (hello *helloArgs) setClientName(context *app.ClientContext) error {
+	if !hello.setName {
+		return nil
+	}
+	return context.SetName(hello.name)
+}
+
+// hello authenticates, names client and switches its protocol as requested by
+// HELLO arguments. It returns reply describing server and connection
+func hello(context *app.ClientContext, args []*resp.Message) (app.Map, error) {
+	hello, err := parseHello(context.Protocol(), args)
+	if err != nil {
+		return nil, err
+	}
+	if hello.auth && !context.Auth(hello.user, hello.pass) {
+		return nil, errWrongPass
+	}
+	if context.RequireAuth {
+		return nil, errHelloNoAuth
+	}
+	if err = hello.setClientName(context); err != nil {
+		return nil, err
+	}
+	context.SetProtocol(hello.protocol)
+	return context.Hello(), nil
+}
+
+func helloCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	reply, err := hello(context, cmd.Args)
+	if err != nil {
+		res.WriteErrorString(err.Error())
+	} else {
+		context.WriteReply(res, reply)
+	}
+	res.Flush()
+	return nil
+}
+
 func selectCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
 	l := len(cmd.Args)
 	if l != 1 {
@@ -133,7 +324,7 @@ func selectCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) e
 		if err != nil {
 			res.WriteErrorString(err.Error())
 		} else {
-			context.DB = db
+			context.SetDB(db)
 			res.WriteOK()
 		}
 	}
@@ -172,7 +363,7 @@ func shutdownCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer)
 
 func commandCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
 	commands := context.App.Commands()
-	res.WriteArray(commands)
+	context.WriteReply(res, commands)
 	res.Flush()
 	return nil
 }
@@ -186,7 +377,7 @@ func keysCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) err
 		if err != nil {
 			res.WriteError(err)
 		} else {
-			res.WriteArray(keys)
+			context.WriteReply(res, keys)
 		}
 	}
 	res.Flush()
@@ -224,3 +415,183 @@ func expireCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) e
 	res.Flush()
 	return nil
 }
+
+func objectCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 2 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		enc, err := context.DB.Object(cmd.Args[0].BulkString(), cmd.Args[1].BulkString())
+		writeBulkOrNil(context, res, enc, err)
+	}
+	res.Flush()
+	return nil
+}
+
+func typeCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 1 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		context.WriteStatus(res, context.DB.Type(cmd.Args[0].BulkString()))
+	}
+	res.Flush()
+	return nil
+}
+
+func renameCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 2 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		err := context.DB.Rename(cmd.Args[0].BulkString(), cmd.Args[1].BulkString())
+		if err != nil {
+			res.WriteError(err)
+		} else {
+			res.WriteOK()
+		}
+	}
+	res.Flush()
+	return nil
+}
+
+func renamenxCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 2 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		ok, err := context.DB.RenameNX(cmd.Args[0].BulkString(), cmd.Args[1].BulkString())
+		if err != nil {
+			res.WriteError(err)
+		} else {
+			res.WriteInteger(ok)
+		}
+	}
+	res.Flush()
+	return nil
+}
+
+func randomkeyCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 0 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		writeBulkOrNil(context, res, context.DB.RandomKey(), nil)
+	}
+	res.Flush()
+	return nil
+}
+
+func dbsizeCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 0 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		res.WriteInteger(context.DB.DBSize())
+	}
+	res.Flush()
+	return nil
+}
+
+func copyCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 2 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		args := bulkStrings(cmd.Args)
+		ok, err := context.DB.Copy(args[0], args[1], args[2:]...)
+		if err != nil {
+			res.WriteError(err)
+		} else {
+			res.WriteInteger(ok)
+		}
+	}
+	res.Flush()
+	return nil
+}
+
+func touchCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 1 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		res.WriteInteger(context.DB.Touch(bulkStrings(cmd.Args)...))
+	}
+	res.Flush()
+	return nil
+}
+
+func unlinkCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 1 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		res.WriteInteger(context.DB.Unlink(bulkStrings(cmd.Args)...))
+	}
+	res.Flush()
+	return nil
+}
+
+func flushdbCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l > 1 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		err := context.DB.FlushDB(bulkStrings(cmd.Args)...)
+		if err != nil {
+			res.WriteError(err)
+		} else {
+			res.WriteOK()
+		}
+	}
+	res.Flush()
+	return nil
+}
+
+func flushallCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l > 1 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		err := context.App.FlushAll(bulkStrings(cmd.Args)...)
+		if err != nil {
+			res.WriteError(err)
+		} else {
+			res.WriteOK()
+		}
+	}
+	res.Flush()
+	return nil
+}
+
+func moveCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 2 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		ok, err := context.DB.Move(cmd.Args[0].BulkString(), cmd.Args[1].BulkString())
+		if err != nil {
+			res.WriteError(err)
+		} else {
+			res.WriteInteger(ok)
+		}
+	}
+	res.Flush()
+	return nil
+}
+
+func swapdbCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 2 {
+		res.WriteArityError(cmd.Cmd)
+	} else {
+		err := context.App.SwapDB(string(cmd.Args[0].BulkString()), string(cmd.Args[1].BulkString()))
+		if err != nil {
+			res.WriteErrorString(err.Error())
+		} else {
+			res.WriteOK()
+		}
+	}
+	res.Flush()
+	return nil
+}
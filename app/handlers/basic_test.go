@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/valery-barysok/gredisd/app"
+	"github.com/valery-barysok/resp"
+
+	. "github.com/onsi/gomega"
+)
+
+func messages(args ...string) []*resp.Message {
+	msgs := make([]*resp.Message, len(args))
+	for i, arg := range args {
+		msgs[i] = &resp.Message{Value: []byte(arg)}
+	}
+	return msgs
+}
+
+func newTestContext(a *app.App) *app.ClientContext {
+	db, _ := a.SelectIndex(0)
+	return &app.ClientContext{App: a, DB: db, RequireAuth: a.RequireAuth()}
+}
+
+func TestHello(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := app.DefaultOptions()
+	a := app.NewApp(&opts)
+	context := newTestContext(a)
+
+	// without arguments protocol is kept
+	reply, err := hello(context, nil)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(reply[4:6]).To(Equal(app.Map{[]byte("proto"), app.RESP2}))
+
+	reply, err = hello(context, messages("3", "setname", "worker"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(reply[4:6]).To(Equal(app.Map{[]byte("proto"), app.RESP3}))
+	Expect(context.Protocol()).To(Equal(app.RESP3))
+
+	reply, err = hello(context, messages("2"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(reply[4:6]).To(Equal(app.Map{[]byte("proto"), app.RESP2}))
+	Expect(context.Protocol()).To(Equal(app.RESP2))
+
+	// failed HELLO keeps protocol
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"4"}, "NOPROTO unsupported protocol version"},
+		{[]string{"1"}, "NOPROTO unsupported protocol version"},
+		{[]string{"three"}, "ERR Protocol version is not an integer or out of range"},
+		{[]string{"3", "auth", "default"}, "ERR Syntax error in HELLO option 'auth'"},
+		{[]string{"3", "setname"}, "ERR Syntax error in HELLO option 'setname'"},
+		{[]string{"3", "unknown"}, "ERR Syntax error in HELLO option 'unknown'"},
+		{[]string{"3", "setname", "bad name"}, "ERR Client names cannot contain spaces, newlines or special characters."},
+	}
+	for _, test := range tests {
+		_, err := hello(context, messages(test.args...))
+		Expect(err).To(MatchError(test.err), "%v", test.args)
+		Expect(context.Protocol()).To(Equal(app.RESP2), "%v", test.args)
+	}
+}
+
+func TestHelloAuth(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := app.DefaultOptions()
+	a := app.NewApp(&opts)
+	Expect(a.ConfigSet("requirepass", "secret")).To(Succeed())
+	Expect(a.ACLSetUser("alice", "on", ">pass", "~*", "+@all")).To(Succeed())
+	context := newTestContext(a)
+	Expect(context.RequireAuth).To(BeTrue())
+
+	_, err := hello(context, messages("3"))
+	Expect(err).To(Equal(errHelloNoAuth))
+	Expect(context.Protocol()).To(Equal(app.RESP2))
+
+	_, err = hello(context, messages("3", "AUTH", "default", "wrong"))
+	Expect(err).To(Equal(errWrongPass))
+	Expect(context.RequireAuth).To(BeTrue())
+	Expect(context.Protocol()).To(Equal(app.RESP2))
+
+	_, err = hello(context, messages("3", "AUTH", "alice", "secret"))
+	Expect(err).To(Equal(errWrongPass))
+
+	_, err = hello(context, messages("3", "AUTH", "default", "secret"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(context.RequireAuth).To(BeFalse())
+	Expect(context.Protocol()).To(Equal(app.RESP3))
+
+	_, err = hello(context, messages("2", "auth", "alice", "pass"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(context.User()).To(Equal("alice"))
+	Expect(context.Protocol()).To(Equal(app.RESP2))
+
+	// failed inline AUTH deauthenticates client like AUTH does
+	_, err = hello(context, messages("3", "auth", "alice", "wrong"))
+	Expect(err).To(Equal(errWrongPass))
+	Expect(context.RequireAuth).To(BeTrue())
+	_, err = hello(context, nil)
+	Expect(err).To(Equal(errHelloNoAuth))
+}
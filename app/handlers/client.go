@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/valery-barysok/gredisd/app"
+	"github.com/valery-barysok/gredisd/app/cmd"
+	"github.com/valery-barysok/resp"
+)
+
+// List of client commands.
+const (
+	ClientCommand = "client"
+)
+
+// Subcommands of Client command
+const (
+	clientID       = "id"
+	clientInfo     = "info"
+	clientList     = "list"
+	clientSetName  = "setname"
+	clientGetName  = "getname"
+	clientKill     = "kill"
+	clientPause    = "pause"
+	clientUnpause  = "unpause"
+	clientNoEvict  = "no-evict"
+	clientPauseAll = "all"
+	clientPauseWr  = "write"
+)
+
+// writeCommands are commands which may change keys. They are suspended by CLIENT PAUSE WRITE
+var writeCommands = commandsWith(func(spec *app.CommandSpec) bool { return spec.InCategory("write") })
+
+// BindAllClientHandlers binds all client management commands at once
+func BindAllClientHandlers(app *app.App) {
+	BindClientPause(app)
+	BindClient(app)
+}
+
+// BindClient binds Client command that inspects and manages client connections
+func BindClient(app *app.App) {
+	app.Bind(ClientCommand, clientCmd)
+}
+
+// BindClientPause binds filter which suspends commands while clients are paused
+// by CLIENT PAUSE. Client command is never suspended so clients can be unpaused
+func BindClientPause(app *app.App) {
+	app.BindFilter(clientPauseFilter)
+}
+
+func clientPauseFilter(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) (bool, error) {
+	if cmd.Cmd != ClientCommand {
+		context.App.WaitUnpaused(writeCommands[cmd.Cmd])
+	}
+	return false, nil
+}
+
+func clientCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 1 {
+		w.WriteArityError(cmd.Cmd)
+		w.Flush()
+		return nil
+	}
+
+	args := bulkStrings(cmd.Args[1:])
+	switch strings.ToLower(string(cmd.Args[0].BulkString())) {
+	case clientID:
+		if len(args) != 0 {
+			w.WriteArityError(cmd.Cmd)
+		} else {
+			w.WriteInteger(int(context.ID()))
+		}
+	case clientInfo:
+		if len(args) != 0 {
+			w.WriteArityError(cmd.Cmd)
+		} else {
+			context.WriteReply(w, app.Verbatim{Format: "txt", Text: []byte(context.ClientInfo())})
+		}
+	case clientList:
+		list, err := context.App.ClientList(args...)
+		if err != nil {
+			w.WriteErrorString(err.Error())
+		} else {
+			context.WriteReply(w, app.Verbatim{Format: "txt", Text: []byte(list)})
+		}
+	case clientSetName:
+		if len(args) != 1 {
+			w.WriteArityError(cmd.Cmd)
+		} else if err := context.SetName(string(args[0])); err != nil {
+			w.WriteErrorString(err.Error())
+		} else {
+			w.WriteOK()
+		}
+	case clientGetName:
+		if len(args) != 0 {
+			w.WriteArityError(cmd.Cmd)
+		} else if name := context.Name(); name == "" {
+			context.WriteReply(w, nil)
+		} else {
+			w.WriteBulkString([]byte(name))
+		}
+	case clientKill:
+		switch len(args) {
+		case 0:
+			w.WriteArityError(cmd.Cmd)
+		case 1:
+			if err := context.KillAddr(string(args[0])); err != nil {
+				w.WriteErrorString(err.Error())
+			} else {
+				w.WriteOK()
+			}
+		default:
+			killed, err := context.KillClients(args...)
+			if err != nil {
+				w.WriteErrorString(err.Error())
+			} else {
+				w.WriteInteger(killed)
+			}
+		}
+	case clientPause:
+		if len(args) != 1 && len(args) != 2 {
+			w.WriteArityError(cmd.Cmd)
+			break
+		}
+
+		all := true
+		if len(args) == 2 {
+			switch strings.ToLower(string(args[1])) {
+			case clientPauseAll:
+			case clientPauseWr:
+				all = false
+			default:
+				w.WriteErrorString("ERR syntax error")
+				w.Flush()
+				return nil
+			}
+		}
+
+		if err := context.App.Pause(string(args[0]), all); err != nil {
+			w.WriteErrorString(err.Error())
+		} else {
+			w.WriteOK()
+		}
+	case clientUnpause:
+		if len(args) != 0 {
+			w.WriteArityError(cmd.Cmd)
+		} else {
+			context.App.Unpause()
+			w.WriteOK()
+		}
+	case clientNoEvict:
+		if len(args) != 1 {
+			w.WriteArityError(cmd.Cmd)
+			break
+		}
+
+		switch strings.ToLower(string(args[0])) {
+		case "on":
+			context.SetNoEvict(true)
+			w.WriteOK()
+		case "off":
+			context.SetNoEvict(false)
+			w.WriteOK()
+		default:
+			w.WriteErrorString("ERR syntax error")
+		}
+	default:
+		w.WriteErrorString("ERR unknown subcommand or wrong number of arguments for CLIENT")
+	}
+	w.Flush()
+	return nil
+}
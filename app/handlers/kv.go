@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"strings"
+
 	"github.com/valery-barysok/gredisd/app"
 	"github.com/valery-barysok/gredisd/app/cmd"
 	"github.com/valery-barysok/resp"
@@ -55,7 +57,7 @@ func getCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) erro
 		} else if val != nil {
 			res.WriteBulkString(val)
 		} else {
-			res.WriteNilBulk()
+			context.WriteReply(res, nil)
 		}
 	}
 	res.Flush()
@@ -78,3 +80,31 @@ func delCmd(context *app.ClientContext, cmd *cmd.Command, res *resp.Writer) erro
 	res.Flush()
 	return nil
 }
+
+func bulkStrings(args []*resp.Message) [][]byte {
+	values := make([][]byte, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.BulkString())
+	}
+	return values
+}
+
+// hasOption reports whether option is one of args, case insensitive
+func hasOption(args []*resp.Message, option string) bool {
+	for _, arg := range args {
+		if strings.EqualFold(string(arg.BulkString()), option) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeBulkOrNil(context *app.ClientContext, w *resp.Writer, value []byte, err error) {
+	if err != nil {
+		w.WriteError(err)
+	} else if value != nil {
+		w.WriteBulkString(value)
+	} else {
+		context.WriteReply(w, nil)
+	}
+}
@@ -3,35 +3,94 @@ package handlers
 import (
 	"github.com/valery-barysok/gredisd/app"
 	"github.com/valery-barysok/gredisd/app/cmd"
+	"github.com/valery-barysok/gredisd/app/model"
 	"github.com/valery-barysok/resp"
 )
 
 // List of key value dict commands.
 const (
-	HSetCommand    = "hset"
-	HGetCommand    = "hget"
-	HDelCommand    = "hdel"
-	HLenCommand    = "hlen"
-	HExistsCommand = "hexists"
+	HSetCommand         = "hset"
+	HSetNXCommand       = "hsetnx"
+	HMSetCommand        = "hmset"
+	HGetCommand         = "hget"
+	HMGetCommand        = "hmget"
+	HGetAllCommand      = "hgetall"
+	HKeysCommand        = "hkeys"
+	HValsCommand        = "hvals"
+	HDelCommand         = "hdel"
+	HLenCommand         = "hlen"
+	HExistsCommand      = "hexists"
+	HStrLenCommand      = "hstrlen"
+	HIncrByCommand      = "hincrby"
+	HIncrByFloatCommand = "hincrbyfloat"
+	HRandFieldCommand   = "hrandfield"
+	HExpireCommand      = "hexpire"
+	HPExpireCommand     = "hpexpire"
+	HExpireAtCommand    = "hexpireat"
+	HPExpireAtCommand   = "hpexpireat"
+	HTTLCommand         = "httl"
+	HPTTLCommand        = "hpttl"
+	HPersistCommand     = "hpersist"
 )
 
 // BindAllKVDictHandlers binds all key value dict commands at once
 func BindAllKVDictHandlers(app *app.App) {
 	BindHSet(app)
+	BindHSetNX(app)
+	BindHMSet(app)
 	BindHGet(app)
+	BindHMGet(app)
+	BindHGetAll(app)
+	BindHKeys(app)
+	BindHVals(app)
 	BindHDel(app)
 	BindHLen(app)
 	BindHExists(app)
+	BindHStrLen(app)
+	BindHIncrBy(app)
+	BindHIncrByFloat(app)
+	BindHRandField(app)
+	BindHExpire(app)
+	BindHPExpire(app)
+	BindHExpireAt(app)
+	BindHPExpireAt(app)
+	BindHTTL(app)
+	BindHPTTL(app)
+	BindHPersist(app)
 }
 
 func BindHSet(app *app.App) {
 	app.Bind(HSetCommand, hSetCmd)
 }
 
+func BindHSetNX(app *app.App) {
+	app.Bind(HSetNXCommand, hSetNXCmd)
+}
+
+func BindHMSet(app *app.App) {
+	app.Bind(HMSetCommand, hMSetCmd)
+}
+
 func BindHGet(app *app.App) {
 	app.Bind(HGetCommand, hGetCmd)
 }
 
+func BindHMGet(app *app.App) {
+	app.Bind(HMGetCommand, hMGetCmd)
+}
+
+func BindHGetAll(app *app.App) {
+	app.Bind(HGetAllCommand, hGetAllCmd)
+}
+
+func BindHKeys(app *app.App) {
+	app.Bind(HKeysCommand, hKeysCmd)
+}
+
+func BindHVals(app *app.App) {
+	app.Bind(HValsCommand, hValsCmd)
+}
+
 func BindHDel(app *app.App) {
 	app.Bind(HDelCommand, hDelCmd)
 }
@@ -44,12 +103,76 @@ func BindHExists(app *app.App) {
 	app.Bind(HExistsCommand, hExistsCmd)
 }
 
+func BindHStrLen(app *app.App) {
+	app.Bind(HStrLenCommand, hStrLenCmd)
+}
+
+func BindHIncrBy(app *app.App) {
+	app.Bind(HIncrByCommand, hIncrByCmd)
+}
+
+func BindHIncrByFloat(app *app.App) {
+	app.Bind(HIncrByFloatCommand, hIncrByFloatCmd)
+}
+
+func BindHRandField(app *app.App) {
+	app.Bind(HRandFieldCommand, hRandFieldCmd)
+}
+
+func BindHExpire(app *app.App) {
+	app.Bind(HExpireCommand, hExpireCmd)
+}
+
+func BindHPExpire(app *app.App) {
+	app.Bind(HPExpireCommand, hPExpireCmd)
+}
+
+func BindHExpireAt(app *app.App) {
+	app.Bind(HExpireAtCommand, hExpireAtCmd)
+}
+
+func BindHPExpireAt(app *app.App) {
+	app.Bind(HPExpireAtCommand, hPExpireAtCmd)
+}
+
+func BindHTTL(app *app.App) {
+	app.Bind(HTTLCommand, hTTLCmd)
+}
+
+func BindHPTTL(app *app.App) {
+	app.Bind(HPTTLCommand, hPTTLCmd)
+}
+
+func BindHPersist(app *app.App) {
+	app.Bind(HPersistCommand, hPersistCmd)
+}
+
+type hGetAll func(db *model.DBModel, key []byte) (interface{}, error)
+type hExpire func(db *model.DBModel, key []byte, when []byte, args ...[]byte) ([]interface{}, error)
+type hFields func(db *model.DBModel, key []byte, args ...[]byte) ([]interface{}, error)
+
 func hSetCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 3 || l%2 == 0 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := context.DB.HSet(cmd.Args[0].BulkString(), bulkStrings(cmd.Args[1:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func hSetNXCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
 	l := len(cmd.Args)
 	if l != 3 {
 		w.WriteArityError(cmd.Cmd)
 	} else {
-		cnt, err := context.DB.HSet(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), cmd.Args[2].BulkString())
+		cnt, err := context.DB.HSetNX(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), cmd.Args[2].BulkString())
 		if err != nil {
 			w.WriteError(err)
 		} else {
@@ -60,6 +183,22 @@ func hSetCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error
 	return nil
 }
 
+func hMSetCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 3 || l%2 == 0 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		_, err := context.DB.HSet(cmd.Args[0].BulkString(), bulkStrings(cmd.Args[1:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteOK()
+		}
+	}
+	w.Flush()
+	return nil
+}
+
 func hGetCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
 	l := len(cmd.Args)
 	if l != 2 {
@@ -71,13 +210,64 @@ func hGetCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error
 		} else if val != nil {
 			w.WriteBulkString(val)
 		} else {
-			w.WriteNilBulk()
+			context.WriteReply(w, nil)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func hMGetCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		values, err := context.DB.HMGet(cmd.Args[0].BulkString(), bulkStrings(cmd.Args[1:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			context.WriteReply(w, values)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func hGetAllGenericCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, getAll hGetAll) error {
+	l := len(cmd.Args)
+	if l != 1 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		values, err := getAll(context.DB, cmd.Args[0].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			context.WriteReply(w, values)
 		}
 	}
 	w.Flush()
 	return nil
 }
 
+func hGetAllCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return hGetAllGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte) (interface{}, error) {
+		values, err := db.HGetAll(key)
+		return app.Map(values), err
+	})
+}
+
+func hKeysCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return hGetAllGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte) (interface{}, error) {
+		return db.HKeys(key)
+	})
+}
+
+func hValsCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return hGetAllGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte) (interface{}, error) {
+		return db.HVals(key)
+	})
+}
+
 func hDelCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
 	l := len(cmd.Args)
 	if l < 2 {
@@ -131,3 +321,140 @@ func hExistsCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) er
 	w.Flush()
 	return nil
 }
+
+func hStrLenCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		l, err := context.DB.HStrLen(cmd.Args[0].BulkString(), cmd.Args[1].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(l)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func hIncrByCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		val, err := context.DB.HIncrBy(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), cmd.Args[2].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(int(val))
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func hIncrByFloatCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		val, err := context.DB.HIncrByFloat(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), cmd.Args[2].BulkString())
+		writeBulkOrNil(context, w, val, err)
+	}
+	w.Flush()
+	return nil
+}
+
+func hRandFieldCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l == 1 {
+		field, err := context.DB.HRandField(cmd.Args[0].BulkString())
+		writeBulkOrNil(context, w, field, err)
+	} else if l == 2 || l == 3 {
+		values, err := context.DB.HRandFieldCount(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), bulkStrings(cmd.Args[2:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			context.WriteReply(w, values)
+		}
+	} else {
+		w.WriteArityError(cmd.Cmd)
+	}
+	w.Flush()
+	return nil
+}
+
+func hExpireGenericCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, expire hExpire) error {
+	l := len(cmd.Args)
+	if l < 5 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		res, err := expire(context.DB, cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), bulkStrings(cmd.Args[2:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			context.WriteReply(w, res)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func hExpireCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return hExpireGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, when []byte, args ...[]byte) ([]interface{}, error) {
+		return db.HExpire(key, when, args...)
+	})
+}
+
+func hPExpireCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return hExpireGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, when []byte, args ...[]byte) ([]interface{}, error) {
+		return db.HPExpire(key, when, args...)
+	})
+}
+
+func hExpireAtCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return hExpireGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, when []byte, args ...[]byte) ([]interface{}, error) {
+		return db.HExpireAt(key, when, args...)
+	})
+}
+
+func hPExpireAtCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return hExpireGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, when []byte, args ...[]byte) ([]interface{}, error) {
+		return db.HPExpireAt(key, when, args...)
+	})
+}
+
+func hFieldsGenericCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, fields hFields) error {
+	l := len(cmd.Args)
+	if l < 4 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		res, err := fields(context.DB, cmd.Args[0].BulkString(), bulkStrings(cmd.Args[1:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			context.WriteReply(w, res)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func hTTLCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return hFieldsGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, args ...[]byte) ([]interface{}, error) {
+		return db.HTTL(key, args...)
+	})
+}
+
+func hPTTLCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return hFieldsGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, args ...[]byte) ([]interface{}, error) {
+		return db.HPTTL(key, args...)
+	})
+}
+
+func hPersistCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return hFieldsGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, args ...[]byte) ([]interface{}, error) {
+		return db.HPersist(key, args...)
+	})
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+
 	"github.com/valery-barysok/gredisd/app"
 	"github.com/valery-barysok/gredisd/app/cmd"
 	"github.com/valery-barysok/gredisd/app/model"
@@ -17,8 +19,25 @@ const (
 	LInsertCommand = "linsert"
 	LIndexCommand  = "lindex"
 	LRangeCommand  = "lrange"
+	LPushXCommand  = "lpushx"
+	RPushXCommand  = "rpushx"
+	LMPopCommand   = "lmpop"
+	LSetCommand    = "lset"
+	LRemCommand    = "lrem"
+	LTrimCommand   = "ltrim"
+	LPosCommand    = "lpos"
+	BLPopCommand   = "blpop"
+	BRPopCommand   = "brpop"
+	BLMPopCommand  = "blmpop"
+	BLMoveCommand  = "blmove"
+
+	LMoveCommand      = "lmove"
+	RPopLPushCommand  = "rpoplpush"
+	BRPopLPushCommand = "brpoplpush"
 )
 
+var errClientUnblocked = errors.New("Client has gone while blocked")
+
 // BindAllKVListHandlers binds all key value list commands at once
 func BindAllKVListHandlers(app *app.App) {
 	BindLPush(app)
@@ -29,6 +48,20 @@ func BindAllKVListHandlers(app *app.App) {
 	BindLInsert(app)
 	BindLIndex(app)
 	BindLRange(app)
+	BindLPushX(app)
+	BindRPushX(app)
+	BindLMPop(app)
+	BindLSet(app)
+	BindLRem(app)
+	BindLTrim(app)
+	BindLPos(app)
+	BindBLPop(app)
+	BindBRPop(app)
+	BindBLMPop(app)
+	BindBLMove(app)
+	BindLMove(app)
+	BindRPopLPush(app)
+	BindBRPopLPush(app)
 }
 
 func BindLPush(app *app.App) {
@@ -63,12 +96,70 @@ func BindLRange(app *app.App) {
 	app.Bind(LRangeCommand, lrangeCmd)
 }
 
+func BindLPushX(app *app.App) {
+	app.Bind(LPushXCommand, lpushxCmd)
+}
+
+func BindRPushX(app *app.App) {
+	app.Bind(RPushXCommand, rpushxCmd)
+}
+
+func BindLMPop(app *app.App) {
+	app.Bind(LMPopCommand, lmpopCmd)
+}
+
+func BindLSet(app *app.App) {
+	app.Bind(LSetCommand, lsetCmd)
+}
+
+func BindLRem(app *app.App) {
+	app.Bind(LRemCommand, lremCmd)
+}
+
+func BindLTrim(app *app.App) {
+	app.Bind(LTrimCommand, ltrimCmd)
+}
+
+func BindLPos(app *app.App) {
+	app.Bind(LPosCommand, lposCmd)
+}
+
+func BindBLPop(app *app.App) {
+	app.Bind(BLPopCommand, blpopCmd)
+}
+
+func BindBRPop(app *app.App) {
+	app.Bind(BRPopCommand, brpopCmd)
+}
+
+func BindBLMPop(app *app.App) {
+	app.Bind(BLMPopCommand, blmpopCmd)
+}
+
+func BindBLMove(app *app.App) {
+	app.Bind(BLMoveCommand, blmoveCmd)
+}
+
+func BindLMove(app *app.App) {
+	app.Bind(LMoveCommand, lmoveCmd)
+}
+
+func BindRPopLPush(app *app.App) {
+	app.Bind(RPopLPushCommand, rpoplpushCmd)
+}
+
+func BindBRPopLPush(app *app.App) {
+	app.Bind(BRPopLPushCommand, brpoplpushCmd)
+}
+
 type lrPush func(db *model.DBModel, key []byte, values ...[]byte) (int, error)
 type lrPop func(db *model.DBModel, key []byte) ([]byte, error)
+type lrPopCount func(db *model.DBModel, key []byte, count []byte) ([]interface{}, error)
+type blrPop func(db *model.DBModel, cancel <-chan struct{}, args ...[]byte) ([]interface{}, error)
 
 func lrpushCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, push lrPush) error {
 	l := len(cmd.Args)
-	if l < 1 {
+	if l < 2 {
 		w.WriteArityError(cmd.Cmd)
 	} else {
 		l := len(cmd.Args)
@@ -99,19 +190,22 @@ func rpushCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) erro
 	})
 }
 
-func lrpopCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, pop lrPop) error {
+func lrpopCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, pop lrPop, popCount lrPopCount) error {
 	l := len(cmd.Args)
-	if l != 1 {
-		w.WriteArityError(cmd.Cmd)
-	} else {
+	if l == 1 {
 		value, err := pop(context.DB, cmd.Args[0].BulkString())
+		writeBulkOrNil(context, w, value, err)
+	} else if l == 2 {
+		values, err := popCount(context.DB, cmd.Args[0].BulkString(), cmd.Args[1].BulkString())
 		if err != nil {
 			w.WriteError(err)
-		} else if value != nil {
-			w.WriteBulkString(value)
+		} else if values != nil {
+			context.WriteReply(w, values)
 		} else {
-			w.WriteNilBulk()
+			context.WriteNilArray(w)
 		}
+	} else {
+		w.WriteArityError(cmd.Cmd)
 	}
 	w.Flush()
 	return nil
@@ -120,12 +214,16 @@ func lrpopCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, pop
 func lpopCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
 	return lrpopCmd(context, cmd, w, func(db *model.DBModel, key []byte) ([]byte, error) {
 		return db.LPop(key)
+	}, func(db *model.DBModel, key []byte, count []byte) ([]interface{}, error) {
+		return db.LPopCount(key, count)
 	})
 }
 
 func rpopCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
 	return lrpopCmd(context, cmd, w, func(db *model.DBModel, key []byte) ([]byte, error) {
 		return db.RPop(key)
+	}, func(db *model.DBModel, key []byte, count []byte) ([]interface{}, error) {
+		return db.RPopCount(key, count)
 	})
 }
 
@@ -173,7 +271,7 @@ func lindexCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) err
 		} else if s != nil {
 			w.WriteBulkString(s)
 		} else {
-			w.WriteNilBulk()
+			context.WriteReply(w, nil)
 		}
 	}
 	w.Flush()
@@ -189,10 +287,251 @@ func lrangeCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) err
 		if err != nil {
 			w.WriteError(err)
 		} else if values != nil {
-			w.WriteArray(values)
+			context.WriteReply(w, values)
+		} else {
+			context.WriteReply(w, nil)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func lpushxCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return lrpushCmd(context, cmd, w, func(db *model.DBModel, key []byte, values ...[]byte) (int, error) {
+		return db.LPushX(key, values...)
+	})
+}
+
+func rpushxCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return lrpushCmd(context, cmd, w, func(db *model.DBModel, key []byte, values ...[]byte) (int, error) {
+		return db.RPushX(key, values...)
+	})
+}
+
+func lmpopCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		key, values, err := context.DB.LMPop(bulkStrings(cmd.Args)...)
+		if err != nil {
+			w.WriteError(err)
+		} else if key != nil {
+			context.WriteReply(w, []interface{}{key, values})
+		} else {
+			context.WriteNilArray(w)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func lsetCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		err := context.DB.LSet(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), cmd.Args[2].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteOK()
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func lremCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := context.DB.LRem(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), cmd.Args[2].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func ltrimCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		err := context.DB.LTrim(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), cmd.Args[2].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteOK()
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func lposCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		positions, withCount, err := context.DB.LPos(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), bulkStrings(cmd.Args[2:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else if withCount {
+			context.WriteReply(w, positions)
+		} else if len(positions) > 0 {
+			w.WriteInteger(positions[0].(int))
+		} else {
+			context.WriteReply(w, nil)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+// blocking runs blocking command. It returns error if client has gone while
+// command was blocked, so nothing should be written in reply
+func blocking(context *app.ClientContext, block func(cancel <-chan struct{})) error {
+	cancel, done := context.Block()
+	block(cancel)
+	done()
+
+	select {
+	case <-cancel:
+		return errClientUnblocked
+	default:
+		return nil
+	}
+}
+
+func blrpopCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, pop blrPop) error {
+	l := len(cmd.Args)
+	if l < 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		var values []interface{}
+		var err error
+		if e := blocking(context, func(cancel <-chan struct{}) {
+			values, err = pop(context.DB, cancel, bulkStrings(cmd.Args)...)
+		}); e != nil {
+			return e
+		}
+
+		if err != nil {
+			w.WriteError(err)
+		} else if values != nil {
+			context.WriteReply(w, values)
+		} else {
+			context.WriteNilArray(w)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func blpopCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return blrpopCmd(context, cmd, w, func(db *model.DBModel, cancel <-chan struct{}, args ...[]byte) ([]interface{}, error) {
+		return db.BLPop(cancel, args...)
+	})
+}
+
+func brpopCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return blrpopCmd(context, cmd, w, func(db *model.DBModel, cancel <-chan struct{}, args ...[]byte) ([]interface{}, error) {
+		return db.BRPop(cancel, args...)
+	})
+}
+
+func blmpopCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 4 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		var key []byte
+		var values []interface{}
+		var err error
+		if e := blocking(context, func(cancel <-chan struct{}) {
+			key, values, err = context.DB.BLMPop(cancel, bulkStrings(cmd.Args)...)
+		}); e != nil {
+			return e
+		}
+
+		if err != nil {
+			w.WriteError(err)
+		} else if key != nil {
+			context.WriteReply(w, []interface{}{key, values})
 		} else {
-			w.WriteNilBulk()
+			context.WriteNilArray(w)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func blmoveCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 5 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		var value []byte
+		var err error
+		if e := blocking(context, func(cancel <-chan struct{}) {
+			value, err = context.DB.BLMove(cancel, cmd.Args[0].BulkString(), cmd.Args[1].BulkString(),
+				cmd.Args[2].BulkString(), cmd.Args[3].BulkString(), cmd.Args[4].BulkString())
+		}); e != nil {
+			return e
 		}
+
+		writeBulkOrNil(context, w, value, err)
+	}
+	w.Flush()
+	return nil
+}
+
+func lmoveCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 4 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		value, err := context.DB.LMove(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(),
+			cmd.Args[2].BulkString(), cmd.Args[3].BulkString())
+		writeBulkOrNil(context, w, value, err)
+	}
+	w.Flush()
+	return nil
+}
+
+func rpoplpushCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		value, err := context.DB.RPopLPush(cmd.Args[0].BulkString(), cmd.Args[1].BulkString())
+		writeBulkOrNil(context, w, value, err)
+	}
+	w.Flush()
+	return nil
+}
+
+func brpoplpushCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		var value []byte
+		var err error
+		if e := blocking(context, func(cancel <-chan struct{}) {
+			value, err = context.DB.BRPopLPush(cancel, cmd.Args[0].BulkString(), cmd.Args[1].BulkString(),
+				cmd.Args[2].BulkString())
+		}); e != nil {
+			return e
+		}
+
+		writeBulkOrNil(context, w, value, err)
 	}
 	w.Flush()
 	return nil
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"github.com/valery-barysok/gredisd/app"
+	"github.com/valery-barysok/gredisd/app/cmd"
+	"github.com/valery-barysok/gredisd/app/model"
+	"github.com/valery-barysok/resp"
+)
+
+// List of key value set commands.
+const (
+	SAddCommand        = "sadd"
+	SRemCommand        = "srem"
+	SMembersCommand    = "smembers"
+	SIsMemberCommand   = "sismember"
+	SMIsMemberCommand  = "smismember"
+	SCardCommand       = "scard"
+	SPopCommand        = "spop"
+	SRandMemberCommand = "srandmember"
+	SInterCommand      = "sinter"
+	SUnionCommand      = "sunion"
+	SDiffCommand       = "sdiff"
+	SInterStoreCommand = "sinterstore"
+	SUnionStoreCommand = "sunionstore"
+	SDiffStoreCommand  = "sdiffstore"
+	SInterCardCommand  = "sintercard"
+	SMoveCommand       = "smove"
+)
+
+// BindAllKVSetHandlers binds all key value set commands at once
+func BindAllKVSetHandlers(app *app.App) {
+	BindSAdd(app)
+	BindSRem(app)
+	BindSMembers(app)
+	BindSIsMember(app)
+	BindSMIsMember(app)
+	BindSCard(app)
+	BindSPop(app)
+	BindSRandMember(app)
+	BindSInter(app)
+	BindSUnion(app)
+	BindSDiff(app)
+	BindSInterStore(app)
+	BindSUnionStore(app)
+	BindSDiffStore(app)
+	BindSInterCard(app)
+	BindSMove(app)
+}
+
+func BindSAdd(app *app.App) {
+	app.Bind(SAddCommand, saddCmd)
+}
+
+func BindSRem(app *app.App) {
+	app.Bind(SRemCommand, sremCmd)
+}
+
+func BindSMembers(app *app.App) {
+	app.Bind(SMembersCommand, smembersCmd)
+}
+
+func BindSIsMember(app *app.App) {
+	app.Bind(SIsMemberCommand, sismemberCmd)
+}
+
+func BindSMIsMember(app *app.App) {
+	app.Bind(SMIsMemberCommand, smismemberCmd)
+}
+
+func BindSCard(app *app.App) {
+	app.Bind(SCardCommand, scardCmd)
+}
+
+func BindSPop(app *app.App) {
+	app.Bind(SPopCommand, spopCmd)
+}
+
+func BindSRandMember(app *app.App) {
+	app.Bind(SRandMemberCommand, srandmemberCmd)
+}
+
+func BindSInter(app *app.App) {
+	app.Bind(SInterCommand, sinterCmd)
+}
+
+func BindSUnion(app *app.App) {
+	app.Bind(SUnionCommand, sunionCmd)
+}
+
+func BindSDiff(app *app.App) {
+	app.Bind(SDiffCommand, sdiffCmd)
+}
+
+func BindSInterStore(app *app.App) {
+	app.Bind(SInterStoreCommand, sinterstoreCmd)
+}
+
+func BindSUnionStore(app *app.App) {
+	app.Bind(SUnionStoreCommand, sunionstoreCmd)
+}
+
+func BindSDiffStore(app *app.App) {
+	app.Bind(SDiffStoreCommand, sdiffstoreCmd)
+}
+
+func BindSInterCard(app *app.App) {
+	app.Bind(SInterCardCommand, sintercardCmd)
+}
+
+func BindSMove(app *app.App) {
+	app.Bind(SMoveCommand, smoveCmd)
+}
+
+type sOp func(db *model.DBModel, keys ...[]byte) ([]interface{}, error)
+type sOpStore func(db *model.DBModel, dst []byte, keys ...[]byte) (int, error)
+
+func saddCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := context.DB.SAdd(cmd.Args[0].BulkString(), bulkStrings(cmd.Args[1:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func sremCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := context.DB.SRem(cmd.Args[0].BulkString(), bulkStrings(cmd.Args[1:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func smembersCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 1 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		members, err := context.DB.SMembers(cmd.Args[0].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			context.WriteReply(w, app.Set(members))
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func sismemberCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := context.DB.SIsMember(cmd.Args[0].BulkString(), cmd.Args[1].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func smismemberCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		res, err := context.DB.SMIsMember(cmd.Args[0].BulkString(), bulkStrings(cmd.Args[1:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			context.WriteReply(w, res)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func scardCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 1 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := context.DB.SCard(cmd.Args[0].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func spopCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l == 1 {
+		member, err := context.DB.SPop(cmd.Args[0].BulkString())
+		writeBulkOrNil(context, w, member, err)
+	} else if l == 2 {
+		members, err := context.DB.SPopCount(cmd.Args[0].BulkString(), cmd.Args[1].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			context.WriteReply(w, app.Set(members))
+		}
+	} else {
+		w.WriteArityError(cmd.Cmd)
+	}
+	w.Flush()
+	return nil
+}
+
+func srandmemberCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l == 1 {
+		member, err := context.DB.SRandMember(cmd.Args[0].BulkString())
+		writeBulkOrNil(context, w, member, err)
+	} else if l == 2 {
+		members, err := context.DB.SRandMemberCount(cmd.Args[0].BulkString(), cmd.Args[1].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			context.WriteReply(w, members)
+		}
+	} else {
+		w.WriteArityError(cmd.Cmd)
+	}
+	w.Flush()
+	return nil
+}
+
+func sopCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, op sOp) error {
+	l := len(cmd.Args)
+	if l < 1 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		members, err := op(context.DB, bulkStrings(cmd.Args)...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			context.WriteReply(w, app.Set(members))
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func sinterCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return sopCmd(context, cmd, w, func(db *model.DBModel, keys ...[]byte) ([]interface{}, error) {
+		return db.SInter(keys...)
+	})
+}
+
+func sunionCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return sopCmd(context, cmd, w, func(db *model.DBModel, keys ...[]byte) ([]interface{}, error) {
+		return db.SUnion(keys...)
+	})
+}
+
+func sdiffCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return sopCmd(context, cmd, w, func(db *model.DBModel, keys ...[]byte) ([]interface{}, error) {
+		return db.SDiff(keys...)
+	})
+}
+
+func sopStoreCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, op sOpStore) error {
+	l := len(cmd.Args)
+	if l < 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := op(context.DB, cmd.Args[0].BulkString(), bulkStrings(cmd.Args[1:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func sinterstoreCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return sopStoreCmd(context, cmd, w, func(db *model.DBModel, dst []byte, keys ...[]byte) (int, error) {
+		return db.SInterStore(dst, keys...)
+	})
+}
+
+func sunionstoreCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return sopStoreCmd(context, cmd, w, func(db *model.DBModel, dst []byte, keys ...[]byte) (int, error) {
+		return db.SUnionStore(dst, keys...)
+	})
+}
+
+func sdiffstoreCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return sopStoreCmd(context, cmd, w, func(db *model.DBModel, dst []byte, keys ...[]byte) (int, error) {
+		return db.SDiffStore(dst, keys...)
+	})
+}
+
+func sintercardCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := context.DB.SInterCard(bulkStrings(cmd.Args)...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func smoveCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := context.DB.SMove(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), cmd.Args[2].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"github.com/valery-barysok/gredisd/app"
+	"github.com/valery-barysok/gredisd/app/cmd"
+	"github.com/valery-barysok/gredisd/app/model"
+	"github.com/valery-barysok/resp"
+)
+
+// List of key value sorted set commands.
+const (
+	ZAddCommand             = "zadd"
+	ZRemCommand             = "zrem"
+	ZScoreCommand           = "zscore"
+	ZIncrByCommand          = "zincrby"
+	ZCardCommand            = "zcard"
+	ZCountCommand           = "zcount"
+	ZRankCommand            = "zrank"
+	ZRevRankCommand         = "zrevrank"
+	ZRangeCommand           = "zrange"
+	ZRangeStoreCommand      = "zrangestore"
+	ZPopMinCommand          = "zpopmin"
+	ZPopMaxCommand          = "zpopmax"
+	ZRemRangeByRankCommand  = "zremrangebyrank"
+	ZRemRangeByScoreCommand = "zremrangebyscore"
+	ZRemRangeByLexCommand   = "zremrangebylex"
+	ZUnionStoreCommand      = "zunionstore"
+	ZInterStoreCommand      = "zinterstore"
+)
+
+// BindAllKVZSetHandlers binds all key value sorted set commands at once
+func BindAllKVZSetHandlers(app *app.App) {
+	BindZAdd(app)
+	BindZRem(app)
+	BindZScore(app)
+	BindZIncrBy(app)
+	BindZCard(app)
+	BindZCount(app)
+	BindZRank(app)
+	BindZRevRank(app)
+	BindZRange(app)
+	BindZRangeStore(app)
+	BindZPopMin(app)
+	BindZPopMax(app)
+	BindZRemRangeByRank(app)
+	BindZRemRangeByScore(app)
+	BindZRemRangeByLex(app)
+	BindZUnionStore(app)
+	BindZInterStore(app)
+}
+
+func BindZAdd(app *app.App) {
+	app.Bind(ZAddCommand, zaddCmd)
+}
+
+func BindZRem(app *app.App) {
+	app.Bind(ZRemCommand, zremCmd)
+}
+
+func BindZScore(app *app.App) {
+	app.Bind(ZScoreCommand, zscoreCmd)
+}
+
+func BindZIncrBy(app *app.App) {
+	app.Bind(ZIncrByCommand, zincrbyCmd)
+}
+
+func BindZCard(app *app.App) {
+	app.Bind(ZCardCommand, zcardCmd)
+}
+
+func BindZCount(app *app.App) {
+	app.Bind(ZCountCommand, zcountCmd)
+}
+
+func BindZRank(app *app.App) {
+	app.Bind(ZRankCommand, zrankCmd)
+}
+
+func BindZRevRank(app *app.App) {
+	app.Bind(ZRevRankCommand, zrevrankCmd)
+}
+
+func BindZRange(app *app.App) {
+	app.Bind(ZRangeCommand, zrangeCmd)
+}
+
+func BindZRangeStore(app *app.App) {
+	app.Bind(ZRangeStoreCommand, zrangestoreCmd)
+}
+
+func BindZPopMin(app *app.App) {
+	app.Bind(ZPopMinCommand, zpopminCmd)
+}
+
+func BindZPopMax(app *app.App) {
+	app.Bind(ZPopMaxCommand, zpopmaxCmd)
+}
+
+func BindZRemRangeByRank(app *app.App) {
+	app.Bind(ZRemRangeByRankCommand, zremrangebyrankCmd)
+}
+
+func BindZRemRangeByScore(app *app.App) {
+	app.Bind(ZRemRangeByScoreCommand, zremrangebyscoreCmd)
+}
+
+func BindZRemRangeByLex(app *app.App) {
+	app.Bind(ZRemRangeByLexCommand, zremrangebylexCmd)
+}
+
+func BindZUnionStore(app *app.App) {
+	app.Bind(ZUnionStoreCommand, zunionstoreCmd)
+}
+
+func BindZInterStore(app *app.App) {
+	app.Bind(ZInterStoreCommand, zinterstoreCmd)
+}
+
+type zRank func(db *model.DBModel, key []byte, member []byte) (int, error)
+type zPop func(db *model.DBModel, key []byte, count []byte) ([]interface{}, error)
+type zRemRange func(db *model.DBModel, key []byte, min []byte, max []byte) (int, error)
+type zStore func(db *model.DBModel, dst []byte, args ...[]byte) (int, error)
+
+func zaddCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, score, incr, err := context.DB.ZAdd(cmd.Args[0].BulkString(), bulkStrings(cmd.Args[1:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else if !incr {
+			w.WriteInteger(cnt)
+		} else if score != nil {
+			context.WriteReply(w, app.Double(score))
+		} else {
+			context.WriteReply(w, nil)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func zremCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := context.DB.ZRem(cmd.Args[0].BulkString(), bulkStrings(cmd.Args[1:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func zscoreCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		score, err := context.DB.ZScore(cmd.Args[0].BulkString(), cmd.Args[1].BulkString())
+		writeScoreOrNil(context, w, score, err)
+	}
+	w.Flush()
+	return nil
+}
+
+func zincrbyCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		score, err := context.DB.ZIncrBy(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), cmd.Args[2].BulkString())
+		writeScoreOrNil(context, w, score, err)
+	}
+	w.Flush()
+	return nil
+}
+
+func zcardCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 1 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := context.DB.ZCard(cmd.Args[0].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func zcountCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := context.DB.ZCount(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), cmd.Args[2].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func zrankGenericCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, rank zRank) error {
+	l := len(cmd.Args)
+	if l != 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		r, err := rank(context.DB, cmd.Args[0].BulkString(), cmd.Args[1].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else if r >= 0 {
+			w.WriteInteger(r)
+		} else {
+			context.WriteReply(w, nil)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func zrankCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return zrankGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, member []byte) (int, error) {
+		return db.ZRank(key, member)
+	})
+}
+
+func zrevrankCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return zrankGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, member []byte) (int, error) {
+		return db.ZRevRank(key, member)
+	})
+}
+
+func zrangeCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		values, err := context.DB.ZRange(cmd.Args[0].BulkString(), bulkStrings(cmd.Args[1:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else if hasOption(cmd.Args[3:], "withscores") {
+			context.WriteReply(w, app.WithScores(values))
+		} else {
+			context.WriteReply(w, values)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func zrangestoreCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 4 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := context.DB.ZRangeStore(cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), bulkStrings(cmd.Args[2:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func zpopGenericCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, pop zPop) error {
+	l := len(cmd.Args)
+	if l < 1 || l > 2 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		var count []byte
+		if l == 2 {
+			count = cmd.Args[1].BulkString()
+		}
+
+		values, err := pop(context.DB, cmd.Args[0].BulkString(), count)
+		if err != nil {
+			w.WriteError(err)
+		} else if count != nil {
+			context.WriteReply(w, app.WithScores(values))
+		} else if len(values) == 2 {
+			context.WriteReply(w, []interface{}{values[0], app.Double(values[1].([]byte))})
+		} else {
+			context.WriteReply(w, values)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func zpopminCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return zpopGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, count []byte) ([]interface{}, error) {
+		return db.ZPopMin(key, count)
+	})
+}
+
+func zpopmaxCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return zpopGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, count []byte) ([]interface{}, error) {
+		return db.ZPopMax(key, count)
+	})
+}
+
+func zremrangeGenericCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, remRange zRemRange) error {
+	l := len(cmd.Args)
+	if l != 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := remRange(context.DB, cmd.Args[0].BulkString(), cmd.Args[1].BulkString(), cmd.Args[2].BulkString())
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func zremrangebyrankCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return zremrangeGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, min []byte, max []byte) (int, error) {
+		return db.ZRemRangeByRank(key, min, max)
+	})
+}
+
+func zremrangebyscoreCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return zremrangeGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, min []byte, max []byte) (int, error) {
+		return db.ZRemRangeByScore(key, min, max)
+	})
+}
+
+func zremrangebylexCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return zremrangeGenericCmd(context, cmd, w, func(db *model.DBModel, key []byte, min []byte, max []byte) (int, error) {
+		return db.ZRemRangeByLex(key, min, max)
+	})
+}
+
+func zstoreGenericCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer, store zStore) error {
+	l := len(cmd.Args)
+	if l < 3 {
+		w.WriteArityError(cmd.Cmd)
+	} else {
+		cnt, err := store(context.DB, cmd.Args[0].BulkString(), bulkStrings(cmd.Args[1:])...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			w.WriteInteger(cnt)
+		}
+	}
+	w.Flush()
+	return nil
+}
+
+func zunionstoreCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return zstoreGenericCmd(context, cmd, w, func(db *model.DBModel, dst []byte, args ...[]byte) (int, error) {
+		return db.ZUnionStore(dst, args...)
+	})
+}
+
+func zinterstoreCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	return zstoreGenericCmd(context, cmd, w, func(db *model.DBModel, dst []byte, args ...[]byte) (int, error) {
+		return db.ZInterStore(dst, args...)
+	})
+}
+
+// writeScoreOrNil writes score as double in RESP3 and as bulk string in RESP2
+func writeScoreOrNil(context *app.ClientContext, w *resp.Writer, score []byte, err error) {
+	if err != nil {
+		w.WriteError(err)
+	} else if score != nil {
+		context.WriteReply(w, app.Double(score))
+	} else {
+		context.WriteReply(w, nil)
+	}
+}
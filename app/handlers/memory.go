@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/valery-barysok/gredisd/app"
+	"github.com/valery-barysok/gredisd/app/cmd"
+	"github.com/valery-barysok/resp"
+)
+
+// List of memory commands.
+const (
+	MemoryCommand = "memory"
+)
+
+// Subcommands of Memory command
+const (
+	memoryUsage   = "usage"
+	memoryStats   = "stats"
+	memoryBigKeys = "bigkeys"
+	memoryMemKeys = "memkeys"
+)
+
+// denyOOMCommands are commands which may take more memory. They are rejected
+// when used memory exceeds maxmemory and keys can not be evicted
+var denyOOMCommands = commandsWith(func(spec *app.CommandSpec) bool { return spec.DenyOOM })
+
+// BindAllMemoryHandlers binds all memory management commands at once
+func BindAllMemoryHandlers(app *app.App) {
+	BindMaxMemory(app)
+	BindMemory(app)
+}
+
+// BindMemory binds Memory command that reports memory usage of keys and databases
+func BindMemory(app *app.App) {
+	app.Bind(MemoryCommand, memoryCmd)
+}
+
+// BindMaxMemory binds filter which evicts keys before commands taking memory
+// and rejects them if used memory can not be reduced below maxmemory
+func BindMaxMemory(app *app.App) {
+	app.BindFilter(maxMemoryFilter)
+}
+
+func maxMemoryFilter(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) (bool, error) {
+	if !denyOOMCommands[cmd.Cmd] {
+		return false, nil
+	}
+
+	if err := context.App.FreeMemory(); err != nil {
+		w.WriteErrorString(err.Error())
+		w.Flush()
+		return true, nil
+	}
+	return false, nil
+}
+
+func memoryCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 1 {
+		w.WriteArityError(cmd.Cmd)
+		w.Flush()
+		return nil
+	}
+
+	args := bulkStrings(cmd.Args[1:])
+	switch strings.ToLower(string(cmd.Args[0].BulkString())) {
+	case memoryUsage:
+		if len(args) < 1 {
+			w.WriteArityError(cmd.Cmd)
+			break
+		}
+
+		usage, err := context.DB.MemoryUsage(args[0], args[1:]...)
+		if err != nil {
+			w.WriteError(err)
+		} else if usage < 0 {
+			context.WriteReply(w, nil)
+		} else {
+			w.WriteInteger(usage)
+		}
+	case memoryStats:
+		if len(args) != 0 {
+			w.WriteArityError(cmd.Cmd)
+		} else {
+			context.WriteReply(w, context.App.MemoryStats())
+		}
+	case memoryBigKeys, memoryMemKeys:
+		mem := strings.EqualFold(string(cmd.Args[0].BulkString()), memoryMemKeys)
+		report, err := context.DB.KeysReport(mem, args...)
+		if err != nil {
+			w.WriteError(err)
+		} else {
+			context.WriteReply(w, report)
+		}
+	default:
+		w.WriteErrorString("ERR unknown subcommand or wrong number of arguments for MEMORY")
+	}
+	w.Flush()
+	return nil
+}
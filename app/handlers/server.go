@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/valery-barysok/gredisd/app"
+	"github.com/valery-barysok/gredisd/app/cmd"
+	"github.com/valery-barysok/resp"
+)
+
+// List of server commands.
+const (
+	InfoCommand    = "info"
+	SlowLogCommand = "slowlog"
+	MonitorCommand = "monitor"
+	LatencyCommand = "latency"
+	ConfigCommand  = "config"
+)
+
+// Subcommands of SlowLog command
+const (
+	slowLogGet   = "get"
+	slowLogLen   = "len"
+	slowLogReset = "reset"
+
+	// slowLogDefaultCount is number of entries returned by SLOWLOG GET by default
+	slowLogDefaultCount = 10
+)
+
+// Subcommands of Latency command
+const (
+	latencyLatest    = "latest"
+	latencyHistory   = "history"
+	latencyReset     = "reset"
+	latencyHistogram = "histogram"
+	latencyDoctor    = "doctor"
+)
+
+// Subcommands of Config command
+const (
+	configGet       = "get"
+	configSet       = "set"
+	configResetStat = "resetstat"
+	configRewrite   = "rewrite"
+)
+
+// BindAllServerHandlers binds all server introspection commands at once
+func BindAllServerHandlers(app *app.App) {
+	BindInfo(app)
+	BindSlowLog(app)
+	BindMonitor(app)
+	BindLatency(app)
+	BindConfig(app)
+}
+
+// BindInfo binds Info command that reports server state and statistics
+func BindInfo(app *app.App) {
+	app.Bind(InfoCommand, infoCmd)
+}
+
+func infoCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	sections := make([]string, 0, len(cmd.Args))
+	for _, arg := range cmd.Args {
+		sections = append(sections, string(arg.BulkString()))
+	}
+
+	context.WriteReply(w, app.Verbatim{Format: "txt", Text: []byte(context.App.Info(sections...))})
+	w.Flush()
+	return nil
+}
+
+// BindSlowLog binds SlowLog command that reads and resets log of slow commands
+func BindSlowLog(app *app.App) {
+	app.Bind(SlowLogCommand, slowLogCmd)
+}
+
+func slowLogCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 1 {
+		w.WriteArityError(cmd.Cmd)
+		w.Flush()
+		return nil
+	}
+
+	switch strings.ToLower(string(cmd.Args[0].BulkString())) {
+	case slowLogGet:
+		if l > 2 {
+			w.WriteArityError(cmd.Cmd)
+			break
+		}
+
+		count := slowLogDefaultCount
+		if l == 2 {
+			n, err := strconv.Atoi(string(cmd.Args[1].BulkString()))
+			if err != nil || n < -1 {
+				w.WriteErrorString("ERR count should be greater than or equal to -1")
+				break
+			}
+			count = n
+		}
+		context.WriteReply(w, context.App.SlowLog(count))
+	case slowLogLen:
+		if l != 1 {
+			w.WriteArityError(cmd.Cmd)
+		} else {
+			w.WriteInteger(context.App.SlowLogLen())
+		}
+	case slowLogReset:
+		if l != 1 {
+			w.WriteArityError(cmd.Cmd)
+		} else {
+			context.App.SlowLogReset()
+			w.WriteOK()
+		}
+	default:
+		w.WriteErrorString("ERR unknown subcommand or wrong number of arguments for SLOWLOG")
+	}
+	w.Flush()
+	return nil
+}
+
+// BindMonitor binds Monitor command that streams commands processed by server
+func BindMonitor(app *app.App) {
+	app.Bind(MonitorCommand, monitorCmd)
+}
+
+func monitorCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l != 0 {
+		w.WriteArityError(cmd.Cmd)
+		w.Flush()
+		return nil
+	}
+
+	w.WriteOK()
+	w.Flush()
+	context.Monitor()
+	return nil
+}
+
+// BindLatency binds Latency command that reports latency events and command latency histograms
+func BindLatency(app *app.App) {
+	app.Bind(LatencyCommand, latencyCmd)
+}
+
+func latencyCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 1 {
+		w.WriteArityError(cmd.Cmd)
+		w.Flush()
+		return nil
+	}
+
+	args := make([]string, 0, l-1)
+	for _, arg := range cmd.Args[1:] {
+		args = append(args, string(arg.BulkString()))
+	}
+
+	switch strings.ToLower(string(cmd.Args[0].BulkString())) {
+	case latencyLatest:
+		if len(args) != 0 {
+			w.WriteArityError(cmd.Cmd)
+		} else {
+			context.WriteReply(w, context.App.LatencyLatest())
+		}
+	case latencyHistory:
+		if len(args) != 1 {
+			w.WriteArityError(cmd.Cmd)
+		} else {
+			context.WriteReply(w, context.App.LatencyHistory(args[0]))
+		}
+	case latencyReset:
+		w.WriteInteger(context.App.LatencyReset(args...))
+	case latencyHistogram:
+		for i := range args {
+			args[i] = strings.ToLower(args[i])
+		}
+		context.WriteReply(w, context.App.LatencyHistogram(args...))
+	case latencyDoctor:
+		if len(args) != 0 {
+			w.WriteArityError(cmd.Cmd)
+		} else {
+			context.WriteReply(w, app.Verbatim{Format: "txt", Text: []byte(context.App.LatencyDoctor())})
+		}
+	default:
+		w.WriteErrorString("ERR unknown subcommand or wrong number of arguments for LATENCY")
+	}
+	w.Flush()
+	return nil
+}
+
+// BindConfig binds Config command that reads and changes server parameters
+func BindConfig(app *app.App) {
+	app.Bind(ConfigCommand, configCmd)
+}
+
+func configCmd(context *app.ClientContext, cmd *cmd.Command, w *resp.Writer) error {
+	l := len(cmd.Args)
+	if l < 1 {
+		w.WriteArityError(cmd.Cmd)
+		w.Flush()
+		return nil
+	}
+
+	args := make([]string, 0, l-1)
+	for _, arg := range cmd.Args[1:] {
+		args = append(args, string(arg.BulkString()))
+	}
+
+	switch strings.ToLower(string(cmd.Args[0].BulkString())) {
+	case configGet:
+		if len(args) == 0 {
+			w.WriteArityError(cmd.Cmd)
+		} else {
+			context.WriteReply(w, context.App.ConfigGet(args...))
+		}
+	case configSet:
+		if len(args) == 0 || len(args)%2 != 0 {
+			w.WriteArityError(cmd.Cmd)
+		} else if err := context.App.ConfigSet(args...); err != nil {
+			w.WriteErrorString(err.Error())
+		} else {
+			w.WriteOK()
+		}
+	case configResetStat:
+		if len(args) != 0 {
+			w.WriteArityError(cmd.Cmd)
+		} else {
+			context.App.ConfigResetStat()
+			w.WriteOK()
+		}
+	case configRewrite:
+		if len(args) != 0 {
+			w.WriteArityError(cmd.Cmd)
+		} else if err := context.App.ConfigRewrite(); err != nil {
+			w.WriteErrorString(err.Error())
+		} else {
+			w.WriteOK()
+		}
+	default:
+		w.WriteErrorString("ERR unknown subcommand or wrong number of arguments for CONFIG")
+	}
+	w.Flush()
+	return nil
+}
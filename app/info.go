@@ -0,0 +1,209 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valery-barysok/gredisd/app/model"
+)
+
+// Sections of INFO report
+const (
+	infoServer       = "server"
+	infoClients      = "clients"
+	infoMemory       = "memory"
+	infoStats        = "stats"
+	infoKeyspace     = "keyspace"
+	infoCommandStats = "commandstats"
+)
+
+// infoDefault are sections reported when no section is requested
+var infoDefault = []string{infoServer, infoClients, infoMemory, infoStats, infoKeyspace}
+
+// infoAll are all sections, they are reported for "all" and "everything"
+var infoAll = []string{infoServer, infoClients, infoMemory, infoStats, infoKeyspace, infoCommandStats}
+
+// Info returns report of requested sections in format of Redis INFO command
+func (app *App) Info(sections ...string) string {
+	requested := infoDefault
+	if len(sections) > 0 {
+		requested = nil
+		for _, s := range sections {
+			s = strings.ToLower(s)
+			switch s {
+			case "default":
+				requested = append(requested, infoDefault...)
+			case "all", "everything":
+				requested = append(requested, infoAll...)
+			default:
+				requested = append(requested, s)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	reported := make(map[string]bool)
+	for _, section := range requested {
+		if reported[section] {
+			continue
+		}
+		reported[section] = true
+
+		var write func(buf *bytes.Buffer)
+		switch section {
+		case infoServer:
+			buf.WriteString("# Server\r\n")
+			write = app.infoServer
+		case infoClients:
+			buf.WriteString("# Clients\r\n")
+			write = app.infoClients
+		case infoMemory:
+			buf.WriteString("# Memory\r\n")
+			write = app.infoMemory
+		case infoStats:
+			buf.WriteString("# Stats\r\n")
+			write = app.infoStats
+		case infoKeyspace:
+			buf.WriteString("# Keyspace\r\n")
+			write = app.infoKeyspace
+		case infoCommandStats:
+			buf.WriteString("# Commandstats\r\n")
+			write = app.infoCommandStats
+		default:
+			continue
+		}
+
+		write(&buf)
+		buf.WriteString("\r\n")
+	}
+	return strings.TrimSuffix(buf.String(), "\r\n")
+}
+
+func infoField(buf *bytes.Buffer, name string, value interface{}) {
+	fmt.Fprintf(buf, "%s:%v\r\n", name, value)
+}
+
+func (app *App) infoServer(buf *bytes.Buffer) {
+	uptime := time.Duration(0)
+	if app.server != nil {
+		uptime = time.Since(app.server.StartTime())
+	}
+
+	infoField(buf, "redis_version", RedisVersion)
+	infoField(buf, "gredis_version", app.info.Version)
+	infoField(buf, "redis_mode", "standalone")
+	infoField(buf, "os", runtime.GOOS)
+	infoField(buf, "arch_bits", 32<<(^uint(0)>>63))
+	infoField(buf, "go_version", app.info.GoVersion)
+	infoField(buf, "process_id", os.Getpid())
+	infoField(buf, "run_id", app.info.ID)
+	infoField(buf, "tcp_port", app.info.Port)
+	infoField(buf, "uptime_in_seconds", int64(uptime/time.Second))
+	infoField(buf, "uptime_in_days", int64(uptime/(24*time.Hour)))
+}
+
+func (app *App) infoClients(buf *bytes.Buffer) {
+	connected := 0
+	if app.server != nil {
+		connected = app.server.ClientsCount()
+	}
+
+	infoField(buf, "connected_clients", connected)
+	infoField(buf, "maxclients", app.options().MaxClients)
+	infoField(buf, "blocked_clients", atomic.LoadInt64(&app.blockedClients))
+}
+
+func (app *App) infoMemory(buf *bytes.Buffer) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	used := app.model.UsedMemory()
+	maxMemory := app.model.MaxMemory()
+
+	infoField(buf, "used_memory", used)
+	infoField(buf, "used_memory_human", humanBytes(used))
+	infoField(buf, "used_memory_heap", ms.HeapAlloc)
+	infoField(buf, "used_memory_heap_human", humanBytes(int64(ms.HeapAlloc)))
+	infoField(buf, "used_memory_system", ms.Sys)
+	infoField(buf, "used_memory_system_human", humanBytes(int64(ms.Sys)))
+	infoField(buf, "maxmemory", maxMemory)
+	infoField(buf, "maxmemory_human", humanBytes(maxMemory))
+	infoField(buf, "maxmemory_policy", model.MaxMemoryPolicyName(app.model.MaxMemoryPolicy()))
+}
+
+func (app *App) infoStats(buf *bytes.Buffer) {
+	var connections, rejected uint64
+	if app.server != nil {
+		connections = app.server.ConnectionsReceived()
+		rejected = app.server.RejectedConnections()
+	}
+
+	var commands int64
+	for _, stat := range app.router.stats {
+		commands += atomic.LoadInt64(&stat.calls)
+	}
+
+	infoField(buf, "total_connections_received", connections)
+	infoField(buf, "rejected_connections", rejected)
+	infoField(buf, "total_net_input_bytes", atomic.LoadInt64(&app.net.in))
+	infoField(buf, "total_net_output_bytes", atomic.LoadInt64(&app.net.out))
+	infoField(buf, "total_commands_processed", commands)
+	infoField(buf, "expired_keys", app.model.ExpiredKeys())
+	infoField(buf, "evicted_keys", app.model.EvictedKeys())
+	infoField(buf, "total_eviction_exceeded_time", int64(app.model.EvictionTime()/time.Millisecond))
+	infoField(buf, "client_query_buffer_limit_disconnections", atomic.LoadInt64(&app.limits.queryDisconnections))
+	infoField(buf, "client_output_buffer_limit_disconnections", atomic.LoadInt64(&app.limits.outputDisconnections))
+}
+
+func (app *App) infoKeyspace(buf *bytes.Buffer) {
+	for _, db := range app.model.Keyspace() {
+		fmt.Fprintf(buf, "db%d:keys=%d,expires=%d\r\n", db.Index, db.Keys, db.Expires)
+	}
+}
+
+func (app *App) infoCommandStats(buf *bytes.Buffer) {
+	names := make([]string, 0, len(app.router.stats))
+	for name := range app.router.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		stat := app.router.stats[name]
+		calls := atomic.LoadInt64(&stat.calls)
+		rejected := atomic.LoadInt64(&stat.rejected)
+		failed := atomic.LoadInt64(&stat.failed)
+		if calls == 0 && rejected == 0 {
+			continue
+		}
+
+		usec := atomic.LoadInt64(&stat.usec)
+		perCall := float64(0)
+		if calls > 0 {
+			perCall = float64(usec) / float64(calls)
+		}
+		fmt.Fprintf(buf, "cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d\r\n",
+			name, calls, usec, perCall, rejected, failed)
+	}
+}
+
+// humanBytes formats amount of bytes like Redis does in INFO
+func humanBytes(n int64) string {
+	units := []string{"B", "K", "M", "G", "T"}
+	v := float64(n)
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", n)
+	}
+	return fmt.Sprintf("%.2f%s", v, units[i])
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var infoSectionHeader = regexp.MustCompile(`(?m)^# (\w+)\r$`)
+
+// infoSections returns names of sections of INFO report in order
+func infoSections(info string) []string {
+	var sections []string
+	for _, match := range infoSectionHeader.FindAllStringSubmatch(info, -1) {
+		sections = append(sections, match[1])
+	}
+	return sections
+}
+
+// infoLines returns lines of INFO report which start with prefix
+func infoLines(info string, prefix string) []string {
+	var lines []string
+	for _, line := range strings.Split(info, "\r\n") {
+		if strings.HasPrefix(line, prefix) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestInfoSections(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+
+	defaults := []string{"Server", "Clients", "Memory", "Stats", "Keyspace"}
+	all := append(defaults, "Commandstats")
+
+	tests := []struct {
+		sections []string
+		reported []string
+	}{
+		{nil, defaults},
+		{[]string{"default"}, defaults},
+		{[]string{"all"}, all},
+		{[]string{"Everything"}, all},
+		{[]string{"memory"}, []string{"Memory"}},
+		{[]string{"CommandStats", "server"}, []string{"Commandstats", "Server"}},
+		// sections requested several times are reported once
+		{[]string{"server", "SERVER", "default"}, defaults},
+		{[]string{"all", "default", "commandstats"}, all},
+		{[]string{"unknown", "clients"}, []string{"Clients"}},
+		{[]string{"unknown"}, nil},
+	}
+	for _, test := range tests {
+		Expect(infoSections(app.Info(test.sections...))).To(Equal(test.reported), "%v", test.sections)
+	}
+	Expect(app.Info("unknown")).To(BeEmpty())
+
+	// sections are separated by empty line
+	info := app.Info("server", "clients")
+	Expect(info).To(HavePrefix("# Server\r\n"))
+	Expect(info).To(ContainSubstring("\r\n\r\n# Clients\r\n"))
+
+	// monitoring agents read version of Redis compatible endpoint
+	Expect(infoLines(info, "redis_version:")).To(Equal([]string{"redis_version:" + RedisVersion}))
+	Expect(infoLines(info, "gredis_version:")).To(Equal([]string{"gredis_version:" + Version}))
+	Expect(infoLines(info, "maxclients:")).To(Equal([]string{"maxclients:10000"}))
+}
+
+func TestInfoKeyspace(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+	Expect(app.Info("keyspace")).To(Equal("# Keyspace\r\n"))
+
+	db, _ := app.SelectIndex(0)
+	db.Set([]byte("a"), []byte("1"))
+	db.Set([]byte("b"), []byte("2"))
+	db.ExpireN([]byte("b"), 3600)
+	other, _ := app.SelectIndex(3)
+	other.Set([]byte("c"), []byte("3"))
+	// empty databases are not reported
+	app.SelectIndex(1)
+
+	Expect(app.Info("keyspace")).To(Equal("# Keyspace\r\n" +
+		"db0:keys=2,expires=1\r\n" +
+		"db3:keys=1,expires=0\r\n"))
+}
+
+func TestInfoCommandStats(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+	for _, name := range []string{"get", "set", "del"} {
+		app.Bind(name, nopHandler)
+	}
+	Expect(app.Info("commandstats")).To(Equal("# Commandstats\r\n"))
+
+	app.router.stats["set"].record(10*time.Microsecond, false)
+	app.router.stats["set"].record(15*time.Microsecond, true)
+	app.router.stats["get"].record(time.Microsecond, false)
+	app.router.stats["get"].record(time.Microsecond, false)
+	app.router.stats["get"].record(2*time.Microsecond, false)
+	app.router.stats["del"].rejected = 2
+
+	// commands are sorted, rejected commands without calls are reported
+	Expect(app.Info("commandstats")).To(Equal("# Commandstats\r\n" +
+		"cmdstat_del:calls=0,usec=0,usec_per_call=0.00,rejected_calls=2,failed_calls=0\r\n" +
+		"cmdstat_get:calls=3,usec=4,usec_per_call=1.33,rejected_calls=0,failed_calls=0\r\n" +
+		"cmdstat_set:calls=2,usec=25,usec_per_call=12.50,rejected_calls=0,failed_calls=1\r\n"))
+	Expect(infoLines(app.Info(), "total_commands_processed:")).To(Equal([]string{"total_commands_processed:5"}))
+
+	app.ConfigResetStat()
+	Expect(app.Info("commandstats")).To(Equal("# Commandstats\r\n"))
+}
+
+func TestHumanBytes(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		n     int64
+		human string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1.00K"},
+		{1536, "1.50K"},
+		{1 << 20, "1.00M"},
+		{3 << 29, "1.50G"},
+		{1 << 40, "1.00T"},
+		{1 << 50, "1024.00T"},
+	}
+	for _, test := range tests {
+		Expect(humanBytes(test.n)).To(Equal(test.human), "%d", test.n)
+	}
+}
@@ -0,0 +1,289 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// latencyHistoryLen is number of samples kept per latency event
+	latencyHistoryLen = 160
+
+	// latencyHistogramBuckets is number of buckets of command latency histogram,
+	// bucket i counts commands which took up to 2^i microseconds
+	latencyHistogramBuckets = 40
+)
+
+// Latency events
+const (
+	latencyCommand       = "command"
+	latencyExpireCycle   = "expire-cycle"
+	latencyEvictionCycle = "eviction-cycle"
+)
+
+// latencyAdvices are advices of LATENCY DOCTOR per event
+var latencyAdvices = map[string]string{
+	latencyCommand: "Check SLOWLOG GET for commands taking long. Avoid commands which are O(N) on big values " +
+		"like KEYS, LRANGE 0 -1, SMEMBERS or HGETALL.",
+	latencyExpireCycle: "Many keys expire at the same time. Add random jitter to TTLs so expiration is spread over time.",
+	latencyEvictionCycle: "Used memory is often above maxmemory so keys are evicted before commands. Raise maxmemory " +
+		"or reduce memory of values.",
+}
+
+type latencySample struct {
+	time    int64
+	latency int64
+}
+
+type latencyEvent struct {
+	history []latencySample
+	max     int64
+}
+
+// latencyMonitor keeps history of events which took longer than threshold
+type latencyMonitor struct {
+	// threshold in milliseconds is accessed atomically, zero disables monitor
+	threshold int64
+
+	mu     sync.Mutex
+	events map[string]*latencyEvent
+}
+
+func newLatencyMonitor() *latencyMonitor {
+	return &latencyMonitor{
+		events: make(map[string]*latencyEvent),
+	}
+}
+
+// record adds sample of event if it took at least threshold. Samples of the
+// same second are merged keeping the highest latency
+func (monitor *latencyMonitor) record(event string, d time.Duration) {
+	threshold := atomic.LoadInt64(&monitor.threshold)
+	latency := int64(d / time.Millisecond)
+	if threshold <= 0 || latency < threshold {
+		return
+	}
+
+	now := time.Now().Unix()
+
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+
+	ev, ok := monitor.events[event]
+	if !ok {
+		ev = &latencyEvent{}
+		monitor.events[event] = ev
+	}
+
+	if n := len(ev.history); n > 0 && ev.history[n-1].time == now {
+		if latency > ev.history[n-1].latency {
+			ev.history[n-1].latency = latency
+		}
+	} else {
+		if n == latencyHistoryLen {
+			copy(ev.history, ev.history[1:])
+			ev.history = ev.history[:n-1]
+		}
+		ev.history = append(ev.history, latencySample{time: now, latency: latency})
+	}
+
+	if latency > ev.max {
+		ev.max = latency
+	}
+}
+
+// names returns names of recorded events in order
+func (monitor *latencyMonitor) names() []string {
+	names := make([]string, 0, len(monitor.events))
+	for name := range monitor.events {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// histogramBucket returns bucket of command latency histogram for d
+func histogramBucket(d time.Duration) int {
+	usec := int64(d / time.Microsecond)
+	i := 0
+	for i < latencyHistogramBuckets-1 && int64(1)<<uint(i) < usec {
+		i++
+	}
+	return i
+}
+
+// SetLatencyMonitorThreshold sets threshold of latency monitor in milliseconds, zero disables it
+func (app *App) SetLatencyMonitorThreshold(threshold int64) {
+	atomic.StoreInt64(&app.router.latency.threshold, threshold)
+}
+
+// LatencyLatest returns name, time and latency of the latest sample and maximum
+// latency of every event
+func (app *App) LatencyLatest() []interface{} {
+	monitor := app.router.latency
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+
+	latest := make([]interface{}, 0, len(monitor.events))
+	for _, name := range monitor.names() {
+		ev := monitor.events[name]
+		last := ev.history[len(ev.history)-1]
+		latest = append(latest, []interface{}{
+			[]byte(name),
+			int(last.time),
+			int(last.latency),
+			int(ev.max),
+		})
+	}
+	return latest
+}
+
+// LatencyHistory returns time and latency of samples of event
+func (app *App) LatencyHistory(event string) []interface{} {
+	monitor := app.router.latency
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+
+	ev, ok := monitor.events[event]
+	if !ok {
+		return []interface{}{}
+	}
+
+	history := make([]interface{}, 0, len(ev.history))
+	for _, sample := range ev.history {
+		history = append(history, []interface{}{int(sample.time), int(sample.latency)})
+	}
+	return history
+}
+
+// LatencyReset removes history of events or of all events if none is given.
+// It returns number of removed events
+func (app *App) LatencyReset(events ...string) int {
+	monitor := app.router.latency
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+
+	if len(events) == 0 {
+		n := len(monitor.events)
+		monitor.events = make(map[string]*latencyEvent)
+		return n
+	}
+
+	n := 0
+	for _, event := range events {
+		if _, ok := monitor.events[event]; ok {
+			delete(monitor.events, event)
+			n++
+		}
+	}
+	return n
+}
+
+// LatencyHistogram returns number of calls and cumulative histogram of latency in
+// microseconds of commands or of all called commands if none is given
+func (app *App) LatencyHistogram(commands ...string) Map {
+	if len(commands) == 0 {
+		for name := range app.router.stats {
+			commands = append(commands, name)
+		}
+		sort.Strings(commands)
+	}
+
+	histograms := make(Map, 0, 2*len(commands))
+	for _, name := range commands {
+		stat, ok := app.router.stats[name]
+		if !ok {
+			continue
+		}
+		calls := atomic.LoadInt64(&stat.calls)
+		if calls == 0 {
+			continue
+		}
+
+		first, last := -1, -1
+		counts := make([]int64, latencyHistogramBuckets)
+		for i := range counts {
+			counts[i] = atomic.LoadInt64(&stat.histogram[i])
+			if counts[i] > 0 {
+				if first < 0 {
+					first = i
+				}
+				last = i
+			}
+		}
+
+		buckets := make(Map, 0)
+		var cumulative int64
+		for i := 0; first >= 0 && i <= last; i++ {
+			cumulative += counts[i]
+			if i >= first {
+				buckets = append(buckets, 1<<uint(i), int(cumulative))
+			}
+		}
+
+		histograms = append(histograms, []byte(name), Map{
+			[]byte("calls"), int(calls),
+			[]byte("histogram_usec"), buckets,
+		})
+	}
+	return histograms
+}
+
+// LatencyDoctor returns human readable report of latency events with advices
+func (app *App) LatencyDoctor() string {
+	monitor := app.router.latency
+	var buf bytes.Buffer
+	if atomic.LoadInt64(&monitor.threshold) <= 0 {
+		buf.WriteString("Latency monitoring is disabled. Use CONFIG SET latency-monitor-threshold <milliseconds> " +
+			"to record events taking at least given milliseconds.\n")
+		return buf.String()
+	}
+
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+
+	names := monitor.names()
+	if len(names) == 0 {
+		buf.WriteString("No latency spike was observed since the server was started or latency history was reset.\n")
+		return buf.String()
+	}
+
+	buf.WriteString("Latency spikes were observed for the following events:\n\n")
+	for i, name := range names {
+		ev := monitor.events[name]
+		n := int64(len(ev.history))
+
+		var sum, deviation int64
+		for _, sample := range ev.history {
+			sum += sample.latency
+		}
+		avg := sum / n
+		for _, sample := range ev.history {
+			if d := sample.latency - avg; d > 0 {
+				deviation += d
+			} else {
+				deviation -= d
+			}
+		}
+
+		period := ""
+		if n > 1 {
+			seconds := float64(ev.history[n-1].time-ev.history[0].time) / float64(n-1)
+			period = fmt.Sprintf(", period %.1f sec", seconds)
+		}
+		fmt.Fprintf(&buf, "%d. %s: %d latency spikes (average %dms, mean deviation %dms%s). Worst all time event %dms.\n",
+			i+1, name, n, avg, deviation/n, period, ev.max)
+	}
+
+	buf.WriteString("\nAdvices:\n\n")
+	for _, name := range names {
+		if advice, ok := latencyAdvices[name]; ok {
+			fmt.Fprintf(&buf, "- %s: %s\n", name, advice)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestHistogramBucket(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		d      time.Duration
+		bucket int
+	}{
+		{0, 0},
+		{500 * time.Nanosecond, 0},
+		{time.Microsecond, 0},
+		{2 * time.Microsecond, 1},
+		{3 * time.Microsecond, 2},
+		{4 * time.Microsecond, 2},
+		{5 * time.Microsecond, 3},
+		{time.Millisecond, 10},
+		{1024 * time.Microsecond, 10},
+		{1025 * time.Microsecond, 11},
+		{time.Second, 20},
+		{(1 << 39) * time.Microsecond, 39},
+		{(1<<39 + 1) * time.Microsecond, 39},
+		{1 << 62, 39},
+	}
+	for _, test := range tests {
+		Expect(histogramBucket(test.d)).To(Equal(test.bucket), test.d.String())
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+	for _, name := range []string{"get", "set", "del"} {
+		app.Bind(name, nopHandler)
+	}
+	Expect(app.LatencyHistogram()).To(BeEmpty())
+
+	for _, d := range []time.Duration{time.Microsecond, 3 * time.Microsecond, 3 * time.Microsecond, 8 * time.Microsecond} {
+		app.router.stats["get"].record(d, false)
+	}
+	app.router.stats["set"].record(time.Millisecond, true)
+
+	get := Map{
+		[]byte("calls"), 4,
+		[]byte("histogram_usec"), Map{1, 1, 2, 1, 4, 3, 8, 4},
+	}
+	set := Map{
+		[]byte("calls"), 1,
+		[]byte("histogram_usec"), Map{1024, 1},
+	}
+	// commands without calls are skipped
+	Expect(app.LatencyHistogram()).To(Equal(Map{[]byte("get"), get, []byte("set"), set}))
+	Expect(app.LatencyHistogram("set", "del", "unknown")).To(Equal(Map{[]byte("set"), set}))
+
+	app.ConfigResetStat()
+	Expect(app.LatencyHistogram()).To(BeEmpty())
+}
+
+func TestLatencyMonitor(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+	monitor := app.router.latency
+
+	// disabled monitor records nothing
+	monitor.record(latencyCommand, time.Second)
+	Expect(app.LatencyLatest()).To(BeEmpty())
+
+	app.SetLatencyMonitorThreshold(100)
+	monitor.record(latencyCommand, 99*time.Millisecond)
+	Expect(app.LatencyLatest()).To(BeEmpty())
+	Expect(app.LatencyHistory(latencyCommand)).To(BeEmpty())
+
+	monitor.record(latencyCommand, 100*time.Millisecond)
+	history := app.LatencyHistory(latencyCommand)
+	Expect(history).To(HaveLen(1))
+	now := history[0].([]interface{})[0].(int)
+	Expect(now).To(BeNumerically("~", time.Now().Unix(), 1))
+	Expect(history).To(Equal([]interface{}{[]interface{}{now, 100}}))
+
+	// samples of the same second are merged keeping the highest latency
+	monitor.events[latencyCommand].history[0].time = time.Now().Unix()
+	monitor.record(latencyCommand, 150*time.Millisecond)
+	monitor.record(latencyCommand, 120*time.Millisecond)
+	Expect(monitor.events[latencyCommand].history).To(HaveLen(1))
+	Expect(monitor.events[latencyCommand].history[0].latency).To(Equal(int64(150)))
+
+	// the oldest sample is dropped once history is full
+	ev := monitor.events[latencyCommand]
+	ev.history = ev.history[:0]
+	for i := 0; i < latencyHistoryLen; i++ {
+		ev.history = append(ev.history, latencySample{time: int64(i + 1), latency: 100})
+	}
+	monitor.record(latencyCommand, 200*time.Millisecond)
+	Expect(ev.history).To(HaveLen(latencyHistoryLen))
+	Expect(ev.history[0].time).To(Equal(int64(2)))
+	Expect(ev.history[latencyHistoryLen-1].latency).To(Equal(int64(200)))
+	Expect(ev.max).To(Equal(int64(200)))
+
+	monitor.record(latencyExpireCycle, 100*time.Millisecond)
+	latest := app.LatencyLatest()
+	Expect(latest).To(HaveLen(2))
+	Expect(latest[0]).To(Equal([]interface{}{[]byte(latencyCommand), int(ev.history[latencyHistoryLen-1].time), 200, 200}))
+	Expect(latest[1].([]interface{})[0]).To(Equal([]byte(latencyExpireCycle)))
+
+	Expect(app.LatencyReset(latencyExpireCycle, "unknown")).To(Equal(1))
+	Expect(app.LatencyHistory(latencyExpireCycle)).To(BeEmpty())
+	Expect(app.LatencyHistory(latencyCommand)).To(HaveLen(latencyHistoryLen))
+
+	monitor.record(latencyEvictionCycle, 100*time.Millisecond)
+	Expect(app.LatencyReset()).To(Equal(2))
+	Expect(app.LatencyLatest()).To(BeEmpty())
+	Expect(app.LatencyReset()).To(Equal(0))
+}
+
+func TestLatencyDoctor(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+	Expect(app.LatencyDoctor()).To(HavePrefix("Latency monitoring is disabled."))
+
+	app.SetLatencyMonitorThreshold(100)
+	Expect(app.LatencyDoctor()).To(HavePrefix("No latency spike was observed"))
+
+	monitor := app.router.latency
+	monitor.events[latencyCommand] = &latencyEvent{
+		history: []latencySample{{time: 100, latency: 100}, {time: 110, latency: 200}, {time: 120, latency: 300}},
+		max:     400,
+	}
+	monitor.events[latencyExpireCycle] = &latencyEvent{
+		history: []latencySample{{time: 100, latency: 150}},
+		max:     150,
+	}
+	monitor.events["unknown-event"] = &latencyEvent{
+		history: []latencySample{{time: 100, latency: 100}, {time: 103, latency: 100}},
+		max:     100,
+	}
+
+	Expect(app.LatencyDoctor()).To(Equal("Latency spikes were observed for the following events:\n\n" +
+		"1. command: 3 latency spikes (average 200ms, mean deviation 66ms, period 10.0 sec). Worst all time event 400ms.\n" +
+		"2. expire-cycle: 1 latency spikes (average 150ms, mean deviation 0ms). Worst all time event 150ms.\n" +
+		"3. unknown-event: 2 latency spikes (average 100ms, mean deviation 0ms, period 3.0 sec). Worst all time event 100ms.\n" +
+		"\nAdvices:\n\n" +
+		"- command: " + latencyAdvices[latencyCommand] + "\n" +
+		"- expire-cycle: " + latencyAdvices[latencyExpireCycle] + "\n"))
+}
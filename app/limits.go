@@ -0,0 +1,350 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valery-barysok/resp"
+)
+
+const (
+	// maxMultibulkLen is maximum number of arguments of command
+	maxMultibulkLen = 1024 * 1024
+
+	// maxInlineLen is maximum length of inline command
+	maxInlineLen = 64 * 1024
+
+	// maxHeaderLen is maximum length of multibulk and bulk length line
+	maxHeaderLen = 32
+)
+
+var (
+	errQueryBufferLimit    = errors.New("query buffer limit reached")
+	errOutputHardLimit     = errors.New("output buffer hard limit reached")
+	errOutputSoftLimit     = errors.New("output buffer soft limit reached")
+	errInvalidMultibulkLen = errors.New("ERR Protocol error: invalid multibulk length")
+	errInvalidBulkLen      = errors.New("ERR Protocol error: invalid bulk length")
+	errTooBigInline        = errors.New("ERR Protocol error: too big inline request")
+	errOutputLimitFormat   = errors.New("ERR wrong number of arguments for client-output-buffer-limit, class hard soft seconds are expected")
+)
+
+// Classes of client-output-buffer-limit
+const (
+	normalClass = iota
+	replicaClass
+	pubsubClass
+)
+
+var outputBufferClasses = []string{"normal", "replica", "pubsub"}
+
+// OutputBufferLimit is client-output-buffer-limit of class of clients. Client is
+// disconnected once its reply exceeds Hard bytes or stays above Soft bytes for
+// SoftSeconds seconds, zero disables limit
+type OutputBufferLimit struct {
+	Hard        int64 `json:"hard"`
+	Soft        int64 `json:"soft"`
+	SoftSeconds int64 `json:"soft_seconds"`
+}
+
+// defaultOutputBufferLimits are limits of client classes by default
+var defaultOutputBufferLimits = map[string]OutputBufferLimit{
+	"normal":  {},
+	"replica": {Hard: 256 << 20, Soft: 64 << 20, SoftSeconds: 60},
+	"pubsub":  {Hard: 32 << 20, Soft: 8 << 20, SoftSeconds: 60},
+}
+
+// ParseOutputBufferLimit parses client-output-buffer-limit in format
+// "class hard soft seconds [class hard soft seconds ...]" into limits
+func ParseOutputBufferLimit(s string, limits map[string]OutputBufferLimit) error {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields)%4 != 0 {
+		return errOutputLimitFormat
+	}
+
+	parsed := make(map[string]OutputBufferLimit)
+	for i := 0; i < len(fields); i += 4 {
+		class := strings.ToLower(fields[i])
+		if class == "slave" {
+			class = "replica"
+		}
+		if outputBufferClass(class) < 0 {
+			return fmt.Errorf("ERR Invalid client class specified in buffer limit configuration: %s", fields[i])
+		}
+
+		hard, err := ParseMemory(fields[i+1])
+		if err != nil {
+			return err
+		}
+		soft, err := ParseMemory(fields[i+2])
+		if err != nil {
+			return err
+		}
+		seconds, err := strconv.ParseInt(fields[i+3], 10, 64)
+		if err != nil || seconds < 0 {
+			return fmt.Errorf("ERR Invalid soft limit seconds %q", fields[i+3])
+		}
+		parsed[class] = OutputBufferLimit{Hard: hard, Soft: soft, SoftSeconds: seconds}
+	}
+
+	for class, limit := range parsed {
+		limits[class] = limit
+	}
+	return nil
+}
+
+func outputBufferClass(class string) int {
+	for i, name := range outputBufferClasses {
+		if name == class {
+			return i
+		}
+	}
+	return -1
+}
+
+// outputLimit is limit of class of clients, it is accessed atomically
+type outputLimit struct {
+	hard        int64
+	soft        int64
+	softSeconds int64
+}
+
+// clientLimits are limits of client buffers, they are accessed atomically
+type clientLimits struct {
+	queryBuffer     int64
+	protoMaxBulkLen int64
+	output          [3]outputLimit
+
+	// number of clients disconnected due to limits
+	queryDisconnections  int64
+	outputDisconnections int64
+}
+
+func (limits *clientLimits) setOutput(class string, limit OutputBufferLimit) {
+	output := &limits.output[outputBufferClass(class)]
+	atomic.StoreInt64(&output.hard, limit.Hard)
+	atomic.StoreInt64(&output.soft, limit.Soft)
+	atomic.StoreInt64(&output.softSeconds, limit.SoftSeconds)
+}
+
+// checkLimit returns error once reply exceeds output buffer limit if n more
+// bytes are written. Once reply exceeds soft limit write deadline is set so
+// client which does not read reply is disconnected in time
+func (cw *countWriter) checkLimit(n int64) error {
+	if cw.limit == nil {
+		return nil
+	}
+
+	size := cw.reply + n
+	if hard := atomic.LoadInt64(&cw.limit.hard); hard > 0 && size > hard {
+		return errOutputHardLimit
+	}
+
+	soft := atomic.LoadInt64(&cw.limit.soft)
+	if soft <= 0 || size <= soft {
+		return nil
+	}
+
+	seconds := time.Duration(atomic.LoadInt64(&cw.limit.softSeconds)) * time.Second
+	now := time.Now()
+	if cw.softSince.IsZero() {
+		cw.softSince = now
+		if cw.deadline != nil {
+			cw.deadline(now.Add(seconds))
+		}
+	}
+	if now.Sub(cw.softSince) >= seconds {
+		return errOutputSoftLimit
+	}
+	return nil
+}
+
+// States of queryReader
+const (
+	scanStart = iota
+	scanInline
+	scanMultibulkLen
+	scanBulkStart
+	scanBulkLen
+	scanBulk
+)
+
+// queryReader follows framing of commands read by client to reject them before
+// they are parsed: bulks longer than proto-max-bulk-len, too many arguments and
+// commands longer than client-query-buffer-limit
+type queryReader struct {
+	r      io.Reader
+	limits *clientLimits
+
+	state  int
+	header []byte
+	// args is number of bulks left in command and left is number of bytes left in bulk
+	args int64
+	left int64
+	// size is number of bytes of current command
+	size int64
+
+	err error
+}
+
+func (qr *queryReader) Read(p []byte) (int, error) {
+	if qr.err != nil {
+		return 0, qr.err
+	}
+
+	n, err := qr.r.Read(p)
+	if serr := qr.scan(p[:n]); serr != nil {
+		qr.err = serr
+		return 0, serr
+	}
+	return n, err
+}
+
+func (qr *queryReader) scan(p []byte) error {
+	limit := atomic.LoadInt64(&qr.limits.queryBuffer)
+	for i := 0; i < len(p); {
+		// limits are checked before command is done as it resets its size
+		switch qr.state {
+		case scanBulk:
+			n := qr.left
+			if rest := int64(len(p) - i); rest < n {
+				n = rest
+			}
+			i += int(n)
+			qr.size += n
+			qr.left -= n
+			if limit > 0 && qr.size > limit {
+				return errQueryBufferLimit
+			}
+			if qr.left == 0 {
+				qr.args--
+				if qr.args == 0 {
+					qr.done()
+				} else {
+					qr.state = scanBulkStart
+				}
+			}
+		case scanInline:
+			j := bytes.IndexByte(p[i:], '\n')
+			if j < 0 {
+				qr.size += int64(len(p) - i)
+				i = len(p)
+			} else {
+				qr.size += int64(j + 1)
+				i += j + 1
+			}
+			if qr.size > maxInlineLen {
+				return errTooBigInline
+			}
+			if limit > 0 && qr.size > limit {
+				return errQueryBufferLimit
+			}
+			if j >= 0 {
+				qr.done()
+			}
+		default:
+			if err := qr.scanHeader(p[i]); err != nil {
+				return err
+			}
+			i++
+			if limit > 0 && qr.size > limit {
+				return errQueryBufferLimit
+			}
+		}
+	}
+	return nil
+}
+
+func (qr *queryReader) scanHeader(c byte) error {
+	qr.size++
+	switch qr.state {
+	case scanStart:
+		if c == '*' {
+			qr.state = scanMultibulkLen
+			qr.header = qr.header[:0]
+		} else if c == '\n' {
+			qr.done()
+		} else {
+			qr.state = scanInline
+		}
+		return nil
+	case scanBulkStart:
+		if c != '$' {
+			return fmt.Errorf("ERR Protocol error: expected '$', got '%c'", c)
+		}
+		qr.state = scanBulkLen
+		qr.header = qr.header[:0]
+		return nil
+	}
+
+	if c != '\n' {
+		if len(qr.header) >= maxHeaderLen {
+			if qr.state == scanMultibulkLen {
+				return errInvalidMultibulkLen
+			}
+			return errInvalidBulkLen
+		}
+		qr.header = append(qr.header, c)
+		return nil
+	}
+
+	n, err := strconv.ParseInt(string(bytes.TrimSuffix(qr.header, []byte("\r"))), 10, 64)
+	if qr.state == scanMultibulkLen {
+		if err != nil || n > maxMultibulkLen {
+			return errInvalidMultibulkLen
+		}
+		if n <= 0 {
+			qr.done()
+		} else {
+			qr.args = n
+			qr.state = scanBulkStart
+		}
+		return nil
+	}
+
+	if err != nil || n < 0 || n > atomic.LoadInt64(&qr.limits.protoMaxBulkLen) {
+		return errInvalidBulkLen
+	}
+	qr.left = n + 2
+	qr.state = scanBulk
+	return nil
+}
+
+func (qr *queryReader) done() {
+	qr.state = scanStart
+	qr.size = 0
+}
+
+// disconnect logs reason why client exceeded limits is disconnected. Protocol
+// errors are replied to client
+func (looper *looper) disconnect(context *ClientContext, reason error, w *resp.Writer) {
+	addr := ""
+	var id uint64
+	if context.client != nil {
+		id, addr = context.client.id, context.client.addr
+	}
+	log.Printf("Client %d on %s closed: %s", id, addr, reason)
+
+	switch reason {
+	case errQueryBufferLimit:
+		atomic.AddInt64(&looper.limits.queryDisconnections, 1)
+	case errOutputHardLimit, errOutputSoftLimit:
+		atomic.AddInt64(&looper.limits.outputDisconnections, 1)
+	default:
+		w.WriteErrorString(reason.Error())
+		w.Flush()
+	}
+}
+
+// isTimeout reports whether err is timeout of network operation
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
@@ -0,0 +1,272 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+// chunkReader returns one chunk per read
+type chunkReader struct {
+	chunks []string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks[0] = r.chunks[0][n:]
+	if r.chunks[0] == "" {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+// readQuery reads chunks through queryReader and returns error of limits
+func readQuery(limits *clientLimits, chunks ...string) (*queryReader, error) {
+	qr := &queryReader{r: &chunkReader{append([]string(nil), chunks...)}, limits: limits}
+	p := make([]byte, 4096)
+	for {
+		_, err := qr.Read(p)
+		if err == io.EOF {
+			return qr, nil
+		}
+		if err != nil {
+			return qr, err
+		}
+	}
+}
+
+// splitBytes splits s into chunks of one byte
+func splitBytes(s string) []string {
+	chunks := make([]string, len(s))
+	for i := range s {
+		chunks[i] = s[i : i+1]
+	}
+	return chunks
+}
+
+func TestQueryReader(t *testing.T) {
+	RegisterTestingT(t)
+
+	limits := &clientLimits{queryBuffer: 64, protoMaxBulkLen: 16}
+	get := "*2\r\n$3\r\nget\r\n$1\r\na\r\n"
+
+	tests := []struct {
+		chunks []string
+		err    error
+	}{
+		{[]string{get}, nil},
+		{[]string{get + get + "ping\r\n\r\n*0\r\n" + get}, nil},
+		// command split across reads
+		{splitBytes(get + "ping\r\n" + get), nil},
+		{[]string{"*2\r\n$3\r\nge", "t\r\n$16\r\n0123456789", "abcdef\r\n"}, nil},
+		{[]string{"*2\r\n$3\r\nget\r\n$17\r\n"}, errInvalidBulkLen},
+		{splitBytes("*2\r\n$3\r\nget\r\n$17\r\n"), errInvalidBulkLen},
+		{[]string{"*1\r\n$-1\r\n"}, errInvalidBulkLen},
+		{[]string{"*1\r\n$x\r\n"}, errInvalidBulkLen},
+		{[]string{"*1\r\n$" + strings.Repeat("0", maxHeaderLen+1)}, errInvalidBulkLen},
+		{[]string{"*1048577\r\n"}, errInvalidMultibulkLen},
+		{[]string{"*1048", "577\r\n"}, errInvalidMultibulkLen},
+		{[]string{"*x\r\n"}, errInvalidMultibulkLen},
+		{[]string{"*" + strings.Repeat("0", maxHeaderLen+1)}, errInvalidMultibulkLen},
+		// bulks within limit exceeding query buffer limit together
+		{[]string{"*5\r\n" + strings.Repeat("$16\r\n0123456789abcdef\r\n", 3)}, errQueryBufferLimit},
+		{splitBytes("*5\r\n" + strings.Repeat("$16\r\n0123456789abcdef\r\n", 3)), errQueryBufferLimit},
+		{[]string{"ping " + strings.Repeat("a", 64) + "\r\n"}, errQueryBufferLimit},
+	}
+	for _, test := range tests {
+		qr, err := readQuery(limits, test.chunks...)
+		if test.err == nil {
+			Expect(err).NotTo(HaveOccurred(), "%q", test.chunks)
+			Expect(qr.state).To(Equal(scanStart), "%q", test.chunks)
+			continue
+		}
+		Expect(err).To(Equal(test.err), "%q", test.chunks)
+
+		// error is sticky
+		n, err := qr.Read(make([]byte, 16))
+		Expect(n).To(BeZero())
+		Expect(err).To(Equal(test.err))
+	}
+
+	_, err := readQuery(limits, "*1\r\n+3\r\n")
+	Expect(err).To(MatchError("ERR Protocol error: expected '$', got '+'"))
+}
+
+func TestQueryReaderInline(t *testing.T) {
+	RegisterTestingT(t)
+
+	// inline limit applies even if query buffer limit is disabled
+	limits := &clientLimits{protoMaxBulkLen: 16}
+	line := strings.Repeat("a", maxInlineLen-2)
+
+	_, err := readQuery(limits, "set a ", line[6:]+"\r\n", "get a\r\n")
+	Expect(err).NotTo(HaveOccurred())
+	_, err = readQuery(limits, line+"\r\n", line+"\r\n")
+	Expect(err).NotTo(HaveOccurred())
+	_, err = readQuery(limits, line+"\r\n"+line+"\r\n")
+	Expect(err).NotTo(HaveOccurred())
+	_, err = readQuery(limits, line, "a\r\n")
+	Expect(err).To(Equal(errTooBigInline))
+	_, err = readQuery(limits, line[:100], line, "\r\n")
+	Expect(err).To(Equal(errTooBigInline))
+
+	// multibulk commands are not limited by inline limit
+	bulk := strings.Repeat("a", maxInlineLen)
+	limits.protoMaxBulkLen = 1 << 20
+	_, err = readQuery(limits, "*2\r\n$3\r\nget\r\n$65536\r\n", bulk[:1000], bulk[1000:], "\r\n")
+	Expect(err).NotTo(HaveOccurred())
+}
+
+func TestParseOutputBufferLimit(t *testing.T) {
+	RegisterTestingT(t)
+
+	limits := map[string]OutputBufferLimit{"normal": {}, "pubsub": {Hard: 1, Soft: 1, SoftSeconds: 1}}
+	Expect(ParseOutputBufferLimit("Slave 1mb 512kb 10 normal 100 0 0", limits)).To(Succeed())
+	Expect(limits).To(Equal(map[string]OutputBufferLimit{
+		"normal":  {Hard: 100},
+		"replica": {Hard: 1 << 20, Soft: 512 << 10, SoftSeconds: 10},
+		"pubsub":  {Hard: 1, Soft: 1, SoftSeconds: 1},
+	}))
+
+	tests := []struct {
+		s   string
+		err string
+	}{
+		{"", errOutputLimitFormat.Error()},
+		{"normal 0 0", errOutputLimitFormat.Error()},
+		{"normal 0 0 0 pubsub", errOutputLimitFormat.Error()},
+		{"master 0 0 0", "ERR Invalid client class specified in buffer limit configuration: master"},
+		{"normal 1gb 0 0 pubsub many 0 0", ""},
+		{"normal 0 many 0", ""},
+		{"normal 0 0 many", `ERR Invalid soft limit seconds "many"`},
+		{"normal 0 0 -1", `ERR Invalid soft limit seconds "-1"`},
+	}
+	for _, test := range tests {
+		err := ParseOutputBufferLimit(test.s, limits)
+		Expect(err).To(HaveOccurred(), test.s)
+		if test.err != "" {
+			Expect(err.Error()).To(Equal(test.err))
+		}
+	}
+	// limits are not changed by invalid value
+	Expect(limits["normal"]).To(Equal(OutputBufferLimit{Hard: 100}))
+}
+
+// timeoutError is net.Error of write deadline
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// timeoutWriter fails writes once deadline is passed
+type timeoutWriter struct {
+	bytes.Buffer
+	deadline time.Time
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	if !w.deadline.IsZero() && !time.Now().Before(w.deadline) {
+		return 0, timeoutError{}
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestCountWriterHardLimit(t *testing.T) {
+	RegisterTestingT(t)
+
+	var buf bytes.Buffer
+	var total int64
+	cw := &countWriter{w: &buf, total: &total}
+	Expect(cw.checkLimit(1 << 40)).To(Succeed())
+
+	cw = &countWriter{w: &buf, total: &total, limit: &outputLimit{hard: 10}}
+	Expect(cw.checkLimit(10)).To(Succeed())
+	Expect(cw.checkLimit(11)).To(Equal(errOutputHardLimit))
+
+	// limit applies to reply since mark
+	_, err := cw.Write([]byte("123456"))
+	Expect(err).NotTo(HaveOccurred())
+	cw.mark()
+	_, err = cw.Write([]byte("123456"))
+	Expect(err).NotTo(HaveOccurred())
+	_, err = cw.Write([]byte("12345"))
+	Expect(err).To(Equal(errOutputHardLimit))
+	Expect(buf.String()).To(Equal("123456123456"))
+	Expect(total).To(Equal(int64(12)))
+
+	// client exceeding limit is disconnected so error is sticky
+	cw.mark()
+	_, err = cw.Write([]byte("1"))
+	Expect(err).To(Equal(errOutputHardLimit))
+	Expect(cw.Flush()).To(Equal(errOutputHardLimit))
+}
+
+func TestCountWriterSoftLimit(t *testing.T) {
+	RegisterTestingT(t)
+
+	w := &timeoutWriter{}
+	var total int64
+	var deadlines []time.Time
+	cw := &countWriter{w: w, total: &total, limit: &outputLimit{soft: 5, softSeconds: 60}}
+	cw.deadline = func(t time.Time) error {
+		deadlines = append(deadlines, t)
+		return nil
+	}
+
+	_, err := cw.Write([]byte("12345"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(cw.softSince.IsZero()).To(BeTrue())
+	Expect(deadlines).To(BeEmpty())
+
+	// exceeding soft limit starts timer and sets write deadline
+	start := time.Now()
+	_, err = cw.Write([]byte("6"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(cw.softSince.IsZero()).To(BeFalse())
+	Expect(deadlines).To(HaveLen(1))
+	Expect(deadlines[0]).To(BeTemporally("~", start.Add(60*time.Second), time.Second))
+
+	// reply staying below soft limit resets timer and deadline
+	cw.mark()
+	Expect(cw.softSince.IsZero()).To(BeTrue())
+	Expect(deadlines).To(HaveLen(2))
+	Expect(deadlines[1].IsZero()).To(BeTrue())
+
+	// reply staying above soft limit for given seconds disconnects client
+	_, err = cw.Write([]byte("123456"))
+	Expect(err).NotTo(HaveOccurred())
+	_, err = cw.Write([]byte("7"))
+	Expect(err).NotTo(HaveOccurred())
+	cw.softSince = cw.softSince.Add(-60 * time.Second)
+	_, err = cw.Write([]byte("8"))
+	Expect(err).To(Equal(errOutputSoftLimit))
+	Expect(w.String()).To(Equal("123456" + "1234567"))
+
+	// client not reading reply is disconnected by write deadline
+	w = &timeoutWriter{}
+	cw = &countWriter{w: w, total: &total, limit: &outputLimit{soft: 5, softSeconds: 60}}
+	cw.deadline = func(t time.Time) error {
+		w.deadline = t
+		return nil
+	}
+	_, err = cw.Write([]byte("123456"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(w.deadline.IsZero()).To(BeFalse())
+	w.deadline = time.Now()
+	_, err = cw.Write([]byte("7"))
+	Expect(err).To(Equal(errOutputSoftLimit))
+	Expect(cw.Flush()).To(Equal(errOutputSoftLimit))
+
+	// zero seconds disconnects client once reply exceeds soft limit
+	cw = &countWriter{w: &timeoutWriter{}, total: &total, limit: &outputLimit{soft: 5}}
+	_, err = cw.Write([]byte("123456"))
+	Expect(err).To(Equal(errOutputSoftLimit))
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"io"
+	"time"
 
 	"github.com/valery-barysok/gredisd/app/cmd"
 	"github.com/valery-barysok/resp"
@@ -10,20 +11,41 @@ import (
 type looper struct {
 	protocol *resp.Protocol
 	router   *router
+	net      *netStats
+	limits   *clientLimits
 }
 
-func newLooper(protocol *resp.Protocol, router *router) *looper {
+func newLooper(protocol *resp.Protocol, router *router, net *netStats, limits *clientLimits) *looper {
 	return &looper{
 		protocol: protocol,
 		router:   router,
+		net:      net,
+		limits:   limits,
 	}
 }
 
-func (looper *looper) loop(context *ClientContext, r io.Reader, w io.Writer) {
-	reader := resp.NewReader(r, looper.protocol)
-	writer := resp.NewWriter(w, looper.protocol)
+// wrap counts traffic of client reading from r and writing to w and applies
+// limits of client buffers, deadline sets write deadline of client connection
+func (looper *looper) wrap(context *ClientContext, r io.Reader, w io.Writer, deadline func(t time.Time) error) {
+	context.in = &countReader{r: r, total: &looper.net.in}
+	context.out = &countWriter{
+		w:        w,
+		total:    &looper.net.out,
+		limit:    &looper.limits.output[normalClass],
+		deadline: deadline,
+	}
+	context.query = &queryReader{r: context.in, limits: looper.limits}
+}
+
+func (looper *looper) loop(context *ClientContext) {
+	reader := resp.NewReader(context.query, looper.protocol)
+	writer := resp.NewWriter(context.out, looper.protocol)
 	for {
 		cmd, err := cmd.ReadCommand(reader)
+		if context.query.err != nil {
+			looper.disconnect(context, context.query.err, writer)
+			return
+		}
 		if err != nil {
 			if looper.router.errorHandler != nil {
 				looper.router.errorHandler(context, err, writer)
@@ -31,11 +53,24 @@ func (looper *looper) loop(context *ClientContext, r io.Reader, w io.Writer) {
 			return
 		}
 
+		if context.client != nil {
+			context.client.touch(cmd.Cmd)
+		}
+
 		if err := looper.router.serve(context, cmd, writer); err != nil {
 			if looper.router.errorHandler != nil {
 				looper.router.errorHandler(context, err, writer)
 			}
 			return
 		}
+
+		if context.out.err != nil {
+			looper.disconnect(context, context.out.err, writer)
+			return
+		}
+
+		if context.closeAfterReply {
+			return
+		}
 	}
 }
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are upper bounds of command latency histogram buckets
+var latencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	1 * time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+}
+
+// serveMetrics starts HTTP listener exposing metrics in Prometheus text format on /metrics
+func (app *App) serveMetrics(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", app.metricsHandler)
+
+	app.metrics = l
+	log.Printf("Listening for metrics requests on %s", l.Addr())
+	go http.Serve(l, mux)
+	return nil
+}
+
+func (app *App) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	app.writeMetrics(&buf)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
+
+func metricHeader(buf *bytes.Buffer, name string, typ string, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func metric(buf *bytes.Buffer, name string, typ string, help string, value interface{}) {
+	metricHeader(buf, name, typ, help)
+	fmt.Fprintf(buf, "%s %v\n", name, value)
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
+
+func (app *App) writeMetrics(buf *bytes.Buffer) {
+	var uptime time.Duration
+	var connected int
+	var connections, rejected uint64
+	if app.server != nil {
+		uptime = time.Since(app.server.StartTime())
+		connected = app.server.ClientsCount()
+		connections = app.server.ConnectionsReceived()
+		rejected = app.server.RejectedConnections()
+	}
+
+	metricHeader(buf, "gredis_info", "gauge", "Version of server and Go runtime.")
+	fmt.Fprintf(buf, "gredis_info{version=%q,go_version=%q} 1\n", app.info.Version, app.info.GoVersion)
+	metric(buf, "gredis_uptime_seconds", "gauge", "Time since server start.", int64(uptime/time.Second))
+
+	metric(buf, "gredis_connected_clients", "gauge", "Number of connected clients.", connected)
+	metric(buf, "gredis_blocked_clients", "gauge", "Number of clients blocked by blocking commands.",
+		atomic.LoadInt64(&app.blockedClients))
+	metric(buf, "gredis_connections_received_total", "counter", "Number of accepted connections.", connections)
+	metric(buf, "gredis_rejected_connections_total", "counter", "Number of connections rejected due to maxclients limit.",
+		rejected)
+	metric(buf, "gredis_net_input_bytes_total", "counter", "Number of bytes read from clients.",
+		atomic.LoadInt64(&app.net.in))
+	metric(buf, "gredis_net_output_bytes_total", "counter", "Number of bytes written to clients.",
+		atomic.LoadInt64(&app.net.out))
+
+	app.writeCommandMetrics(buf)
+
+	metricHeader(buf, "gredis_db_keys", "gauge", "Number of keys per database.")
+	keyspace := app.model.Keyspace()
+	for _, db := range keyspace {
+		fmt.Fprintf(buf, "gredis_db_keys{db=\"%d\"} %d\n", db.Index, db.Keys)
+	}
+	metricHeader(buf, "gredis_db_expiring_keys", "gauge", "Number of keys with TTL per database.")
+	for _, db := range keyspace {
+		fmt.Fprintf(buf, "gredis_db_expiring_keys{db=\"%d\"} %d\n", db.Index, db.Expires)
+	}
+
+	metric(buf, "gredis_expired_keys_total", "counter", "Number of keys removed since their TTL had expired.",
+		app.model.ExpiredKeys())
+	metric(buf, "gredis_evicted_keys_total", "counter", "Number of keys evicted due to maxmemory limit.",
+		app.model.EvictedKeys())
+	metric(buf, "gredis_memory_used_bytes", "gauge", "Estimated memory taken by keys and values.",
+		app.model.UsedMemory())
+	metric(buf, "gredis_memory_max_bytes", "gauge", "Value of maxmemory, 0 means no limit.",
+		app.model.MaxMemory())
+	metric(buf, "gredis_persistence_enabled", "gauge", "Whether data is persisted, data is kept in memory only.", 0)
+}
+
+func (app *App) writeCommandMetrics(buf *bytes.Buffer) {
+	names := make([]string, 0, len(app.router.stats))
+	for name, stat := range app.router.stats {
+		if atomic.LoadInt64(&stat.calls) > 0 || atomic.LoadInt64(&stat.rejected) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	metricHeader(buf, "gredis_commands_total", "counter", "Number of processed commands by name and status.")
+	for _, name := range names {
+		stat := app.router.stats[name]
+		calls := atomic.LoadInt64(&stat.calls)
+		failed := atomic.LoadInt64(&stat.failed)
+		fmt.Fprintf(buf, "gredis_commands_total{command=%q,status=\"ok\"} %d\n", name, calls-failed)
+		fmt.Fprintf(buf, "gredis_commands_total{command=%q,status=\"error\"} %d\n", name, failed)
+		fmt.Fprintf(buf, "gredis_commands_total{command=%q,status=\"rejected\"} %d\n", name,
+			atomic.LoadInt64(&stat.rejected))
+	}
+
+	metricHeader(buf, "gredis_command_duration_seconds", "histogram", "Latency of commands by name.")
+	for _, name := range names {
+		stat := app.router.stats[name]
+		calls := atomic.LoadInt64(&stat.calls)
+		if calls == 0 {
+			continue
+		}
+
+		var cumulative int64
+		for i, bucket := range latencyBuckets {
+			cumulative += atomic.LoadInt64(&stat.latency[i])
+			fmt.Fprintf(buf, "gredis_command_duration_seconds_bucket{command=%q,le=%q} %d\n",
+				name, formatSeconds(bucket), cumulative)
+		}
+		fmt.Fprintf(buf, "gredis_command_duration_seconds_bucket{command=%q,le=\"+Inf\"} %d\n", name, calls)
+		fmt.Fprintf(buf, "gredis_command_duration_seconds_sum{command=%q} %s\n", name,
+			formatSeconds(time.Duration(atomic.LoadInt64(&stat.usec))*time.Microsecond))
+		fmt.Fprintf(buf, "gredis_command_duration_seconds_count{command=%q} %d\n", name, calls)
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+var metricBucket = regexp.MustCompile(`^gredis_command_duration_seconds_bucket\{command="(\w+)",le="([^"]+)"\} (\d+)$`)
+
+// checkExposition checks that every sample belongs to family declared by HELP
+// and TYPE lines before it and returns samples
+func checkExposition(text string) []string {
+	Expect(text).To(HaveSuffix("\n"))
+
+	var samples []string
+	family, typ := "", ""
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "# HELP ") {
+			fields := strings.SplitN(line, " ", 4)
+			Expect(fields).To(HaveLen(4), line)
+			family = fields[2]
+
+			i++
+			Expect(i).To(BeNumerically("<", len(lines)), line)
+			fields = strings.Fields(lines[i])
+			Expect(fields).To(HaveLen(4), lines[i])
+			Expect(fields[:3]).To(Equal([]string{"#", "TYPE", family}))
+			typ = fields[3]
+			Expect(typ).To(BeElementOf("counter", "gauge", "histogram"))
+			continue
+		}
+
+		name := line
+		if n := strings.IndexAny(line, "{ "); n >= 0 {
+			name = line[:n]
+		}
+		if typ == "histogram" {
+			name = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(name, "_bucket"), "_sum"), "_count")
+		}
+		Expect(name).To(Equal(family), line)
+		_, err := strconv.ParseFloat(line[strings.LastIndex(line, " ")+1:], 64)
+		Expect(err).NotTo(HaveOccurred(), line)
+		samples = append(samples, line)
+	}
+	return samples
+}
+
+func TestWriteMetrics(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+	db, _ := app.SelectIndex(2)
+	db.Set([]byte("a"), []byte("1"))
+	db.Set([]byte("b"), []byte("2"))
+	db.ExpireN([]byte("b"), 3600)
+
+	var buf bytes.Buffer
+	app.writeMetrics(&buf)
+	samples := checkExposition(buf.String())
+	Expect(samples).To(ContainElement(`gredis_info{version="` + Version + `",go_version="` + app.info.GoVersion + `"} 1`))
+	Expect(samples).To(ContainElement("gredis_connected_clients 0"))
+	Expect(samples).To(ContainElement(`gredis_db_keys{db="2"} 2`))
+	Expect(samples).To(ContainElement(`gredis_db_expiring_keys{db="2"} 1`))
+	Expect(samples).To(ContainElement("gredis_persistence_enabled 0"))
+	Expect(buf.String()).To(ContainSubstring("# HELP gredis_commands_total Number of processed commands by name and status.\n" +
+		"# TYPE gredis_commands_total counter\n"))
+	Expect(buf.String()).To(ContainSubstring("# TYPE gredis_command_duration_seconds histogram\n"))
+}
+
+func TestWriteCommandMetrics(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+	for _, name := range []string{"get", "set", "del", "ping"} {
+		app.Bind(name, nopHandler)
+	}
+
+	get := app.router.stats["get"]
+	for _, d := range []time.Duration{50 * time.Microsecond, 100 * time.Microsecond, 200 * time.Microsecond, 3 * time.Millisecond, 2 * time.Second} {
+		get.record(d, false)
+	}
+	get.record(time.Millisecond, true)
+	app.router.stats["set"].record(time.Second, true)
+	app.router.stats["del"].rejected = 3
+
+	var buf bytes.Buffer
+	app.writeCommandMetrics(&buf)
+	samples := checkExposition(buf.String())
+
+	// commands without calls are skipped, rejected ones are reported
+	Expect(samples).To(ContainElement(`gredis_commands_total{command="get",status="ok"} 5`))
+	Expect(samples).To(ContainElement(`gredis_commands_total{command="get",status="error"} 1`))
+	Expect(samples).To(ContainElement(`gredis_commands_total{command="get",status="rejected"} 0`))
+	Expect(samples).To(ContainElement(`gredis_commands_total{command="set",status="ok"} 0`))
+	Expect(samples).To(ContainElement(`gredis_commands_total{command="set",status="error"} 1`))
+	Expect(samples).To(ContainElement(`gredis_commands_total{command="del",status="rejected"} 3`))
+	Expect(buf.String()).NotTo(ContainSubstring(`command="ping"`))
+
+	// buckets are cumulative and +Inf bucket counts all calls
+	buckets := make(map[string][]string)
+	counts := make(map[string][]int64)
+	for _, sample := range samples {
+		match := metricBucket.FindStringSubmatch(sample)
+		if match == nil {
+			continue
+		}
+		n, _ := strconv.ParseInt(match[3], 10, 64)
+		buckets[match[1]] = append(buckets[match[1]], match[2])
+		counts[match[1]] = append(counts[match[1]], n)
+	}
+	Expect(buckets).To(HaveLen(2))
+	for name, values := range counts {
+		Expect(buckets[name]).To(HaveLen(len(latencyBuckets) + 1))
+		Expect(buckets[name][len(latencyBuckets)]).To(Equal("+Inf"))
+		for i := 1; i < len(values); i++ {
+			Expect(values[i]).To(BeNumerically(">=", values[i-1]), name)
+		}
+		Expect(samples).To(ContainElement(`gredis_command_duration_seconds_count{command="` + name + `"} ` +
+			strconv.FormatInt(values[len(values)-1], 10)))
+	}
+	Expect(buckets["get"][:4]).To(Equal([]string{"0.0001", "0.00025", "0.0005", "0.001"}))
+	Expect(counts["get"]).To(Equal([]int64{2, 3, 3, 4, 4, 5, 5, 5, 5, 5, 5, 5, 5, 6}))
+	Expect(counts["set"]).To(Equal([]int64{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1}))
+	Expect(samples).To(ContainElement(`gredis_command_duration_seconds_sum{command="get"} 2.00435`))
+	Expect(samples).To(ContainElement(`gredis_command_duration_seconds_sum{command="set"} 1`))
+}
@@ -3,24 +3,42 @@ package model
 import (
 	"container/list"
 	"errors"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
-var errInvalidDBIndex = errors.New("ERR invalid DB index")
+var (
+	errInvalidDBIndex       = errors.New("ERR invalid DB index")
+	errInvalidFirstDBIndex  = errors.New("ERR invalid first DB index")
+	errInvalidSecondDBIndex = errors.New("ERR invalid second DB index")
+	errDBIndexOutOfRange    = errors.New("ERR DB index is out of range")
+)
 
 type AppModel struct {
-	mu        sync.Mutex
+	// maxmemory settings and eviction counters are accessed atomically
+	maxMemory        int64
+	maxMemoryPolicy  int32
+	maxMemorySamples int32
+	evictedKeys      int64
+	evictionTime     int64
+
+	// mu protects dbs which are created on first select
+	mu        sync.RWMutex
 	databases int
+	encodings Encodings
 	dbs       map[int]*DBModel
 	commands  *list.List
 }
 
-func NewAppModel(databases int) *AppModel {
+func NewAppModel(databases int, encodings Encodings) *AppModel {
 	return &AppModel{
-		databases: databases,
-		dbs:       make(map[int]*DBModel),
-		commands:  list.New(),
+		maxMemorySamples: DefaultMaxMemorySamples,
+		databases:        databases,
+		encodings:        encodings,
+		dbs:              make(map[int]*DBModel),
+		commands:         list.New(),
 	}
 }
 
@@ -38,7 +56,9 @@ func (model *AppModel) SelectIndex(index int) (*DBModel, error) {
 		return nil, errInvalidDBIndex
 	}
 
+	model.mu.RLock()
 	db, ok := model.dbs[index]
+	model.mu.RUnlock()
 	if ok {
 		return db, nil
 	}
@@ -52,6 +72,8 @@ func (model *AppModel) SelectIndex(index int) (*DBModel, error) {
 	}
 
 	db = newDBModel(index)
+	db.kv.enc = model.encodings
+	db.app = model
 	model.dbs[db.index] = db
 
 	return db, nil
@@ -68,3 +90,126 @@ func (model *AppModel) Commands() []interface{} {
 	}
 	return cmds
 }
+
+// FlushAll removes keys of all databases, it accepts optional ASYNC or SYNC mode
+func (model *AppModel) FlushAll(args ...[]byte) error {
+	if err := parseFlushMode(args); err != nil {
+		return err
+	}
+
+	for _, db := range model.created() {
+		db.FlushDBN()
+	}
+	return nil
+}
+
+func (model *AppModel) SwapDB(index1 string, index2 string) error {
+	ind1, err := strconv.Atoi(index1)
+	if err != nil {
+		return errInvalidFirstDBIndex
+	}
+
+	ind2, err := strconv.Atoi(index2)
+	if err != nil {
+		return errInvalidSecondDBIndex
+	}
+
+	return model.SwapDBIndex(ind1, ind2)
+}
+
+// SwapDBIndex exchanges contents of two databases. Clients keep their selected
+// database and see contents of the other one right after the swap
+func (model *AppModel) SwapDBIndex(index1 int, index2 int) error {
+	db1, err := model.SelectIndex(index1)
+	if err != nil {
+		return errDBIndexOutOfRange
+	}
+
+	db2, err := model.SelectIndex(index2)
+	if err != nil {
+		return errDBIndexOutOfRange
+	}
+
+	if db1 == db2 {
+		return nil
+	}
+
+	unlock := lockDBs(db1, db2)
+	defer unlock()
+
+	swapKeys(db1.kv, db2.kv)
+	return nil
+}
+
+// created returns databases which have been selected at least once
+func (model *AppModel) created() []*DBModel {
+	model.mu.RLock()
+	defer model.mu.RUnlock()
+
+	dbs := make([]*DBModel, 0, len(model.dbs))
+	for _, db := range model.dbs {
+		dbs = append(dbs, db)
+	}
+	return dbs
+}
+
+// sorted returns created databases in order of their indexes
+func (model *AppModel) sorted() []*DBModel {
+	dbs := model.created()
+	byIndex := make(map[int]*DBModel, len(dbs))
+	indexes := make([]int, 0, len(dbs))
+	for _, db := range dbs {
+		byIndex[db.index] = db
+		indexes = append(indexes, db.index)
+	}
+	sort.Ints(indexes)
+
+	for i, index := range indexes {
+		dbs[i] = byIndex[index]
+	}
+	return dbs
+}
+
+// KeyspaceStats is number of keys of database
+type KeyspaceStats struct {
+	Index   int
+	Keys    int
+	Expires int
+}
+
+// Keyspace returns number of keys of non empty databases
+func (model *AppModel) Keyspace() []KeyspaceStats {
+	var stats []KeyspaceStats
+	for _, db := range model.sorted() {
+		keys, expires, _ := db.kv.Stats()
+		if keys > 0 {
+			stats = append(stats, KeyspaceStats{Index: db.index, Keys: keys, Expires: expires})
+		}
+	}
+	return stats
+}
+
+// ExpiredKeys returns number of keys removed since their TTL had expired
+func (model *AppModel) ExpiredKeys() int64 {
+	var expired int64
+	for _, db := range model.created() {
+		expired += atomic.LoadInt64(&db.kv.expired)
+	}
+	return expired
+}
+
+// ResetStats resets counters of expired and evicted keys
+func (model *AppModel) ResetStats() {
+	atomic.StoreInt64(&model.evictedKeys, 0)
+	atomic.StoreInt64(&model.evictionTime, 0)
+	for _, db := range model.created() {
+		atomic.StoreInt64(&db.kv.expired, 0)
+	}
+}
+
+// ActiveExpire runs active expiration cycle over all created databases
+func (model *AppModel) ActiveExpire() {
+	for _, db := range model.created() {
+		db.kv.ActiveExpire()
+	}
+}
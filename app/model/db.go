@@ -3,22 +3,79 @@ package model
 import (
 	"bytes"
 	"errors"
+	"math"
 	"strconv"
+	"time"
 )
 
 var (
-	errSyntax         = errors.New("syntax error")
-	errInvalidInteger = errors.New("value is not an integer or out of range")
+	errSyntax            = errors.New("syntax error")
+	errInvalidInteger    = errors.New("value is not an integer or out of range")
+	errNotPositive       = errors.New("value is out of range, must be positive")
+	errNumKeys           = errors.New("numkeys should be greater than 0")
+	errNumKeysArgs       = errors.New("Number of keys can't be greater than number of args")
+	errLimitNegative     = errors.New("LIMIT can't be negative")
+	errXXNX              = errors.New("XX and NX options at the same time are not compatible")
+	errGTLTNX            = errors.New("GT, LT, and/or NX options at the same time are not compatible")
+	errIncrPair          = errors.New("INCR option supports a single increment-element pair")
+	errLimitByRank       = errors.New("syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	errWithScoresByLex   = errors.New("syntax error, WITHSCORES not supported in combination with BYLEX")
+	errZStoreNumKeys     = errors.New("at least 1 input key is needed for this command")
+	errWeightNotFloat    = errors.New("weight value is not a float")
+	errInvalidExpireTime = errors.New("invalid expire time")
+	errFieldsArg         = errors.New("mandatory argument FIELDS is missing or not at the right position")
+	errNumFields         = errors.New("Parameter `numFields` should be greater than 0")
+	errNumFieldsArgs     = errors.New("The `numfields` parameter must match the number of arguments")
+	errRankZero          = errors.New("RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+	errCountNegative     = errors.New("COUNT can't be negative")
+	errCountPositive     = errors.New("count should be greater than 0")
+	errMaxLenNegative    = errors.New("MAXLEN can't be negative")
+	errTimeoutNotFloat   = errors.New("timeout is not a float or out of range")
+	errTimeoutNegative   = errors.New("timeout is negative")
+	errObjectSubcommand  = errors.New("unknown subcommand or wrong number of arguments for OBJECT")
+	errDBIndexRange      = errors.New("DB index is out of range")
 )
 
 var (
-	insertBefore = []byte("BEFORE")
-	insertAfter  = []byte("AFTER")
+	insertBefore  = []byte("BEFORE")
+	insertAfter   = []byte("AFTER")
+	limitArg      = []byte("LIMIT")
+	nxArg         = []byte("NX")
+	xxArg         = []byte("XX")
+	gtArg         = []byte("GT")
+	ltArg         = []byte("LT")
+	chArg         = []byte("CH")
+	incrArg       = []byte("INCR")
+	byScoreArg    = []byte("BYSCORE")
+	byLexArg      = []byte("BYLEX")
+	revArg        = []byte("REV")
+	withScoresArg = []byte("WITHSCORES")
+	withValuesArg = []byte("WITHVALUES")
+	fieldsArg     = []byte("FIELDS")
+	rankArg       = []byte("RANK")
+	countArg      = []byte("COUNT")
+	maxLenArg     = []byte("MAXLEN")
+	leftArg       = []byte("LEFT")
+	rightArg      = []byte("RIGHT")
+	weightsArg    = []byte("WEIGHTS")
+	aggregateArg  = []byte("AGGREGATE")
+	sumArg        = []byte("SUM")
+	minArg        = []byte("MIN")
+	maxArg        = []byte("MAX")
+	encodingArg   = []byte("ENCODING")
+	dbArg         = []byte("DB")
+	replaceArg    = []byte("REPLACE")
+	asyncArg      = []byte("ASYNC")
+	syncArg       = []byte("SYNC")
+	samplesArg    = []byte("SAMPLES")
 )
 
 type DBModel struct {
 	index int
 	kv    *kvModel
+
+	// app is model database belongs to, it is nil for standalone database
+	app *AppModel
 }
 
 func newDBModel(index int) *DBModel {
@@ -29,6 +86,11 @@ func newDBModel(index int) *DBModel {
 	return model
 }
 
+// Index returns index of database
+func (db *DBModel) Index() int {
+	return db.index
+}
+
 func (db *DBModel) Keys(pattern []byte) ([]interface{}, error) {
 	return db.kv.Keys(pattern)
 }
@@ -49,6 +111,212 @@ func (db *DBModel) Exists(keys ...[]byte) int {
 	return db.kv.Exists(keys...)
 }
 
+// Object returns nil if key does not exist
+func (db *DBModel) Object(subcommand []byte, key []byte) ([]byte, error) {
+	if !bytes.EqualFold(subcommand, encodingArg) {
+		return nil, errObjectSubcommand
+	}
+
+	enc := db.kv.ObjectEncoding(key)
+	if enc == "" {
+		return nil, nil
+	}
+	return []byte(enc), nil
+}
+
+func (db *DBModel) Type(key []byte) []byte {
+	return []byte(db.kv.Type(key))
+}
+
+func (db *DBModel) Rename(src []byte, dst []byte) error {
+	_, err := db.kv.Rename(src, dst, false)
+	return err
+}
+
+func (db *DBModel) RenameNX(src []byte, dst []byte) (int, error) {
+	return db.kv.Rename(src, dst, true)
+}
+
+func (db *DBModel) RandomKey() []byte {
+	return db.kv.RandomKey()
+}
+
+func (db *DBModel) DBSize() int {
+	return db.kv.DBSize()
+}
+
+func (db *DBModel) Touch(keys ...[]byte) int {
+	return db.kv.Touch(keys...)
+}
+
+func (db *DBModel) Unlink(keys ...[]byte) int {
+	return db.kv.Unlink(keys...)
+}
+
+// Copy accepts [DB destination-db] [REPLACE] options
+func (db *DBModel) Copy(src []byte, dst []byte, args ...[]byte) (int, error) {
+	to := db
+	replace := false
+	for i := 0; i < len(args); i++ {
+		if bytes.EqualFold(args[i], dbArg) && i+1 < len(args) {
+			i++
+			index, err := strconv.Atoi(string(args[i]))
+			if err != nil {
+				return 0, errInvalidInteger
+			}
+
+			if index != db.index {
+				if db.app == nil {
+					return 0, errDBIndexRange
+				}
+
+				to, err = db.app.SelectIndex(index)
+				if err != nil {
+					return 0, errDBIndexRange
+				}
+			}
+		} else if bytes.EqualFold(args[i], replaceArg) {
+			replace = true
+		} else {
+			return 0, errSyntax
+		}
+	}
+
+	return db.CopyN(src, to, dst, replace)
+}
+
+func (db *DBModel) CopyN(src []byte, to *DBModel, dst []byte, replace bool) (int, error) {
+	unlock := lockDBs(db, to)
+	defer unlock()
+
+	return copyKey(db.kv, src, to.kv, dst, replace)
+}
+
+// Move moves key to database with index
+func (db *DBModel) Move(key []byte, index []byte) (int, error) {
+	ind, err := strconv.Atoi(string(index))
+	if err != nil {
+		return 0, errInvalidInteger
+	}
+
+	to := db
+	if ind != db.index {
+		if db.app == nil {
+			return 0, errDBIndexRange
+		}
+
+		to, err = db.app.SelectIndex(ind)
+		if err != nil {
+			return 0, errDBIndexRange
+		}
+	}
+
+	return db.MoveN(key, to)
+}
+
+func (db *DBModel) MoveN(key []byte, to *DBModel) (int, error) {
+	unlock := lockDBs(db, to)
+	defer unlock()
+
+	return moveKey(db.kv, to.kv, key)
+}
+
+// FlushDB accepts optional ASYNC or SYNC mode
+func (db *DBModel) FlushDB(args ...[]byte) error {
+	if err := parseFlushMode(args); err != nil {
+		return err
+	}
+
+	db.FlushDBN()
+	return nil
+}
+
+func (db *DBModel) FlushDBN() {
+	db.kv.Flush()
+}
+
+// parseFlushMode checks optional ASYNC or SYNC mode. Both modes are the same as
+// removed keys are detached from database and freed by garbage collector which
+// runs concurrently, so flushing never blocks on freeing values
+func parseFlushMode(args [][]byte) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	if len(args) == 1 && (bytes.EqualFold(args[0], asyncArg) || bytes.EqualFold(args[0], syncArg)) {
+		return nil
+	}
+	return errSyntax
+}
+
+// MemoryUsage accepts [SAMPLES count] option and returns -1 if key does not exist
+func (db *DBModel) MemoryUsage(key []byte, args ...[]byte) (int, error) {
+	samples, err := parseSamples(args)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(db.kv.MemoryUsage(key, samples)), nil
+}
+
+// KeysReport reports the biggest key of each type by number of elements or by
+// memory if mem is set. It accepts [SAMPLES count] option used to estimate memory
+func (db *DBModel) KeysReport(mem bool, args ...[]byte) ([]interface{}, error) {
+	samples, err := parseSamples(args)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := db.kv.keysReport(mem, samples)
+	reply := make([]interface{}, 0, len(reports))
+	for _, r := range reports {
+		var biggest interface{}
+		if r.keys > 0 {
+			biggest = []byte(r.biggest)
+		}
+		reply = append(reply, []interface{}{
+			[]byte(r.name), r.keys, int(r.total), biggest, int(r.size),
+		})
+	}
+	return reply, nil
+}
+
+func parseSamples(args [][]byte) (int, error) {
+	if len(args) == 0 {
+		return memSamples, nil
+	}
+
+	if len(args) != 2 || !bytes.EqualFold(args[0], samplesArg) {
+		return 0, errSyntax
+	}
+
+	samples, err := strconv.Atoi(string(args[1]))
+	if err != nil || samples < 0 {
+		return 0, errInvalidInteger
+	}
+	return samples, nil
+}
+
+// lockDBs locks both databases in order of their indexes so that concurrent
+// operations over the same pair of databases do not deadlock. It returns
+// function which unlocks databases
+func lockDBs(a *DBModel, b *DBModel) func() {
+	if b.index < a.index {
+		a, b = b, a
+	}
+
+	a.kv.lock()
+	if b.kv == a.kv {
+		return a.kv.unlock
+	}
+
+	b.kv.lock()
+	return func() {
+		b.kv.unlock()
+		a.kv.unlock()
+	}
+}
+
 func (db *DBModel) Expire(key []byte, seconds []byte) (int, error) {
 	s, err := strconv.ParseInt(string(seconds), 10, 64)
 	if err != nil {
@@ -127,14 +395,46 @@ func (db *DBModel) LRangeN(key []byte, start int, stop int) ([]interface{}, erro
 	return db.kv.LRange(key, start, stop)
 }
 
-func (db *DBModel) HSet(key []byte, field []byte, value []byte) (int, error) {
-	return db.kv.HSet(key, field, value)
+// HSet parses "field value [field value ...]" arguments
+func (db *DBModel) HSet(key []byte, args ...[]byte) (int, error) {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return 0, errSyntax
+	}
+
+	fields := make([][]byte, 0, len(args)/2)
+	values := make([][]byte, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		fields = append(fields, args[i])
+		values = append(values, args[i+1])
+	}
+
+	return db.kv.HSet(key, fields, values)
+}
+
+func (db *DBModel) HSetNX(key []byte, field []byte, value []byte) (int, error) {
+	return db.kv.HSetNX(key, field, value)
 }
 
 func (db *DBModel) HGet(key []byte, field []byte) ([]byte, error) {
 	return db.kv.HGet(key, field)
 }
 
+func (db *DBModel) HMGet(key []byte, fields ...[]byte) ([]interface{}, error) {
+	return db.kv.HMGet(key, fields...)
+}
+
+func (db *DBModel) HGetAll(key []byte) ([]interface{}, error) {
+	return db.kv.HGetAll(key)
+}
+
+func (db *DBModel) HKeys(key []byte) ([]interface{}, error) {
+	return db.kv.HKeys(key)
+}
+
+func (db *DBModel) HVals(key []byte) ([]interface{}, error) {
+	return db.kv.HVals(key)
+}
+
 func (db *DBModel) HDel(key []byte, fields ...[]byte) (int, error) {
 	return db.kv.HDel(key, fields...)
 }
@@ -146,3 +446,913 @@ func (db *DBModel) HLen(key []byte) (int, error) {
 func (db *DBModel) HExists(key []byte, field []byte) (int, error) {
 	return db.kv.HExists(key, field)
 }
+
+func (db *DBModel) HStrLen(key []byte, field []byte) (int, error) {
+	return db.kv.HStrLen(key, field)
+}
+
+func (db *DBModel) HIncrBy(key []byte, field []byte, increment []byte) (int64, error) {
+	i, err := strconv.ParseInt(string(increment), 10, 64)
+	if err != nil {
+		return 0, errInvalidInteger
+	}
+
+	return db.kv.HIncrBy(key, field, i)
+}
+
+func (db *DBModel) HIncrByFloat(key []byte, field []byte, increment []byte) ([]byte, error) {
+	f, err := strconv.ParseFloat(string(increment), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, errNotFloat
+	}
+
+	return db.kv.HIncrByFloat(key, field, f)
+}
+
+func (db *DBModel) HRandField(key []byte) ([]byte, error) {
+	fields, err := db.kv.HRandField(key, 1, false)
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	return fields[0].([]byte), nil
+}
+
+// HRandFieldCount parses "count [WITHVALUES]" arguments
+func (db *DBModel) HRandFieldCount(key []byte, count []byte, args ...[]byte) ([]interface{}, error) {
+	c, err := strconv.Atoi(string(count))
+	if err != nil {
+		return nil, errInvalidInteger
+	}
+
+	withValues := false
+	if len(args) > 0 {
+		if len(args) != 1 || !bytes.EqualFold(args[0], withValuesArg) {
+			return nil, errSyntax
+		}
+		withValues = true
+	}
+
+	return db.HRandFieldN(key, c, withValues)
+}
+
+func (db *DBModel) HRandFieldN(key []byte, count int, withValues bool) ([]interface{}, error) {
+	return db.kv.HRandField(key, count, withValues)
+}
+
+func (db *DBModel) SAdd(key []byte, members ...[]byte) (int, error) {
+	return db.kv.SAdd(key, members...)
+}
+
+func (db *DBModel) SRem(key []byte, members ...[]byte) (int, error) {
+	return db.kv.SRem(key, members...)
+}
+
+func (db *DBModel) SMembers(key []byte) ([]interface{}, error) {
+	return db.kv.SMembers(key)
+}
+
+func (db *DBModel) SIsMember(key []byte, member []byte) (int, error) {
+	return db.kv.SIsMember(key, member)
+}
+
+func (db *DBModel) SMIsMember(key []byte, members ...[]byte) ([]interface{}, error) {
+	return db.kv.SMIsMember(key, members...)
+}
+
+func (db *DBModel) SCard(key []byte) (int, error) {
+	return db.kv.SCard(key)
+}
+
+func (db *DBModel) SPop(key []byte) ([]byte, error) {
+	members, err := db.kv.SPop(key, 1)
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	return members[0].([]byte), nil
+}
+
+func (db *DBModel) SPopCount(key []byte, count []byte) ([]interface{}, error) {
+	c, err := strconv.Atoi(string(count))
+	if err != nil {
+		return nil, errInvalidInteger
+	}
+	if c < 0 {
+		return nil, errNotPositive
+	}
+
+	return db.SPopN(key, c)
+}
+
+func (db *DBModel) SPopN(key []byte, count int) ([]interface{}, error) {
+	return db.kv.SPop(key, count)
+}
+
+func (db *DBModel) SRandMember(key []byte) ([]byte, error) {
+	members, err := db.kv.SRandMember(key, 1)
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	return members[0].([]byte), nil
+}
+
+func (db *DBModel) SRandMemberCount(key []byte, count []byte) ([]interface{}, error) {
+	c, err := strconv.Atoi(string(count))
+	if err != nil {
+		return nil, errInvalidInteger
+	}
+
+	return db.SRandMemberN(key, c)
+}
+
+func (db *DBModel) SRandMemberN(key []byte, count int) ([]interface{}, error) {
+	return db.kv.SRandMember(key, count)
+}
+
+func (db *DBModel) SInter(keys ...[]byte) ([]interface{}, error) {
+	return db.kv.SInter(keys...)
+}
+
+func (db *DBModel) SUnion(keys ...[]byte) ([]interface{}, error) {
+	return db.kv.SUnion(keys...)
+}
+
+func (db *DBModel) SDiff(keys ...[]byte) ([]interface{}, error) {
+	return db.kv.SDiff(keys...)
+}
+
+func (db *DBModel) SInterStore(dst []byte, keys ...[]byte) (int, error) {
+	return db.kv.SInterStore(dst, keys...)
+}
+
+func (db *DBModel) SUnionStore(dst []byte, keys ...[]byte) (int, error) {
+	return db.kv.SUnionStore(dst, keys...)
+}
+
+func (db *DBModel) SDiffStore(dst []byte, keys ...[]byte) (int, error) {
+	return db.kv.SDiffStore(dst, keys...)
+}
+
+// SInterCard parses "numkeys key [key ...] [LIMIT limit]" arguments
+func (db *DBModel) SInterCard(args ...[]byte) (int, error) {
+	if len(args) < 2 {
+		return 0, errSyntax
+	}
+
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return 0, errInvalidInteger
+	}
+	if numKeys <= 0 {
+		return 0, errNumKeys
+	}
+	if numKeys > len(args)-1 {
+		return 0, errNumKeysArgs
+	}
+
+	keys := args[1 : numKeys+1]
+	opts := args[numKeys+1:]
+	limit := 0
+	if len(opts) > 0 {
+		if len(opts) != 2 || !bytes.EqualFold(opts[0], limitArg) {
+			return 0, errSyntax
+		}
+		limit, err = strconv.Atoi(string(opts[1]))
+		if err != nil {
+			return 0, errInvalidInteger
+		}
+		if limit < 0 {
+			return 0, errLimitNegative
+		}
+	}
+
+	return db.SInterCardN(limit, keys...)
+}
+
+func (db *DBModel) SInterCardN(limit int, keys ...[]byte) (int, error) {
+	return db.kv.SInterCard(limit, keys...)
+}
+
+func (db *DBModel) SMove(src []byte, dst []byte, member []byte) (int, error) {
+	return db.kv.SMove(src, dst, member)
+}
+
+// ZAdd parses "[NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]" arguments.
+// It returns number of added elements or new score of element when INCR is specified
+func (db *DBModel) ZAdd(key []byte, args ...[]byte) (cnt int, score []byte, incr bool, err error) {
+	flags := &zAddFlags{}
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if bytes.EqualFold(arg, nxArg) {
+			flags.nx = true
+		} else if bytes.EqualFold(arg, xxArg) {
+			flags.xx = true
+		} else if bytes.EqualFold(arg, gtArg) {
+			flags.gt = true
+		} else if bytes.EqualFold(arg, ltArg) {
+			flags.lt = true
+		} else if bytes.EqualFold(arg, chArg) {
+			flags.ch = true
+		} else if bytes.EqualFold(arg, incrArg) {
+			flags.incr = true
+		} else {
+			break
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return 0, nil, flags.incr, errSyntax
+	}
+	if flags.nx && flags.xx {
+		return 0, nil, flags.incr, errXXNX
+	}
+	if (flags.gt && flags.lt) || (flags.nx && (flags.gt || flags.lt)) {
+		return 0, nil, flags.incr, errGTLTNX
+	}
+	if flags.incr && len(pairs) > 2 {
+		return 0, nil, flags.incr, errIncrPair
+	}
+
+	scores := make([]float64, 0, len(pairs)/2)
+	members := make([][]byte, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		s, err := parseScore(pairs[j])
+		if err != nil {
+			return 0, nil, flags.incr, err
+		}
+		scores = append(scores, s)
+		members = append(members, pairs[j+1])
+	}
+
+	cnt, score, err = db.kv.ZAdd(key, flags, scores, members)
+	return cnt, score, flags.incr, err
+}
+
+func (db *DBModel) ZIncrBy(key []byte, increment []byte, member []byte) ([]byte, error) {
+	s, err := parseScore(increment)
+	if err != nil {
+		return nil, err
+	}
+
+	_, score, err := db.kv.ZAdd(key, &zAddFlags{incr: true}, []float64{s}, [][]byte{member})
+	return score, err
+}
+
+func (db *DBModel) ZRem(key []byte, members ...[]byte) (int, error) {
+	return db.kv.ZRem(key, members...)
+}
+
+func (db *DBModel) ZScore(key []byte, member []byte) ([]byte, error) {
+	return db.kv.ZScore(key, member)
+}
+
+func (db *DBModel) ZCard(key []byte) (int, error) {
+	return db.kv.ZCard(key)
+}
+
+func (db *DBModel) ZCount(key []byte, min []byte, max []byte) (int, error) {
+	r, err := parseScoreRange(min, max)
+	if err != nil {
+		return 0, err
+	}
+
+	return db.kv.ZCount(key, r)
+}
+
+// ZRank returns zero based rank of member or -1 if member does not exist
+func (db *DBModel) ZRank(key []byte, member []byte) (int, error) {
+	return db.kv.ZRank(key, member, false)
+}
+
+// ZRevRank returns zero based rank of member ordered from high to low scores
+// or -1 if member does not exist
+func (db *DBModel) ZRevRank(key []byte, member []byte) (int, error) {
+	return db.kv.ZRank(key, member, true)
+}
+
+// ZRange parses "start stop [BYSCORE|BYLEX] [REV] [LIMIT offset count] [WITHSCORES]" arguments
+func (db *DBModel) ZRange(key []byte, args ...[]byte) ([]interface{}, error) {
+	spec, err := parseZRangeSpec(args, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.kv.ZRange(key, spec)
+}
+
+// ZRangeStore parses "min max [BYSCORE|BYLEX] [REV] [LIMIT offset count]" arguments
+func (db *DBModel) ZRangeStore(dst []byte, src []byte, args ...[]byte) (int, error) {
+	spec, err := parseZRangeSpec(args, false)
+	if err != nil {
+		return 0, err
+	}
+
+	return db.kv.ZRangeStore(dst, src, spec)
+}
+
+// ZPopMin pops one element when count is nil
+func (db *DBModel) ZPopMin(key []byte, count []byte) ([]interface{}, error) {
+	c, err := parseZPopCount(count)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.ZPopMinN(key, c)
+}
+
+func (db *DBModel) ZPopMinN(key []byte, count int) ([]interface{}, error) {
+	return db.kv.ZPop(key, count, false)
+}
+
+// ZPopMax pops one element when count is nil
+func (db *DBModel) ZPopMax(key []byte, count []byte) ([]interface{}, error) {
+	c, err := parseZPopCount(count)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.ZPopMaxN(key, c)
+}
+
+func (db *DBModel) ZPopMaxN(key []byte, count int) ([]interface{}, error) {
+	return db.kv.ZPop(key, count, true)
+}
+
+func (db *DBModel) ZRemRangeByRank(key []byte, start []byte, stop []byte) (int, error) {
+	s, err := strconv.Atoi(string(start))
+	if err != nil {
+		return 0, errInvalidInteger
+	}
+
+	e, err := strconv.Atoi(string(stop))
+	if err != nil {
+		return 0, errInvalidInteger
+	}
+
+	return db.kv.ZRemRange(key, &zRangeSpec{by: zRangeByRank, start: s, stop: e})
+}
+
+func (db *DBModel) ZRemRangeByScore(key []byte, min []byte, max []byte) (int, error) {
+	r, err := parseScoreRange(min, max)
+	if err != nil {
+		return 0, err
+	}
+
+	return db.kv.ZRemRange(key, &zRangeSpec{by: zRangeByScore, rng: r, count: -1})
+}
+
+func (db *DBModel) ZRemRangeByLex(key []byte, min []byte, max []byte) (int, error) {
+	r, err := parseLexRange(min, max)
+	if err != nil {
+		return 0, err
+	}
+
+	return db.kv.ZRemRange(key, &zRangeSpec{by: zRangeByLex, rng: r, count: -1})
+}
+
+// ZUnionStore parses "numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]" arguments
+func (db *DBModel) ZUnionStore(dst []byte, args ...[]byte) (int, error) {
+	return db.zstore(false, dst, args...)
+}
+
+// ZInterStore parses "numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]" arguments
+func (db *DBModel) ZInterStore(dst []byte, args ...[]byte) (int, error) {
+	return db.zstore(true, dst, args...)
+}
+
+func (db *DBModel) zstore(inter bool, dst []byte, args ...[]byte) (int, error) {
+	if len(args) < 2 {
+		return 0, errSyntax
+	}
+
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return 0, errInvalidInteger
+	}
+	if numKeys <= 0 {
+		return 0, errZStoreNumKeys
+	}
+	if numKeys > len(args)-1 {
+		return 0, errSyntax
+	}
+
+	keys := args[1 : numKeys+1]
+	weights := make([]float64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	aggregate := zAggregateSum
+
+	for i := numKeys + 1; i < len(args); i++ {
+		if bytes.EqualFold(args[i], weightsArg) && i+numKeys < len(args) {
+			for j := 0; j < numKeys; j++ {
+				i++
+				weights[j], err = parseScore(args[i])
+				if err != nil {
+					return 0, errWeightNotFloat
+				}
+			}
+		} else if bytes.EqualFold(args[i], aggregateArg) && i+1 < len(args) {
+			i++
+			if bytes.EqualFold(args[i], sumArg) {
+				aggregate = zAggregateSum
+			} else if bytes.EqualFold(args[i], minArg) {
+				aggregate = zAggregateMin
+			} else if bytes.EqualFold(args[i], maxArg) {
+				aggregate = zAggregateMax
+			} else {
+				return 0, errSyntax
+			}
+		} else {
+			return 0, errSyntax
+		}
+	}
+
+	return db.kv.ZStore(inter, dst, keys, weights, aggregate)
+}
+
+func parseZPopCount(count []byte) (int, error) {
+	if count == nil {
+		return 1, nil
+	}
+
+	c, err := strconv.Atoi(string(count))
+	if err != nil {
+		return 0, errInvalidInteger
+	}
+	if c < 0 {
+		return 0, errNotPositive
+	}
+	return c, nil
+}
+
+func parseZRangeSpec(args [][]byte, withScoresAllowed bool) (*zRangeSpec, error) {
+	if len(args) < 2 {
+		return nil, errSyntax
+	}
+
+	spec := &zRangeSpec{by: zRangeByRank, count: -1}
+	limit := false
+	for i := 2; i < len(args); i++ {
+		arg := args[i]
+		if bytes.EqualFold(arg, byScoreArg) {
+			spec.by = zRangeByScore
+		} else if bytes.EqualFold(arg, byLexArg) {
+			spec.by = zRangeByLex
+		} else if bytes.EqualFold(arg, revArg) {
+			spec.rev = true
+		} else if withScoresAllowed && bytes.EqualFold(arg, withScoresArg) {
+			spec.withScores = true
+		} else if bytes.EqualFold(arg, limitArg) && i+2 < len(args) {
+			offset, err := strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return nil, errInvalidInteger
+			}
+			count, err := strconv.Atoi(string(args[i+2]))
+			if err != nil {
+				return nil, errInvalidInteger
+			}
+			spec.offset, spec.count = offset, count
+			limit = true
+			i += 2
+		} else {
+			return nil, errSyntax
+		}
+	}
+
+	if limit && spec.by == zRangeByRank {
+		return nil, errLimitByRank
+	}
+	if spec.withScores && spec.by == zRangeByLex {
+		return nil, errWithScoresByLex
+	}
+
+	min, max := args[0], args[1]
+	if spec.rev {
+		min, max = max, min
+	}
+
+	var err error
+	switch spec.by {
+	case zRangeByScore:
+		spec.rng, err = parseScoreRange(min, max)
+	case zRangeByLex:
+		spec.rng, err = parseLexRange(min, max)
+	default:
+		spec.start, err = strconv.Atoi(string(args[0]))
+		if err != nil {
+			return nil, errInvalidInteger
+		}
+		spec.stop, err = strconv.Atoi(string(args[1]))
+		if err != nil {
+			return nil, errInvalidInteger
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// HExpire parses "seconds [NX|XX|GT|LT] FIELDS numfields field [field ...]" arguments
+func (db *DBModel) HExpire(key []byte, seconds []byte, args ...[]byte) ([]interface{}, error) {
+	return db.hexpire(key, seconds, 1000, false, args...)
+}
+
+// HPExpire parses "milliseconds [NX|XX|GT|LT] FIELDS numfields field [field ...]" arguments
+func (db *DBModel) HPExpire(key []byte, ms []byte, args ...[]byte) ([]interface{}, error) {
+	return db.hexpire(key, ms, 1, false, args...)
+}
+
+// HExpireAt parses "unix-time-seconds [NX|XX|GT|LT] FIELDS numfields field [field ...]" arguments
+func (db *DBModel) HExpireAt(key []byte, timestamp []byte, args ...[]byte) ([]interface{}, error) {
+	return db.hexpire(key, timestamp, 1000, true, args...)
+}
+
+// HPExpireAt parses "unix-time-milliseconds [NX|XX|GT|LT] FIELDS numfields field [field ...]" arguments
+func (db *DBModel) HPExpireAt(key []byte, timestamp []byte, args ...[]byte) ([]interface{}, error) {
+	return db.hexpire(key, timestamp, 1, true, args...)
+}
+
+// HTTL parses "FIELDS numfields field [field ...]" arguments
+func (db *DBModel) HTTL(key []byte, args ...[]byte) ([]interface{}, error) {
+	fields, err := parseFields(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.kv.HTTL(key, false, fields...)
+}
+
+// HPTTL parses "FIELDS numfields field [field ...]" arguments
+func (db *DBModel) HPTTL(key []byte, args ...[]byte) ([]interface{}, error) {
+	fields, err := parseFields(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.kv.HTTL(key, true, fields...)
+}
+
+// HPersist parses "FIELDS numfields field [field ...]" arguments
+func (db *DBModel) HPersist(key []byte, args ...[]byte) ([]interface{}, error) {
+	fields, err := parseFields(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.kv.HPersist(key, fields...)
+}
+
+func (db *DBModel) hexpire(key []byte, when []byte, unit int64, absolute bool, args ...[]byte) ([]interface{}, error) {
+	w, err := strconv.ParseInt(string(when), 10, 64)
+	if err != nil {
+		return nil, errInvalidInteger
+	}
+	if w < 0 || w > math.MaxInt64/unit {
+		return nil, errInvalidExpireTime
+	}
+
+	w *= unit
+	if !absolute {
+		now := nowMs()
+		if w > math.MaxInt64-now {
+			return nil, errInvalidExpireTime
+		}
+		w += now
+	}
+
+	cond := hExpireAlways
+	if len(args) > 0 {
+		switch {
+		case bytes.EqualFold(args[0], nxArg):
+			cond = hExpireNX
+		case bytes.EqualFold(args[0], xxArg):
+			cond = hExpireXX
+		case bytes.EqualFold(args[0], gtArg):
+			cond = hExpireGT
+		case bytes.EqualFold(args[0], ltArg):
+			cond = hExpireLT
+		}
+		if cond != hExpireAlways {
+			args = args[1:]
+		}
+	}
+
+	fields, err := parseFields(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.kv.HExpire(key, w, cond, fields...)
+}
+
+func parseFields(args [][]byte) ([][]byte, error) {
+	if len(args) < 2 || !bytes.EqualFold(args[0], fieldsArg) {
+		return nil, errFieldsArg
+	}
+
+	numFields, err := strconv.Atoi(string(args[1]))
+	if err != nil {
+		return nil, errInvalidInteger
+	}
+	if numFields <= 0 {
+		return nil, errNumFields
+	}
+	if numFields != len(args)-2 {
+		return nil, errNumFieldsArgs
+	}
+	return args[2:], nil
+}
+
+func (db *DBModel) LPushX(key []byte, values ...[]byte) (int, error) {
+	return db.kv.LPushX(key, values...)
+}
+
+func (db *DBModel) RPushX(key []byte, values ...[]byte) (int, error) {
+	return db.kv.RPushX(key, values...)
+}
+
+// LPopCount returns nil if key does not exist
+func (db *DBModel) LPopCount(key []byte, count []byte) ([]interface{}, error) {
+	c, err := parsePopCount(count)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.kv.LRPopN(key, c, true)
+}
+
+// RPopCount returns nil if key does not exist
+func (db *DBModel) RPopCount(key []byte, count []byte) ([]interface{}, error) {
+	c, err := parsePopCount(count)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.kv.LRPopN(key, c, false)
+}
+
+// LMPop parses "numkeys key [key ...] LEFT|RIGHT [COUNT count]" arguments.
+// It returns nil key if all lists are empty
+func (db *DBModel) LMPop(args ...[]byte) ([]byte, []interface{}, error) {
+	keys, left, count, err := parseMPopArgs(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return db.kv.LMPop(keys, count, left)
+}
+
+func (db *DBModel) LSet(key []byte, index []byte, value []byte) error {
+	ind, err := strconv.Atoi(string(index))
+	if err != nil {
+		return errInvalidInteger
+	}
+
+	return db.kv.LSet(key, ind, value)
+}
+
+func (db *DBModel) LRem(key []byte, count []byte, value []byte) (int, error) {
+	c, err := strconv.Atoi(string(count))
+	if err != nil {
+		return 0, errInvalidInteger
+	}
+
+	return db.kv.LRem(key, c, value)
+}
+
+func (db *DBModel) LTrim(key []byte, start []byte, stop []byte) error {
+	s, err := strconv.Atoi(string(start))
+	if err != nil {
+		return errInvalidInteger
+	}
+
+	e, err := strconv.Atoi(string(stop))
+	if err != nil {
+		return errInvalidInteger
+	}
+
+	return db.kv.LTrim(key, s, e)
+}
+
+// LPos parses "[RANK rank] [COUNT num-matches] [MAXLEN len]" arguments.
+// It reports whether COUNT was specified, in which case all positions are
+// expected in reply, otherwise only the first one
+func (db *DBModel) LPos(key []byte, value []byte, args ...[]byte) ([]interface{}, bool, error) {
+	rank, count, maxLen := 1, 1, 0
+	withCount := false
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, false, errSyntax
+		}
+
+		n, err := strconv.Atoi(string(args[i+1]))
+		if err != nil {
+			return nil, false, errInvalidInteger
+		}
+
+		switch {
+		case bytes.EqualFold(args[i], rankArg):
+			if n == 0 {
+				return nil, false, errRankZero
+			}
+			rank = n
+		case bytes.EqualFold(args[i], countArg):
+			if n < 0 {
+				return nil, false, errCountNegative
+			}
+			count = n
+			withCount = true
+		case bytes.EqualFold(args[i], maxLenArg):
+			if n < 0 {
+				return nil, false, errMaxLenNegative
+			}
+			maxLen = n
+		default:
+			return nil, false, errSyntax
+		}
+	}
+
+	positions, err := db.kv.LPos(key, value, rank, count, maxLen)
+	return positions, withCount, err
+}
+
+// LMove returns nil if source list does not exist
+func (db *DBModel) LMove(src []byte, dst []byte, whereFrom []byte, whereTo []byte) ([]byte, error) {
+	srcLeft, err := parseListSide(whereFrom)
+	if err != nil {
+		return nil, err
+	}
+	dstLeft, err := parseListSide(whereTo)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.kv.LMove(src, dst, srcLeft, dstLeft)
+}
+
+// RPopLPush returns nil if source list does not exist
+func (db *DBModel) RPopLPush(src []byte, dst []byte) ([]byte, error) {
+	return db.kv.LMove(src, dst, false, true)
+}
+
+// BLPop parses "key [key ...] timeout" arguments. It returns key and popped
+// element or nil if timeout expires
+func (db *DBModel) BLPop(cancel <-chan struct{}, args ...[]byte) ([]interface{}, error) {
+	return db.blrpop(cancel, true, args)
+}
+
+// BRPop parses "key [key ...] timeout" arguments. It returns key and popped
+// element or nil if timeout expires
+func (db *DBModel) BRPop(cancel <-chan struct{}, args ...[]byte) ([]interface{}, error) {
+	return db.blrpop(cancel, false, args)
+}
+
+func (db *DBModel) blrpop(cancel <-chan struct{}, left bool, args [][]byte) ([]interface{}, error) {
+	if len(args) < 2 {
+		return nil, errSyntax
+	}
+
+	timeout, err := parseTimeout(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	key, values, err := db.kv.BLRPop(args[:len(args)-1], 1, left, timeout, cancel)
+	if err != nil || key == nil {
+		return nil, err
+	}
+	return []interface{}{key, values[0]}, nil
+}
+
+// BLMPop parses "timeout numkeys key [key ...] LEFT|RIGHT [COUNT count]" arguments.
+// It returns nil key if timeout expires
+func (db *DBModel) BLMPop(cancel <-chan struct{}, args ...[]byte) ([]byte, []interface{}, error) {
+	if len(args) < 1 {
+		return nil, nil, errSyntax
+	}
+
+	timeout, err := parseTimeout(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys, left, count, err := parseMPopArgs(args[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return db.kv.BLRPop(keys, count, left, timeout, cancel)
+}
+
+// BLMove returns nil if timeout expires
+func (db *DBModel) BLMove(cancel <-chan struct{}, src []byte, dst []byte, whereFrom []byte, whereTo []byte, timeout []byte) ([]byte, error) {
+	srcLeft, err := parseListSide(whereFrom)
+	if err != nil {
+		return nil, err
+	}
+	dstLeft, err := parseListSide(whereTo)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseTimeout(timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.kv.BLMove(src, dst, srcLeft, dstLeft, t, cancel)
+}
+
+// BRPopLPush returns nil if timeout expires
+func (db *DBModel) BRPopLPush(cancel <-chan struct{}, src []byte, dst []byte, timeout []byte) ([]byte, error) {
+	t, err := parseTimeout(timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.kv.BLMove(src, dst, false, true, t, cancel)
+}
+
+// minTimeout is the shortest timeout of blocking commands, positive timeouts
+// are rounded up to it so they are not taken for zero timeout blocking forever
+const minTimeout = time.Millisecond
+
+// parseTimeout parses timeout in seconds, fractional values are allowed
+func parseTimeout(timeout []byte) (time.Duration, error) {
+	f, err := strconv.ParseFloat(string(timeout), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) || f > math.MaxInt64/float64(time.Second) {
+		return 0, errTimeoutNotFloat
+	}
+	if f < 0 {
+		return 0, errTimeoutNegative
+	}
+
+	d := time.Duration(f * float64(time.Second))
+	if f > 0 && d < minTimeout {
+		d = minTimeout
+	}
+	return d, nil
+}
+
+// parseListSide parses LEFT|RIGHT argument and reports whether it is LEFT
+func parseListSide(side []byte) (bool, error) {
+	switch {
+	case bytes.EqualFold(side, leftArg):
+		return true, nil
+	case bytes.EqualFold(side, rightArg):
+		return false, nil
+	}
+	return false, errSyntax
+}
+
+func parsePopCount(count []byte) (int, error) {
+	c, err := strconv.Atoi(string(count))
+	if err != nil {
+		return 0, errInvalidInteger
+	}
+	if c < 0 {
+		return 0, errNotPositive
+	}
+	return c, nil
+}
+
+// parseMPopArgs parses "numkeys key [key ...] LEFT|RIGHT [COUNT count]" arguments
+func parseMPopArgs(args [][]byte) ([][]byte, bool, int, error) {
+	if len(args) < 3 {
+		return nil, false, 0, errSyntax
+	}
+
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return nil, false, 0, errInvalidInteger
+	}
+	if numKeys <= 0 {
+		return nil, false, 0, errNumKeys
+	}
+	if numKeys > len(args)-2 {
+		return nil, false, 0, errSyntax
+	}
+
+	keys := args[1 : numKeys+1]
+	opts := args[numKeys+1:]
+
+	left, err := parseListSide(opts[0])
+	if err != nil {
+		return nil, false, 0, err
+	}
+
+	count := 1
+	opts = opts[1:]
+	if len(opts) > 0 {
+		if len(opts) != 2 || !bytes.EqualFold(opts[0], countArg) {
+			return nil, false, 0, errSyntax
+		}
+		count, err = strconv.Atoi(string(opts[1]))
+		if err != nil || count <= 0 {
+			return nil, false, 0, errCountPositive
+		}
+	}
+	return keys, left, count, nil
+}
@@ -0,0 +1,75 @@
+package model
+
+// Encodings of values reported by OBJECT ENCODING
+const (
+	encInt        = "int"
+	encEmbStr     = "embstr"
+	encRaw        = "raw"
+	encListpack   = "listpack"
+	encListpackEx = "listpackex"
+	encQuicklist  = "quicklist"
+	encHashtable  = "hashtable"
+	encIntset     = "intset"
+	encSkiplist   = "skiplist"
+)
+
+// embStrMaxLen is maximum length of string reported as embstr
+const embStrMaxLen = 44
+
+// Encodings keeps thresholds of conversion from compact encodings of small values
+type Encodings struct {
+	// HashMaxListpackEntries is maximum number of fields of listpack encoded hash
+	HashMaxListpackEntries int
+	// HashMaxListpackValue is maximum length of field or value of listpack encoded hash
+	HashMaxListpackValue int
+	// ListMaxListpackSize limits listpack nodes of lists. Positive value is maximum
+	// number of elements in node, negative value from -1 to -5 is maximum node
+	// size of 4, 8, 16, 32 or 64 Kb
+	ListMaxListpackSize int
+	// SetMaxIntsetEntries is maximum number of members of intset encoded set
+	SetMaxIntsetEntries int
+}
+
+// DefaultEncodings returns thresholds used by default
+func DefaultEncodings() Encodings {
+	return Encodings{
+		HashMaxListpackEntries: 128,
+		HashMaxListpackValue:   64,
+		ListMaxListpackSize:    -2,
+		SetMaxIntsetEntries:    512,
+	}
+}
+
+func (val *keyValue) encoding() string {
+	switch val.kvType {
+	case kvType:
+		if val.isNum {
+			return encInt
+		}
+		if len(val.value) <= embStrMaxLen {
+			return encEmbStr
+		}
+		return encRaw
+	case kvListType:
+		if len(val.list.live()) <= 1 {
+			return encListpack
+		}
+		return encQuicklist
+	case kvDictType:
+		if val.dict.lp == nil {
+			return encHashtable
+		}
+		if val.fieldTTL != nil {
+			return encListpackEx
+		}
+		return encListpack
+	case kvSetType:
+		if val.set.dict == nil {
+			return encIntset
+		}
+		return encHashtable
+	case kvZSetType:
+		return encSkiplist
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func objectEncoding(dbModel *DBModel, key []byte) string {
+	enc, err := dbModel.Object([]byte("encoding"), key)
+	Expect(err).ToNot(HaveOccurred())
+	return string(enc)
+}
+
+func TestObjectEncoding(t *testing.T) {
+	RegisterTestingT(t)
+
+	dbModel := newDBModel(0)
+	dbModel.kv.enc = Encodings{
+		HashMaxListpackEntries: 2,
+		HashMaxListpackValue:   8,
+		ListMaxListpackSize:    2,
+		SetMaxIntsetEntries:    3,
+	}
+
+	key := []byte("key")
+	Expect(objectEncoding(dbModel, key)).To(Equal(""))
+
+	_, err := dbModel.Object([]byte("refcount"), key)
+	Expect(err).To(Equal(errObjectSubcommand))
+
+	dbModel.Set(key, []byte("-12345"))
+	Expect(objectEncoding(dbModel, key)).To(Equal(encInt))
+	Expect(dbModel.Get(key)).To(Equal([]byte("-12345")))
+
+	dbModel.Set(key, []byte("012"))
+	Expect(objectEncoding(dbModel, key)).To(Equal(encEmbStr))
+	Expect(dbModel.Get(key)).To(Equal([]byte("012")))
+
+	dbModel.Set(key, bytes.Repeat([]byte("a"), embStrMaxLen+1))
+	Expect(objectEncoding(dbModel, key)).To(Equal(encRaw))
+
+	list := []byte("list")
+	dbModel.RPush(list, []byte("a"), []byte("b"))
+	Expect(objectEncoding(dbModel, list)).To(Equal(encListpack))
+	dbModel.RPush(list, []byte("c"))
+	Expect(objectEncoding(dbModel, list)).To(Equal(encQuicklist))
+
+	hash := []byte("hash")
+	dbModel.HSet(hash, []byte("f1"), []byte("v1"), []byte("f2"), []byte("v2"))
+	Expect(objectEncoding(dbModel, hash)).To(Equal(encListpack))
+	dbModel.HSet(hash, []byte("f1"), []byte("v3"))
+	Expect(objectEncoding(dbModel, hash)).To(Equal(encListpack))
+	dbModel.HSet(hash, []byte("f3"), []byte("v3"))
+	Expect(objectEncoding(dbModel, hash)).To(Equal(encHashtable))
+
+	values, err := dbModel.HGetAll(hash)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(ConsistOf([]byte("f1"), []byte("v3"), []byte("f2"), []byte("v2"), []byte("f3"), []byte("v3")))
+
+	long := []byte("long")
+	dbModel.HSet(long, []byte("f"), []byte("long value"))
+	Expect(objectEncoding(dbModel, long)).To(Equal(encHashtable))
+	Expect(dbModel.HGet(long, []byte("f"))).To(Equal([]byte("long value")))
+
+	ttl := []byte("ttl")
+	dbModel.HSet(ttl, []byte("f"), []byte("v"))
+	dbModel.HExpire(ttl, []byte("100"), []byte("FIELDS"), []byte("1"), []byte("f"))
+	Expect(objectEncoding(dbModel, ttl)).To(Equal(encListpackEx))
+
+	set := []byte("set")
+	dbModel.SAdd(set, []byte("3"), []byte("1"), []byte("2"))
+	Expect(objectEncoding(dbModel, set)).To(Equal(encIntset))
+	members, _ := dbModel.SMembers(set)
+	Expect(members).To(Equal([]interface{}{[]byte("1"), []byte("2"), []byte("3")}))
+
+	dbModel.SAdd(set, []byte("4"))
+	Expect(objectEncoding(dbModel, set)).To(Equal(encHashtable))
+	Expect(dbModel.SIsMember(set, []byte("4"))).To(Equal(1))
+
+	other := []byte("other")
+	dbModel.SAdd(other, []byte("1"), []byte("01"))
+	Expect(objectEncoding(dbModel, other)).To(Equal(encHashtable))
+
+	inter := []byte("inter")
+	cnt, err := dbModel.SInterStore(inter, set, []byte("1"), other)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(0))
+	cnt, err = dbModel.SInterStore(inter, set, other)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(1))
+	Expect(objectEncoding(dbModel, inter)).To(Equal(encIntset))
+
+	cnt, err = dbModel.SRem(set, []byte("1"), []byte("2"), []byte("3"), []byte("4"), []byte("5"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(4))
+	Expect(dbModel.Exists(set)).To(Equal(0))
+}
@@ -0,0 +1,277 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errOOM                  = errors.New("OOM command not allowed when used memory > 'maxmemory'.")
+	errMaxMemoryPolicy      = errors.New("ERR invalid maxmemory policy")
+	errMaxMemorySamplesZero = errors.New("ERR maxmemory samples must be positive")
+)
+
+// Eviction policies applied once used memory exceeds maxmemory
+const (
+	NoEviction = iota
+	AllKeysLRU
+	VolatileLRU
+	AllKeysLFU
+	VolatileLFU
+	AllKeysRandom
+	VolatileRandom
+	VolatileTTL
+)
+
+var maxMemoryPolicies = []string{
+	NoEviction:     "noeviction",
+	AllKeysLRU:     "allkeys-lru",
+	VolatileLRU:    "volatile-lru",
+	AllKeysLFU:     "allkeys-lfu",
+	VolatileLFU:    "volatile-lfu",
+	AllKeysRandom:  "allkeys-random",
+	VolatileRandom: "volatile-random",
+	VolatileTTL:    "volatile-ttl",
+}
+
+// DefaultMaxMemorySamples is number of keys sampled per database to find key to evict
+const DefaultMaxMemorySamples = 5
+
+// evictScanFactor limits number of keys scanned to find volatile keys
+// to evictScanFactor times number of samples
+const evictScanFactor = 10
+
+// ParseMaxMemoryPolicy returns policy by its name
+func ParseMaxMemoryPolicy(name string) (int, error) {
+	for policy, n := range maxMemoryPolicies {
+		if strings.EqualFold(n, name) {
+			return policy, nil
+		}
+	}
+	return 0, errMaxMemoryPolicy
+}
+
+// MaxMemoryPolicyName returns name of policy
+func MaxMemoryPolicyName(policy int) string {
+	return maxMemoryPolicies[policy]
+}
+
+// evictCandidate is sampled key, key with the highest score is evicted first
+type evictCandidate struct {
+	db    *DBModel
+	key   string
+	val   *keyValue
+	score int64
+}
+
+// SetMaxMemory sets limit in bytes for memory taken by keys and values, 0 means no limit
+func (model *AppModel) SetMaxMemory(limit int64) {
+	atomic.StoreInt64(&model.maxMemory, limit)
+}
+
+func (model *AppModel) MaxMemory() int64 {
+	return atomic.LoadInt64(&model.maxMemory)
+}
+
+func (model *AppModel) SetMaxMemoryPolicy(policy int) error {
+	if policy < 0 || policy >= len(maxMemoryPolicies) {
+		return errMaxMemoryPolicy
+	}
+
+	atomic.StoreInt32(&model.maxMemoryPolicy, int32(policy))
+	return nil
+}
+
+func (model *AppModel) MaxMemoryPolicy() int {
+	return int(atomic.LoadInt32(&model.maxMemoryPolicy))
+}
+
+func (model *AppModel) SetMaxMemorySamples(samples int) error {
+	if samples <= 0 {
+		return errMaxMemorySamplesZero
+	}
+
+	atomic.StoreInt32(&model.maxMemorySamples, int32(samples))
+	return nil
+}
+
+func (model *AppModel) MaxMemorySamples() int {
+	return int(atomic.LoadInt32(&model.maxMemorySamples))
+}
+
+// UsedMemory returns estimated memory taken by keys and values of all databases
+func (model *AppModel) UsedMemory() int64 {
+	var used int64
+	for _, db := range model.created() {
+		used += db.kv.usedMemory()
+	}
+	return used
+}
+
+// MemoryStats returns memory statistics of runtime and all databases as
+// name and value pairs
+func (model *AppModel) MemoryStats() []interface{} {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	var keys int
+	var dataset int64
+	var dbStats []interface{}
+	for _, db := range model.sorted() {
+		n, volatile, used := db.kv.Stats()
+		if n == 0 {
+			continue
+		}
+
+		keys += n
+		dataset += used
+		dbStats = append(dbStats, []byte("db."+strconv.Itoa(db.index)), []interface{}{
+			[]byte("keys"), n,
+			[]byte("expires"), volatile,
+			[]byte("dataset.bytes"), int(used),
+		})
+	}
+
+	bytesPerKey := int64(0)
+	if keys > 0 {
+		bytesPerKey = dataset / int64(keys)
+	}
+
+	stats := []interface{}{
+		[]byte("total.allocated"), int(ms.HeapAlloc),
+		[]byte("total.system"), int(ms.Sys),
+		[]byte("maxmemory"), int(model.MaxMemory()),
+		[]byte("maxmemory.policy"), []byte(MaxMemoryPolicyName(model.MaxMemoryPolicy())),
+		[]byte("evicted.keys"), int(model.EvictedKeys()),
+		[]byte("keys.count"), keys,
+		[]byte("keys.bytes-per-key"), int(bytesPerKey),
+		[]byte("dataset.bytes"), int(dataset),
+	}
+	return append(stats, dbStats...)
+}
+
+// EvictedKeys returns number of keys evicted so far
+func (model *AppModel) EvictedKeys() int64 {
+	return atomic.LoadInt64(&model.evictedKeys)
+}
+
+// EvictionTime returns total time spent on eviction
+func (model *AppModel) EvictionTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&model.evictionTime))
+}
+
+// FreeMemory evicts keys according to maxmemory policy until used memory is
+// below maxmemory. It returns OOM error if memory can not be freed, so
+// commands which may take more memory are rejected
+func (model *AppModel) FreeMemory() error {
+	limit := model.MaxMemory()
+	if limit <= 0 || model.UsedMemory() <= limit {
+		return nil
+	}
+
+	policy := model.MaxMemoryPolicy()
+	if policy == NoEviction {
+		return errOOM
+	}
+
+	start := time.Now()
+	defer func() {
+		atomic.AddInt64(&model.evictionTime, int64(time.Since(start)))
+	}()
+
+	for model.UsedMemory() > limit {
+		if !model.evictOne(policy) {
+			return errOOM
+		}
+	}
+	return nil
+}
+
+// evictOne evicts the best candidate among keys sampled from all databases.
+// It returns false if there are no keys to evict
+func (model *AppModel) evictOne(policy int) bool {
+	samples := model.MaxMemorySamples()
+	now := time.Now()
+
+	var best *evictCandidate
+	for _, db := range model.created() {
+		c := db.kv.sampleEvictCandidate(policy, samples, now)
+		if c != nil && (best == nil || c.score > best.score) {
+			c.db = db
+			best = c
+		}
+	}
+
+	if best == nil {
+		return false
+	}
+
+	if best.db.kv.evict(best.key, best.val) {
+		atomic.AddInt64(&model.evictedKeys, 1)
+	}
+	return true
+}
+
+// sampleEvictCandidate returns sampled key which suits policy best or nil if
+// there are no keys policy applies to
+func (kv *kvModel) sampleEvictCandidate(policy int, samples int, now time.Time) *evictCandidate {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	volatile := policy == VolatileLRU || policy == VolatileLFU ||
+		policy == VolatileRandom || policy == VolatileTTL
+
+	var best *evictCandidate
+	nowMs := now.UnixNano() / int64(time.Millisecond)
+	scanLimit := samples * evictScanFactor
+	for k, val := range kv.storage {
+		if scanLimit == 0 {
+			break
+		}
+		scanLimit--
+
+		if volatile && val.ttl == 0 {
+			continue
+		}
+
+		var score int64
+		switch policy {
+		case AllKeysLRU, VolatileLRU:
+			score = val.idle(nowMs)
+		case AllKeysLFU, VolatileLFU:
+			score = math.MaxUint8 - int64(val.lfu(nowMs))
+		case VolatileTTL:
+			// keys expiring sooner are evicted first
+			score = math.MaxInt64 - val.ttl
+		}
+
+		if best == nil || score > best.score {
+			best = &evictCandidate{key: k, val: val, score: score}
+		}
+
+		samples--
+		if samples == 0 {
+			break
+		}
+	}
+	return best
+}
+
+// evict removes key if it still holds val
+func (kv *kvModel) evict(key string, val *keyValue) bool {
+	kv.lock()
+	defer kv.unlock()
+
+	if kv.storage[key] != val {
+		return false
+	}
+
+	kv.remove(key)
+	delete(kv.hfe, key)
+	return true
+}
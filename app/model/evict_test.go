@@ -0,0 +1,111 @@
+package model
+
+import (
+	"strconv"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestMemoryAccounting(t *testing.T) {
+	RegisterTestingT(t)
+
+	db := newDBModel(0)
+	Expect(db.kv.usedMemory()).To(BeZero())
+
+	db.Set([]byte("s"), []byte("value"))
+	used := db.kv.usedMemory()
+	Expect(used).To(BeNumerically(">", 0))
+
+	for i := 0; i < 1000; i++ {
+		db.RPush([]byte("l"), []byte("element:"+strconv.Itoa(i)))
+		db.HSet([]byte("h"), []byte("field:"+strconv.Itoa(i)), []byte("value"))
+		db.SAdd([]byte("set"), []byte("member:"+strconv.Itoa(i)))
+		db.ZAdd([]byte("z"), []byte(strconv.Itoa(i)), []byte("member:"+strconv.Itoa(i)))
+	}
+	Expect(db.kv.usedMemory()).To(BeNumerically(">", used+4*1000*10))
+
+	db.Del([]byte("l"), []byte("h"), []byte("set"), []byte("z"))
+	Expect(db.kv.usedMemory()).To(Equal(used))
+
+	db.Set([]byte("s"), []byte("longer value"))
+	Expect(db.kv.usedMemory()).To(BeNumerically(">", used))
+
+	db.FlushDBN()
+	Expect(db.kv.usedMemory()).To(BeZero())
+}
+
+func fillDB(db *DBModel, n int) {
+	for i := 0; i < n; i++ {
+		db.Set([]byte("key:"+strconv.Itoa(i)), []byte("value"))
+	}
+}
+
+func TestEviction(t *testing.T) {
+	RegisterTestingT(t)
+
+	app := NewAppModel(16, DefaultEncodings())
+	db, _ := app.SelectIndex(0)
+	fillDB(db, 100)
+	used := app.UsedMemory()
+
+	// noeviction rejects commands once limit is reached
+	Expect(app.FreeMemory()).To(Succeed())
+	app.SetMaxMemory(used / 2)
+	Expect(app.FreeMemory()).To(Equal(errOOM))
+	Expect(db.DBSize()).To(Equal(100))
+
+	// volatile policies do not touch keys without TTL
+	Expect(app.SetMaxMemoryPolicy(VolatileLRU)).To(Succeed())
+	Expect(app.FreeMemory()).To(Equal(errOOM))
+
+	Expect(app.SetMaxMemoryPolicy(AllKeysRandom)).To(Succeed())
+	Expect(app.FreeMemory()).To(Succeed())
+	Expect(app.UsedMemory()).To(BeNumerically("<=", used/2))
+	Expect(app.EvictedKeys()).To(BeEquivalentTo(100 - db.DBSize()))
+
+	app.ResetStats()
+	Expect(app.EvictedKeys()).To(BeZero())
+	Expect(app.EvictionTime()).To(BeZero())
+}
+
+func TestEvictionPolicies(t *testing.T) {
+	RegisterTestingT(t)
+
+	for _, policy := range []int{AllKeysLRU, AllKeysLFU, VolatileTTL} {
+		app := NewAppModel(16, DefaultEncodings())
+		Expect(app.SetMaxMemorySamples(100)).To(Succeed())
+		Expect(app.SetMaxMemoryPolicy(policy)).To(Succeed())
+
+		db, _ := app.SelectIndex(0)
+		fillDB(db, 10)
+		for i := 0; i < 10; i++ {
+			db.ExpireN([]byte("key:"+strconv.Itoa(i)), int64(1000-i))
+		}
+
+		// key:0 is accessed recently and frequently and expires last
+		for i := 1; i < 10; i++ {
+			db.kv.storage["key:"+strconv.Itoa(i)].access -= 1000
+		}
+		db.kv.storage["key:0"].freq = 255
+
+		app.SetMaxMemory(app.UsedMemory() - 1)
+		Expect(app.FreeMemory()).To(Succeed())
+		Expect(db.DBSize()).To(Equal(9))
+		Expect(db.Exists([]byte("key:0"))).To(Equal(1))
+		if policy == VolatileTTL {
+			Expect(db.Exists([]byte("key:9"))).To(Equal(0))
+		}
+	}
+}
+
+func TestParseMaxMemoryPolicy(t *testing.T) {
+	RegisterTestingT(t)
+
+	for policy, name := range maxMemoryPolicies {
+		Expect(ParseMaxMemoryPolicy(name)).To(Equal(policy))
+		Expect(MaxMemoryPolicyName(policy)).To(Equal(name))
+	}
+	_, err := ParseMaxMemoryPolicy("allkeys-fifo")
+	Expect(err).To(Equal(errMaxMemoryPolicy))
+}
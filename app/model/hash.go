@@ -0,0 +1,106 @@
+package model
+
+// hash keeps fields and values interleaved in listpack while hash is small
+// and converts to hash table once it grows over thresholds
+type hash struct {
+	lp   *listpack
+	dict map[string][]byte
+}
+
+func newHash() *hash {
+	return &hash{lp: &listpack{}}
+}
+
+func (h *hash) len() int {
+	if h.lp != nil {
+		return h.lp.len() / 2
+	}
+	return len(h.dict)
+}
+
+func (h *hash) get(field string) ([]byte, bool) {
+	if h.lp != nil {
+		i := h.lp.find(field, 2)
+		if i < 0 {
+			return nil, false
+		}
+		return h.lp.get(i + 1), true
+	}
+
+	v, exists := h.dict[field]
+	return v, exists
+}
+
+// set sets value of field and reports whether field is new
+func (h *hash) set(field string, value []byte, enc *Encodings) bool {
+	if h.lp != nil {
+		i := h.lp.find(field, 2)
+		if len(field) > enc.HashMaxListpackValue || len(value) > enc.HashMaxListpackValue ||
+			(i < 0 && h.len() >= enc.HashMaxListpackEntries) {
+			h.convert()
+		} else if i < 0 {
+			h.lp.insert(h.lp.len(), []byte(field))
+			h.lp.insert(h.lp.len(), value)
+			return true
+		} else {
+			h.lp.set(i+1, value)
+			return false
+		}
+	}
+
+	_, exists := h.dict[field]
+	h.dict[field] = value
+	return !exists
+}
+
+// del removes field and reports whether it existed
+func (h *hash) del(field string) bool {
+	if h.lp != nil {
+		i := h.lp.find(field, 2)
+		if i < 0 {
+			return false
+		}
+		h.lp.remove(i, 2)
+		return true
+	}
+
+	if _, exists := h.dict[field]; !exists {
+		return false
+	}
+	delete(h.dict, field)
+	return true
+}
+
+// each calls f for all fields of hash
+func (h *hash) each(f func(field []byte, value []byte)) {
+	if h.lp != nil {
+		for i := 0; i < h.lp.len(); i += 2 {
+			f(h.lp.get(i), h.lp.get(i+1))
+		}
+		return
+	}
+
+	for field, value := range h.dict {
+		f([]byte(field), value)
+	}
+}
+
+func (h *hash) convert() {
+	h.dict = make(map[string][]byte, h.len())
+	for i := 0; i < h.lp.len(); i += 2 {
+		h.dict[string(h.lp.get(i))] = h.lp.get(i + 1)
+	}
+	h.lp = nil
+}
+
+func (h *hash) clone() *hash {
+	if h.lp != nil {
+		return &hash{lp: h.lp.clone()}
+	}
+
+	dict := make(map[string][]byte, len(h.dict))
+	for f, v := range h.dict {
+		dict[f] = v
+	}
+	return &hash{dict: dict}
+}
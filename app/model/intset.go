@@ -0,0 +1,169 @@
+package model
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// setValue keeps members of set sorted in intset while all of them are
+// integers and set is small and converts to hash table otherwise
+type setValue struct {
+	ints []int64
+	dict map[string]struct{}
+}
+
+func newSetValue() *setValue {
+	return &setValue{}
+}
+
+// newSetValueFrom creates set with members choosing the most compact encoding
+func newSetValueFrom(members map[string]struct{}, enc *Encodings) *setValue {
+	s := newSetValue()
+	for m := range members {
+		s.add(m, enc)
+	}
+	return s
+}
+
+// parseIntsetMember parses member which can be kept in intset. Only canonical
+// representations are accepted so that member is formatted back unchanged
+func parseIntsetMember(m string) (int64, bool) {
+	if len(m) == 0 || len(m) > 20 {
+		return 0, false
+	}
+
+	v, err := strconv.ParseInt(m, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, strconv.FormatInt(v, 10) == m
+}
+
+func (s *setValue) search(v int64) (int, bool) {
+	i := sort.Search(len(s.ints), func(i int) bool {
+		return s.ints[i] >= v
+	})
+	return i, i < len(s.ints) && s.ints[i] == v
+}
+
+func (s *setValue) len() int {
+	if s.dict == nil {
+		return len(s.ints)
+	}
+	return len(s.dict)
+}
+
+func (s *setValue) has(m string) bool {
+	if s.dict == nil {
+		v, ok := parseIntsetMember(m)
+		if !ok {
+			return false
+		}
+		_, found := s.search(v)
+		return found
+	}
+
+	_, found := s.dict[m]
+	return found
+}
+
+// add adds member and reports whether it is new
+func (s *setValue) add(m string, enc *Encodings) bool {
+	if s.dict == nil {
+		v, ok := parseIntsetMember(m)
+		if ok {
+			i, found := s.search(v)
+			if found {
+				return false
+			}
+			if len(s.ints) < enc.SetMaxIntsetEntries {
+				s.ints = append(s.ints, 0)
+				copy(s.ints[i+1:], s.ints[i:])
+				s.ints[i] = v
+				return true
+			}
+		}
+		s.convert()
+	}
+
+	if _, found := s.dict[m]; found {
+		return false
+	}
+	s.dict[m] = struct{}{}
+	return true
+}
+
+// remove removes member and reports whether it existed
+func (s *setValue) remove(m string) bool {
+	if s.dict == nil {
+		v, ok := parseIntsetMember(m)
+		if !ok {
+			return false
+		}
+		i, found := s.search(v)
+		if !found {
+			return false
+		}
+		s.ints = append(s.ints[:i], s.ints[i+1:]...)
+		return true
+	}
+
+	if _, found := s.dict[m]; !found {
+		return false
+	}
+	delete(s.dict, m)
+	return true
+}
+
+// random returns random member of non empty set
+func (s *setValue) random() string {
+	if s.dict == nil {
+		return strconv.FormatInt(s.ints[rand.Intn(len(s.ints))], 10)
+	}
+
+	for m := range s.dict {
+		return m
+	}
+	return ""
+}
+
+func (s *setValue) members() []interface{} {
+	if s.dict == nil {
+		members := make([]interface{}, 0, len(s.ints))
+		for _, v := range s.ints {
+			members = append(members, strconv.AppendInt(nil, v, 10))
+		}
+		return members
+	}
+	return setMembers(s.dict)
+}
+
+// toMap returns members as hash table. It must not be modified by caller
+func (s *setValue) toMap() map[string]struct{} {
+	if s.dict == nil {
+		m := make(map[string]struct{}, len(s.ints))
+		for _, v := range s.ints {
+			m[strconv.FormatInt(v, 10)] = struct{}{}
+		}
+		return m
+	}
+	return s.dict
+}
+
+func (s *setValue) convert() {
+	s.dict = s.toMap()
+	s.ints = nil
+}
+
+func (s *setValue) clone() *setValue {
+	if s.dict == nil {
+		return &setValue{ints: append([]int64(nil), s.ints...)}
+	}
+
+	dict := make(map[string]struct{}, len(s.dict))
+	for m := range s.dict {
+		dict[m] = struct{}{}
+	}
+	return &setValue{dict: dict}
+}
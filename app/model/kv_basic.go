@@ -5,53 +5,245 @@ import (
 	"errors"
 	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Names of value types reported by TYPE
+const (
+	typeNone   = "none"
+	typeString = "string"
+	typeList   = "list"
+	typeHash   = "hash"
+	typeSet    = "set"
+	typeZSet   = "zset"
+)
+
 const (
 	kvType     byte = 1
 	kvListType byte = 2
 	kvDictType byte = 3
+	kvSetType  byte = 4
+	kvZSetType byte = 5
 )
 
-var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+var (
+	errWrongType  = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errSameObject = errors.New("source and destination objects are the same")
+)
 
 type keyValue struct {
+	// access is time of last access in milliseconds, freq is LFU counter.
+	// They are updated atomically since values are read under read lock
+	access int64
+	freq   uint32
+
+	// mem is memory taken by key and value as it was last estimated
+	mem int64
+
 	kvType byte
 	value  []byte
-	list   *list.List
-	dict   map[string]string
+	list   *quicklist
+	dict   *hash
+	set    *setValue
+	zset   *zset
 	ttl    int64
+
+	// num keeps value of string encoded as integer, value is nil then
+	num   int64
+	isNum bool
+
+	// fieldTTL keeps expiration time in milliseconds of dict fields
+	fieldTTL map[string]int64
 }
 
 type kvModel struct {
+	// used is estimated memory taken by keys and values and expired is number
+	// of expired keys, they are updated atomically
+	used    int64
+	expired int64
+
 	mu      sync.RWMutex
 	storage map[string]*keyValue
+	enc     Encodings
+
+	// exclusive is set while write lock is taken, dirty keeps keys which
+	// values memory is estimated once write lock is released
+	exclusive bool
+	dirty     map[string]struct{}
+
+	// hfe keeps keys of dicts having fields with TTL
+	hfe map[string]struct{}
+
+	// blocked keeps queues of clients blocked on list keys, ready keeps keys
+	// which got elements while serving is set and blocked clients are served
+	blocked map[string]*list.List
+	ready   [][]byte
+	serving bool
 }
 
 func newKVModel() *kvModel {
 	return &kvModel{
 		storage: make(map[string]*keyValue),
+		enc:     DefaultEncodings(),
+		dirty:   make(map[string]struct{}),
+		hfe:     make(map[string]struct{}),
+		blocked: make(map[string]*list.List),
 	}
 }
 
 func (kv *kvModel) Keys(pattern []byte) ([]interface{}, error) {
-	kv.mu.RLock()
-	defer kv.mu.RUnlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.keys(pattern)
 }
 
 func (kv *kvModel) Exists(keys ...[]byte) int {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.exists(keys...)
+}
+
+// ObjectEncoding returns encoding of value stored at key or empty string if key does not exist
+func (kv *kvModel) ObjectEncoding(key []byte) string {
+	kv.lock()
+	defer kv.unlock()
+
+	val, exists := kv.tryGet(string(key))
+	if !exists {
+		return ""
+	}
+	return val.encoding()
+}
+
+func (kv *kvModel) Type(key []byte) string {
+	kv.lock()
+	defer kv.unlock()
+
+	val, exists := kv.tryGet(string(key))
+	if !exists {
+		return typeNone
+	}
+	return val.typeName()
+}
+
+func (kv *kvModel) Rename(src []byte, dst []byte, nx bool) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.rename(src, dst, nx)
+}
+
+// RandomKey returns nil if database is empty
+func (kv *kvModel) RandomKey() []byte {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.randomKey()
+}
+
+func (kv *kvModel) DBSize() int {
 	kv.mu.RLock()
 	defer kv.mu.RUnlock()
 
+	return len(kv.storage)
+}
+
+func (kv *kvModel) Touch(keys ...[]byte) int {
+	kv.lock()
+	defer kv.unlock()
+
 	return kv.exists(keys...)
 }
 
+// Unlink removes keys like Del. Removed values are only detached from storage,
+// they are freed by garbage collector which runs concurrently with clients, so
+// removing large values does not block like in Redis
+func (kv *kvModel) Unlink(keys ...[]byte) int {
+	kv.lock()
+	defer kv.unlock()
+
+	cnt := 0
+	for _, key := range keys {
+		k := string(key)
+		if _, exists := kv.tryGet(k); exists {
+			kv.remove(k)
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// copyKey copies value with its TTL from src key of one model to dst key of
+// another one or the same model. Both models must be locked by caller
+func copyKey(from *kvModel, src []byte, to *kvModel, dst []byte, replace bool) (int, error) {
+	if from == to && string(src) == string(dst) {
+		return 0, errSameObject
+	}
+
+	val, exists := from.tryGet(string(src))
+	if !exists {
+		return 0, nil
+	}
+
+	if _, exists := to.tryGet(string(dst)); exists && !replace {
+		return 0, nil
+	}
+
+	to.store(dst, val.clone())
+	return 1, nil
+}
+
+// Flush removes all keys by detaching storage, values are freed by garbage collector
+func (kv *kvModel) Flush() {
+	kv.lock()
+	defer kv.unlock()
+
+	kv.storage = make(map[string]*keyValue)
+	kv.hfe = make(map[string]struct{})
+	kv.dirty = make(map[string]struct{})
+	atomic.StoreInt64(&kv.used, 0)
+}
+
+// moveKey moves key with its TTL from one model to another one. Both models
+// must be locked by caller
+func moveKey(from *kvModel, to *kvModel, key []byte) (int, error) {
+	if from == to {
+		return 0, errSameObject
+	}
+
+	val, exists := from.tryGet(string(key))
+	if !exists || to.keyExists(key) {
+		return 0, nil
+	}
+
+	from.remove(string(key))
+	delete(from.hfe, string(key))
+	to.store(key, val)
+	return 1, nil
+}
+
+// swapKeys exchanges keys of two models. Both models must be locked by caller.
+// Clients blocked on lists stay with their databases and are served by keys
+// which come with the swap
+func swapKeys(a *kvModel, b *kvModel) {
+	a.account()
+	b.account()
+
+	a.storage, b.storage = b.storage, a.storage
+	a.hfe, b.hfe = b.hfe, a.hfe
+	used := atomic.LoadInt64(&a.used)
+	atomic.StoreInt64(&a.used, atomic.SwapInt64(&b.used, used))
+
+	a.serveAllBlocked()
+	b.serveAllBlocked()
+}
+
 func (kv *kvModel) Expire(key []byte, ttl int64) int {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.expire(key, ttl)
 }
@@ -114,6 +306,57 @@ func (kv *kvModel) expire(key []byte, ttl int64) int {
 	return 1
 }
 
+func (kv *kvModel) rename(src []byte, dst []byte, nx bool) (int, error) {
+	val, exists := kv.tryGet(string(src))
+	if !exists {
+		return 0, errNoSuchKey
+	}
+
+	if string(src) == string(dst) {
+		if nx {
+			return 0, nil
+		}
+		return 1, nil
+	}
+
+	if nx && kv.keyExists(dst) {
+		return 0, nil
+	}
+
+	kv.remove(string(src))
+	delete(kv.hfe, string(src))
+	kv.store(dst, val)
+	return 1, nil
+}
+
+func (kv *kvModel) randomKey() []byte {
+	now := time.Now().Unix()
+	for k := range kv.storage {
+		if _, exists := kv.tryGetN(k, now); exists {
+			return []byte(k)
+		}
+	}
+	return nil
+}
+
+// store puts value at key replacing existing one, registers dict fields
+// TTLs and wakes up clients blocked on list
+func (kv *kvModel) store(key []byte, val *keyValue) {
+	k := string(key)
+	kv.put(k, val)
+
+	delete(kv.hfe, k)
+	if val.fieldTTL != nil {
+		kv.hfe[k] = struct{}{}
+	}
+
+	if val.kvType == kvListType {
+		kv.serveBlocked(key)
+	}
+}
+
+// tryGet returns value stored at key, expired key is removed so callers must
+// hold write lock
 func (kv *kvModel) tryGet(key string) (*keyValue, bool) {
 	return kv.tryGetN(key, time.Now().Unix())
 }
@@ -125,13 +368,72 @@ func (kv *kvModel) tryGetN(key string, now int64) (*keyValue, bool) {
 	}
 
 	if isExpired(val, now) {
-		delete(kv.storage, key)
+		kv.remove(key)
+		atomic.AddInt64(&kv.expired, 1)
 		return nil, false
 	}
 
+	kv.markDirty(key)
+	val.touch(nowMs())
 	return val, true
 }
 
+func (val *keyValue) typeName() string {
+	switch val.kvType {
+	case kvListType:
+		return typeList
+	case kvDictType:
+		return typeHash
+	case kvSetType:
+		return typeSet
+	case kvZSetType:
+		return typeZSet
+	}
+	return typeString
+}
+
+// length returns number of elements of value
+func (val *keyValue) length() int {
+	switch val.kvType {
+	case kvListType:
+		return val.list.len()
+	case kvDictType:
+		return val.dict.len()
+	case kvSetType:
+		return val.set.len()
+	case kvZSetType:
+		return val.zset.len()
+	}
+	return 1
+}
+
+// clone returns deep copy of value with its TTLs
+func (val *keyValue) clone() *keyValue {
+	c := *val
+	switch val.kvType {
+	case kvListType:
+		c.list = val.list.clone()
+	case kvDictType:
+		c.dict = val.dict.clone()
+	case kvSetType:
+		c.set = val.set.clone()
+	case kvZSetType:
+		c.zset = val.zset.clone()
+	}
+
+	if val.fieldTTL != nil {
+		c.fieldTTL = make(map[string]int64, len(val.fieldTTL))
+		for f, ttl := range val.fieldTTL {
+			c.fieldTTL[f] = ttl
+		}
+	}
+	return &c
+}
+
+func nowMs() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
 func isExpired(val *keyValue, now int64) bool {
 	return val.ttl != 0 && val.ttl-now <= 0
 }
@@ -0,0 +1,263 @@
+package model
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestKeyManagement(t *testing.T) {
+	RegisterTestingT(t)
+
+	db := newDBModel(0)
+	Expect(db.Type([]byte("s"))).To(BeEquivalentTo("none"))
+	Expect(db.RandomKey()).To(BeNil())
+	Expect(db.DBSize()).To(Equal(0))
+
+	db.Set([]byte("s"), []byte("v"))
+	db.LPush([]byte("l"), []byte("a"))
+	db.HSet([]byte("h"), []byte("f"), []byte("v"))
+	db.SAdd([]byte("set"), []byte("m"))
+	db.ZAdd([]byte("z"), []byte("1"), []byte("m"))
+
+	Expect(db.Type([]byte("s"))).To(BeEquivalentTo("string"))
+	Expect(db.Type([]byte("l"))).To(BeEquivalentTo("list"))
+	Expect(db.Type([]byte("h"))).To(BeEquivalentTo("hash"))
+	Expect(db.Type([]byte("set"))).To(BeEquivalentTo("set"))
+	Expect(db.Type([]byte("z"))).To(BeEquivalentTo("zset"))
+	Expect(db.DBSize()).To(Equal(5))
+	Expect(db.RandomKey()).ToNot(BeNil())
+	Expect(db.Touch([]byte("s"), []byte("l"), []byte("missing"))).To(Equal(2))
+
+	// rename keeps TTL and replaces destination
+	db.ExpireN([]byte("s"), 100)
+	ttl := db.kv.storage["s"].ttl
+	Expect(db.Rename([]byte("s"), []byte("l"))).To(Succeed())
+	Expect(db.Exists([]byte("s"))).To(Equal(0))
+	Expect(db.Type([]byte("l"))).To(BeEquivalentTo("string"))
+	Expect(db.kv.storage["l"].ttl).To(Equal(ttl))
+	Expect(db.Rename([]byte("s"), []byte("l"))).To(Equal(errNoSuchKey))
+
+	ok, err := db.RenameNX([]byte("l"), []byte("h"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ok).To(Equal(0))
+	ok, err = db.RenameNX([]byte("l"), []byte("s"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ok).To(Equal(1))
+
+	Expect(db.Unlink([]byte("s"), []byte("missing"))).To(Equal(1))
+	Expect(db.DBSize()).To(Equal(3))
+}
+
+func TestReadExpiredKeys(t *testing.T) {
+	RegisterTestingT(t)
+
+	db := newDBModel(0)
+	const n = 200
+	for i := 0; i < n; i++ {
+		k := strconv.Itoa(i)
+		db.Set([]byte("s"+k), []byte("v"))
+		db.LPush([]byte("l"+k), []byte("a"))
+		db.SAdd([]byte("set"+k), []byte("m"))
+		db.ZAdd([]byte("z"+k), []byte("1"), []byte("m"))
+	}
+	for _, val := range db.kv.storage {
+		val.ttl = 1
+	}
+
+	// reads remove expired keys so they must not run concurrently
+	var wg sync.WaitGroup
+	for _, prefix := range []string{"s", "l", "set", "z"} {
+		for _, read := range []func(key []byte){
+			func(key []byte) { db.Get(key) },
+			func(key []byte) { db.Exists(key) },
+			func(key []byte) { db.Object([]byte("encoding"), key) },
+			func(key []byte) { db.LLen(key) },
+			func(key []byte) { db.SCard(key) },
+			func(key []byte) { db.ZCard(key) },
+		} {
+			wg.Add(1)
+			go func(prefix string, read func(key []byte)) {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					read([]byte(prefix + strconv.Itoa(i)))
+				}
+			}(prefix, read)
+		}
+	}
+	wg.Wait()
+
+	Expect(db.DBSize()).To(Equal(0))
+	Expect(db.kv.used).To(BeZero())
+}
+
+func TestCopy(t *testing.T) {
+	RegisterTestingT(t)
+
+	app := NewAppModel(16, DefaultEncodings())
+	db, _ := app.SelectIndex(0)
+	other, _ := app.SelectIndex(1)
+
+	db.RPush([]byte("l"), []byte("a"), []byte("b"))
+	db.ExpireN([]byte("l"), 100)
+
+	_, err := db.Copy([]byte("l"), []byte("l"))
+	Expect(err).To(Equal(errSameObject))
+
+	ok, err := db.Copy([]byte("l"), []byte("c"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ok).To(Equal(1))
+	Expect(db.kv.storage["c"].ttl).To(Equal(db.kv.storage["l"].ttl))
+
+	// copy is independent of source
+	db.RPush([]byte("c"), []byte("c"))
+	Expect(db.LRangeN([]byte("l"), 0, -1)).To(HaveLen(2))
+	Expect(db.LRangeN([]byte("c"), 0, -1)).To(HaveLen(3))
+
+	ok, err = db.Copy([]byte("c"), []byte("l"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ok).To(Equal(0))
+
+	ok, err = db.Copy([]byte("c"), []byte("l"), []byte("replace"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ok).To(Equal(1))
+	Expect(db.LRangeN([]byte("l"), 0, -1)).To(HaveLen(3))
+
+	ok, err = db.Copy([]byte("l"), []byte("l"), []byte("DB"), []byte("1"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ok).To(Equal(1))
+	Expect(other.LRangeN([]byte("l"), 0, -1)).To(HaveLen(3))
+
+	_, err = db.Copy([]byte("l"), []byte("l"), []byte("DB"), []byte("16"))
+	Expect(err).To(Equal(errDBIndexRange))
+	_, err = db.Copy([]byte("l"), []byte("l"), []byte("DB"))
+	Expect(err).To(Equal(errSyntax))
+
+	ok, err = db.Copy([]byte("missing"), []byte("x"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ok).To(Equal(0))
+}
+
+func TestUnlinkLargeValue(t *testing.T) {
+	RegisterTestingT(t)
+
+	db := newDBModel(0)
+	for i := 0; i < 1000; i++ {
+		db.SAdd([]byte("set"), []byte(strconv.Itoa(i)))
+	}
+	db.Set([]byte("a"), []byte("1"))
+	Expect(db.kv.usedMemory()).NotTo(BeZero())
+
+	Expect(db.Unlink([]byte("set"), []byte("missing"), []byte("a"))).To(Equal(2))
+	Expect(db.Exists([]byte("set"), []byte("a"))).To(Equal(0))
+	Expect(db.DBSize()).To(Equal(0))
+	Expect(db.kv.usedMemory()).To(BeZero())
+}
+
+func TestFlushAndMove(t *testing.T) {
+	RegisterTestingT(t)
+
+	app := NewAppModel(16, DefaultEncodings())
+	db, _ := app.SelectIndex(0)
+	other, _ := app.SelectIndex(1)
+
+	db.Set([]byte("a"), []byte("1"))
+	db.Set([]byte("b"), []byte("2"))
+	db.ExpireN([]byte("a"), 100)
+	other.Set([]byte("b"), []byte("3"))
+
+	ok, err := db.Move([]byte("a"), []byte("1"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ok).To(Equal(1))
+	Expect(db.Exists([]byte("a"))).To(Equal(0))
+	Expect(other.kv.storage["a"].ttl).ToNot(BeZero())
+
+	ok, err = db.Move([]byte("b"), []byte("1"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ok).To(Equal(0))
+	_, err = db.Move([]byte("b"), []byte("0"))
+	Expect(err).To(Equal(errSameObject))
+	_, err = db.Move([]byte("b"), []byte("16"))
+	Expect(err).To(Equal(errDBIndexRange))
+
+	Expect(db.FlushDB([]byte("now"))).To(Equal(errSyntax))
+	Expect(db.FlushDB([]byte("async"))).To(Succeed())
+	Expect(db.DBSize()).To(Equal(0))
+	Expect(other.DBSize()).To(Equal(2))
+
+	db.Set([]byte("a"), []byte("1"))
+	Expect(app.FlushAll()).To(Succeed())
+	Expect(db.DBSize()).To(Equal(0))
+	Expect(other.DBSize()).To(Equal(0))
+}
+
+func TestSelectIndexConcurrent(t *testing.T) {
+	RegisterTestingT(t)
+
+	app := NewAppModel(16, DefaultEncodings())
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			for j := 0; j < 16; j++ {
+				db, err := app.SelectIndex((index + j) % 16)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(db.Index()).To(Equal((index + j) % 16))
+				app.created()
+			}
+		}(i)
+	}
+	wg.Wait()
+	Expect(app.created()).To(HaveLen(16))
+
+	_, err := app.SelectIndex(16)
+	Expect(err).To(Equal(errInvalidDBIndex))
+}
+
+func TestSwapDB(t *testing.T) {
+	RegisterTestingT(t)
+
+	app := NewAppModel(16, DefaultEncodings())
+	db, _ := app.SelectIndex(0)
+	other, _ := app.SelectIndex(1)
+
+	db.Set([]byte("a"), []byte("1"))
+	other.RPush([]byte("l"), []byte("x"))
+
+	popped := make(chan []byte)
+	go func() {
+		_, values, _ := db.kv.BLRPop([][]byte{[]byte("l")}, 1, true, 0, nil)
+		popped <- values[0].([]byte)
+	}()
+	Eventually(blockedOn(db, "l")).Should(Equal(1))
+
+	Expect(app.SwapDB("0", "1")).To(Succeed())
+	Expect(<-popped).To(BeEquivalentTo("x"))
+	Expect(db.Exists([]byte("a"))).To(Equal(0))
+	Expect(other.Get([]byte("a"))).To(BeEquivalentTo("1"))
+
+	Expect(app.SwapDB("0", "x")).To(Equal(errInvalidSecondDBIndex))
+	Expect(app.SwapDB("0", "16")).To(Equal(errDBIndexOutOfRange))
+	Expect(app.SwapDB("1", "1")).To(Succeed())
+
+	// client blocked on key holding other type after swap stays blocked
+	other.Set([]byte("l"), []byte("x"))
+	cancel := make(chan struct{})
+	errs := make(chan error)
+	go func() {
+		_, _, err := db.kv.BLRPop([][]byte{[]byte("l")}, 1, true, 0, cancel)
+		errs <- err
+	}()
+	Eventually(blockedOn(db, "l")).Should(Equal(1))
+
+	Expect(app.SwapDB("0", "1")).To(Succeed())
+	Expect(db.Type([]byte("l"))).To(BeEquivalentTo("string"))
+	Consistently(errs).ShouldNot(Receive())
+	Expect(blockedOn(db, "l")()).To(Equal(1))
+
+	close(cancel)
+	Expect(<-errs).To(Equal(errUnblocked))
+}
@@ -1,137 +1,598 @@
 package model
 
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+var (
+	errHashNotInteger = errors.New("hash value is not an integer")
+	errHashNotFloat   = errors.New("hash value is not a float")
+	errOverflow       = errors.New("increment or decrement would overflow")
+	errNaNOrInfinity  = errors.New("increment would produce NaN or Infinity")
+)
+
 func newKeyValueDict() *keyValue {
 	return &keyValue{
 		kvType: kvDictType,
-		dict:   make(map[string]string),
+		dict:   newHash(),
 	}
 }
 
-func (kv *kvModel) HSet(key []byte, field []byte, value []byte) (int, error) {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+func (kv *kvModel) HSet(key []byte, fields [][]byte, values [][]byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
 
-	return kv.hset(key, field, value)
+	return kv.hset(key, fields, values)
+}
+
+func (kv *kvModel) HSetNX(key []byte, field []byte, value []byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.hsetnx(key, field, value)
 }
 
 func (kv *kvModel) HGet(key []byte, field []byte) ([]byte, error) {
-	kv.mu.RLock()
-	defer kv.mu.RUnlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.hget(key, field)
 }
 
+func (kv *kvModel) HMGet(key []byte, fields ...[]byte) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.hmget(key, fields...)
+}
+
+func (kv *kvModel) HGetAll(key []byte) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.hgetall(key, true, true)
+}
+
+func (kv *kvModel) HKeys(key []byte) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.hgetall(key, true, false)
+}
+
+func (kv *kvModel) HVals(key []byte) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.hgetall(key, false, true)
+}
+
 func (kv *kvModel) HDel(key []byte, fields ...[]byte) (int, error) {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.hdel(key, fields...)
 }
 
-func (kv *kvModel) HLen(key []byte, fields ...[]byte) (int, error) {
-	kv.mu.RLock()
-	defer kv.mu.RUnlock()
+func (kv *kvModel) HLen(key []byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.hlen(key)
 }
 
 func (kv *kvModel) HExists(key []byte, field []byte) (int, error) {
-	kv.mu.RLock()
-	defer kv.mu.RUnlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.hexists(key, field)
 }
 
-func (kv *kvModel) hset(key []byte, field []byte, value []byte) (int, error) {
+func (kv *kvModel) HStrLen(key []byte, field []byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.hstrlen(key, field)
+}
+
+func (kv *kvModel) HIncrBy(key []byte, field []byte, increment int64) (int64, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.hincrby(key, field, increment)
+}
+
+func (kv *kvModel) HIncrByFloat(key []byte, field []byte, increment float64) ([]byte, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.hincrbyfloat(key, field, increment)
+}
+
+func (kv *kvModel) HRandField(key []byte, count int, withValues bool) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.hrandfield(key, count, withValues)
+}
+
+// tryDict returns dict stored at key or nil if key does not exist.
+// Expired fields are removed on access so all dict commands take write lock
+func (kv *kvModel) tryDict(key []byte) (*keyValue, error) {
 	k := string(key)
 	val, exists := kv.tryGet(k)
-	if exists {
-		if val.kvType != kvDictType {
-			return 0, errWrongType
-		}
-	} else {
+	if !exists {
+		return nil, nil
+	}
+
+	if val.kvType != kvDictType {
+		return nil, errWrongType
+	}
+
+	if !kv.expireFields(k, val, nowMs()) {
+		return nil, nil
+	}
+	return val, nil
+}
+
+// getOrCreateDict returns dict stored at key and creates new one if key does not exist
+func (kv *kvModel) getOrCreateDict(key []byte) (*keyValue, error) {
+	val, err := kv.tryDict(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if val == nil {
 		val = newKeyValueDict()
-		kv.storage[k] = val
+		kv.put(string(key), val)
+	}
+	return val, nil
+}
+
+func (kv *kvModel) hset(key []byte, fields [][]byte, values [][]byte) (int, error) {
+	val, err := kv.getOrCreateDict(key)
+	if err != nil {
+		return 0, err
+	}
+
+	cnt := 0
+	for i, field := range fields {
+		f := string(field)
+		if val.dict.set(f, values[i], &kv.enc) {
+			cnt++
+		}
+		val.persistField(f)
+	}
+	return cnt, nil
+}
+
+func (kv *kvModel) hsetnx(key []byte, field []byte, value []byte) (int, error) {
+	val, err := kv.getOrCreateDict(key)
+	if err != nil {
+		return 0, err
 	}
 
 	f := string(field)
-	_, ok := val.dict[f]
-	val.dict[f] = string(value)
-	if ok {
+	if _, ok := val.dict.get(f); ok {
 		return 0, nil
 	}
+	val.dict.set(f, value, &kv.enc)
 	return 1, nil
 }
 
 func (kv *kvModel) hget(key []byte, field []byte) ([]byte, error) {
-	k := string(key)
-	val, exists := kv.tryGet(k)
-	if !exists {
-		return nil, nil
+	val, err := kv.tryDict(key)
+	if val == nil {
+		return nil, err
 	}
 
-	if val.kvType != kvDictType {
-		return nil, errWrongType
+	v, exists := val.dict.get(string(field))
+	if exists && v == nil {
+		return []byte{}, nil
+	}
+	return v, nil
+}
+
+func (kv *kvModel) hmget(key []byte, fields ...[]byte) ([]interface{}, error) {
+	val, err := kv.tryDict(key)
+	if err != nil {
+		return nil, err
 	}
 
-	v, exists := val.dict[string(field)]
-	if exists {
-		return []byte(v), nil
+	values := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		if val == nil {
+			values = append(values, nil)
+		} else if v, exists := val.dict.get(string(field)); exists {
+			values = append(values, v)
+		} else {
+			values = append(values, nil)
+		}
 	}
-	return nil, nil
+	return values, nil
 }
 
-func (kv *kvModel) hdel(key []byte, fields ...[]byte) (int, error) {
-	k := string(key)
-	val, exists := kv.tryGet(k)
-	if !exists {
-		return 0, nil
+func (kv *kvModel) hgetall(key []byte, withFields bool, withValues bool) ([]interface{}, error) {
+	val, err := kv.tryDict(key)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return make([]interface{}, 0), nil
 	}
 
-	if val.kvType != kvDictType {
-		return 0, errWrongType
+	values := make([]interface{}, 0, 2*val.dict.len())
+	val.dict.each(func(f []byte, v []byte) {
+		if withFields {
+			values = append(values, f)
+		}
+		if withValues {
+			values = append(values, v)
+		}
+	})
+	return values, nil
+}
+
+func (kv *kvModel) hdel(key []byte, fields ...[]byte) (int, error) {
+	val, err := kv.tryDict(key)
+	if val == nil {
+		return 0, err
 	}
 
 	cnt := 0
 	for _, field := range fields {
 		f := string(field)
-		_, exists = val.dict[f]
-		if exists {
+		if val.dict.del(f) {
+			val.persistField(f)
 			cnt++
 		}
-		delete(val.dict, f)
 	}
 
+	if val.dict.len() == 0 {
+		kv.remove(string(key))
+	}
 	return cnt, nil
 }
 
 func (kv *kvModel) hlen(key []byte) (int, error) {
-	k := string(key)
-	val, exists := kv.tryGet(k)
-	if exists {
-		if val.kvType != kvDictType {
-			return 0, errWrongType
-		}
+	val, err := kv.tryDict(key)
+	if val == nil {
+		return 0, err
+	}
 
-		return val.list.Len(), nil
+	return val.dict.len(), nil
+}
+
+func (kv *kvModel) hexists(key []byte, field []byte) (int, error) {
+	val, err := kv.tryDict(key)
+	if val == nil {
+		return 0, err
 	}
 
+	if _, exists := val.dict.get(string(field)); exists {
+		return 1, nil
+	}
 	return 0, nil
 }
 
-func (kv *kvModel) hexists(key []byte, field []byte) (int, error) {
+func (kv *kvModel) hstrlen(key []byte, field []byte) (int, error) {
+	val, err := kv.tryDict(key)
+	if val == nil {
+		return 0, err
+	}
+
+	v, _ := val.dict.get(string(field))
+	return len(v), nil
+}
+
+func (kv *kvModel) hincrby(key []byte, field []byte, increment int64) (int64, error) {
+	val, err := kv.getOrCreateDict(key)
+	if err != nil {
+		return 0, err
+	}
+
+	f := string(field)
+	var cur int64
+	if v, exists := val.dict.get(f); exists {
+		cur, err = strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return 0, errHashNotInteger
+		}
+	}
+
+	if (increment < 0 && cur < 0 && increment < math.MinInt64-cur) ||
+		(increment > 0 && cur > 0 && increment > math.MaxInt64-cur) {
+		return 0, errOverflow
+	}
+
+	cur += increment
+	val.dict.set(f, strconv.AppendInt(nil, cur, 10), &kv.enc)
+	return cur, nil
+}
+
+func (kv *kvModel) hincrbyfloat(key []byte, field []byte, increment float64) ([]byte, error) {
+	val, err := kv.getOrCreateDict(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f := string(field)
+	var cur float64
+	if v, exists := val.dict.get(f); exists {
+		cur, err = strconv.ParseFloat(string(v), 64)
+		if err != nil || math.IsNaN(cur) || math.IsInf(cur, 0) {
+			return nil, errHashNotFloat
+		}
+	}
+
+	cur += increment
+	if math.IsNaN(cur) || math.IsInf(cur, 0) {
+		if val.dict.len() == 0 {
+			kv.remove(string(key))
+		}
+		return nil, errNaNOrInfinity
+	}
+
+	v := strconv.AppendFloat(nil, cur, 'f', -1, 64)
+	val.dict.set(f, v, &kv.enc)
+	return v, nil
+}
+
+// hrandfield returns count distinct fields when count is positive and
+// count possibly repeated fields when count is negative
+func (kv *kvModel) hrandfield(key []byte, count int, withValues bool) ([]interface{}, error) {
+	val, err := kv.tryDict(key)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return make([]interface{}, 0), nil
+	}
+
+	fields := make([][]byte, 0, val.dict.len())
+	val.dict.each(func(f []byte, v []byte) {
+		fields = append(fields, f)
+	})
+
+	var picked [][]byte
+	if count >= 0 {
+		count = min(count, len(fields))
+		for i := 0; i < count; i++ {
+			j := i + rand.Intn(len(fields)-i)
+			fields[i], fields[j] = fields[j], fields[i]
+		}
+		picked = fields[:count]
+	} else {
+		picked = make([][]byte, 0, -count)
+		for i := 0; i < -count; i++ {
+			picked = append(picked, fields[rand.Intn(len(fields))])
+		}
+	}
+
+	values := make([]interface{}, 0, 2*len(picked))
+	for _, f := range picked {
+		values = append(values, f)
+		if withValues {
+			v, _ := val.dict.get(string(f))
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// Conditions of HEXPIRE family commands
+const (
+	hExpireAlways = iota
+	hExpireNX
+	hExpireXX
+	hExpireGT
+	hExpireLT
+)
+
+// Per field replies of HEXPIRE, HTTL and HPERSIST commands
+const (
+	hFieldMissing = -2
+	hFieldNoTTL   = -1
+	hFieldSkipped = 0
+	hFieldUpdated = 1
+	hFieldDeleted = 2
+)
+
+func (kv *kvModel) HExpire(key []byte, when int64, cond int, fields ...[]byte) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.hexpire(key, when, cond, fields...)
+}
+
+func (kv *kvModel) HTTL(key []byte, ms bool, fields ...[]byte) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.httl(key, ms, fields...)
+}
+
+func (kv *kvModel) HPersist(key []byte, fields ...[]byte) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.hpersist(key, fields...)
+}
+
+// ActiveExpire removes expired fields of all dicts having field TTLs
+// so that fields which are never accessed again do not hold memory
+func (kv *kvModel) ActiveExpire() {
+	kv.lock()
+	defer kv.unlock()
+
+	now := nowMs()
+	for k := range kv.hfe {
+		val, exists := kv.storage[k]
+		if !exists || val.kvType != kvDictType || val.fieldTTL == nil {
+			delete(kv.hfe, k)
+			continue
+		}
+
+		kv.markDirty(k)
+		if !kv.expireFields(k, val, now) || val.fieldTTL == nil {
+			delete(kv.hfe, k)
+		}
+	}
+}
+
+// hexpire sets absolute expiration time in milliseconds for fields of dict
+func (kv *kvModel) hexpire(key []byte, when int64, cond int, fields ...[]byte) ([]interface{}, error) {
+	val, err := kv.tryDict(key)
+	if err != nil {
+		return nil, err
+	}
+
 	k := string(key)
-	val, exists := kv.tryGet(k)
-	if exists {
-		if val.kvType != kvDictType {
-			return 0, errWrongType
+	now := nowMs()
+	res := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		f := string(field)
+		if val == nil {
+			res = append(res, hFieldMissing)
+			continue
+		}
+		if _, exists := val.dict.get(f); !exists {
+			res = append(res, hFieldMissing)
+			continue
 		}
 
-		_, exists := val.dict[string(field)]
-		if exists {
-			return 1, nil
+		cur, hasTTL := val.fieldTTL[f]
+		ok := true
+		switch cond {
+		case hExpireNX:
+			ok = !hasTTL
+		case hExpireXX:
+			ok = hasTTL
+		case hExpireGT:
+			ok = hasTTL && when > cur
+		case hExpireLT:
+			ok = !hasTTL || when < cur
+		}
+		if !ok {
+			res = append(res, hFieldSkipped)
+			continue
 		}
+
+		if when <= now {
+			val.dict.del(f)
+			val.persistField(f)
+			res = append(res, hFieldDeleted)
+			continue
+		}
+
+		if val.fieldTTL == nil {
+			val.fieldTTL = make(map[string]int64)
+			kv.hfe[k] = struct{}{}
+		}
+		val.fieldTTL[f] = when
+		res = append(res, hFieldUpdated)
 	}
 
-	return 0, nil
+	if val != nil && val.dict.len() == 0 {
+		kv.remove(k)
+	}
+	return res, nil
+}
+
+func (kv *kvModel) httl(key []byte, ms bool, fields ...[]byte) ([]interface{}, error) {
+	val, err := kv.tryDict(key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := nowMs()
+	res := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		f := string(field)
+		if val == nil {
+			res = append(res, hFieldMissing)
+			continue
+		}
+		if _, exists := val.dict.get(f); !exists {
+			res = append(res, hFieldMissing)
+			continue
+		}
+
+		ttl, hasTTL := val.fieldTTL[f]
+		if !hasTTL {
+			res = append(res, hFieldNoTTL)
+		} else if ms {
+			res = append(res, int(ttl-now))
+		} else {
+			res = append(res, int((ttl-now+500)/1000))
+		}
+	}
+	return res, nil
+}
+
+func (kv *kvModel) hpersist(key []byte, fields ...[]byte) ([]interface{}, error) {
+	val, err := kv.tryDict(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		f := string(field)
+		if val == nil {
+			res = append(res, hFieldMissing)
+			continue
+		}
+		if _, exists := val.dict.get(f); !exists {
+			res = append(res, hFieldMissing)
+			continue
+		}
+
+		if val.persistField(f) {
+			res = append(res, hFieldUpdated)
+		} else {
+			res = append(res, hFieldNoTTL)
+		}
+	}
+	return res, nil
+}
+
+// expireFields removes expired fields of dict and deletes key when its
+// last field has expired. It returns false if key has been deleted
+func (kv *kvModel) expireFields(k string, val *keyValue, now int64) bool {
+	if val.fieldTTL == nil {
+		return true
+	}
+
+	for f, ttl := range val.fieldTTL {
+		if ttl <= now {
+			val.dict.del(f)
+			delete(val.fieldTTL, f)
+		}
+	}
+	if len(val.fieldTTL) == 0 {
+		val.fieldTTL = nil
+	}
+
+	if val.dict.len() == 0 {
+		kv.remove(k)
+		return false
+	}
+	return true
+}
+
+// persistField removes TTL of field and reports whether field had TTL
+func (val *keyValue) persistField(f string) bool {
+	if _, hasTTL := val.fieldTTL[f]; !hasTTL {
+		return false
+	}
+
+	delete(val.fieldTTL, f)
+	if len(val.fieldTTL) == 0 {
+		val.fieldTTL = nil
+	}
+	return true
 }
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDictCommands(t *testing.T) {
+	RegisterTestingT(t)
+
+	dbModel := newDBModel(0)
+
+	key := []byte("dict")
+	f1, f2, f3 := []byte("f1"), []byte("f2"), []byte("f3")
+	v1, v2 := []byte("v1"), []byte("bin\x00ary")
+
+	cnt, err := dbModel.HSet(key, f1, v1, f2, v2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(2))
+
+	_, err = dbModel.HSet(key, f1)
+	Expect(err).To(Equal(errSyntax))
+
+	cnt, err = dbModel.HLen(key)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(2))
+
+	cnt, err = dbModel.HSetNX(key, f1, v2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(0))
+
+	values, err := dbModel.HMGet(key, f1, f3, f2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(Equal([]interface{}{v1, nil, v2}))
+
+	values, err = dbModel.HGetAll(key)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(HaveLen(4))
+
+	cnt, err = dbModel.HStrLen(key, f2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(len(v2)))
+
+	i, err := dbModel.HIncrBy(key, f3, []byte("5"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(i).To(Equal(int64(5)))
+
+	_, err = dbModel.HIncrBy(key, f1, []byte("1"))
+	Expect(err).To(Equal(errHashNotInteger))
+
+	_, err = dbModel.HIncrBy(key, f3, []byte("9223372036854775807"))
+	Expect(err).To(Equal(errOverflow))
+
+	f, err := dbModel.HIncrByFloat(key, f3, []byte("0.5"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(f).To(BeEquivalentTo("5.5"))
+
+	values, err = dbModel.HRandFieldCount(key, []byte("-5"), []byte("WITHVALUES"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(HaveLen(10))
+
+	cnt, err = dbModel.HDel(key, f1, f2, f3)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(3))
+	Expect(dbModel.Exists(key)).To(Equal(0))
+}
+
+func TestDictFieldExpiration(t *testing.T) {
+	RegisterTestingT(t)
+
+	dbModel := newDBModel(0)
+
+	key := []byte("flags")
+	f1, f2, f3 := []byte("f1"), []byte("f2"), []byte("f3")
+	fields := func(fields ...[]byte) [][]byte {
+		return append([][]byte{[]byte("FIELDS"), []byte(strconv.Itoa(len(fields)))}, fields...)
+	}
+
+	dbModel.HSet(key, f1, f1, f2, f2)
+
+	res, err := dbModel.HExpire(key, []byte("100"), fields(f1, f3)...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(res).To(Equal([]interface{}{hFieldUpdated, hFieldMissing}))
+
+	res, err = dbModel.HExpire(key, []byte("200"), append([][]byte{[]byte("NX")}, fields(f1, f2)...)...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(res).To(Equal([]interface{}{hFieldSkipped, hFieldUpdated}))
+
+	res, err = dbModel.HTTL(key, fields(f1, f2, f3)...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(res).To(Equal([]interface{}{100, 200, hFieldMissing}))
+
+	res, err = dbModel.HPersist(key, fields(f1, f1)...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(res).To(Equal([]interface{}{hFieldUpdated, hFieldNoTTL}))
+
+	_, err = dbModel.HTTL(key, []byte("FIELDS"), []byte("2"), f1)
+	Expect(err).To(Equal(errNumFieldsArgs))
+
+	res, err = dbModel.HPExpire(key, []byte("1"), fields(f2)...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(res).To(Equal([]interface{}{hFieldUpdated}))
+
+	res, err = dbModel.HExpireAt(key, []byte("1"), fields(f1)...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(res).To(Equal([]interface{}{hFieldDeleted}))
+
+	time.Sleep(5 * time.Millisecond)
+	dbModel.kv.ActiveExpire()
+
+	Expect(dbModel.Exists(key)).To(Equal(0))
+	Expect(dbModel.kv.hfe).To(BeEmpty())
+}
@@ -2,71 +2,76 @@ package model
 
 import (
 	"bytes"
-	"container/list"
+	"errors"
 )
 
-type lrPush func(list *list.List, v interface{}) *list.Element
-type lrPop func(list *list.List) []byte
+var (
+	errNoSuchKey       = errors.New("ERR no such key")
+	errIndexOutOfRange = errors.New("ERR index out of range")
+)
+
+type lrPush func(list *quicklist, v []byte)
+type lrPop func(list *quicklist) []byte
 
-func newKeyValueList() *keyValue {
+func newKeyValueList(fill int) *keyValue {
 	return &keyValue{
 		kvType: kvListType,
-		list:   list.New(),
+		list:   newQuicklist(fill),
 	}
 }
 
 func (kv *kvModel) LPush(key []byte, values ...[]byte) (int, error) {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.lpush(key, values...)
 }
 
 func (kv *kvModel) RPush(key []byte, values ...[]byte) (int, error) {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.rpush(key, values...)
 }
 
 func (kv *kvModel) LPop(key []byte) ([]byte, error) {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.lpop(key)
 }
 
 func (kv *kvModel) RPop(key []byte) ([]byte, error) {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.rpop(key)
 }
 
 func (kv *kvModel) LLen(key []byte) (int, error) {
-	kv.mu.RLock()
-	defer kv.mu.RUnlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.llen(key)
 }
 
 func (kv *kvModel) LInsert(key []byte, before bool, pivot []byte, value []byte) (int, error) {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.linsert(key, before, pivot, value)
 }
 
 func (kv *kvModel) LIndex(key []byte, index int) ([]byte, error) {
-	kv.mu.RLock()
-	defer kv.mu.RUnlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.lindex(key, index)
 }
 
 func (kv *kvModel) LRange(key []byte, start int, stop int) ([]interface{}, error) {
-	kv.mu.RLock()
-	defer kv.mu.RUnlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.lrange(key, start, stop)
 }
@@ -79,26 +84,28 @@ func (kv *kvModel) lrpush(push lrPush, key []byte, values ...[]byte) (int, error
 			return 0, errWrongType
 		}
 	} else {
-		val = newKeyValueList()
-		kv.storage[k] = val
+		val = newKeyValueList(kv.enc.ListMaxListpackSize)
+		kv.put(k, val)
 	}
 
 	for _, value := range values {
 		push(val.list, value)
 	}
 
-	return val.list.Len(), nil
+	l := val.list.len()
+	kv.serveBlocked(key)
+	return l, nil
 }
 
 func (kv *kvModel) lpush(key []byte, values ...[]byte) (int, error) {
-	return kv.lrpush(func(list *list.List, v interface{}) *list.Element {
-		return list.PushFront(v)
+	return kv.lrpush(func(list *quicklist, v []byte) {
+		list.pushFront(v)
 	}, key, values...)
 }
 
 func (kv *kvModel) rpush(key []byte, values ...[]byte) (int, error) {
-	return kv.lrpush(func(list *list.List, v interface{}) *list.Element {
-		return list.PushBack(v)
+	return kv.lrpush(func(list *quicklist, v []byte) {
+		list.pushBack(v)
 	}, key, values...)
 }
 
@@ -114,22 +121,22 @@ func (kv *kvModel) lrpop(pop lrPop, key []byte) ([]byte, error) {
 	}
 
 	e := pop(val.list)
-	if val.list.Len() == 0 {
-		delete(kv.storage, k)
+	if val.list.len() == 0 {
+		kv.remove(k)
 	}
 
 	return e, nil
 }
 
 func (kv *kvModel) lpop(key []byte) ([]byte, error) {
-	return kv.lrpop(func(list *list.List) []byte {
-		return list.Remove(list.Front()).([]byte)
+	return kv.lrpop(func(list *quicklist) []byte {
+		return list.popFront()
 	}, key)
 }
 
 func (kv *kvModel) rpop(key []byte) ([]byte, error) {
-	return kv.lrpop(func(list *list.List) []byte {
-		return list.Remove(list.Back()).([]byte)
+	return kv.lrpop(func(list *quicklist) []byte {
+		return list.popBack()
 	}, key)
 }
 
@@ -140,7 +147,7 @@ func (kv *kvModel) llen(key []byte) (int, error) {
 			return 0, errWrongType
 		}
 
-		return val.list.Len(), nil
+		return val.list.len(), nil
 	}
 
 	return 0, nil
@@ -153,19 +160,27 @@ func (kv *kvModel) linsert(key []byte, before bool, pivot []byte, value []byte)
 			return 0, errWrongType
 		}
 
-		for it := val.list.Front(); it != nil; it = it.Next() {
-			if bytes.Equal(it.Value.([]byte), pivot) {
-				if before {
-					val.list.InsertBefore(value, it)
-				} else {
-					val.list.InsertAfter(value, it)
-				}
-
-				return val.list.Len(), nil
+		pos := -1
+		val.list.iterate(0, false, func(i int, v []byte) bool {
+			if bytes.Equal(v, pivot) {
+				pos = i
+				return false
 			}
+			return true
+		})
+
+		if pos < 0 {
+			return -1, nil
+		}
+
+		if !before {
+			pos++
 		}
+		val.list.insert(pos, value)
 
-		return -1, nil
+		l := val.list.len()
+		kv.serveBlocked(key)
+		return l, nil
 	}
 
 	return 0, nil
@@ -178,15 +193,10 @@ func (kv *kvModel) lindex(key []byte, index int) ([]byte, error) {
 			return nil, errWrongType
 		}
 
-		l := val.list.Len()
+		l := val.list.len()
 		index = leftIndex(index, l)
 		if 0 <= index && index < l {
-			for it := val.list.Front(); it != nil; it = it.Next() {
-				if index == 0 {
-					return it.Value.([]byte), nil
-				}
-				index--
-			}
+			return val.list.index(index), nil
 		}
 	}
 
@@ -200,20 +210,15 @@ func (kv *kvModel) lrange(key []byte, start int, stop int) ([]interface{}, error
 			return nil, errWrongType
 		}
 
-		l := val.list.Len()
+		l := val.list.len()
 		left := leftIndex(start, l)
-		right := rightIndex(stop, l)
+		right := min(rightIndex(stop, l), l)
 		if left < right {
 			values := make([]interface{}, 0, right-left)
-			for i, it := 0, val.list.Front(); it != nil; it = it.Next() {
-				if i >= right {
-					break
-				}
-				if i >= left {
-					values = append(values, it.Value)
-				}
-				i++
-			}
+			val.list.iterate(left, false, func(i int, v []byte) bool {
+				values = append(values, v)
+				return i+1 < right
+			})
 			return values, nil
 		}
 	}
@@ -248,3 +253,286 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+func (kv *kvModel) LPushX(key []byte, values ...[]byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.lrpushx(true, key, values...)
+}
+
+func (kv *kvModel) RPushX(key []byte, values ...[]byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.lrpushx(false, key, values...)
+}
+
+func (kv *kvModel) LRPopN(key []byte, count int, left bool) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.lrpopN(key, count, left)
+}
+
+func (kv *kvModel) LMPop(keys [][]byte, count int, left bool) ([]byte, []interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.lmpop(keys, count, left)
+}
+
+func (kv *kvModel) LSet(key []byte, index int, value []byte) error {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.lset(key, index, value)
+}
+
+func (kv *kvModel) LRem(key []byte, count int, value []byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.lrem(key, count, value)
+}
+
+func (kv *kvModel) LTrim(key []byte, start int, stop int) error {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.ltrim(key, start, stop)
+}
+
+func (kv *kvModel) LPos(key []byte, value []byte, rank int, count int, maxLen int) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.lpos(key, value, rank, count, maxLen)
+}
+
+func (kv *kvModel) LMove(src []byte, dst []byte, srcLeft bool, dstLeft bool) ([]byte, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.lmove(src, dst, srcLeft, dstLeft)
+}
+
+// tryList returns list stored at key or nil if key does not exist
+func (kv *kvModel) tryList(key []byte) (*keyValue, error) {
+	val, exists := kv.tryGet(string(key))
+	if !exists {
+		return nil, nil
+	}
+
+	if val.kvType != kvListType {
+		return nil, errWrongType
+	}
+	return val, nil
+}
+
+func (kv *kvModel) lrpushx(left bool, key []byte, values ...[]byte) (int, error) {
+	val, err := kv.tryList(key)
+	if val == nil {
+		return 0, err
+	}
+
+	if left {
+		return kv.lpush(key, values...)
+	}
+	return kv.rpush(key, values...)
+}
+
+// lrpopN pops up to count elements from the head or the tail of list.
+// It returns nil if key does not exist
+func (kv *kvModel) lrpopN(key []byte, count int, left bool) ([]interface{}, error) {
+	val, err := kv.tryList(key)
+	if val == nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, 0, min(count, val.list.len()))
+	for ; count > 0 && val.list.len() > 0; count-- {
+		if left {
+			values = append(values, val.list.popFront())
+		} else {
+			values = append(values, val.list.popBack())
+		}
+	}
+
+	if val.list.len() == 0 {
+		kv.remove(string(key))
+	}
+	return values, nil
+}
+
+// lmpop pops elements from the first non empty list. It returns nil key
+// if all lists are empty
+func (kv *kvModel) lmpop(keys [][]byte, count int, left bool) ([]byte, []interface{}, error) {
+	for _, key := range keys {
+		values, err := kv.lrpopN(key, count, left)
+		if err != nil {
+			return nil, nil, err
+		}
+		if values != nil {
+			return key, values, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+func (kv *kvModel) lset(key []byte, index int, value []byte) error {
+	val, err := kv.tryList(key)
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		return errNoSuchKey
+	}
+
+	l := val.list.len()
+	index = leftIndex(index, l)
+	if index < 0 || index >= l {
+		return errIndexOutOfRange
+	}
+
+	val.list.set(index, value)
+	return nil
+}
+
+// lrem removes count occurrences of value starting from the head when count is
+// positive, from the tail when count is negative or all occurrences when count is zero
+func (kv *kvModel) lrem(key []byte, count int, value []byte) (int, error) {
+	val, err := kv.tryList(key)
+	if val == nil {
+		return 0, err
+	}
+
+	// matches to keep before removing when removing from the tail
+	skip := 0
+	if count < 0 {
+		count = -count
+		val.list.iterate(0, false, func(i int, v []byte) bool {
+			if bytes.Equal(v, value) {
+				skip++
+			}
+			return true
+		})
+		skip = max(skip-count, 0)
+	}
+
+	removed := 0
+	val.list.removeIf(func(v []byte) bool {
+		if !bytes.Equal(v, value) || (count > 0 && removed >= count) {
+			return false
+		}
+		if skip > 0 {
+			skip--
+			return false
+		}
+		removed++
+		return true
+	})
+
+	if val.list.len() == 0 {
+		kv.remove(string(key))
+	}
+	return removed, nil
+}
+
+func (kv *kvModel) ltrim(key []byte, start int, stop int) error {
+	val, err := kv.tryList(key)
+	if val == nil {
+		return err
+	}
+
+	l := val.list.len()
+	left := leftIndex(start, l)
+	right := min(rightIndex(stop, l), l)
+	if left >= right {
+		kv.remove(string(key))
+		return nil
+	}
+
+	val.list.removeRange(right, l-right)
+	val.list.removeRange(0, left)
+	return nil
+}
+
+// lpos returns positions of matching elements. Negative rank means
+// searching from the tail, zero count means returning all matches and
+// zero maxLen means scanning the whole list
+func (kv *kvModel) lpos(key []byte, value []byte, rank int, count int, maxLen int) ([]interface{}, error) {
+	val, err := kv.tryList(key)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]interface{}, 0)
+	if val == nil {
+		return positions, nil
+	}
+
+	skip, start, rev := rank-1, 0, false
+	if rank < 0 {
+		skip, start, rev = -rank-1, val.list.len()-1, true
+	}
+
+	scanned := 0
+	val.list.iterate(start, rev, func(i int, v []byte) bool {
+		if maxLen > 0 && scanned >= maxLen {
+			return false
+		}
+		scanned++
+
+		if bytes.Equal(v, value) {
+			if skip > 0 {
+				skip--
+			} else {
+				positions = append(positions, i)
+				if count > 0 && len(positions) >= count {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return positions, nil
+}
+
+// lmove pops element from the head or the tail of src list and pushes it to
+// the head or the tail of dst list. It returns nil if src does not exist
+func (kv *kvModel) lmove(src []byte, dst []byte, srcLeft bool, dstLeft bool) ([]byte, error) {
+	srcVal, err := kv.tryList(src)
+	if srcVal == nil {
+		return nil, err
+	}
+	dstVal, err := kv.tryList(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	var value []byte
+	if srcLeft {
+		value = srcVal.list.popFront()
+	} else {
+		value = srcVal.list.popBack()
+	}
+
+	if srcVal != dstVal && srcVal.list.len() == 0 {
+		kv.remove(string(src))
+	}
+
+	if dstVal == nil {
+		dstVal = newKeyValueList(kv.enc.ListMaxListpackSize)
+		kv.put(string(dst), dstVal)
+	}
+
+	if dstLeft {
+		dstVal.list.pushFront(value)
+	} else {
+		dstVal.list.pushBack(value)
+	}
+
+	kv.serveBlocked(dst)
+	return value, nil
+}
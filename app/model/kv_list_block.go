@@ -0,0 +1,203 @@
+package model
+
+import (
+	"container/list"
+	"errors"
+	"time"
+)
+
+var errUnblocked = errors.New("UNBLOCKED client unblocked")
+
+// listWaiter is client blocked on one or more lists until one of them gets elements
+type listWaiter struct {
+	keys  [][]byte
+	count int
+	left  bool
+
+	// dst is destination list of BLMOVE or nil for pops
+	dst     []byte
+	dstLeft bool
+
+	reply chan *listReply
+}
+
+type listReply struct {
+	key    []byte
+	values []interface{}
+	err    error
+}
+
+func newListWaiter(keys [][]byte, count int, left bool) *listWaiter {
+	return &listWaiter{
+		keys:  keys,
+		count: count,
+		left:  left,
+		reply: make(chan *listReply, 1),
+	}
+}
+
+// BLRPop pops up to count elements from the first non empty list or waits until
+// one of lists gets elements. It returns nil key if timeout expires, zero timeout
+// means waiting forever. Waiting is interrupted with error once cancel is closed
+func (kv *kvModel) BLRPop(keys [][]byte, count int, left bool, timeout time.Duration, cancel <-chan struct{}) ([]byte, []interface{}, error) {
+	reply := kv.block(newListWaiter(keys, count, left), timeout, cancel)
+	return reply.key, reply.values, reply.err
+}
+
+// BLMove moves element from src list to dst list or waits until src list gets elements.
+// It returns nil if timeout expires
+func (kv *kvModel) BLMove(src []byte, dst []byte, srcLeft bool, dstLeft bool, timeout time.Duration, cancel <-chan struct{}) ([]byte, error) {
+	w := newListWaiter([][]byte{src}, 1, srcLeft)
+	w.dst = dst
+	w.dstLeft = dstLeft
+
+	reply := kv.block(w, timeout, cancel)
+	if reply.err != nil || reply.key == nil {
+		return nil, reply.err
+	}
+	return reply.values[0].([]byte), nil
+}
+
+func (kv *kvModel) block(w *listWaiter, timeout time.Duration, cancel <-chan struct{}) *listReply {
+	kv.lock()
+	for _, key := range w.keys {
+		if reply := kv.serveWaiter(w, key); reply != nil {
+			kv.unlock()
+			return reply
+		}
+	}
+
+	for _, key := range w.keys {
+		k := string(key)
+		waiters, ok := kv.blocked[k]
+		if !ok {
+			waiters = list.New()
+			kv.blocked[k] = waiters
+		}
+		waiters.PushBack(w)
+	}
+	kv.unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	reply := &listReply{}
+	select {
+	case reply = <-w.reply:
+		return reply
+	case <-expired:
+	case <-cancel:
+		reply.err = errUnblocked
+	}
+
+	kv.lock()
+	defer kv.unlock()
+
+	// waiter could be served after timeout expired but before lock was taken
+	select {
+	case served := <-w.reply:
+		return served
+	default:
+	}
+
+	kv.unblock(w)
+	return reply
+}
+
+// serveWaiter pops elements for waiter from list stored at key. It returns nil
+// if key does not exist
+func (kv *kvModel) serveWaiter(w *listWaiter, key []byte) *listReply {
+	if w.dst != nil {
+		value, err := kv.lmove(key, w.dst, w.left, w.dstLeft)
+		if value == nil && err == nil {
+			return nil
+		}
+		return &listReply{key: key, values: []interface{}{value}, err: err}
+	}
+
+	values, err := kv.lrpopN(key, w.count, w.left)
+	if values == nil && err == nil {
+		return nil
+	}
+	return &listReply{key: key, values: values, err: err}
+}
+
+// serveBlocked passes elements of list stored at key to blocked clients in order
+// they were blocked. It must be called each time list gets new elements. Serving
+// BLMOVE makes its destination ready, such keys are queued and served in the same
+// loop, so clients moving elements between lists do not recurse into each other
+func (kv *kvModel) serveBlocked(key []byte) {
+	kv.ready = append(kv.ready, key)
+	if kv.serving {
+		return
+	}
+
+	kv.serving = true
+	for len(kv.ready) > 0 {
+		key := kv.ready[0]
+		kv.ready = kv.ready[1:]
+		kv.serveKey(key)
+	}
+	kv.ready = nil
+	kv.serving = false
+}
+
+// serveKey serves clients blocked on key while list stored at key has elements
+func (kv *kvModel) serveKey(key []byte) {
+	k := string(key)
+	for {
+		waiters, ok := kv.blocked[k]
+		if !ok {
+			return
+		}
+		// clients stay blocked on key holding other type, e.g. after SWAPDB
+		if val, err := kv.tryList(key); val == nil || err != nil {
+			return
+		}
+
+		// waiter is unblocked before it is served as serving BLMOVE makes
+		// destination ready which may be the same key
+		w := waiters.Front().Value.(*listWaiter)
+		kv.unblock(w)
+		w.reply <- kv.serveWaiter(w, key)
+	}
+}
+
+// serveAllBlocked passes elements to clients blocked on any list
+func (kv *kvModel) serveAllBlocked() {
+	keys := make([][]byte, 0, len(kv.blocked))
+	for k := range kv.blocked {
+		keys = append(keys, []byte(k))
+	}
+
+	for _, key := range keys {
+		kv.serveBlocked(key)
+	}
+}
+
+// unblock removes waiter from queues of all keys it is blocked on
+func (kv *kvModel) unblock(w *listWaiter) {
+	for _, key := range w.keys {
+		k := string(key)
+		waiters, ok := kv.blocked[k]
+		if !ok {
+			continue
+		}
+
+		for it := waiters.Front(); it != nil; {
+			next := it.Next()
+			if it.Value.(*listWaiter) == w {
+				waiters.Remove(it)
+			}
+			it = next
+		}
+
+		if waiters.Len() == 0 {
+			delete(kv.blocked, k)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package model
+
+import (
+	. "github.com/onsi/gomega"
+	"testing"
+	"time"
+)
+
+func TestListCommands(t *testing.T) {
+	RegisterTestingT(t)
+
+	dbModel := newDBModel(0)
+
+	key := []byte("list")
+	missing := []byte("missing")
+	a, b, c := []byte("a"), []byte("b"), []byte("c")
+
+	cnt, err := dbModel.RPushX(key, a)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(0))
+
+	dbModel.RPush(key, a, b, c, a, b, c, a)
+
+	positions, withCount, err := dbModel.LPos(key, a, []byte("RANK"), []byte("-1"), []byte("COUNT"), []byte("0"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(withCount).To(BeTrue())
+	Expect(positions).To(Equal([]interface{}{6, 3, 0}))
+
+	positions, withCount, err = dbModel.LPos(key, c, []byte("RANK"), []byte("2"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(withCount).To(BeFalse())
+	Expect(positions).To(Equal([]interface{}{5}))
+
+	cnt, err = dbModel.LRem(key, []byte("-2"), a)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(2))
+
+	err = dbModel.LSet(key, []byte("-1"), a)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = dbModel.LSet(key, []byte("10"), a)
+	Expect(err).To(Equal(errIndexOutOfRange))
+
+	err = dbModel.LSet(missing, []byte("0"), a)
+	Expect(err).To(Equal(errNoSuchKey))
+
+	err = dbModel.LTrim(key, []byte("1"), []byte("-2"))
+	Expect(err).ToNot(HaveOccurred())
+
+	values, err := dbModel.LRange(key, []byte("0"), []byte("-1"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(Equal([]interface{}{b, c, b}))
+
+	values, err = dbModel.LPopCount(key, []byte("2"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(Equal([]interface{}{b, c}))
+
+	values, err = dbModel.LPopCount(missing, []byte("2"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(BeNil())
+
+	popped, values, err := dbModel.LMPop([]byte("2"), missing, key, []byte("RIGHT"), []byte("COUNT"), []byte("5"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(popped).To(Equal(key))
+	Expect(values).To(Equal([]interface{}{b}))
+	Expect(dbModel.Exists(key)).To(Equal(0))
+}
+
+func TestParseTimeout(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		timeout string
+		d       time.Duration
+		err     error
+	}{
+		{"0", 0, nil},
+		{"0.0", 0, nil},
+		{"-0", 0, nil},
+		{"1", time.Second, nil},
+		{"1.5", 1500 * time.Millisecond, nil},
+		{"0.01", 10 * time.Millisecond, nil},
+		// tiny timeouts are rounded up rather than truncated to zero which blocks forever
+		{"0.0001", minTimeout, nil},
+		{"1e-10", minTimeout, nil},
+		{"5e-324", minTimeout, nil},
+		{"-1", 0, errTimeoutNegative},
+		{"-0.0001", 0, errTimeoutNegative},
+		{"a", 0, errTimeoutNotFloat},
+		{"", 0, errTimeoutNotFloat},
+		{"NaN", 0, errTimeoutNotFloat},
+		{"+Inf", 0, errTimeoutNotFloat},
+		{"1e300", 0, errTimeoutNotFloat},
+	}
+	for _, test := range tests {
+		d, err := parseTimeout([]byte(test.timeout))
+		if test.err != nil {
+			Expect(err).To(Equal(test.err), test.timeout)
+			continue
+		}
+		Expect(err).NotTo(HaveOccurred(), test.timeout)
+		Expect(d).To(Equal(test.d), test.timeout)
+	}
+
+	values, err := newDBModel(0).BLPop(nil, []byte("queue"), []byte("0.000001"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(BeNil())
+}
+
+func blockedOn(dbModel *DBModel, key string) func() int {
+	return func() int {
+		dbModel.kv.mu.Lock()
+		defer dbModel.kv.mu.Unlock()
+
+		if waiters, ok := dbModel.kv.blocked[key]; ok {
+			return waiters.Len()
+		}
+		return 0
+	}
+}
+
+func TestListBlocking(t *testing.T) {
+	RegisterTestingT(t)
+
+	dbModel := newDBModel(0)
+
+	a, b := []byte("a"), []byte("b")
+	queue, other := []byte("queue"), []byte("other")
+
+	values, err := dbModel.BLPop(nil, queue, []byte("0.01"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(BeNil())
+
+	_, err = dbModel.BLPop(nil, queue, []byte("-1"))
+	Expect(err).To(Equal(errTimeoutNegative))
+
+	dbModel.RPush(queue, a)
+	values, err = dbModel.BRPop(nil, other, queue, []byte("0"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(Equal([]interface{}{queue, a}))
+
+	// clients are served in order they were blocked
+	first := make(chan []interface{})
+	second := make(chan []interface{})
+	go func() {
+		values, _ := dbModel.BLPop(nil, other, queue, []byte("0"))
+		first <- values
+	}()
+	Eventually(blockedOn(dbModel, "queue")).Should(Equal(1))
+	go func() {
+		values, _ := dbModel.BLPop(nil, queue, []byte("0"))
+		second <- values
+	}()
+	Eventually(blockedOn(dbModel, "queue")).Should(Equal(2))
+
+	cnt, err := dbModel.RPush(queue, a, b)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(2))
+	Expect(<-first).To(Equal([]interface{}{queue, a}))
+	Expect(<-second).To(Equal([]interface{}{queue, b}))
+	Expect(blockedOn(dbModel, "other")()).To(Equal(0))
+	Expect(dbModel.Exists(queue)).To(Equal(0))
+
+	// blocked move wakes up client blocked on destination
+	moved := make(chan []byte)
+	go func() {
+		value, _ := dbModel.BLMove(nil, queue, other, []byte("RIGHT"), []byte("LEFT"), []byte("0"))
+		moved <- value
+	}()
+	Eventually(blockedOn(dbModel, "queue")).Should(Equal(1))
+	go func() {
+		key, values, _ := dbModel.BLMPop(nil, []byte("0"), []byte("1"), other, []byte("LEFT"), []byte("COUNT"), []byte("2"))
+		first <- []interface{}{key, values}
+	}()
+	Eventually(blockedOn(dbModel, "other")).Should(Equal(1))
+
+	dbModel.LPush(queue, a)
+	Expect(<-moved).To(Equal(a))
+	Expect(<-first).To(Equal([]interface{}{other, []interface{}{a}}))
+
+	// cancel releases blocked client
+	cancel := make(chan struct{})
+	go func() {
+		_, err := dbModel.BLPop(cancel, queue, []byte("0"))
+		first <- []interface{}{err}
+	}()
+	Eventually(blockedOn(dbModel, "queue")).Should(Equal(1))
+	close(cancel)
+	Expect(<-first).To(Equal([]interface{}{errUnblocked}))
+	Expect(blockedOn(dbModel, "queue")()).To(Equal(0))
+
+	dbModel.Set(other, a)
+	_, err = dbModel.BLPop(nil, other, []byte("0"))
+	Expect(err).To(Equal(errWrongType))
+}
+
+func TestListBlockedMoveLoops(t *testing.T) {
+	RegisterTestingT(t)
+
+	dbModel := newDBModel(0)
+
+	a, b := []byte("a"), []byte("b")
+	queue, other := []byte("queue"), []byte("other")
+	moved := make(chan []byte, 2)
+
+	// client moving element to the list it is blocked on is served once
+	go func() {
+		value, _ := dbModel.BLMove(nil, queue, queue, []byte("RIGHT"), []byte("LEFT"), []byte("0"))
+		moved <- value
+	}()
+	Eventually(blockedOn(dbModel, "queue")).Should(Equal(1))
+
+	dbModel.LPush(queue, a)
+	Expect(<-moved).To(Equal(a))
+	Expect(blockedOn(dbModel, "queue")()).To(Equal(0))
+	values, _ := dbModel.LRange(queue, []byte("0"), []byte("-1"))
+	Expect(values).To(Equal([]interface{}{a}))
+
+	// clients moving elements between two lists are served once each
+	dbModel.Del(queue)
+	go func() {
+		value, _ := dbModel.BLMove(nil, queue, other, []byte("LEFT"), []byte("LEFT"), []byte("0"))
+		moved <- value
+	}()
+	Eventually(blockedOn(dbModel, "queue")).Should(Equal(1))
+	go func() {
+		value, _ := dbModel.BLMove(nil, other, queue, []byte("LEFT"), []byte("LEFT"), []byte("0"))
+		moved <- value
+	}()
+	Eventually(blockedOn(dbModel, "other")).Should(Equal(1))
+
+	dbModel.RPush(queue, b)
+	Expect(<-moved).To(Equal(b))
+	Expect(<-moved).To(Equal(b))
+	Expect(blockedOn(dbModel, "queue")()).To(Equal(0))
+	Expect(blockedOn(dbModel, "other")()).To(Equal(0))
+	values, _ = dbModel.LRange(queue, []byte("0"), []byte("-1"))
+	Expect(values).To(Equal([]interface{}{b}))
+	Expect(dbModel.Exists(other)).To(Equal(0))
+}
+
+func TestListMove(t *testing.T) {
+	RegisterTestingT(t)
+
+	dbModel := newDBModel(0)
+
+	a, b, c := []byte("a"), []byte("b"), []byte("c")
+	pending, processing := []byte("pending"), []byte("processing")
+
+	value, err := dbModel.RPopLPush(pending, processing)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(value).To(BeNil())
+
+	dbModel.RPush(pending, a, b, c)
+
+	value, err = dbModel.RPopLPush(pending, processing)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(value).To(Equal(c))
+
+	value, err = dbModel.LMove(pending, processing, []byte("left"), []byte("right"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(value).To(Equal(a))
+
+	values, _ := dbModel.LRange(processing, []byte("0"), []byte("-1"))
+	Expect(values).To(Equal([]interface{}{c, a}))
+
+	// same key rotation
+	value, err = dbModel.LMove(processing, processing, []byte("LEFT"), []byte("RIGHT"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(value).To(Equal(c))
+
+	values, _ = dbModel.LRange(processing, []byte("0"), []byte("-1"))
+	Expect(values).To(Equal([]interface{}{a, c}))
+
+	value, err = dbModel.LMove(pending, pending, []byte("RIGHT"), []byte("LEFT"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(value).To(Equal(b))
+	Expect(dbModel.Exists(pending)).To(Equal(1))
+
+	value, err = dbModel.LMove(pending, processing, []byte("UP"), []byte("LEFT"))
+	Expect(err).To(Equal(errSyntax))
+
+	dbModel.Set(c, a)
+	_, err = dbModel.LMove(pending, c, []byte("LEFT"), []byte("LEFT"))
+	Expect(err).To(Equal(errWrongType))
+
+	values, _ = dbModel.LRange(pending, []byte("0"), []byte("-1"))
+	Expect(values).To(Equal([]interface{}{b}))
+
+	// blocked same key rotation is woken by push
+	rotating := []byte("rotating")
+	moved := make(chan []byte)
+	go func() {
+		value, _ := dbModel.BLMove(nil, rotating, rotating, []byte("LEFT"), []byte("RIGHT"), []byte("0"))
+		moved <- value
+	}()
+	Eventually(blockedOn(dbModel, "rotating")).Should(Equal(1))
+
+	dbModel.RPush(rotating, a, b)
+	Expect(<-moved).To(Equal(a))
+	values, _ = dbModel.LRange(rotating, []byte("0"), []byte("-1"))
+	Expect(values).To(Equal([]interface{}{b, a}))
+	Expect(blockedOn(dbModel, "rotating")()).To(Equal(0))
+}
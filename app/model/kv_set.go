@@ -0,0 +1,432 @@
+package model
+
+import "math/rand"
+
+type setOp func(sets []map[string]struct{}) map[string]struct{}
+
+func newKeyValueSet() *keyValue {
+	return &keyValue{
+		kvType: kvSetType,
+		set:    newSetValue(),
+	}
+}
+
+func (kv *kvModel) SAdd(key []byte, members ...[]byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.sadd(key, members...)
+}
+
+func (kv *kvModel) SRem(key []byte, members ...[]byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.srem(key, members...)
+}
+
+func (kv *kvModel) SMembers(key []byte) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.smembers(key)
+}
+
+func (kv *kvModel) SIsMember(key []byte, member []byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.sismember(key, member)
+}
+
+func (kv *kvModel) SMIsMember(key []byte, members ...[]byte) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.smismember(key, members...)
+}
+
+func (kv *kvModel) SCard(key []byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.scard(key)
+}
+
+func (kv *kvModel) SPop(key []byte, count int) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.spop(key, count)
+}
+
+func (kv *kvModel) SRandMember(key []byte, count int) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.srandmember(key, count)
+}
+
+func (kv *kvModel) SInter(keys ...[]byte) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.sop(inter, keys...)
+}
+
+func (kv *kvModel) SUnion(keys ...[]byte) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.sop(union, keys...)
+}
+
+func (kv *kvModel) SDiff(keys ...[]byte) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.sop(diff, keys...)
+}
+
+func (kv *kvModel) SInterStore(dst []byte, keys ...[]byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.sopStore(inter, dst, keys...)
+}
+
+func (kv *kvModel) SUnionStore(dst []byte, keys ...[]byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.sopStore(union, dst, keys...)
+}
+
+func (kv *kvModel) SDiffStore(dst []byte, keys ...[]byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.sopStore(diff, dst, keys...)
+}
+
+func (kv *kvModel) SInterCard(limit int, keys ...[]byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.sintercard(limit, keys...)
+}
+
+func (kv *kvModel) SMove(src []byte, dst []byte, member []byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.smove(src, dst, member)
+}
+
+// trySet returns set stored at key or nil if key does not exist
+func (kv *kvModel) trySet(key []byte) (*keyValue, error) {
+	val, exists := kv.tryGet(string(key))
+	if !exists {
+		return nil, nil
+	}
+
+	if val.kvType != kvSetType {
+		return nil, errWrongType
+	}
+	return val, nil
+}
+
+func (kv *kvModel) sadd(key []byte, members ...[]byte) (int, error) {
+	k := string(key)
+	val, exists := kv.tryGet(k)
+	if exists {
+		if val.kvType != kvSetType {
+			return 0, errWrongType
+		}
+	} else {
+		val = newKeyValueSet()
+		kv.put(k, val)
+	}
+
+	cnt := 0
+	for _, member := range members {
+		if val.set.add(string(member), &kv.enc) {
+			cnt++
+		}
+	}
+	return cnt, nil
+}
+
+func (kv *kvModel) srem(key []byte, members ...[]byte) (int, error) {
+	val, err := kv.trySet(key)
+	if val == nil {
+		return 0, err
+	}
+
+	cnt := 0
+	for _, member := range members {
+		if val.set.remove(string(member)) {
+			cnt++
+		}
+	}
+
+	if val.set.len() == 0 {
+		kv.remove(string(key))
+	}
+	return cnt, nil
+}
+
+func (kv *kvModel) smembers(key []byte) ([]interface{}, error) {
+	val, err := kv.trySet(key)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return make([]interface{}, 0), nil
+	}
+
+	return val.set.members(), nil
+}
+
+func (kv *kvModel) sismember(key []byte, member []byte) (int, error) {
+	val, err := kv.trySet(key)
+	if val == nil {
+		return 0, err
+	}
+
+	if val.set.has(string(member)) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (kv *kvModel) smismember(key []byte, members ...[]byte) ([]interface{}, error) {
+	val, err := kv.trySet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]interface{}, 0, len(members))
+	for _, member := range members {
+		found := 0
+		if val != nil && val.set.has(string(member)) {
+			found = 1
+		}
+		res = append(res, found)
+	}
+	return res, nil
+}
+
+func (kv *kvModel) scard(key []byte) (int, error) {
+	val, err := kv.trySet(key)
+	if val == nil {
+		return 0, err
+	}
+
+	return val.set.len(), nil
+}
+
+func (kv *kvModel) spop(key []byte, count int) ([]interface{}, error) {
+	val, err := kv.trySet(key)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return make([]interface{}, 0), nil
+	}
+
+	members := make([]interface{}, 0, min(count, val.set.len()))
+	for len(members) < count && val.set.len() > 0 {
+		m := val.set.random()
+		val.set.remove(m)
+		members = append(members, []byte(m))
+	}
+
+	if val.set.len() == 0 {
+		kv.remove(string(key))
+	}
+	return members, nil
+}
+
+// srandmember returns count distinct members when count is positive and
+// count possibly repeated members when count is negative
+func (kv *kvModel) srandmember(key []byte, count int) ([]interface{}, error) {
+	val, err := kv.trySet(key)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return make([]interface{}, 0), nil
+	}
+
+	all := val.set.members()
+	if count >= 0 {
+		count = min(count, len(all))
+		for i := 0; i < count; i++ {
+			j := i + rand.Intn(len(all)-i)
+			all[i], all[j] = all[j], all[i]
+		}
+		return all[:count], nil
+	}
+
+	members := make([]interface{}, 0, -count)
+	for i := 0; i < -count; i++ {
+		members = append(members, all[rand.Intn(len(all))])
+	}
+	return members, nil
+}
+
+// loadSets returns sets stored at keys, missing keys are treated as empty sets
+func (kv *kvModel) loadSets(keys ...[]byte) ([]map[string]struct{}, error) {
+	sets := make([]map[string]struct{}, 0, len(keys))
+	for _, key := range keys {
+		val, err := kv.trySet(key)
+		if err != nil {
+			return nil, err
+		}
+		if val == nil {
+			sets = append(sets, nil)
+		} else {
+			sets = append(sets, val.set.toMap())
+		}
+	}
+	return sets, nil
+}
+
+func (kv *kvModel) sop(op setOp, keys ...[]byte) ([]interface{}, error) {
+	sets, err := kv.loadSets(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	return setMembers(op(sets)), nil
+}
+
+func (kv *kvModel) sopStore(op setOp, dst []byte, keys ...[]byte) (int, error) {
+	sets, err := kv.loadSets(keys...)
+	if err != nil {
+		return 0, err
+	}
+
+	res := op(sets)
+	k := string(dst)
+	kv.remove(k)
+	if len(res) > 0 {
+		val := newKeyValueSet()
+		val.set = newSetValueFrom(res, &kv.enc)
+		kv.put(k, val)
+	}
+	return len(res), nil
+}
+
+func (kv *kvModel) sintercard(limit int, keys ...[]byte) (int, error) {
+	sets, err := kv.loadSets(keys...)
+	if err != nil {
+		return 0, err
+	}
+
+	cnt := len(inter(sets))
+	if limit > 0 && cnt > limit {
+		return limit, nil
+	}
+	return cnt, nil
+}
+
+func (kv *kvModel) smove(src []byte, dst []byte, member []byte) (int, error) {
+	srcVal, err := kv.trySet(src)
+	if err != nil || srcVal == nil {
+		return 0, err
+	}
+	// type of destination is checked once source exists like Redis does
+	dstVal, err := kv.trySet(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	m := string(member)
+	if !srcVal.set.has(m) {
+		return 0, nil
+	}
+	if srcVal == dstVal {
+		return 1, nil
+	}
+
+	srcVal.set.remove(m)
+	if srcVal.set.len() == 0 {
+		kv.remove(string(src))
+	}
+
+	if dstVal == nil {
+		dstVal = newKeyValueSet()
+		kv.put(string(dst), dstVal)
+	}
+	dstVal.set.add(m, &kv.enc)
+	return 1, nil
+}
+
+func setMembers(set map[string]struct{}) []interface{} {
+	members := make([]interface{}, 0, len(set))
+	for m := range set {
+		members = append(members, []byte(m))
+	}
+	return members
+}
+
+func inter(sets []map[string]struct{}) map[string]struct{} {
+	res := make(map[string]struct{})
+	if len(sets) == 0 {
+		return res
+	}
+
+	smallest := sets[0]
+	for _, set := range sets {
+		if len(set) < len(smallest) {
+			smallest = set
+		}
+	}
+
+	for m := range smallest {
+		found := true
+		for _, set := range sets {
+			if _, ok := set[m]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			res[m] = struct{}{}
+		}
+	}
+	return res
+}
+
+func union(sets []map[string]struct{}) map[string]struct{} {
+	res := make(map[string]struct{})
+	for _, set := range sets {
+		for m := range set {
+			res[m] = struct{}{}
+		}
+	}
+	return res
+}
+
+func diff(sets []map[string]struct{}) map[string]struct{} {
+	res := make(map[string]struct{})
+	if len(sets) == 0 {
+		return res
+	}
+
+	for m := range sets[0] {
+		found := false
+		for _, set := range sets[1:] {
+			if _, ok := set[m]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res[m] = struct{}{}
+		}
+	}
+	return res
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestSetCommands(t *testing.T) {
+	RegisterTestingT(t)
+
+	dbModel := newDBModel(0)
+
+	key1 := []byte("set1")
+	key2 := []byte("set2")
+	dst := []byte("dst")
+	a, b, c, d := []byte("a"), []byte("b"), []byte("c"), []byte("d")
+
+	cnt, err := dbModel.SAdd(key1, a, b, c, a)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(3))
+
+	cnt, err = dbModel.SAdd(key2, b, c, d)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(3))
+
+	cnt, err = dbModel.SCard(key1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(3))
+
+	cnt, err = dbModel.SIsMember(key1, d)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(0))
+
+	res, err := dbModel.SMIsMember(key1, a, d)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(res).To(Equal([]interface{}{1, 0}))
+
+	members, err := dbModel.SInter(key1, key2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(members).To(ConsistOf(b, c))
+
+	members, err = dbModel.SUnion(key1, key2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(members).To(ConsistOf(a, b, c, d))
+
+	members, err = dbModel.SDiff(key1, key2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(members).To(Equal([]interface{}{a}))
+
+	cnt, err = dbModel.SInterStore(dst, key1, key2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(2))
+
+	cnt, err = dbModel.SInterCard([]byte("2"), key1, key2, []byte("LIMIT"), []byte("1"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(1))
+
+	cnt, err = dbModel.SMove(key1, key2, a)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(1))
+
+	members, err = dbModel.SMembers(key2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(members).To(ConsistOf(a, b, c, d))
+
+	cnt, err = dbModel.SRem(dst, b, c)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(2))
+	Expect(dbModel.Exists(dst)).To(Equal(0))
+
+	members, err = dbModel.SRandMemberN(key1, -5)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(members).To(HaveLen(5))
+
+	members, err = dbModel.SPopN(key1, 10)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(members).To(ConsistOf(b, c))
+	Expect(dbModel.Exists(key1)).To(Equal(0))
+
+	dbModel.Set(key1, a)
+	_, err = dbModel.SAdd(key1, a)
+	Expect(err).To(Equal(errWrongType))
+	_, err = dbModel.SUnion(key2, key1)
+	Expect(err).To(Equal(errWrongType))
+
+	// missing source is checked before type of destination
+	cnt, err = dbModel.SMove([]byte("missing"), key1, a)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(0))
+	_, err = dbModel.SMove(key2, key1, []byte("missing"))
+	Expect(err).To(Equal(errWrongType))
+	_, err = dbModel.SMove(key1, key2, a)
+	Expect(err).To(Equal(errWrongType))
+}
@@ -1,35 +1,57 @@
 package model
 
+import "strconv"
+
+// newKeyValue creates string value. Strings representing integers are kept
+// as integers
 func newKeyValue(s []byte) *keyValue {
+	if len(s) <= 20 {
+		if n, err := strconv.ParseInt(string(s), 10, 64); err == nil && strconv.FormatInt(n, 10) == string(s) {
+			return &keyValue{
+				kvType: kvType,
+				num:    n,
+				isNum:  true,
+			}
+		}
+	}
+
 	return &keyValue{
 		kvType: kvType,
 		value:  s,
 	}
 }
 
+// bytes returns value of string
+func (val *keyValue) bytes() []byte {
+	if val.isNum {
+		return strconv.AppendInt(nil, val.num, 10)
+	}
+	return val.value
+}
+
 func (kv *kvModel) Set(key []byte, value []byte) {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+	kv.lock()
+	defer kv.unlock()
 
 	kv.set(key, value)
 }
 
 func (kv *kvModel) Get(key []byte) ([]byte, error) {
-	kv.mu.RLock()
-	defer kv.mu.RUnlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.get(key)
 }
 
 func (kv *kvModel) Del(keys ...[]byte) int {
-	kv.mu.RLock()
-	defer kv.mu.RUnlock()
+	kv.lock()
+	defer kv.unlock()
 
 	return kv.delKeys(keys...)
 }
 
 func (kv *kvModel) set(key []byte, value []byte) {
-	kv.storage[string(key)] = newKeyValue(value)
+	kv.put(string(key), newKeyValue(value))
 }
 
 func (kv *kvModel) get(key []byte) ([]byte, error) {
@@ -38,7 +60,7 @@ func (kv *kvModel) get(key []byte) ([]byte, error) {
 		if val.kvType != kvType {
 			return nil, errWrongType
 		}
-		return val.value, nil
+		return val.bytes(), nil
 	}
 
 	return nil, nil
@@ -54,7 +76,7 @@ func (kv *kvModel) delKeys(keys ...[]byte) int {
 
 func (kv *kvModel) del(key []byte) int {
 	if kv.keyExists(key) {
-		delete(kv.storage, string(key))
+		kv.remove(string(key))
 		return 1
 	}
 	return 0
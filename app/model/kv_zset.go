@@ -0,0 +1,519 @@
+package model
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	zRangeByRank = iota
+	zRangeByScore
+	zRangeByLex
+)
+
+const (
+	zAggregateSum = iota
+	zAggregateMin
+	zAggregateMax
+)
+
+var errScoreNaN = errors.New("resulting score is not a number (NaN)")
+
+// zRangeSpec describes elements selected by ZRANGE-like commands
+type zRangeSpec struct {
+	by         int
+	start      int
+	stop       int
+	rng        zRange
+	rev        bool
+	offset     int
+	count      int
+	withScores bool
+}
+
+type zAddFlags struct {
+	nx, xx, gt, lt, ch, incr bool
+}
+
+func newKeyValueZSet() *keyValue {
+	return &keyValue{
+		kvType: kvZSetType,
+		zset:   newZSet(),
+	}
+}
+
+func (kv *kvModel) ZAdd(key []byte, flags *zAddFlags, scores []float64, members [][]byte) (int, []byte, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.zadd(key, flags, scores, members)
+}
+
+func (kv *kvModel) ZRem(key []byte, members ...[]byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.zrem(key, members...)
+}
+
+func (kv *kvModel) ZScore(key []byte, member []byte) ([]byte, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.zscore(key, member)
+}
+
+func (kv *kvModel) ZCard(key []byte) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.zcard(key)
+}
+
+func (kv *kvModel) ZCount(key []byte, r zRange) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.zcount(key, r)
+}
+
+func (kv *kvModel) ZRank(key []byte, member []byte, rev bool) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.zrank(key, member, rev)
+}
+
+func (kv *kvModel) ZRange(key []byte, spec *zRangeSpec) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.zrange(key, spec)
+}
+
+func (kv *kvModel) ZRangeStore(dst []byte, src []byte, spec *zRangeSpec) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.zrangestore(dst, src, spec)
+}
+
+func (kv *kvModel) ZPop(key []byte, count int, max bool) ([]interface{}, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.zpop(key, count, max)
+}
+
+func (kv *kvModel) ZRemRange(key []byte, spec *zRangeSpec) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.zremrange(key, spec)
+}
+
+func (kv *kvModel) ZStore(inter bool, dst []byte, keys [][]byte, weights []float64, aggregate int) (int, error) {
+	kv.lock()
+	defer kv.unlock()
+
+	return kv.zstore(inter, dst, keys, weights, aggregate)
+}
+
+// tryZSet returns sorted set stored at key or nil if key does not exist
+func (kv *kvModel) tryZSet(key []byte) (*keyValue, error) {
+	val, exists := kv.tryGet(string(key))
+	if !exists {
+		return nil, nil
+	}
+
+	if val.kvType != kvZSetType {
+		return nil, errWrongType
+	}
+	return val, nil
+}
+
+// zadd returns number of added (or changed with CH flag) elements and,
+// in INCR mode, new score of element or nil when operation was aborted
+func (kv *kvModel) zadd(key []byte, flags *zAddFlags, scores []float64, members [][]byte) (int, []byte, error) {
+	k := string(key)
+	val, exists := kv.tryGet(k)
+	if exists {
+		if val.kvType != kvZSetType {
+			return 0, nil, errWrongType
+		}
+	} else {
+		if flags.xx {
+			return 0, nil, nil
+		}
+		val = newKeyValueZSet()
+		kv.put(k, val)
+	}
+
+	added, changed := 0, 0
+	var score []byte
+	for i, member := range members {
+		m := string(member)
+		s := scores[i]
+		cur, found := val.zset.dict[m]
+		if found {
+			if flags.nx {
+				continue
+			}
+			if flags.incr {
+				s += cur
+				if math.IsNaN(s) {
+					if val.zset.len() == 0 {
+						kv.remove(k)
+					}
+					return 0, nil, errScoreNaN
+				}
+			}
+			if (flags.gt && s <= cur) || (flags.lt && s >= cur) {
+				continue
+			}
+			if s != cur {
+				val.zset.add(m, s)
+				changed++
+			}
+		} else {
+			if flags.xx {
+				continue
+			}
+			val.zset.add(m, s)
+			added++
+		}
+		score = formatScore(s)
+	}
+
+	if val.zset.len() == 0 {
+		kv.remove(k)
+	}
+
+	if flags.ch {
+		added += changed
+	}
+	return added, score, nil
+}
+
+func (kv *kvModel) zrem(key []byte, members ...[]byte) (int, error) {
+	val, err := kv.tryZSet(key)
+	if val == nil {
+		return 0, err
+	}
+
+	cnt := 0
+	for _, member := range members {
+		if val.zset.remove(string(member)) {
+			cnt++
+		}
+	}
+
+	if val.zset.len() == 0 {
+		kv.remove(string(key))
+	}
+	return cnt, nil
+}
+
+func (kv *kvModel) zscore(key []byte, member []byte) ([]byte, error) {
+	val, err := kv.tryZSet(key)
+	if val == nil {
+		return nil, err
+	}
+
+	score, exists := val.zset.dict[string(member)]
+	if !exists {
+		return nil, nil
+	}
+	return formatScore(score), nil
+}
+
+func (kv *kvModel) zcard(key []byte) (int, error) {
+	val, err := kv.tryZSet(key)
+	if val == nil {
+		return 0, err
+	}
+
+	return val.zset.len(), nil
+}
+
+func (kv *kvModel) zcount(key []byte, r zRange) (int, error) {
+	val, err := kv.tryZSet(key)
+	if val == nil {
+		return 0, err
+	}
+
+	zsl := val.zset.zsl
+	first := zsl.firstInRange(r)
+	if first == nil {
+		return 0, nil
+	}
+	last := zsl.lastInRange(r)
+
+	return zsl.rank(last.score, last.member) - zsl.rank(first.score, first.member) + 1, nil
+}
+
+func (kv *kvModel) zrank(key []byte, member []byte, rev bool) (int, error) {
+	val, err := kv.tryZSet(key)
+	if val == nil {
+		return -1, err
+	}
+
+	return val.zset.rank(string(member), rev), nil
+}
+
+func (kv *kvModel) zrange(key []byte, spec *zRangeSpec) ([]interface{}, error) {
+	val, err := kv.tryZSet(key)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return make([]interface{}, 0), nil
+	}
+
+	nodes := val.zset.rangeNodes(spec)
+	values := make([]interface{}, 0, len(nodes))
+	for _, node := range nodes {
+		values = append(values, []byte(node.member))
+		if spec.withScores {
+			values = append(values, formatScore(node.score))
+		}
+	}
+	return values, nil
+}
+
+func (kv *kvModel) zrangestore(dst []byte, src []byte, spec *zRangeSpec) (int, error) {
+	val, err := kv.tryZSet(src)
+	if err != nil {
+		return 0, err
+	}
+
+	res := newZSet()
+	if val != nil {
+		for _, node := range val.zset.rangeNodes(spec) {
+			res.add(node.member, node.score)
+		}
+	}
+
+	kv.storeZSet(dst, res)
+	return res.len(), nil
+}
+
+func (kv *kvModel) zpop(key []byte, count int, max bool) ([]interface{}, error) {
+	val, err := kv.tryZSet(key)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return make([]interface{}, 0), nil
+	}
+
+	zsl := val.zset.zsl
+	values := make([]interface{}, 0, 2*min(count, zsl.length))
+	for ; count > 0 && zsl.length > 0; count-- {
+		node := zsl.header.level[0].forward
+		if max {
+			node = zsl.tail
+		}
+		values = append(values, []byte(node.member), formatScore(node.score))
+		val.zset.remove(node.member)
+	}
+
+	if val.zset.len() == 0 {
+		kv.remove(string(key))
+	}
+	return values, nil
+}
+
+func (kv *kvModel) zremrange(key []byte, spec *zRangeSpec) (int, error) {
+	val, err := kv.tryZSet(key)
+	if val == nil {
+		return 0, err
+	}
+
+	nodes := val.zset.rangeNodes(spec)
+	for _, node := range nodes {
+		val.zset.remove(node.member)
+	}
+
+	if val.zset.len() == 0 {
+		kv.remove(string(key))
+	}
+	return len(nodes), nil
+}
+
+// zstore computes union or intersection of sorted sets and sets stored at
+// keys and stores result at dst. Members of plain sets have score 1
+func (kv *kvModel) zstore(inter bool, dst []byte, keys [][]byte, weights []float64, aggregate int) (int, error) {
+	inputs := make([]map[string]float64, 0, len(keys))
+	for _, key := range keys {
+		val, exists := kv.tryGet(string(key))
+		if !exists {
+			inputs = append(inputs, nil)
+			continue
+		}
+
+		switch val.kvType {
+		case kvZSetType:
+			inputs = append(inputs, val.zset.dict)
+		case kvSetType:
+			input := make(map[string]float64, val.set.len())
+			for m := range val.set.toMap() {
+				input[m] = 1
+			}
+			inputs = append(inputs, input)
+		default:
+			return 0, errWrongType
+		}
+	}
+
+	scores := make(map[string]float64)
+	if inter {
+		for m, score := range inputs[0] {
+			score = zWeightedScore(score, weights[0])
+			found := true
+			for i := 1; i < len(inputs); i++ {
+				other, exists := inputs[i][m]
+				if !exists {
+					found = false
+					break
+				}
+				score = zAggregateScores(aggregate, score, zWeightedScore(other, weights[i]))
+			}
+			if found {
+				scores[m] = score
+			}
+		}
+	} else {
+		for i, input := range inputs {
+			for m, score := range input {
+				score = zWeightedScore(score, weights[i])
+				if cur, exists := scores[m]; exists {
+					score = zAggregateScores(aggregate, cur, score)
+				}
+				scores[m] = score
+			}
+		}
+	}
+
+	res := newZSet()
+	for m, score := range scores {
+		res.add(m, score)
+	}
+
+	kv.storeZSet(dst, res)
+	return res.len(), nil
+}
+
+func (kv *kvModel) storeZSet(key []byte, zs *zset) {
+	k := string(key)
+	kv.remove(k)
+	if zs.len() > 0 {
+		val := newKeyValueZSet()
+		val.zset = zs
+		kv.put(k, val)
+	}
+}
+
+// rangeNodes returns nodes selected by spec in requested order
+func (zs *zset) rangeNodes(spec *zRangeSpec) []*zslNode {
+	zsl := zs.zsl
+	if spec.by == zRangeByRank {
+		l := zsl.length
+		start, stop := spec.start, spec.stop
+		if start < 0 {
+			start += l
+		}
+		if stop < 0 {
+			stop += l
+		}
+		if start < 0 {
+			start = 0
+		}
+		if start > stop || start >= l {
+			return nil
+		}
+		if stop >= l {
+			stop = l - 1
+		}
+
+		var x *zslNode
+		if spec.rev {
+			x = zsl.byRank(l - start)
+		} else {
+			x = zsl.byRank(start + 1)
+		}
+
+		nodes := make([]*zslNode, 0, stop-start+1)
+		for n := stop - start + 1; n > 0 && x != nil; n-- {
+			nodes = append(nodes, x)
+			if spec.rev {
+				x = x.backward
+			} else {
+				x = x.level[0].forward
+			}
+		}
+		return nodes
+	}
+
+	if spec.offset < 0 {
+		return nil
+	}
+
+	var x *zslNode
+	if spec.rev {
+		x = zsl.lastInRange(spec.rng)
+	} else {
+		x = zsl.firstInRange(spec.rng)
+	}
+
+	var nodes []*zslNode
+	offset, count := spec.offset, spec.count
+	for x != nil && count != 0 {
+		if spec.rev {
+			if !spec.rng.gteMin(x) {
+				break
+			}
+		} else if !spec.rng.lteMax(x) {
+			break
+		}
+
+		if offset > 0 {
+			offset--
+		} else {
+			nodes = append(nodes, x)
+			if count > 0 {
+				count--
+			}
+		}
+
+		if spec.rev {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+	return nodes
+}
+
+func zWeightedScore(score float64, weight float64) float64 {
+	score *= weight
+	if math.IsNaN(score) {
+		return 0
+	}
+	return score
+}
+
+func zAggregateScores(aggregate int, a float64, b float64) float64 {
+	switch aggregate {
+	case zAggregateMin:
+		return math.Min(a, b)
+	case zAggregateMax:
+		return math.Max(a, b)
+	}
+
+	sum := a + b
+	if math.IsNaN(sum) {
+		return 0
+	}
+	return sum
+}
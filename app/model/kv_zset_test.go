@@ -0,0 +1,130 @@
+package model
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSkiplistRankAndOrder(t *testing.T) {
+	RegisterTestingT(t)
+
+	zs := newZSet()
+	expected := make(map[string]float64)
+	for i := 0; i < 1000; i++ {
+		member := strconv.Itoa(rand.Intn(300))
+		score := float64(rand.Intn(50))
+		if rand.Intn(4) == 0 {
+			zs.remove(member)
+			delete(expected, member)
+		} else {
+			zs.add(member, score)
+			expected[member] = score
+		}
+	}
+
+	members := make([]string, 0, len(expected))
+	for m := range expected {
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		a, b := members[i], members[j]
+		return expected[a] < expected[b] || (expected[a] == expected[b] && a < b)
+	})
+
+	Expect(zs.zsl.length).To(Equal(len(members)))
+	for i, m := range members {
+		Expect(zs.rank(m, false)).To(Equal(i))
+		Expect(zs.rank(m, true)).To(Equal(len(members) - 1 - i))
+		Expect(zs.zsl.byRank(i + 1).member).To(Equal(m))
+	}
+}
+
+func TestSortedSetCommands(t *testing.T) {
+	RegisterTestingT(t)
+
+	dbModel := newDBModel(0)
+	key := []byte("zset")
+	args := func(s ...string) [][]byte {
+		res := make([][]byte, 0, len(s))
+		for _, v := range s {
+			res = append(res, []byte(v))
+		}
+		return res
+	}
+
+	cnt, _, _, err := dbModel.ZAdd(key, args("1", "a", "2", "b", "3", "c", "4", "d")...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(4))
+
+	cnt, _, _, err = dbModel.ZAdd(key, args("GT", "CH", "0", "a", "5", "b")...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(1))
+
+	_, score, incr, err := dbModel.ZAdd(key, args("INCR", "1.5", "a")...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(incr).To(BeTrue())
+	Expect(score).To(BeEquivalentTo("2.5"))
+
+	_, _, _, err = dbModel.ZAdd(key, args("NX", "XX", "1", "a")...)
+	Expect(err).To(Equal(errXXNX))
+
+	values, err := dbModel.ZRange(key, args("0", "-1", "WITHSCORES")...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(Equal([]interface{}{
+		[]byte("a"), []byte("2.5"),
+		[]byte("c"), []byte("3"),
+		[]byte("d"), []byte("4"),
+		[]byte("b"), []byte("5"),
+	}))
+
+	values, err = dbModel.ZRange(key, args("+inf", "(3", "BYSCORE", "REV", "LIMIT", "0", "1")...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(Equal([]interface{}{[]byte("b")}))
+
+	lexKey := []byte("lex")
+	dbModel.ZAdd(lexKey, args("0", "a", "0", "b", "0", "c", "0", "d")...)
+	values, err = dbModel.ZRange(lexKey, args("[b", "(d", "BYLEX")...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(Equal([]interface{}{[]byte("b"), []byte("c")}))
+
+	cnt, err = dbModel.ZCount(key, []byte("3"), []byte("+inf"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(3))
+
+	rank, err := dbModel.ZRevRank(key, []byte("a"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(rank).To(Equal(3))
+
+	values, err = dbModel.ZPopMax(key, nil)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(Equal([]interface{}{[]byte("b"), []byte("5")}))
+
+	other := []byte("other")
+	dbModel.SAdd(other, []byte("a"), []byte("x"))
+
+	dst := []byte("dst")
+	cnt, err = dbModel.ZInterStore(dst, args("2", "zset", "other", "WEIGHTS", "2", "10", "AGGREGATE", "SUM")...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(1))
+
+	score, err = dbModel.ZScore(dst, []byte("a"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(score).To(BeEquivalentTo("15"))
+
+	cnt, err = dbModel.ZUnionStore(dst, args("2", "zset", "other", "AGGREGATE", "MAX")...)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(4))
+
+	cnt, err = dbModel.ZRemRangeByScore(dst, []byte("-inf"), []byte("(3"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(2))
+
+	cnt, err = dbModel.ZRemRangeByRank(key, []byte("0"), []byte("-1"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cnt).To(Equal(3))
+	Expect(dbModel.Exists(key)).To(Equal(0))
+}
@@ -0,0 +1,114 @@
+package model
+
+// lpCompactGarbage is amount of garbage in bytes listpack tolerates before compaction
+const lpCompactGarbage = 4 * 1024
+
+// lpEntry is position of element inside listpack buffer
+type lpEntry struct {
+	off, end int
+}
+
+// listpack keeps elements packed in single buffer instead of allocating
+// each of them separately. Buffer is append only: bytes of element are
+// never changed once written, so slices returned to callers stay valid
+// after listpack modifications. Removed and replaced elements leave
+// garbage which is dropped by compaction
+type listpack struct {
+	buf     []byte
+	entries []lpEntry
+	garbage int
+}
+
+func (lp *listpack) len() int {
+	return len(lp.entries)
+}
+
+// size returns number of bytes taken by live elements
+func (lp *listpack) size() int {
+	return len(lp.buf) - lp.garbage
+}
+
+func (lp *listpack) get(i int) []byte {
+	e := lp.entries[i]
+	return lp.buf[e.off:e.end:e.end]
+}
+
+func (lp *listpack) write(value []byte) lpEntry {
+	off := len(lp.buf)
+	lp.buf = append(lp.buf, value...)
+	return lpEntry{off: off, end: len(lp.buf)}
+}
+
+func (lp *listpack) insert(i int, value []byte) {
+	e := lp.write(value)
+	lp.entries = append(lp.entries, lpEntry{})
+	copy(lp.entries[i+1:], lp.entries[i:])
+	lp.entries[i] = e
+}
+
+func (lp *listpack) set(i int, value []byte) {
+	e := lp.entries[i]
+	lp.garbage += e.end - e.off
+	lp.entries[i] = lp.write(value)
+	lp.compactIfNeeded()
+}
+
+func (lp *listpack) remove(i int, n int) {
+	for _, e := range lp.entries[i : i+n] {
+		lp.garbage += e.end - e.off
+	}
+	lp.entries = append(lp.entries[:i], lp.entries[i+n:]...)
+	lp.compactIfNeeded()
+}
+
+// find returns index of the first element equal to value among elements
+// 0, step, 2*step, ... or -1 if there is no such element
+func (lp *listpack) find(value string, step int) int {
+	for i := 0; i < len(lp.entries); i += step {
+		e := lp.entries[i]
+		if string(lp.buf[e.off:e.end]) == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func (lp *listpack) compactIfNeeded() {
+	if lp.garbage > lpCompactGarbage && lp.garbage > lp.size() {
+		lp.compact()
+	}
+}
+
+// compact moves live elements to new buffer. Old buffer is left untouched
+// since it can still be referenced by returned elements
+func (lp *listpack) compact() {
+	buf := make([]byte, 0, lp.size())
+	for i, e := range lp.entries {
+		off := len(buf)
+		buf = append(buf, lp.buf[e.off:e.end]...)
+		lp.entries[i] = lpEntry{off: off, end: len(buf)}
+	}
+	lp.buf = buf
+	lp.garbage = 0
+}
+
+// split moves elements starting from i to new listpack
+func (lp *listpack) split(i int) *listpack {
+	next := &listpack{}
+	for j := i; j < lp.len(); j++ {
+		next.insert(next.len(), lp.get(j))
+	}
+	lp.remove(i, lp.len()-i)
+	return next
+}
+
+func (lp *listpack) clone() *listpack {
+	c := &listpack{
+		buf:     make([]byte, 0, lp.size()),
+		entries: make([]lpEntry, 0, lp.len()),
+	}
+	for i := range lp.entries {
+		c.insert(c.len(), lp.get(i))
+	}
+	return c
+}
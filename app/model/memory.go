@@ -0,0 +1,336 @@
+package model
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// Approximate sizes in bytes of runtime structures on 64 bit platforms used to
+// estimate memory taken by values. They do not need to be exact, eviction only
+// needs estimations which grow and shrink together with data
+const (
+	sliceHeaderSize  = 24
+	stringHeaderSize = 16
+	pointerSize      = 8
+	mapEntrySize     = 16
+)
+
+var (
+	keyValueSize = int64(unsafe.Sizeof(keyValue{}))
+	listpackSize = int64(unsafe.Sizeof(listpack{}))
+	lpEntrySize  = int64(unsafe.Sizeof(lpEntry{}))
+	zslNodeSize  = int64(unsafe.Sizeof(zslNode{}))
+	zslLevelSize = int64(unsafe.Sizeof(zslLevel{}))
+)
+
+// memSamples is number of elements sampled to estimate memory of aggregate
+// values kept in storage
+const memSamples = 5
+
+// LFU counter of accessed keys grows logarithmically and decays by one each
+// lfuDecayTime milliseconds the key is not accessed
+const (
+	lfuInitVal   = 5
+	lfuLogFactor = 10
+	lfuDecayTime = 60 * 1000
+)
+
+// memUsage estimates memory taken by key and its value. Elements of aggregate
+// values are sampled, samples of 0 or less means all elements
+func memUsage(key string, val *keyValue, samples int) int64 {
+	size := mapEntrySize + stringHeaderSize + int64(len(key)) + pointerSize + keyValueSize
+	switch val.kvType {
+	case kvListType:
+		size += val.list.memUsage(samples)
+	case kvDictType:
+		size += val.dict.memUsage(samples)
+	case kvSetType:
+		size += val.set.memUsage(samples)
+	case kvZSetType:
+		size += val.zset.memUsage(samples)
+	default:
+		size += int64(cap(val.value))
+	}
+
+	for f := range val.fieldTTL {
+		size += mapEntrySize + stringHeaderSize + int64(len(f)) + 8
+	}
+	return size
+}
+
+func (lp *listpack) memUsage() int64 {
+	return listpackSize + int64(cap(lp.buf)) + int64(cap(lp.entries))*lpEntrySize
+}
+
+func (ql *quicklist) memUsage(samples int) int64 {
+	live := ql.live()
+	size := int64(cap(ql.nodes)) * pointerSize
+	if len(live) == 0 {
+		return size
+	}
+
+	if samples <= 0 || samples >= len(live) {
+		for _, node := range live {
+			size += node.memUsage()
+		}
+		return size
+	}
+
+	var sampled int64
+	for i := 0; i < samples; i++ {
+		sampled += live[rand.Intn(len(live))].memUsage()
+	}
+	return size + sampled*int64(len(live))/int64(samples)
+}
+
+func (h *hash) memUsage(samples int) int64 {
+	if h.lp != nil {
+		return h.lp.memUsage()
+	}
+
+	return sampleMap(len(h.dict), samples, func(each func(size int64) bool) {
+		for f, v := range h.dict {
+			if !each(int64(len(f)) + int64(cap(v)) + stringHeaderSize + sliceHeaderSize + mapEntrySize) {
+				return
+			}
+		}
+	})
+}
+
+func (s *setValue) memUsage(samples int) int64 {
+	if s.dict == nil {
+		return int64(cap(s.ints)) * 8
+	}
+
+	return sampleMap(len(s.dict), samples, func(each func(size int64) bool) {
+		for m := range s.dict {
+			if !each(int64(len(m)) + stringHeaderSize + mapEntrySize) {
+				return
+			}
+		}
+	})
+}
+
+func (zs *zset) memUsage(samples int) int64 {
+	return sampleMap(len(zs.dict), samples, func(each func(size int64) bool) {
+		for x := zs.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+			// member string is shared by dict and skiplist node
+			size := int64(len(x.member)) + zslNodeSize + int64(cap(x.level))*zslLevelSize
+			if !each(size + stringHeaderSize + 8 + mapEntrySize) {
+				return
+			}
+		}
+	})
+}
+
+// sampleMap estimates memory of n elements by sizes of up to samples elements
+// passed to each by iterate
+func sampleMap(n int, samples int, iterate func(each func(size int64) bool)) int64 {
+	if n == 0 {
+		return 0
+	}
+
+	var size int64
+	cnt := 0
+	iterate(func(s int64) bool {
+		size += s
+		cnt++
+		return samples <= 0 || cnt < samples
+	})
+	return size * int64(n) / int64(cnt)
+}
+
+// lock takes write lock. Memory of values accessed under write lock is
+// estimated again once lock is released
+func (kv *kvModel) lock() {
+	kv.mu.Lock()
+	kv.exclusive = true
+}
+
+func (kv *kvModel) unlock() {
+	kv.account()
+	kv.exclusive = false
+	kv.mu.Unlock()
+}
+
+// markDirty schedules memory estimation of key value
+func (kv *kvModel) markDirty(key string) {
+	if kv.exclusive {
+		kv.dirty[key] = struct{}{}
+	}
+}
+
+// account estimates memory of values changed since write lock was taken
+func (kv *kvModel) account() {
+	for k := range kv.dirty {
+		if val, exists := kv.storage[k]; exists {
+			mem := memUsage(k, val, memSamples)
+			atomic.AddInt64(&kv.used, mem-val.mem)
+			val.mem = mem
+		}
+		delete(kv.dirty, k)
+	}
+}
+
+// put stores value at key replacing existing one
+func (kv *kvModel) put(key string, val *keyValue) {
+	if old, exists := kv.storage[key]; exists {
+		atomic.AddInt64(&kv.used, -old.mem)
+	}
+
+	if atomic.LoadInt64(&val.access) == 0 {
+		atomic.StoreInt64(&val.access, nowMs())
+		atomic.StoreUint32(&val.freq, lfuInitVal)
+	}
+
+	val.mem = 0
+	kv.storage[key] = val
+	kv.markDirty(key)
+}
+
+// remove deletes key from storage
+func (kv *kvModel) remove(key string) {
+	if val, exists := kv.storage[key]; exists {
+		atomic.AddInt64(&kv.used, -val.mem)
+		delete(kv.storage, key)
+	}
+}
+
+// usedMemory returns estimated memory taken by keys and values
+func (kv *kvModel) usedMemory() int64 {
+	return atomic.LoadInt64(&kv.used)
+}
+
+// touch updates access time and LFU counter of value
+func (val *keyValue) touch(now int64) {
+	freq := val.lfu(now)
+	if freq < 255 {
+		base := float64(0)
+		if freq > lfuInitVal {
+			base = float64(freq - lfuInitVal)
+		}
+		if rand.Float64() < 1/(base*lfuLogFactor+1) {
+			freq++
+		}
+	}
+
+	atomic.StoreUint32(&val.freq, freq)
+	atomic.StoreInt64(&val.access, now)
+}
+
+// lfu returns LFU counter of value decayed by time passed since last access
+func (val *keyValue) lfu(now int64) uint32 {
+	freq := atomic.LoadUint32(&val.freq)
+	periods := (now - atomic.LoadInt64(&val.access)) / lfuDecayTime
+	if periods >= int64(freq) {
+		return 0
+	}
+	return freq - uint32(periods)
+}
+
+// idle returns time in milliseconds passed since last access of value
+func (val *keyValue) idle(now int64) int64 {
+	return now - atomic.LoadInt64(&val.access)
+}
+
+// MemoryUsage returns estimated memory taken by key and its value or -1 if key
+// does not exist. Elements of aggregate values are sampled, samples of 0 means all
+func (kv *kvModel) MemoryUsage(key []byte, samples int) int64 {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	k := string(key)
+	val, exists := kv.storage[k]
+	if !exists || isExpired(val, time.Now().Unix()) {
+		return -1
+	}
+	return memUsage(k, val, samples)
+}
+
+// Stats returns number of keys, number of keys with TTL and used memory
+func (kv *kvModel) Stats() (int, int, int64) {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	volatile := 0
+	for _, val := range kv.storage {
+		if val.ttl != 0 {
+			volatile++
+		}
+	}
+	return len(kv.storage), volatile, kv.usedMemory()
+}
+
+// keysReportBatch is number of keys analyzed under single read lock
+const keysReportBatch = 100
+
+// typeReport summarizes keys of single type
+type typeReport struct {
+	name    string
+	keys    int
+	total   int64
+	biggest string
+	size    int64
+}
+
+// keysReport finds the biggest key of each type by number of elements or by
+// memory if mem is set. Keys are analyzed in batches so writers are not
+// blocked for the whole analysis
+func (kv *kvModel) keysReport(mem bool, samples int) []*typeReport {
+	kv.mu.RLock()
+	keys := make([]string, 0, len(kv.storage))
+	for k := range kv.storage {
+		keys = append(keys, k)
+	}
+	kv.mu.RUnlock()
+
+	reports := []*typeReport{
+		{name: typeString},
+		{name: typeList},
+		{name: typeHash},
+		{name: typeSet},
+		{name: typeZSet},
+	}
+	byType := map[string]*typeReport{}
+	for _, r := range reports {
+		byType[r.name] = r
+	}
+
+	for len(keys) > 0 {
+		batch := keys
+		if len(batch) > keysReportBatch {
+			batch = batch[:keysReportBatch]
+		}
+		keys = keys[len(batch):]
+
+		kv.mu.RLock()
+		now := time.Now().Unix()
+		for _, k := range batch {
+			val, exists := kv.storage[k]
+			if !exists || isExpired(val, now) {
+				continue
+			}
+
+			var size int64
+			if mem {
+				size = memUsage(k, val, samples)
+			} else if val.kvType == kvType {
+				size = int64(len(val.bytes()))
+			} else {
+				size = int64(val.length())
+			}
+
+			r := byType[val.typeName()]
+			r.keys++
+			r.total += size
+			if r.keys == 1 || size > r.size {
+				r.biggest = k
+				r.size = size
+			}
+		}
+		kv.mu.RUnlock()
+	}
+	return reports
+}
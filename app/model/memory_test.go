@@ -0,0 +1,86 @@
+package model
+
+import (
+	"strconv"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestMemoryUsage(t *testing.T) {
+	RegisterTestingT(t)
+
+	db := newDBModel(0)
+	Expect(db.MemoryUsage([]byte("missing"))).To(Equal(-1))
+
+	db.Set([]byte("s"), []byte("value"))
+	usage, err := db.MemoryUsage([]byte("s"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(usage).To(BeEquivalentTo(db.kv.usedMemory()))
+
+	for i := 0; i < 1000; i++ {
+		db.SAdd([]byte("set"), []byte("member:"+strconv.Itoa(i)))
+	}
+	all, err := db.MemoryUsage([]byte("set"), []byte("SAMPLES"), []byte("0"))
+	Expect(err).ToNot(HaveOccurred())
+	sampled, err := db.MemoryUsage([]byte("set"), []byte("samples"), []byte("10"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(sampled).To(BeNumerically("~", all, all/10))
+
+	_, err = db.MemoryUsage([]byte("set"), []byte("SAMPLES"))
+	Expect(err).To(Equal(errSyntax))
+	_, err = db.MemoryUsage([]byte("set"), []byte("SAMPLES"), []byte("-1"))
+	Expect(err).To(Equal(errInvalidInteger))
+}
+
+func TestKeysReport(t *testing.T) {
+	RegisterTestingT(t)
+
+	db := newDBModel(0)
+	db.Set([]byte("short"), []byte("v"))
+	db.Set([]byte("long"), []byte("long value"))
+	db.RPush([]byte("l1"), []byte("a"))
+	db.RPush([]byte("l2"), []byte("a"), []byte("b"), []byte("c"))
+	db.HSet([]byte("h"), []byte("f"), []byte("v"))
+
+	report, err := db.KeysReport(false)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(report).To(Equal([]interface{}{
+		[]interface{}{[]byte("string"), 2, 11, []byte("long"), 10},
+		[]interface{}{[]byte("list"), 2, 4, []byte("l2"), 3},
+		[]interface{}{[]byte("hash"), 1, 1, []byte("h"), 1},
+		[]interface{}{[]byte("set"), 0, 0, nil, 0},
+		[]interface{}{[]byte("zset"), 0, 0, nil, 0},
+	}))
+
+	report, err = db.KeysReport(true, []byte("SAMPLES"), []byte("0"))
+	Expect(err).ToNot(HaveOccurred())
+	lists := report[1].([]interface{})
+	Expect(lists[3]).To(BeEquivalentTo("l2"))
+	l2, _ := db.MemoryUsage([]byte("l2"), []byte("SAMPLES"), []byte("0"))
+	Expect(lists[4]).To(Equal(l2))
+}
+
+func TestMemoryStats(t *testing.T) {
+	RegisterTestingT(t)
+
+	app := NewAppModel(16, DefaultEncodings())
+	db, _ := app.SelectIndex(3)
+	db.Set([]byte("a"), []byte("1"))
+	db.Set([]byte("b"), []byte("2"))
+	db.ExpireN([]byte("b"), 100)
+
+	stats := app.MemoryStats()
+	values := map[string]interface{}{}
+	for i := 0; i < len(stats); i += 2 {
+		values[string(stats[i].([]byte))] = stats[i+1]
+	}
+
+	Expect(values["keys.count"]).To(Equal(2))
+	Expect(values["dataset.bytes"]).To(BeEquivalentTo(app.UsedMemory()))
+	Expect(values["db.3"]).To(Equal([]interface{}{
+		[]byte("keys"), 2,
+		[]byte("expires"), 1,
+		[]byte("dataset.bytes"), int(app.UsedMemory()),
+	}))
+}
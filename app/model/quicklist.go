@@ -0,0 +1,318 @@
+package model
+
+// qlSizeSafetyLimit is maximum size of node limited by number of elements
+const qlSizeSafetyLimit = 8 * 1024
+
+// qlSizeClasses are maximum node sizes for negative fill from -1 to -5
+var qlSizeClasses = []int{4 * 1024, 8 * 1024, 16 * 1024, 32 * 1024, 64 * 1024}
+
+// quicklist is deque of listpack nodes. It avoids allocation per element
+// and walks nodes instead of elements to access element by index
+type quicklist struct {
+	// nodes[head:] are nodes of list, free space before head is used to push
+	// nodes to the front without moving the rest
+	nodes []*listpack
+	head  int
+	count int
+
+	// fill limits nodes, see Encodings.ListMaxListpackSize
+	fill int
+}
+
+func newQuicklist(fill int) *quicklist {
+	return &quicklist{fill: fill}
+}
+
+// fits reports whether element of size n can be added to node
+func (ql *quicklist) fits(node *listpack, n int) bool {
+	if node.len() == 0 {
+		return true
+	}
+
+	if ql.fill > 0 {
+		return node.len() < ql.fill && node.size()+n <= qlSizeSafetyLimit
+	}
+
+	class := min(max(-ql.fill, 1), len(qlSizeClasses)) - 1
+	return node.size()+n <= qlSizeClasses[class]
+}
+
+func (ql *quicklist) len() int {
+	return ql.count
+}
+
+func (ql *quicklist) live() []*listpack {
+	return ql.nodes[ql.head:]
+}
+
+func (ql *quicklist) pushNodeFront(node *listpack) {
+	if ql.head == 0 {
+		live := ql.live()
+		head := len(live) + 1
+		nodes := make([]*listpack, head+len(live))
+		copy(nodes[head:], live)
+		ql.nodes = nodes
+		ql.head = head
+	}
+	ql.head--
+	ql.nodes[ql.head] = node
+}
+
+func (ql *quicklist) insertNode(i int, node *listpack) {
+	if i == 0 {
+		ql.pushNodeFront(node)
+		return
+	}
+
+	if len(ql.nodes) == cap(ql.nodes) && ql.head > 0 {
+		// reuse free space before head instead of growing
+		live := ql.live()
+		copy(ql.nodes, live)
+		for j := len(live); j < len(ql.nodes); j++ {
+			ql.nodes[j] = nil
+		}
+		ql.nodes = ql.nodes[:len(live)]
+		ql.head = 0
+	}
+
+	i += ql.head
+	ql.nodes = append(ql.nodes, nil)
+	copy(ql.nodes[i+1:], ql.nodes[i:])
+	ql.nodes[i] = node
+}
+
+func (ql *quicklist) removeNodes(i int, n int) {
+	if n == 0 {
+		return
+	}
+
+	if i == 0 {
+		for j := ql.head; j < ql.head+n; j++ {
+			ql.nodes[j] = nil
+		}
+		ql.head += n
+	} else {
+		i += ql.head
+		copy(ql.nodes[i:], ql.nodes[i+n:])
+		for j := len(ql.nodes) - n; j < len(ql.nodes); j++ {
+			ql.nodes[j] = nil
+		}
+		ql.nodes = ql.nodes[:len(ql.nodes)-n]
+	}
+
+	if ql.head == len(ql.nodes) {
+		ql.nodes = nil
+		ql.head = 0
+	}
+}
+
+// locate returns index of node holding element at index i and index of element inside node
+func (ql *quicklist) locate(i int) (int, int) {
+	live := ql.live()
+	if i < ql.count/2 {
+		for n, node := range live {
+			if i < node.len() {
+				return n, i
+			}
+			i -= node.len()
+		}
+	} else {
+		i = ql.count - i
+		for n := len(live) - 1; n >= 0; n-- {
+			if i <= live[n].len() {
+				return n, live[n].len() - i
+			}
+			i -= live[n].len()
+		}
+	}
+	return -1, -1
+}
+
+func (ql *quicklist) pushFront(value []byte) {
+	ql.insert(0, value)
+}
+
+func (ql *quicklist) pushBack(value []byte) {
+	ql.insert(ql.count, value)
+}
+
+func (ql *quicklist) popFront() []byte {
+	value := ql.index(0)
+	ql.removeRange(0, 1)
+	return value
+}
+
+func (ql *quicklist) popBack() []byte {
+	value := ql.index(ql.count - 1)
+	ql.removeRange(ql.count-1, 1)
+	return value
+}
+
+// index returns element at index i, 0 <= i < len()
+func (ql *quicklist) index(i int) []byte {
+	n, j := ql.locate(i)
+	return ql.live()[n].get(j)
+}
+
+// set replaces element at index i, 0 <= i < len()
+func (ql *quicklist) set(i int, value []byte) {
+	n, j := ql.locate(i)
+	ql.live()[n].set(j, value)
+}
+
+// insert inserts value before element at index i, 0 <= i <= len()
+func (ql *quicklist) insert(i int, value []byte) {
+	live := ql.live()
+	var n, j int
+	switch {
+	case len(live) == 0:
+		ql.insertNode(0, &listpack{})
+		n, j = 0, 0
+	case i == ql.count:
+		n = len(live) - 1
+		j = live[n].len()
+	default:
+		n, j = ql.locate(i)
+	}
+
+	node := ql.live()[n]
+	if !ql.fits(node, len(value)) {
+		switch {
+		case j == 0:
+			// prefer previous node when inserting at the head of node
+			if n > 0 && ql.fits(ql.live()[n-1], len(value)) {
+				n--
+				node = ql.live()[n]
+				j = node.len()
+			} else {
+				node = &listpack{}
+				ql.insertNode(n, node)
+			}
+		case j == node.len():
+			if n+1 < len(ql.live()) && ql.fits(ql.live()[n+1], len(value)) {
+				n++
+				node = ql.live()[n]
+			} else {
+				node = &listpack{}
+				ql.insertNode(n+1, node)
+			}
+			j = 0
+		default:
+			ql.insertNode(n+1, node.split(j))
+		}
+	}
+
+	node.insert(j, value)
+	ql.count++
+}
+
+// removeRange removes n elements starting from index i
+func (ql *quicklist) removeRange(i int, n int) {
+	if n <= 0 {
+		return
+	}
+
+	start, j := ql.locate(i)
+	ql.count -= n
+
+	first, drop := start, 0
+	for k := start; n > 0; k++ {
+		node := ql.live()[k]
+		cnt := min(n, node.len()-j)
+		if cnt == node.len() {
+			if drop == 0 {
+				first = k
+			}
+			drop++
+		} else {
+			node.remove(j, cnt)
+		}
+		n -= cnt
+		j = 0
+	}
+	ql.removeNodes(first, drop)
+}
+
+// removeIf removes elements matching f and returns number of removed elements
+func (ql *quicklist) removeIf(f func(value []byte) bool) int {
+	removed := 0
+	live := ql.live()
+	for _, node := range live {
+		entries := node.entries[:0]
+		for _, e := range node.entries {
+			if f(node.buf[e.off:e.end:e.end]) {
+				node.garbage += e.end - e.off
+				removed++
+			} else {
+				entries = append(entries, e)
+			}
+		}
+		node.entries = entries
+		node.compactIfNeeded()
+	}
+
+	if removed > 0 {
+		nodes := live[:0]
+		for _, node := range live {
+			if node.len() > 0 {
+				nodes = append(nodes, node)
+			}
+		}
+		for k := len(nodes); k < len(live); k++ {
+			live[k] = nil
+		}
+		ql.nodes = nodes
+		ql.head = 0
+		ql.count -= removed
+	}
+	return removed
+}
+
+// iterate calls f for elements starting from index i towards the tail or
+// towards the head if rev is set until f returns false
+func (ql *quicklist) iterate(i int, rev bool, f func(i int, value []byte) bool) {
+	if i < 0 || i >= ql.count {
+		return
+	}
+
+	live := ql.live()
+	n, j := ql.locate(i)
+	if rev {
+		for ; n >= 0; n-- {
+			node := live[n]
+			if j < 0 {
+				j = node.len() - 1
+			}
+			for ; j >= 0; j-- {
+				if !f(i, node.get(j)) {
+					return
+				}
+				i--
+			}
+		}
+	} else {
+		for ; n < len(live); n++ {
+			node := live[n]
+			for ; j < node.len(); j++ {
+				if !f(i, node.get(j)) {
+					return
+				}
+				i++
+			}
+			j = 0
+		}
+	}
+}
+
+func (ql *quicklist) clone() *quicklist {
+	live := ql.live()
+	c := &quicklist{
+		nodes: make([]*listpack, 0, len(live)),
+		count: ql.count,
+		fill:  ql.fill,
+	}
+	for _, node := range live {
+		c.nodes = append(c.nodes, node.clone())
+	}
+	return c
+}
@@ -0,0 +1,230 @@
+package model
+
+import (
+	"bytes"
+	"container/list"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const benchListLen = 1000000
+
+func quicklistValues(ql *quicklist) [][]byte {
+	values := make([][]byte, 0, ql.len())
+	ql.iterate(0, false, func(i int, v []byte) bool {
+		values = append(values, v)
+		return true
+	})
+	return values
+}
+
+func TestQuicklist(t *testing.T) {
+	RegisterTestingT(t)
+
+	ql := newQuicklist(DefaultEncodings().ListMaxListpackSize)
+	var expected [][]byte
+
+	for i := 0; i < 20000; i++ {
+		value := []byte(strconv.Itoa(rand.Intn(1000)))
+		if rand.Intn(50) == 0 {
+			// large elements take node of their own
+			value = bytes.Repeat(value, qlSizeSafetyLimit)
+		}
+
+		switch op := rand.Intn(10); {
+		case op < 3:
+			ql.pushBack(value)
+			expected = append(expected, value)
+		case op < 5:
+			ql.pushFront(value)
+			expected = append([][]byte{value}, expected...)
+		case op < 7:
+			pos := rand.Intn(len(expected) + 1)
+			ql.insert(pos, value)
+			expected = append(expected[:pos], append([][]byte{value}, expected[pos:]...)...)
+		case op < 8 && len(expected) > 0:
+			pos := rand.Intn(len(expected))
+			ql.set(pos, value)
+			expected[pos] = value
+		case op < 9 && len(expected) > 0:
+			Expect(ql.popFront()).To(Equal(expected[0]))
+			expected = expected[1:]
+		case len(expected) > 0:
+			pos := rand.Intn(len(expected))
+			n := rand.Intn(len(expected) - pos + 1)
+			ql.removeRange(pos, n)
+			expected = append(expected[:pos:pos], expected[pos+n:]...)
+		}
+
+		Expect(ql.len()).To(Equal(len(expected)))
+		if len(expected) > 0 {
+			pos := rand.Intn(len(expected))
+			Expect(ql.index(pos)).To(Equal(expected[pos]))
+		}
+	}
+
+	Expect(quicklistValues(ql)).To(Equal(expected))
+
+	removed := ql.removeIf(func(v []byte) bool {
+		return len(v)%2 == 0
+	})
+	kept := expected[:0]
+	for _, v := range expected {
+		if len(v)%2 != 0 {
+			kept = append(kept, v)
+		}
+	}
+	Expect(removed).To(Equal(len(expected) - len(kept)))
+	Expect(quicklistValues(ql)).To(Equal(kept))
+
+	var reversed [][]byte
+	ql.iterate(ql.len()-1, true, func(i int, v []byte) bool {
+		Expect(v).To(Equal(kept[i]))
+		reversed = append(reversed, v)
+		return true
+	})
+	Expect(len(reversed)).To(Equal(len(kept)))
+}
+
+func TestQuicklistReturnedValuesStayValid(t *testing.T) {
+	RegisterTestingT(t)
+
+	ql := newQuicklist(DefaultEncodings().ListMaxListpackSize)
+	ql.pushBack([]byte("a"))
+	ql.pushBack([]byte("b"))
+
+	a := ql.popFront()
+	b := ql.index(0)
+	ql.set(0, []byte("c"))
+	ql.pushBack([]byte("d"))
+	ql.pushFront([]byte("e"))
+
+	Expect(a).To(Equal([]byte("a")))
+	Expect(b).To(Equal([]byte("b")))
+	Expect(quicklistValues(ql)).To(Equal([][]byte{[]byte("e"), []byte("c"), []byte("d")}))
+}
+
+// Benchmarks below compare list implementation based on container/list which
+// was used before with quicklist on million element lists
+
+func benchValue(i int) []byte {
+	return []byte("element:" + strconv.Itoa(i))
+}
+
+func newBenchContainerList() *list.List {
+	l := list.New()
+	for i := 0; i < benchListLen; i++ {
+		l.PushBack(benchValue(i))
+	}
+	return l
+}
+
+func newBenchQuicklist() *quicklist {
+	ql := newQuicklist(DefaultEncodings().ListMaxListpackSize)
+	for i := 0; i < benchListLen; i++ {
+		ql.pushBack(benchValue(i))
+	}
+	return ql
+}
+
+func containerListIndex(l *list.List, index int) []byte {
+	for it := l.Front(); it != nil; it = it.Next() {
+		if index == 0 {
+			return it.Value.([]byte)
+		}
+		index--
+	}
+	return nil
+}
+
+func BenchmarkContainerListPush(b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		newBenchContainerList()
+	}
+}
+
+func BenchmarkQuicklistPush(b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		newBenchQuicklist()
+	}
+}
+
+func BenchmarkContainerListIndex(b *testing.B) {
+	l := newBenchContainerList()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		containerListIndex(l, benchListLen/2)
+	}
+}
+
+func BenchmarkQuicklistIndex(b *testing.B) {
+	ql := newBenchQuicklist()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		ql.index(benchListLen / 2)
+	}
+}
+
+func BenchmarkContainerListRange(b *testing.B) {
+	l := newBenchContainerList()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		values := make([]interface{}, 0, 100)
+		for i, it := 0, l.Front(); it != nil && i < benchListLen/2+100; it = it.Next() {
+			if i >= benchListLen/2 {
+				values = append(values, it.Value)
+			}
+			i++
+		}
+	}
+}
+
+func BenchmarkQuicklistRange(b *testing.B) {
+	ql := newBenchQuicklist()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		values := make([]interface{}, 0, 100)
+		ql.iterate(benchListLen/2, false, func(i int, v []byte) bool {
+			values = append(values, v)
+			return len(values) < 100
+		})
+	}
+}
+
+func BenchmarkContainerListInsert(b *testing.B) {
+	l := newBenchContainerList()
+	pivot := benchValue(benchListLen / 2)
+	value := []byte("inserted")
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for it := l.Front(); it != nil; it = it.Next() {
+			if bytes.Equal(it.Value.([]byte), pivot) {
+				l.InsertBefore(value, it)
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkQuicklistInsert(b *testing.B) {
+	ql := newBenchQuicklist()
+	pivot := benchValue(benchListLen / 2)
+	value := []byte("inserted")
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		pos := -1
+		ql.iterate(0, false, func(i int, v []byte) bool {
+			if bytes.Equal(v, pivot) {
+				pos = i
+				return false
+			}
+			return true
+		})
+		ql.insert(pos, value)
+	}
+}
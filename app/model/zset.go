@@ -0,0 +1,459 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+const (
+	zslMaxLevel = 32
+	zslP        = 0.25
+)
+
+var (
+	errNotFloat        = errors.New("value is not a valid float")
+	errMinMaxNotFloat  = errors.New("min or max is not a float")
+	errMinMaxNotString = errors.New("min or max not valid string range item")
+)
+
+// zset keeps members both in hash map for O(1) score lookups and
+// in skiplist for ordered access by score, rank or lex
+type zset struct {
+	dict map[string]float64
+	zsl  *skiplist
+}
+
+func newZSet() *zset {
+	return &zset{
+		dict: make(map[string]float64),
+		zsl:  newSkiplist(),
+	}
+}
+
+func (zs *zset) len() int {
+	return len(zs.dict)
+}
+
+func (zs *zset) add(member string, score float64) {
+	if cur, exists := zs.dict[member]; exists {
+		if cur == score {
+			return
+		}
+		zs.zsl.delete(cur, member)
+	}
+	zs.dict[member] = score
+	zs.zsl.insert(score, member)
+}
+
+func (zs *zset) remove(member string) bool {
+	score, exists := zs.dict[member]
+	if !exists {
+		return false
+	}
+	delete(zs.dict, member)
+	zs.zsl.delete(score, member)
+	return true
+}
+
+// rank returns zero based rank of member or -1 if member does not exist
+func (zs *zset) rank(member string, rev bool) int {
+	score, exists := zs.dict[member]
+	if !exists {
+		return -1
+	}
+
+	r := zs.zsl.rank(score, member)
+	if rev {
+		return zs.zsl.length - r
+	}
+	return r - 1
+}
+
+func (zs *zset) clone() *zset {
+	c := newZSet()
+	for x := zs.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		c.add(x.member, x.score)
+	}
+	return c
+}
+
+type zslLevel struct {
+	forward *zslNode
+	span    int
+}
+
+type zslNode struct {
+	member   string
+	score    float64
+	backward *zslNode
+	level    []zslLevel
+}
+
+type skiplist struct {
+	header *zslNode
+	tail   *zslNode
+	length int
+	level  int
+}
+
+func newZslNode(level int, score float64, member string) *zslNode {
+	return &zslNode{
+		member: member,
+		score:  score,
+		level:  make([]zslLevel, level),
+	}
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: newZslNode(zslMaxLevel, 0, ""),
+		level:  1,
+	}
+}
+
+func zslRandomLevel() int {
+	level := 1
+	for level < zslMaxLevel && rand.Float64() < zslP {
+		level++
+	}
+	return level
+}
+
+// less reports whether node is ordered before (score, member)
+func (node *zslNode) less(score float64, member string) bool {
+	return node.score < score || (node.score == score && node.member < member)
+}
+
+// lessOrEqual reports whether node is ordered before or equal to (score, member)
+func (node *zslNode) lessOrEqual(score float64, member string) bool {
+	return node.score < score || (node.score == score && node.member <= member)
+}
+
+func (zsl *skiplist) insert(score float64, member string) *zslNode {
+	var update [zslMaxLevel]*zslNode
+	var rank [zslMaxLevel]int
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i != zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.less(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := zslRandomLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+
+	x = newZslNode(level, score, member)
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = (rank[0] - rank[i]) + 1
+	}
+
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+	return x
+}
+
+func (zsl *skiplist) deleteNode(x *zslNode, update []*zslNode) {
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+}
+
+func (zsl *skiplist) delete(score float64, member string) bool {
+	var update [zslMaxLevel]*zslNode
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.less(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.level[0].forward
+	if x != nil && x.score == score && x.member == member {
+		zsl.deleteNode(x, update[:])
+		return true
+	}
+	return false
+}
+
+// rank returns 1-based rank of element or 0 if element does not exist
+func (zsl *skiplist) rank(score float64, member string) int {
+	rank := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.lessOrEqual(score, member) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+
+		if x != zsl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns node by 1-based rank
+func (zsl *skiplist) byRank(rank int) *zslNode {
+	traversed := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// zRange is range of elements sorted by score or by lex
+type zRange interface {
+	gteMin(node *zslNode) bool
+	lteMax(node *zslNode) bool
+	empty() bool
+}
+
+func (zsl *skiplist) isInRange(r zRange) bool {
+	if r.empty() {
+		return false
+	}
+
+	if zsl.tail == nil || !r.gteMin(zsl.tail) {
+		return false
+	}
+
+	first := zsl.header.level[0].forward
+	return first != nil && r.lteMax(first)
+}
+
+func (zsl *skiplist) firstInRange(r zRange) *zslNode {
+	if !zsl.isInRange(r) {
+		return nil
+	}
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.gteMin(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+
+	x = x.level[0].forward
+	if !r.lteMax(x) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *skiplist) lastInRange(r zRange) *zslNode {
+	if !zsl.isInRange(r) {
+		return nil
+	}
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && r.lteMax(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+
+	if !r.gteMin(x) {
+		return nil
+	}
+	return x
+}
+
+type zScoreRange struct {
+	min, max     float64
+	minex, maxex bool
+}
+
+func (r *zScoreRange) gteMin(node *zslNode) bool {
+	if r.minex {
+		return node.score > r.min
+	}
+	return node.score >= r.min
+}
+
+func (r *zScoreRange) lteMax(node *zslNode) bool {
+	if r.maxex {
+		return node.score < r.max
+	}
+	return node.score <= r.max
+}
+
+func (r *zScoreRange) empty() bool {
+	return r.min > r.max || (r.min == r.max && (r.minex || r.maxex))
+}
+
+const (
+	lexNegInf = -1
+	lexValue  = 0
+	lexPosInf = 1
+)
+
+type zLexRange struct {
+	min, max         string
+	minKind, maxKind int
+	minex, maxex     bool
+}
+
+func (r *zLexRange) gteMin(node *zslNode) bool {
+	switch r.minKind {
+	case lexNegInf:
+		return true
+	case lexPosInf:
+		return false
+	}
+
+	if r.minex {
+		return node.member > r.min
+	}
+	return node.member >= r.min
+}
+
+func (r *zLexRange) lteMax(node *zslNode) bool {
+	switch r.maxKind {
+	case lexPosInf:
+		return true
+	case lexNegInf:
+		return false
+	}
+
+	if r.maxex {
+		return node.member < r.max
+	}
+	return node.member <= r.max
+}
+
+func (r *zLexRange) empty() bool {
+	if r.minKind == lexPosInf || r.maxKind == lexNegInf {
+		return true
+	}
+	if r.minKind == lexNegInf || r.maxKind == lexPosInf {
+		return false
+	}
+	return r.min > r.max || (r.min == r.max && (r.minex || r.maxex))
+}
+
+func parseScore(s []byte) (float64, error) {
+	f, err := strconv.ParseFloat(string(s), 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errNotFloat
+	}
+	return f, nil
+}
+
+func formatScore(f float64) []byte {
+	if math.IsInf(f, 1) {
+		return []byte("inf")
+	}
+	if math.IsInf(f, -1) {
+		return []byte("-inf")
+	}
+	return strconv.AppendFloat(nil, f, 'g', -1, 64)
+}
+
+func parseScoreRangeItem(s []byte) (float64, bool, error) {
+	ex := false
+	if len(s) > 0 && s[0] == '(' {
+		ex = true
+		s = s[1:]
+	}
+
+	f, err := strconv.ParseFloat(string(s), 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false, errMinMaxNotFloat
+	}
+	return f, ex, nil
+}
+
+func parseScoreRange(min []byte, max []byte) (*zScoreRange, error) {
+	r := &zScoreRange{}
+	var err error
+	if r.min, r.minex, err = parseScoreRangeItem(min); err != nil {
+		return nil, err
+	}
+	if r.max, r.maxex, err = parseScoreRangeItem(max); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func parseLexRangeItem(s []byte) (string, int, bool, error) {
+	switch {
+	case bytes.Equal(s, []byte("-")):
+		return "", lexNegInf, false, nil
+	case bytes.Equal(s, []byte("+")):
+		return "", lexPosInf, false, nil
+	case len(s) > 0 && s[0] == '(':
+		return string(s[1:]), lexValue, true, nil
+	case len(s) > 0 && s[0] == '[':
+		return string(s[1:]), lexValue, false, nil
+	}
+	return "", lexValue, false, errMinMaxNotString
+}
+
+func parseLexRange(min []byte, max []byte) (*zLexRange, error) {
+	r := &zLexRange{}
+	var err error
+	if r.min, r.minKind, r.minex, err = parseLexRangeItem(min); err != nil {
+		return nil, err
+	}
+	if r.max, r.maxKind, r.maxex, err = parseLexRangeItem(max); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
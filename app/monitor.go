@@ -0,0 +1,200 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valery-barysok/gredisd/app/cmd"
+)
+
+// monitorBacklog is number of lines monitor may lag behind before it is disconnected
+const monitorBacklog = 1024
+
+// monitors are clients streaming commands processed by server
+type monitors struct {
+	// count is number of monitors, it is accessed atomically so commands are not
+	// formatted when nobody is monitoring
+	count int32
+
+	mu   sync.Mutex
+	subs map[*monitor]struct{}
+}
+
+type monitor struct {
+	client *client
+	lines  chan []byte
+}
+
+func newMonitors() *monitors {
+	return &monitors{
+		subs: make(map[*monitor]struct{}),
+	}
+}
+
+func (monitors *monitors) add(m *monitor) {
+	monitors.mu.Lock()
+	defer monitors.mu.Unlock()
+
+	monitors.subs[m] = struct{}{}
+	atomic.AddInt32(&monitors.count, 1)
+}
+
+func (monitors *monitors) remove(m *monitor) {
+	monitors.mu.Lock()
+	defer monitors.mu.Unlock()
+
+	monitors.removeLocked(m)
+}
+
+func (monitors *monitors) removeLocked(m *monitor) {
+	if _, ok := monitors.subs[m]; ok {
+		delete(monitors.subs, m)
+		atomic.AddInt32(&monitors.count, -1)
+	}
+}
+
+// feed passes command to monitors, monitors lagging behind are disconnected
+func (monitors *monitors) feed(context *ClientContext, cmd *cmd.Command) {
+	if atomic.LoadInt32(&monitors.count) == 0 {
+		return
+	}
+
+	line := monitorLine(context, cmd, time.Now())
+
+	monitors.mu.Lock()
+	defer monitors.mu.Unlock()
+
+	for m := range monitors.subs {
+		select {
+		case m.lines <- line:
+		default:
+			log.Printf("Client %d on %s closed: monitor is lagging behind", m.client.id, m.client.addr)
+			monitors.removeLocked(m)
+			m.client.kill()
+		}
+	}
+}
+
+// monitorLine formats command in format of Redis MONITOR:
+// 1339518083.107412 [0 127.0.0.1:60866] "keys" "*"
+func monitorLine(context *ClientContext, cmd *cmd.Command, now time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d.%06d [", now.Unix(), now.Nanosecond()/1000)
+	if context.DB != nil {
+		buf.WriteString(strconv.Itoa(context.DB.Index()))
+	}
+	if context.client != nil {
+		buf.WriteByte(' ')
+		buf.WriteString(context.client.addr)
+	}
+	buf.WriteString("] ")
+
+	quoteArg(&buf, []byte(cmd.Cmd))
+	for _, arg := range redactArgs(cmd) {
+		buf.WriteByte(' ')
+		quoteArg(&buf, arg)
+	}
+	return buf.Bytes()
+}
+
+// quoteArg writes quoted argument escaping special and non printable characters
+func quoteArg(buf *bytes.Buffer, arg []byte) {
+	buf.WriteByte('"')
+	for _, c := range arg {
+		switch c {
+		case '\\', '"':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\a':
+			buf.WriteString(`\a`)
+		case '\b':
+			buf.WriteString(`\b`)
+		default:
+			if c < ' ' || c > '~' {
+				fmt.Fprintf(buf, `\x%02x`, c)
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// Monitor streams commands processed by server to client until it disconnects.
+// Client must be replied before monitoring starts
+func (context *ClientContext) Monitor() {
+	client := context.client
+	if client == nil || context.out == nil {
+		return
+	}
+
+	m := &monitor{
+		client: client,
+		lines:  make(chan []byte, monitorBacklog),
+	}
+	// monitoring is not a slow command
+	context.blocked = true
+
+	monitors := context.App.router.monitors
+	monitors.add(m)
+	defer monitors.remove(m)
+
+	client.setMonitor(true)
+	defer client.setMonitor(false)
+
+	cancel, stop := client.watch()
+	defer stop()
+
+	for {
+		select {
+		case line := <-m.lines:
+			if err := context.writeMonitorLines(m, line); err != nil {
+				return
+			}
+		case <-cancel:
+			return
+		case <-context.App.quit:
+			return
+		}
+	}
+}
+
+// writeMonitorLines writes line and lines already queued as status replies
+func (context *ClientContext) writeMonitorLines(m *monitor, line []byte) error {
+	for {
+		// every line is a reply of its own for output buffer limits
+		context.out.mark()
+		if err := context.writeStatus(line); err != nil {
+			return err
+		}
+
+		select {
+		case line = <-m.lines:
+			continue
+		default:
+		}
+		return context.out.Flush()
+	}
+}
+
+func (context *ClientContext) writeStatus(line []byte) error {
+	if _, err := context.out.Write([]byte{'+'}); err != nil {
+		return err
+	}
+	if _, err := context.out.Write(line); err != nil {
+		return err
+	}
+	_, err := context.out.Write([]byte("\r\n"))
+	return err
+}
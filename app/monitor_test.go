@@ -0,0 +1,71 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestMonitorLine(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+	context := newClientContext(app)
+	now := time.Unix(1339518083, 107412999)
+
+	Expect(string(monitorLine(context, command("keys", "*"), now))).To(Equal(`1339518083.107412 [0] "keys" "*"`))
+	Expect(string(monitorLine(context, command("ping"), time.Unix(1339518083, 1000)))).To(Equal(`1339518083.000001 [0] "ping"`))
+
+	context.client = &client{addr: "127.0.0.1:60866"}
+	context.DB, _ = app.SelectIndex(3)
+	Expect(string(monitorLine(context, command("get", "a"), now))).To(Equal(`1339518083.107412 [3 127.0.0.1:60866] "get" "a"`))
+
+	tests := []struct {
+		cmd  string
+		args []string
+		line string
+	}{
+		{"set", []string{"a b", ""}, `"set" "a b" ""`},
+		{"set", []string{`say "hi"`, `back\slash`}, `"set" "say \"hi\"" "back\\slash"`},
+		{"set", []string{"\n\r\t\a\b"}, `"set" "\n\r\t\a\b"`},
+		{"set", []string{"\x00\x1f\x7f\xff", "é"}, `"set" "\x00\x1f\x7f\xff" "\xc3\xa9"`},
+		{"auth", []string{"secret"}, `"auth" "(redacted)"`},
+		{"auth", []string{"user", "secret"}, `"auth" "(redacted)" "(redacted)"`},
+		{"hello", []string{"3", "AUTH", "user", "secret", "setname", "name"}, `"hello" "3" "AUTH" "(redacted)" "(redacted)" "setname" "name"`},
+		{"acl", []string{"setuser", "user", "on", ">secret"}, `"acl" "setuser" "user" "(redacted)" "(redacted)"`},
+		{"acl", []string{"whoami"}, `"acl" "whoami"`},
+		{"config", []string{"set", "requirepass", "secret", "maxclients", "10"}, `"config" "set" "requirepass" "(redacted)" "maxclients" "10"`},
+	}
+	for _, test := range tests {
+		line := string(monitorLine(context, command(test.cmd, test.args...), now))
+		Expect(line).To(Equal(`1339518083.107412 [3 127.0.0.1:60866] `+test.line), test.cmd)
+	}
+}
+
+func TestMonitorsFeed(t *testing.T) {
+	RegisterTestingT(t)
+
+	opts := DefaultOptions()
+	app := NewApp(&opts)
+	context := newClientContext(app)
+	monitors := newMonitors()
+
+	// commands are not formatted without monitors
+	monitors.feed(context, command("get", "a"))
+
+	m := &monitor{lines: make(chan []byte, 2)}
+	monitors.add(m)
+	monitors.feed(context, command("get", "a"))
+	monitors.feed(context, command("auth", "secret"))
+	Expect(m.lines).To(HaveLen(2))
+	Expect(string(<-m.lines)).To(HaveSuffix(` [0] "get" "a"`))
+	Expect(string(<-m.lines)).To(HaveSuffix(` [0] "auth" "(redacted)"`))
+
+	monitors.remove(m)
+	monitors.remove(m)
+	Expect(monitors.count).To(BeZero())
+	monitors.feed(context, command("get", "a"))
+	Expect(m.lines).To(BeEmpty())
+}
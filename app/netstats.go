@@ -0,0 +1,113 @@
+package app
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// netStats keeps total number of bytes read from and written to clients,
+// it is updated atomically
+type netStats struct {
+	in  int64
+	out int64
+}
+
+// countReader counts bytes read by client
+type countReader struct {
+	r     io.Reader
+	total *int64
+	n     int64
+}
+
+func (cr *countReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	atomic.AddInt64(cr.total, int64(n))
+	atomic.AddInt64(&cr.n, int64(n))
+	return n, err
+}
+
+// countWriter counts bytes written to client and remembers the first byte of
+// reply written since mark to find out whether command replied with error.
+// It also limits size of reply, once limit is exceeded nothing is written
+type countWriter struct {
+	w     io.Writer
+	total *int64
+	n     int64
+
+	marked bool
+	first  byte
+
+	// limit is output buffer limit of client class and deadline sets write deadline of connection
+	limit    *outputLimit
+	deadline func(t time.Time) error
+	// reply is size of reply written since mark and softSince is time it exceeded soft limit
+	reply     int64
+	softSince time.Time
+	err       error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	if err := cw.checkLimit(int64(len(p))); err != nil {
+		cw.err = err
+		return 0, err
+	}
+
+	if cw.marked && len(p) > 0 {
+		cw.first = p[0]
+		cw.marked = false
+	}
+
+	n, err := cw.w.Write(p)
+	atomic.AddInt64(cw.total, int64(n))
+	atomic.AddInt64(&cw.n, int64(n))
+	cw.reply += int64(n)
+	if err != nil && !cw.softSince.IsZero() && isTimeout(err) {
+		// client has not read reply exceeding soft limit in time
+		cw.err = errOutputSoftLimit
+		return n, cw.err
+	}
+	return n, err
+}
+
+// Flush flushes buffered writer underneath so countWriter can replace it
+func (cw *countWriter) Flush() error {
+	if cw.err != nil {
+		return cw.err
+	}
+
+	f, ok := cw.w.(interface {
+		Flush() error
+	})
+	if !ok {
+		return nil
+	}
+
+	err := f.Flush()
+	if err != nil && !cw.softSince.IsZero() && isTimeout(err) {
+		cw.err = errOutputSoftLimit
+		return cw.err
+	}
+	return err
+}
+
+// mark starts new reply
+func (cw *countWriter) mark() {
+	cw.marked = true
+	cw.first = 0
+	cw.reply = 0
+	if !cw.softSince.IsZero() {
+		cw.softSince = time.Time{}
+		if cw.deadline != nil {
+			cw.deadline(time.Time{})
+		}
+	}
+}
+
+// replyError reports whether reply written since mark is error
+func (cw *countWriter) replyError() bool {
+	return !cw.marked && cw.first == '-'
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"bytes"
+
+	"github.com/valery-barysok/gredisd/app/cmd"
+)
+
+// redactedArg replaces arguments holding credentials
+var redactedArg = []byte("(redacted)")
+
+// redactors hide credentials in arguments of commands before they are shown by
+// MONITOR or kept by SLOWLOG
+var redactors = map[string]func(args [][]byte){
+	"acl":    redactACLSetUser,
+	"auth":   redactFrom(0),
+	"config": redactConfigSet,
+	"hello":  redactHelloAuth,
+}
+
+// secretConfigParams are config parameters which values are redacted
+var secretConfigParams = map[string]bool{
+	"requirepass": true,
+}
+
+// redactFrom returns redactor hiding all arguments starting from first
+func redactFrom(first int) func(args [][]byte) {
+	return func(args [][]byte) {
+		for i := first; i < len(args); i++ {
+			args[i] = redactedArg
+		}
+	}
+}
+
+// redactHelloAuth hides username and password given by AUTH option of HELLO
+func redactHelloAuth(args [][]byte) {
+	for i := 1; i < len(args); i++ {
+		if !bytes.EqualFold(args[i], []byte("auth")) {
+			continue
+		}
+		for j := i + 1; j <= i+2 && j < len(args); j++ {
+			args[j] = redactedArg
+		}
+		i += 2
+	}
+}
+
+// redactACLSetUser hides rules of ACL SETUSER as they may hold passwords or their hashes
+func redactACLSetUser(args [][]byte) {
+	if len(args) > 0 && bytes.EqualFold(args[0], []byte("setuser")) {
+		redactFrom(2)(args)
+	}
+}
+
+// redactConfigSet hides values of secret parameters given to CONFIG SET
+func redactConfigSet(args [][]byte) {
+	if len(args) == 0 || !bytes.EqualFold(args[0], []byte("set")) {
+		return
+	}
+	for i := 1; i+1 < len(args); i += 2 {
+		if secretConfigParams[string(bytes.ToLower(args[i]))] {
+			args[i+1] = redactedArg
+		}
+	}
+}
+
+// redactArgs returns arguments of command with credentials replaced by "(redacted)"
+func redactArgs(cmd *cmd.Command) [][]byte {
+	args := make([][]byte, len(cmd.Args))
+	for i, arg := range cmd.Args {
+		args[i] = arg.BulkString()
+	}
+
+	if redact, ok := redactors[cmd.Cmd]; ok {
+		redact(args)
+	}
+	return args
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestRedactArgs(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		cmd  string
+		args []string
+		want []string
+	}{
+		{"get", []string{"key"}, []string{"key"}},
+		{"auth", []string{"secret"}, []string{"(redacted)"}},
+		{"auth", []string{"user", "secret"}, []string{"(redacted)", "(redacted)"}},
+		{"hello", []string{"3"}, []string{"3"}},
+		{"hello", []string{"3", "AUTH", "user", "secret", "SETNAME", "name"}, []string{"3", "AUTH", "(redacted)", "(redacted)", "SETNAME", "name"}},
+		{"hello", []string{"3", "SETNAME", "name", "auth", "user"}, []string{"3", "SETNAME", "name", "auth", "(redacted)"}},
+		{"acl", []string{"SETUSER", "user", "on", ">secret", "#hash", "~*"}, []string{"SETUSER", "user", "(redacted)", "(redacted)", "(redacted)", "(redacted)"}},
+		{"acl", []string{"getuser", "user"}, []string{"getuser", "user"}},
+		{"config", []string{"SET", "maxclients", "10", "RequirePass", "secret"}, []string{"SET", "maxclients", "10", "RequirePass", "(redacted)"}},
+		{"config", []string{"get", "requirepass"}, []string{"get", "requirepass"}},
+	}
+
+	for _, test := range tests {
+		args := redactArgs(command(test.cmd, test.args...))
+		got := make([]string, len(args))
+		for i, arg := range args {
+			got[i] = string(arg)
+		}
+		Expect(got).To(Equal(test.want), "%s %v", test.cmd, test.args)
+	}
+}
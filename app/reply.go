@@ -0,0 +1,294 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/valery-barysok/resp"
+)
+
+// Versions of protocol negotiated by HELLO
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+// Map is flat list of keys followed by their values. It is map in RESP3 and
+// array in RESP2
+type Map []interface{}
+
+// Set is set in RESP3 and array in RESP2
+type Set []interface{}
+
+// Push is out of band message, it is push in RESP3 and array in RESP2
+type Push []interface{}
+
+// Double is formatted floating point number, it is double in RESP3 and bulk
+// string in RESP2
+type Double []byte
+
+// Bool is boolean in RESP3 and integer 1 or 0 in RESP2
+type Bool bool
+
+// Verbatim is text of given format, e.g. "txt", it is verbatim string in RESP3
+// and bulk string in RESP2
+type Verbatim struct {
+	Format string
+	Text   []byte
+}
+
+// WithScores is flat list of members followed by their scores. It is array of
+// member and double score pairs in RESP3 and flat array in RESP2
+type WithScores []interface{}
+
+// Protocol returns version of protocol used by client
+func (context *ClientContext) Protocol() int {
+	if context.protocol == 0 {
+		return RESP2
+	}
+	return context.protocol
+}
+
+// SetProtocol switches protocol used by client
+func (context *ClientContext) SetProtocol(protocol int) {
+	context.protocol = protocol
+	if context.client != nil {
+		context.client.mu.Lock()
+		context.client.resp = protocol
+		context.client.mu.Unlock()
+	}
+}
+
+// WriteReply writes value as reply of type matching protocol of client. Value
+// is nil, []byte, string, int, int64, []interface{}, [][]byte or one of reply
+// types above. Unsupported value is a bug, it is logged and replied with error
+// so client does not wait for reply forever
+func (context *ClientContext) WriteReply(w *resp.Writer, v interface{}) {
+	if context.Protocol() < RESP3 || context.out == nil {
+		converted, err := toRESP2(v)
+		if err != nil {
+			log.Println(err)
+			w.WriteErrorString(err.Error())
+			return
+		}
+		writeRESP2(w, converted)
+		return
+	}
+
+	// resp.Writer knows nothing about RESP3 so frames are written to
+	// connection directly once everything written by it is flushed
+	if err := w.Flush(); err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := encodeRESP3(&buf, v); err != nil {
+		log.Println(err)
+		buf.Reset()
+		encodeRESP3Line(&buf, '-', err.Error())
+	}
+	context.out.Write(buf.Bytes())
+}
+
+// WriteStatus writes status reply, e.g. +string, in both protocols. resp.Writer
+// has no status replies so it is written to connection directly once everything
+// written by resp.Writer is flushed
+func (context *ClientContext) WriteStatus(w *resp.Writer, status []byte) {
+	if context.out == nil {
+		w.WriteBulkString(status)
+		return
+	}
+
+	if err := w.Flush(); err != nil {
+		return
+	}
+	context.writeStatus(status)
+}
+
+// WriteNilArray writes null array reply, *-1 in RESP2 and null in RESP3, e.g.
+// reply of blocking pop once timeout expires. resp.Writer has no null arrays so
+// it is written to connection directly like status reply
+func (context *ClientContext) WriteNilArray(w *resp.Writer) {
+	if context.Protocol() >= RESP3 || context.out == nil {
+		context.WriteReply(w, nil)
+		return
+	}
+
+	if err := w.Flush(); err != nil {
+		return
+	}
+	context.out.Write([]byte("*-1\r\n"))
+}
+
+// writeRESP2 writes value converted by toRESP2
+func writeRESP2(w *resp.Writer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		w.WriteNilBulk()
+	case []byte:
+		w.WriteBulkString(v)
+	case int:
+		w.WriteInteger(v)
+	case []interface{}:
+		w.WriteArray(v)
+	}
+}
+
+func errReplyType(v interface{}) error {
+	return fmt.Errorf("ERR unsupported reply type %T", v)
+}
+
+func errReplyPairs(v interface{}) error {
+	return fmt.Errorf("ERR odd number of elements in reply of type %T", v)
+}
+
+// toRESP2 converts reply types to nil, []byte, int or []interface{} of them
+// which resp.Writer knows about
+func toRESP2(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, []byte, int:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	case int64:
+		return int(v), nil
+	case Double:
+		return []byte(v), nil
+	case Bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case Verbatim:
+		return v.Text, nil
+	case Map:
+		return toRESP2Array(v)
+	case Set:
+		return toRESP2Array(v)
+	case Push:
+		return toRESP2Array(v)
+	case WithScores:
+		return toRESP2Array(v)
+	case []interface{}:
+		return toRESP2Array(v)
+	case [][]byte:
+		values := make([]interface{}, len(v))
+		for i, b := range v {
+			values[i] = b
+		}
+		return values, nil
+	}
+	return nil, errReplyType(v)
+}
+
+func toRESP2Array(values []interface{}) (interface{}, error) {
+	converted := make([]interface{}, len(values))
+	for i, v := range values {
+		c, err := toRESP2(v)
+		if err != nil {
+			return nil, err
+		}
+		converted[i] = c
+	}
+	return converted, nil
+}
+
+func encodeRESP3(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("_\r\n")
+	case []byte:
+		encodeRESP3Bulk(buf, '$', v)
+	case string:
+		encodeRESP3Bulk(buf, '$', []byte(v))
+	case int:
+		encodeRESP3Line(buf, ':', strconv.Itoa(v))
+	case int64:
+		encodeRESP3Line(buf, ':', strconv.FormatInt(v, 10))
+	case Double:
+		encodeRESP3Line(buf, ',', string(v))
+	case Bool:
+		if v {
+			buf.WriteString("#t\r\n")
+		} else {
+			buf.WriteString("#f\r\n")
+		}
+	case Verbatim:
+		text := make([]byte, 0, len(v.Format)+1+len(v.Text))
+		text = append(text, v.Format...)
+		text = append(text, ':')
+		text = append(text, v.Text...)
+		encodeRESP3Bulk(buf, '=', text)
+	case Map:
+		if len(v)%2 != 0 {
+			return errReplyPairs(v)
+		}
+		return encodeRESP3Aggregate(buf, '%', len(v)/2, v)
+	case Set:
+		return encodeRESP3Aggregate(buf, '~', len(v), v)
+	case Push:
+		return encodeRESP3Aggregate(buf, '>', len(v), v)
+	case WithScores:
+		if len(v)%2 != 0 {
+			return errReplyPairs(v)
+		}
+		encodeRESP3Line(buf, '*', strconv.Itoa(len(v)/2))
+		for i := 0; i < len(v); i += 2 {
+			buf.WriteString("*2\r\n")
+			if err := encodeRESP3(buf, v[i]); err != nil {
+				return err
+			}
+			score, ok := v[i+1].([]byte)
+			if !ok {
+				return errReplyType(v[i+1])
+			}
+			encodeRESP3Line(buf, ',', string(score))
+		}
+	case []interface{}:
+		return encodeRESP3Aggregate(buf, '*', len(v), v)
+	case [][]byte:
+		encodeRESP3Line(buf, '*', strconv.Itoa(len(v)))
+		for _, b := range v {
+			encodeRESP3Bulk(buf, '$', b)
+		}
+	default:
+		return errReplyType(v)
+	}
+	return nil
+}
+
+func encodeRESP3Line(buf *bytes.Buffer, kind byte, line string) {
+	buf.WriteByte(kind)
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+func encodeRESP3Bulk(buf *bytes.Buffer, kind byte, b []byte) {
+	encodeRESP3Line(buf, kind, strconv.Itoa(len(b)))
+	buf.Write(b)
+	buf.WriteString("\r\n")
+}
+
+func encodeRESP3Aggregate(buf *bytes.Buffer, kind byte, n int, values []interface{}) error {
+	encodeRESP3Line(buf, kind, strconv.Itoa(n))
+	for _, v := range values {
+		if err := encodeRESP3(buf, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Hello describes server and connection of client in reply to HELLO
+func (context *ClientContext) Hello() Map {
+	return Map{
+		[]byte("server"), []byte("gredis"),
+		[]byte("version"), []byte(Version),
+		[]byte("proto"), context.Protocol(),
+		[]byte("id"), int(context.ID()),
+		[]byte("mode"), []byte("standalone"),
+		[]byte("role"), []byte("master"),
+		[]byte("modules"), []interface{}{},
+	}
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/valery-barysok/resp"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestReplyRESP2(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		reply interface{}
+		want  interface{}
+	}{
+		{"value", []byte("value")},
+		{int64(7), 7},
+		{Double("1.5"), []byte("1.5")},
+		{Bool(true), 1},
+		{Bool(false), 0},
+		{Verbatim{Format: "txt", Text: []byte("text")}, []byte("text")},
+		{Map{[]byte("key"), 1, []byte("nested"), Map{[]byte("a"), nil}}, []interface{}{[]byte("key"), 1, []byte("nested"), []interface{}{[]byte("a"), nil}}},
+		{Set{[]byte("a"), []byte("b")}, []interface{}{[]byte("a"), []byte("b")}},
+		{Push{[]byte("message"), []byte("ch"), Bool(true)}, []interface{}{[]byte("message"), []byte("ch"), 1}},
+		{WithScores{[]byte("m"), []byte("2"), []byte("n"), []byte("inf")}, []interface{}{[]byte("m"), []byte("2"), []byte("n"), []byte("inf")}},
+		{[][]byte{[]byte("a")}, []interface{}{[]byte("a")}},
+	}
+
+	for _, test := range tests {
+		converted, err := toRESP2(test.reply)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(converted).To(Equal(test.want), "%#v", test.reply)
+	}
+
+	_, err := toRESP2([]interface{}{1.5})
+	Expect(err).To(MatchError("ERR unsupported reply type float64"))
+}
+
+func TestReplyRESP3(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		reply interface{}
+		want  string
+	}{
+		{nil, "_\r\n"},
+		{[]byte("value"), "$5\r\nvalue\r\n"},
+		{int64(-7), ":-7\r\n"},
+		{Double("1.5"), ",1.5\r\n"},
+		{Double("-inf"), ",-inf\r\n"},
+		{Bool(true), "#t\r\n"},
+		{Bool(false), "#f\r\n"},
+		{Verbatim{Format: "txt", Text: []byte("text")}, "=8\r\ntxt:text\r\n"},
+		{Map{[]byte("key"), 1, []byte("nested"), Map{}}, "%2\r\n$3\r\nkey\r\n:1\r\n$6\r\nnested\r\n%0\r\n"},
+		{Set{[]byte("a"), nil}, "~2\r\n$1\r\na\r\n_\r\n"},
+		{Push{[]byte("message"), []byte("ch"), Bool(false)}, ">3\r\n$7\r\nmessage\r\n$2\r\nch\r\n#f\r\n"},
+		{WithScores{[]byte("m"), []byte("2")}, "*1\r\n*2\r\n$1\r\nm\r\n,2\r\n"},
+		{[]interface{}{1, []interface{}{}}, "*2\r\n:1\r\n*0\r\n"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		Expect(encodeRESP3(&buf, test.reply)).To(Succeed())
+		Expect(buf.String()).To(Equal(test.want), "%#v", test.reply)
+	}
+
+	var buf bytes.Buffer
+	Expect(encodeRESP3(&buf, Set{true})).To(MatchError("ERR unsupported reply type bool"))
+	Expect(encodeRESP3(&buf, Map{[]byte("key")})).To(MatchError("ERR odd number of elements in reply of type app.Map"))
+}
+
+func TestWriteReplyProtocol(t *testing.T) {
+	RegisterTestingT(t)
+
+	var out bytes.Buffer
+	var total int64
+	context := &ClientContext{out: &countWriter{w: &out, total: &total, limit: &outputLimit{}}}
+	Expect(context.Protocol()).To(Equal(RESP2))
+
+	w := resp.NewWriter(context.out, resp.NewProtocol())
+	context.SetProtocol(RESP3)
+	context.WriteReply(w, Map{[]byte("proto"), context.Protocol()})
+	context.WriteReply(w, 1.5)
+	Expect(out.String()).To(Equal("%1\r\n$5\r\nproto\r\n:3\r\n-ERR unsupported reply type float64\r\n"))
+}
+
+func TestWriteStatus(t *testing.T) {
+	RegisterTestingT(t)
+
+	var out bytes.Buffer
+	var total int64
+	context := &ClientContext{out: &countWriter{w: &out, total: &total, limit: &outputLimit{}}}
+	w := resp.NewWriter(context.out, resp.NewProtocol())
+
+	context.WriteStatus(w, []byte("string"))
+	context.SetProtocol(RESP3)
+	context.WriteStatus(w, []byte("none"))
+	Expect(out.String()).To(Equal("+string\r\n+none\r\n"))
+}
+
+func TestWriteNilArray(t *testing.T) {
+	RegisterTestingT(t)
+
+	var out bytes.Buffer
+	var total int64
+	context := &ClientContext{out: &countWriter{w: &out, total: &total, limit: &outputLimit{}}}
+	w := resp.NewWriter(context.out, resp.NewProtocol())
+
+	context.WriteNilArray(w)
+	context.SetProtocol(RESP3)
+	context.WriteNilArray(w)
+	Expect(out.String()).To(Equal("*-1\r\n_\r\n"))
+}
@@ -2,23 +2,87 @@ package app
 
 import (
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/valery-barysok/gredisd/app/cmd"
 	"github.com/valery-barysok/resp"
 )
 
+// commandStat keeps statistics of command, it is updated atomically
+type commandStat struct {
+	calls    int64
+	usec     int64
+	rejected int64
+	failed   int64
+
+	// latency keeps number of calls which took up to corresponding latencyBuckets
+	// duration but longer than previous one
+	latency []int64
+
+	// histogram keeps number of calls per histogramBucket
+	histogram []int64
+}
+
+func newCommandStat() *commandStat {
+	return &commandStat{
+		latency:   make([]int64, len(latencyBuckets)),
+		histogram: make([]int64, latencyHistogramBuckets),
+	}
+}
+
+func (stat *commandStat) record(d time.Duration, failed bool) {
+	atomic.AddInt64(&stat.calls, 1)
+	atomic.AddInt64(&stat.usec, int64(d/time.Microsecond))
+	if failed {
+		atomic.AddInt64(&stat.failed, 1)
+	}
+
+	for i, bucket := range latencyBuckets {
+		if d <= bucket {
+			atomic.AddInt64(&stat.latency[i], 1)
+			break
+		}
+	}
+	atomic.AddInt64(&stat.histogram[histogramBucket(d)], 1)
+}
+
+// reset zeroes statistics of command
+func (stat *commandStat) reset() {
+	atomic.StoreInt64(&stat.calls, 0)
+	atomic.StoreInt64(&stat.usec, 0)
+	atomic.StoreInt64(&stat.rejected, 0)
+	atomic.StoreInt64(&stat.failed, 0)
+	for i := range stat.latency {
+		atomic.StoreInt64(&stat.latency[i], 0)
+	}
+	for i := range stat.histogram {
+		atomic.StoreInt64(&stat.histogram[i], 0)
+	}
+}
+
 type router struct {
 	filters []Filter
 	routes  map[string]Handler
 
+	// stats are created on bind so map is not modified while serving
+	stats    map[string]*commandStat
+	slowlog  *slowLog
+	latency  *latencyMonitor
+	monitors *monitors
+
 	notFound     Handler
 	errorHandler ErrorHandler
 }
 
 func newRouter() *router {
 	return &router{
-		filters: make([]Filter, 0),
-		routes:  make(map[string]Handler),
+		filters:  make([]Filter, 0),
+		routes:   make(map[string]Handler),
+		stats:    make(map[string]*commandStat),
+		slowlog:  newSlowLog(),
+		latency:  newLatencyMonitor(),
+		monitors: newMonitors(),
 	}
 }
 
@@ -31,6 +95,9 @@ func (router *router) bind(cmd string, handler Handler) Handler {
 	cmd = strings.ToLower(cmd)
 	oldHandler := router.routes[cmd]
 	router.routes[cmd] = handler
+	if _, ok := router.stats[cmd]; !ok {
+		router.stats[cmd] = newCommandStat()
+	}
 	return oldHandler
 }
 
@@ -47,17 +114,39 @@ func (router *router) bindError(errorHandler ErrorHandler) ErrorHandler {
 }
 
 func (router *router) serve(context *ClientContext, cmd *cmd.Command, res *resp.Writer) error {
+	stat := router.stats[cmd.Cmd]
+	if context.out != nil {
+		context.out.mark()
+	}
+
 	for _, filter := range router.filters {
 		done, err := filter(context, cmd, res)
 		if err != nil {
 			return err
 		}
 		if done {
+			if stat != nil {
+				atomic.AddInt64(&stat.rejected, 1)
+			}
 			return nil
 		}
 	}
 
-	return router.handle(context, cmd, res)
+	router.monitors.feed(context, cmd)
+
+	context.blocked = false
+	start := time.Now()
+	err := router.handle(context, cmd, res)
+	d := time.Since(start)
+	if stat != nil {
+		stat.record(d, err != nil || context.out != nil && context.out.replyError())
+	}
+	// time of blocked command is mostly waiting
+	if !context.blocked {
+		router.slowlog.record(context, cmd, start, d)
+		router.latency.record(latencyCommand, d)
+	}
+	return err
 }
 
 func (router *router) handle(context *ClientContext, cmd *cmd.Command, res *resp.Writer) error {
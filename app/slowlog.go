@@ -0,0 +1,161 @@
+package app
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valery-barysok/gredisd/app/cmd"
+)
+
+const (
+	// slowLogMaxArgs is maximum number of arguments of logged command
+	slowLogMaxArgs = 32
+
+	// slowLogMaxArgLen is maximum length of logged argument
+	slowLogMaxArgLen = 128
+)
+
+// slowLog keeps the latest commands which took longer than threshold
+type slowLog struct {
+	// threshold in microseconds and maxLen are accessed atomically. Negative
+	// threshold disables log, zero logs every command
+	threshold int64
+	maxLen    int64
+
+	mu      sync.Mutex
+	entries *list.List
+	lastID  int64
+}
+
+type slowLogEntry struct {
+	id       int64
+	time     int64
+	duration int64
+	args     []interface{}
+	addr     string
+	name     string
+}
+
+func newSlowLog() *slowLog {
+	return &slowLog{
+		threshold: DefaultSlowLogSlowerThan,
+		maxLen:    DefaultSlowLogMaxLen,
+		entries:   list.New(),
+	}
+}
+
+// record logs command if it took longer than threshold
+func (slowlog *slowLog) record(context *ClientContext, cmd *cmd.Command, start time.Time, d time.Duration) {
+	threshold := atomic.LoadInt64(&slowlog.threshold)
+	usec := int64(d / time.Microsecond)
+	if threshold < 0 || usec < threshold {
+		return
+	}
+
+	entry := &slowLogEntry{
+		time:     start.Unix(),
+		duration: usec,
+		args:     slowLogArgs(cmd),
+	}
+	if context.client != nil {
+		entry.addr, entry.name = context.client.identity()
+	}
+
+	slowlog.mu.Lock()
+	defer slowlog.mu.Unlock()
+
+	entry.id = slowlog.lastID
+	slowlog.lastID++
+	slowlog.entries.PushFront(entry)
+	slowlog.trim()
+}
+
+// trim removes the oldest entries exceeding maxLen
+func (slowlog *slowLog) trim() {
+	maxLen := int(atomic.LoadInt64(&slowlog.maxLen))
+	for slowlog.entries.Len() > maxLen && slowlog.entries.Len() > 0 {
+		slowlog.entries.Remove(slowlog.entries.Back())
+	}
+}
+
+// slowLogArgs returns arguments of command with credentials redacted truncated
+// to slowLogMaxArgs arguments of slowLogMaxArgLen bytes
+func slowLogArgs(cmd *cmd.Command) []interface{} {
+	n := len(cmd.Args) + 1
+	if n > slowLogMaxArgs {
+		n = slowLogMaxArgs
+	}
+
+	redacted := redactArgs(cmd)
+	args := make([]interface{}, 0, n)
+	args = append(args, []byte(cmd.Cmd))
+	for i, value := range redacted {
+		if len(args) == slowLogMaxArgs-1 && i < len(redacted)-1 {
+			args = append(args, []byte(fmt.Sprintf("... (%d more arguments)", len(redacted)-i)))
+			break
+		}
+
+		if len(value) > slowLogMaxArgLen {
+			value = []byte(fmt.Sprintf("%s... (%d more bytes)", value[:slowLogMaxArgLen], len(value)-slowLogMaxArgLen))
+		}
+		args = append(args, value)
+	}
+	return args
+}
+
+// SlowLog returns up to count the latest entries of slowlog, negative count returns all entries
+func (app *App) SlowLog(count int) []interface{} {
+	slowlog := app.router.slowlog
+	slowlog.mu.Lock()
+	defer slowlog.mu.Unlock()
+
+	if count < 0 || count > slowlog.entries.Len() {
+		count = slowlog.entries.Len()
+	}
+
+	entries := make([]interface{}, 0, count)
+	for it := slowlog.entries.Front(); it != nil && len(entries) < count; it = it.Next() {
+		entry := it.Value.(*slowLogEntry)
+		entries = append(entries, []interface{}{
+			int(entry.id),
+			int(entry.time),
+			int(entry.duration),
+			entry.args,
+			[]byte(entry.addr),
+			[]byte(entry.name),
+		})
+	}
+	return entries
+}
+
+// SlowLogLen returns number of entries of slowlog
+func (app *App) SlowLogLen() int {
+	slowlog := app.router.slowlog
+	slowlog.mu.Lock()
+	defer slowlog.mu.Unlock()
+
+	return slowlog.entries.Len()
+}
+
+// SlowLogReset removes all entries of slowlog
+func (app *App) SlowLogReset() {
+	slowlog := app.router.slowlog
+	slowlog.mu.Lock()
+	defer slowlog.mu.Unlock()
+
+	slowlog.entries.Init()
+}
+
+// SetSlowLog sets threshold in microseconds and maximum length of slowlog
+func (app *App) SetSlowLog(threshold int64, maxLen int) {
+	slowlog := app.router.slowlog
+	atomic.StoreInt64(&slowlog.threshold, threshold)
+	atomic.StoreInt64(&slowlog.maxLen, int64(maxLen))
+
+	slowlog.mu.Lock()
+	slowlog.trim()
+	slowlog.mu.Unlock()
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/valery-barysok/gredisd/app/cmd"
+	"github.com/valery-barysok/resp"
+
+	. "github.com/onsi/gomega"
+)
+
+func command(name string, args ...string) *cmd.Command {
+	c := &cmd.Command{Cmd: name}
+	for _, arg := range args {
+		c.Args = append(c.Args, &resp.Message{Value: []byte(arg)})
+	}
+	return c
+}
+
+func TestSlowLog(t *testing.T) {
+	RegisterTestingT(t)
+
+	slowlog := newSlowLog()
+	slowlog.threshold = 100
+	context := &ClientContext{}
+	start := time.Now()
+
+	slowlog.record(context, command("get", "key"), start, time.Millisecond)
+	slowlog.record(context, command("auth", "user", "secret"), start, time.Millisecond)
+	slowlog.record(context, command("set", "key", "value"), start, time.Microsecond)
+	Expect(slowlog.entries.Len()).To(Equal(2))
+
+	entry := slowlog.entries.Front().Value.(*slowLogEntry)
+	Expect(entry.id).To(Equal(int64(1)))
+	Expect(entry.duration).To(Equal(int64(1000)))
+	Expect(entry.args).To(Equal([]interface{}{[]byte("auth"), []byte("(redacted)"), []byte("(redacted)")}))
+
+	args := make([]string, 40)
+	for i := range args {
+		args[i] = "a"
+	}
+	args[0] = string(make([]byte, slowLogMaxArgLen+2))
+	logged := slowLogArgs(command("rpush", args...))
+	Expect(logged).To(HaveLen(slowLogMaxArgs))
+	Expect(logged[1]).To(HaveSuffix("... (2 more bytes)"))
+	Expect(logged[slowLogMaxArgs-1]).To(Equal([]byte("... (10 more arguments)")))
+
+	slowlog.maxLen = 1
+	slowlog.trim()
+	Expect(slowlog.entries.Len()).To(Equal(1))
+}
@@ -1,17 +1,20 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/valery-barysok/gredisd/app"
+	"github.com/valery-barysok/gredisd/app/cli"
 	"github.com/valery-barysok/gredisd/app/gredisd"
 )
 
 var usageStr = `
-Usage: gredisd [options]
+Usage: gredisd [/path/to/gredisd.conf] [options]
 
 Server Options:
     -a, --addr <host>                Bind to host address (default: 0.0.0.0)
@@ -20,13 +23,63 @@ Server Options:
                                      a different one on a per-connection basis using SELECT <dbid> where
                                      dbid is a number between 0 and 'databases'-1
         --trace_protocol             Trace low level read/write operations
+        --metrics-addr <host:port>   Expose metrics in Prometheus format on http://<host:port>/metrics
+                                     (default: disabled)
+        --timeout <seconds>          Close connection of client idle for given number of seconds
+                                     (default: 0, never)
+        --maxclients <count>         Maximum number of connected clients, new connections are rejected
+                                     once limit is reached (default: 10000)
+        --tcp-keepalive <seconds>    Period of TCP keepalive probes of client connections, 0 disables
+                                     them (default: 300)
+
+Client Limits Options:
+        --client-output-buffer-limit <class hard soft seconds>
+                                     Disconnect client of class normal, replica or pubsub once its reply
+                                     exceeds hard bytes or stays above soft bytes for given seconds, 0
+                                     disables limit, may be repeated (default: normal 0 0 0,
+                                     replica 256mb 64mb 60, pubsub 32mb 8mb 60)
+        --client-query-buffer-limit <bytes>  Disconnect client sending longer command (default: 1gb)
+        --proto-max-bulk-len <bytes>         Reject command with longer argument (default: 512mb)
+
+Latency Options:
+        --slowlog-log-slower-than <usec>     Log commands taking longer than given microseconds, 0 logs
+                                             every command and negative value disables log (default: 10000)
+        --slowlog-max-len <count>            Maximum number of logged commands (default: 128)
+        --latency-monitor-threshold <ms>     Record latency events taking at least given milliseconds,
+                                             0 disables latency monitor (default: 0)
+
+Encoding Options:
+        --hash-max-listpack-entries <count>  Maximum number of fields of listpack encoded hash (default: 128)
+        --hash-max-listpack-value <len>      Maximum length of field or value of listpack encoded hash (default: 64)
+        --list-max-listpack-size <size>      Maximum number of elements in list node when positive or node size
+                                             of 4, 8, 16, 32 or 64 Kb when from -1 to -5 (default: -2)
+        --set-max-intset-entries <count>     Maximum number of members of intset encoded set (default: 512)
+
+Memory Options:
+        --maxmemory <bytes>          Limit memory taken by keys and values, units k, kb, m, mb, g and gb
+                                     are accepted (default: 0, no limit)
+        --maxmemory-policy <policy>  Eviction policy applied once maxmemory is reached: noeviction,
+                                     allkeys-lru, volatile-lru, allkeys-lfu, volatile-lfu, allkeys-random,
+                                     volatile-random or volatile-ttl (default: noeviction)
+        --maxmemory-samples <count>  Number of keys sampled per database to find key to evict (default: 5)
+
+Analysis Options:
+        --bigkeys                    Connect to running server and report the biggest keys of each type by
+                                     number of elements in all databases
+        --memkeys                    Same as --bigkeys but keys are compared by memory
 
 Authorization Options:
         --auth <token>               Authorization token required for connections
+        --auth-file <path>           Read authorization token from file, trailing line breaks are trimmed.
+                                     Unlike --auth the token is not visible in process list
+        --aclfile <path>             Load users from ACL file at startup, see ACL LOAD (default: none)
+        --acllog-max-len <count>     Maximum number of ACL LOG entries (default: 128)
 
 Common Options:
     -h, --help                       Show this message
     -v, --version                    Show version
+        --print-config               Print effective configuration in config file format and exit,
+                                     authorization token is redacted
 `
 
 func usage() {
@@ -35,57 +88,167 @@ func usage() {
 }
 
 func main() {
-	opts := app.Options{}
-	initFromEnv(&opts)
-
-	var showVersion bool
-
-	flag.IntVar(&opts.Port, "port", opts.Port, "Port to listen on.")
-	flag.IntVar(&opts.Port, "p", opts.Port, "Port to listen on.")
-	flag.StringVar(&opts.Host, "addr", opts.Host, "Network host to listen on.")
-	flag.StringVar(&opts.Host, "a", opts.Host, "Network host to listen on.")
-	flag.BoolVar(&opts.TraceProtocol, "trace_protocol", false, "Trace low level read/write operations")
-	flag.StringVar(&opts.Auth, "auth", "", "Password for AUTH command.")
-	flag.IntVar(&opts.Databases, "databases", app.DefaultDatabases, "Password for AUTH command.")
-	flag.BoolVar(&showVersion, "version", false, "Print version information.")
-	flag.BoolVar(&showVersion, "v", false, "Print version information.")
-
-	flag.Usage = usage
-
-	flag.Parse()
+	opts, cl, err := parseOptions(os.Args[1:])
+	if err != nil {
+		exitWithError(err)
+	}
 
 	gApp := gredisd.NewApp(&opts)
-	if showVersion {
+	if cl.showVersion {
 		gApp.ShowVersion()
 		os.Exit(0)
 	}
 
-	gApp.Run()
+	if cl.printConfig {
+		app.PrintConfig(os.Stdout, &opts)
+		os.Exit(0)
+	}
+
+	if cl.bigKeys || cl.memKeys {
+		err := cli.KeysReport(os.Stdout, opts.Host, opts.Port, opts.Auth, opts.Databases, cl.memKeys)
+		if err != nil {
+			exitWithError(err)
+		}
+		os.Exit(0)
+	}
+
+	if err := gApp.Run(); err != nil {
+		exitWithError(err)
+	}
+}
+
+// commandLine holds command line options which are not options of app
+type commandLine struct {
+	showVersion bool
+	printConfig bool
+	bigKeys     bool
+	memKeys     bool
 }
 
-func initFromEnv(opts *app.Options) {
-	intEnvVar(&opts.Port, "GREDIS_PORT", app.DefaultPort)
-	stringVar(&opts.Host, "GREDIS_HOST", app.DefaultHost)
+// parseOptions reads options from environment, config file given as first
+// argument and command line options, each of them overrides previous ones
+func parseOptions(args []string) (app.Options, commandLine, error) {
+	var cl commandLine
+	opts := app.DefaultOptions()
+	if err := app.LoadEnv(&opts); err != nil {
+		return opts, cl, err
+	}
 
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if err := app.LoadConfig(args[0], &opts); err != nil {
+			return opts, cl, err
+		}
+		args = args[1:]
+	}
+
+	var authFile string
+
+	fs := flag.NewFlagSet("gredisd", flag.ContinueOnError)
+	fs.IntVar(&opts.Port, "port", opts.Port, "Port to listen on.")
+	fs.IntVar(&opts.Port, "p", opts.Port, "Port to listen on.")
+	fs.StringVar(&opts.Host, "addr", opts.Host, "Network host to listen on.")
+	fs.StringVar(&opts.Host, "a", opts.Host, "Network host to listen on.")
+	fs.BoolVar(&opts.TraceProtocol, "trace_protocol", opts.TraceProtocol, "Trace low level read/write operations")
+	fs.StringVar(&opts.MetricsAddr, "metrics-addr", opts.MetricsAddr, "Address of metrics endpoint.")
+	fs.IntVar(&opts.Timeout, "timeout", opts.Timeout, "Idle timeout of clients in seconds.")
+	fs.IntVar(&opts.MaxClients, "maxclients", opts.MaxClients, "Maximum number of connected clients.")
+	fs.IntVar(&opts.TCPKeepAlive, "tcp-keepalive", opts.TCPKeepAlive, "Period of TCP keepalive probes in seconds.")
+	fs.Var(outputLimitFlag{&opts.ClientOutputBufferLimit}, "client-output-buffer-limit", "Output buffer limit of client class.")
+	fs.Var(memoryFlag{&opts.ClientQueryBufferLimit}, "client-query-buffer-limit", "Maximum size of command.")
+	fs.Var(memoryFlag{&opts.ProtoMaxBulkLen}, "proto-max-bulk-len", "Maximum length of command argument.")
+	fs.Int64Var(&opts.SlowLogSlowerThan, "slowlog-log-slower-than", opts.SlowLogSlowerThan, "Threshold of slow log in microseconds.")
+	fs.IntVar(&opts.SlowLogMaxLen, "slowlog-max-len", opts.SlowLogMaxLen, "Maximum length of slow log.")
+	fs.Int64Var(&opts.LatencyMonitorThreshold, "latency-monitor-threshold", opts.LatencyMonitorThreshold, "Threshold of latency monitor in milliseconds.")
+	fs.StringVar(&opts.Auth, "auth", opts.Auth, "Password for AUTH command.")
+	fs.StringVar(&authFile, "auth-file", "", "File containing password for AUTH command.")
+	fs.StringVar(&opts.ACLFile, "aclfile", opts.ACLFile, "File with ACL users.")
+	fs.IntVar(&opts.ACLLogMaxLen, "acllog-max-len", opts.ACLLogMaxLen, "Maximum length of ACL log.")
+	fs.IntVar(&opts.Databases, "databases", opts.Databases, "Number of databases.")
+	fs.IntVar(&opts.HashMaxListpackEntries, "hash-max-listpack-entries", opts.HashMaxListpackEntries, "Maximum number of fields of listpack encoded hash.")
+	fs.IntVar(&opts.HashMaxListpackValue, "hash-max-listpack-value", opts.HashMaxListpackValue, "Maximum length of field or value of listpack encoded hash.")
+	fs.IntVar(&opts.ListMaxListpackSize, "list-max-listpack-size", opts.ListMaxListpackSize, "Maximum size of list node.")
+	fs.IntVar(&opts.SetMaxIntsetEntries, "set-max-intset-entries", opts.SetMaxIntsetEntries, "Maximum number of members of intset encoded set.")
+	fs.Var(memoryFlag{&opts.MaxMemory}, "maxmemory", "Limit of memory taken by keys and values.")
+	fs.StringVar(&opts.MaxMemoryPolicy, "maxmemory-policy", opts.MaxMemoryPolicy, "Eviction policy.")
+	fs.IntVar(&opts.MaxMemorySamples, "maxmemory-samples", opts.MaxMemorySamples, "Number of keys sampled to find key to evict.")
+	fs.BoolVar(&cl.bigKeys, "bigkeys", false, "Report the biggest keys by number of elements.")
+	fs.BoolVar(&cl.memKeys, "memkeys", false, "Report the biggest keys by memory.")
+	fs.BoolVar(&cl.printConfig, "print-config", false, "Print effective configuration.")
+	fs.BoolVar(&cl.showVersion, "version", false, "Print version information.")
+	fs.BoolVar(&cl.showVersion, "v", false, "Print version information.")
+
+	fs.Usage = usage
+
+	if err := fs.Parse(args); err != nil {
+		return opts, cl, err
+	}
+
+	if authFile != "" {
+		if isFlagSet(fs, "auth") {
+			return opts, cl, errors.New("--auth and --auth-file are mutually exclusive")
+		}
+		auth, err := app.ReadAuthFile(authFile)
+		if err != nil {
+			return opts, cl, err
+		}
+		opts.Auth = auth
+	}
+
+	if err := app.ValidateOptions(&opts); err != nil {
+		return opts, cl, err
+	}
+	return opts, cl, nil
+}
+
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
 }
 
-func intEnvVar(p *int, name string, value int) {
-	val := os.Getenv(name)
-	if val != "" {
-		v, err := strconv.Atoi(val)
-		if err == nil {
-			*p = v
-			return
+// isFlagSet reports whether flag was given on command line
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
 		}
+	})
+	return set
+}
+
+// memoryFlag is flag value which accepts memory amount with units
+type memoryFlag struct {
+	p *int64
+}
+
+func (f memoryFlag) String() string {
+	if f.p == nil {
+		return "0"
+	}
+	return strconv.FormatInt(*f.p, 10)
+}
+
+func (f memoryFlag) Set(s string) error {
+	v, err := app.ParseMemory(s)
+	if err != nil {
+		return err
 	}
-	*p = value
+	*f.p = v
+	return nil
+}
+
+// outputLimitFlag is flag value which accepts client-output-buffer-limit, it may be repeated
+type outputLimitFlag struct {
+	p *map[string]app.OutputBufferLimit
+}
+
+func (f outputLimitFlag) String() string {
+	return ""
 }
 
-func stringVar(p *string, name string, value string) {
-	val := os.Getenv(name)
-	if val != "" {
-		*p = val
-		return
+func (f outputLimitFlag) Set(s string) error {
+	if *f.p == nil {
+		*f.p = make(map[string]app.OutputBufferLimit)
 	}
-	*p = value
+	return app.ParseOutputBufferLimit(s, *f.p)
 }
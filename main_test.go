@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/valery-barysok/gredisd/app"
+
+	. "github.com/onsi/gomega"
+)
+
+// setEnv sets environment variables and returns function restoring them
+func setEnv(vars map[string]string) func() {
+	saved := make(map[string]string, len(vars))
+	for name, value := range vars {
+		saved[name] = os.Getenv(name)
+		os.Setenv(name, value)
+	}
+	return func() {
+		for name, value := range saved {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir, err := ioutil.TempDir("", "gredisd")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "gredisd.conf")
+	Expect(ioutil.WriteFile(config, []byte("maxclients 200\ntimeout 30\n"), 0600)).To(Succeed())
+	authFile := filepath.Join(dir, "auth")
+	Expect(ioutil.WriteFile(authFile, []byte("file secret\n"), 0600)).To(Succeed())
+
+	defer setEnv(map[string]string{
+		"GREDIS_PORT":       "6380",
+		"GREDIS_MAXCLIENTS": "100",
+		"GREDIS_TIMEOUT":    "10",
+		"GREDIS_AUTH":       "env secret",
+		app.AuthFileEnv:     "",
+	})()
+
+	opts, cl, err := parseOptions(nil)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(cl).To(Equal(commandLine{}))
+	Expect(opts.Port).To(Equal(6380))
+	Expect(opts.MaxClients).To(Equal(100))
+	Expect(opts.Auth).To(Equal("env secret"))
+
+	// config file overrides environment and flags override both
+	opts, _, err = parseOptions([]string{config, "--timeout", "60", "-p", "6381"})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(opts.ConfigFile).To(Equal(config))
+	Expect(opts.Port).To(Equal(6381))
+	Expect(opts.MaxClients).To(Equal(200))
+	Expect(opts.Timeout).To(Equal(60))
+
+	opts, _, err = parseOptions([]string{"--maxmemory", "1mb", "--client-output-buffer-limit", "pubsub 1mb 512kb 10"})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(opts.MaxMemory).To(Equal(int64(1 << 20)))
+	Expect(opts.ClientOutputBufferLimit["pubsub"]).To(Equal(app.OutputBufferLimit{Hard: 1 << 20, Soft: 512 << 10, SoftSeconds: 10}))
+	Expect(opts.ClientOutputBufferLimit["normal"]).To(Equal(app.DefaultOptions().ClientOutputBufferLimit["normal"]))
+
+	// --auth-file overrides GREDIS_AUTH but not --auth
+	opts, _, err = parseOptions([]string{"--auth-file", authFile})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(opts.Auth).To(Equal("file secret"))
+	opts, _, err = parseOptions([]string{"--auth", "flag secret"})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(opts.Auth).To(Equal("flag secret"))
+	_, _, err = parseOptions([]string{"--auth", "flag secret", "--auth-file", authFile})
+	Expect(err).To(MatchError("--auth and --auth-file are mutually exclusive"))
+	_, _, err = parseOptions([]string{"--auth-file", filepath.Join(dir, "missing")})
+	Expect(err).To(HaveOccurred())
+
+	opts, cl, err = parseOptions([]string{"--print-config", "--auth", "flag secret"})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(cl).To(Equal(commandLine{printConfig: true}))
+
+	// flags are validated the same way as config file
+	_, _, err = parseOptions([]string{"--maxclients", "0"})
+	Expect(err).To(MatchError("invalid maxclients: argument must be between 1 and 1073741824 inclusive"))
+	_, _, err = parseOptions([]string{"--maxmemory-policy", "never"})
+	Expect(err).To(MatchError("invalid maxmemory-policy: invalid maxmemory policy"))
+
+	// invalid environment is reported before config file and flags
+	defer setEnv(map[string]string{"GREDIS_PORT": "port"})()
+	_, _, err = parseOptions([]string{"-p", "6381"})
+	Expect(err).To(MatchError("GREDIS_PORT: argument couldn't be parsed into an integer"))
+}
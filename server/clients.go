@@ -23,3 +23,15 @@ func (clients *clientRegistry) detach() map[uint64]Client {
 	clients.clients = nil
 	return tmp
 }
+
+func (clients *clientRegistry) len() int {
+	return len(clients.clients)
+}
+
+func (clients *clientRegistry) list() []Client {
+	list := make([]Client, 0, len(clients.clients))
+	for _, client := range clients.clients {
+		list = append(list, client)
+	}
+	return list
+}
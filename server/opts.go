@@ -1,7 +1,15 @@
 package server
 
+import "time"
+
 // Options struct is configuration settings of Server
 type Options struct {
 	Host string `json:"addr"`
 	Port int    `json:"port"`
+
+	// MaxClients limits number of connected clients, 0 means no limit
+	MaxClients int `json:"maxclients"`
+
+	// KeepAlive is period of TCP keepalive probes of accepted connections, 0 disables them
+	KeepAlive time.Duration `json:"tcp_keepalive"`
 }
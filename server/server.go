@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"log"
 	"net"
 	"strconv"
@@ -12,8 +13,13 @@ import (
 const (
 	acceptMinSleep = 10 * time.Millisecond
 	acceptMaxSleep = 1 * time.Second
+
+	// rejectDeadline is timeout for writing error to rejected connection
+	rejectDeadline = 1 * time.Second
 )
 
+var errMaxClients = errors.New("ERR max number of clients reached")
+
 // Client serves specific incoming connection
 type Client interface {
 	ID() uint64
@@ -29,7 +35,11 @@ type ClientProvider interface {
 // Server contains various details about specific server like connected clients, server options etc
 type Server struct {
 	// last client id. used for generate next client id
-	cid            uint64
+	cid uint64
+	// number of accepted connections
+	connections uint64
+	// number of connections rejected due to maxclients limit
+	rejected       uint64
 	mu             sync.Mutex
 	opts           *Options
 	running        bool
@@ -37,6 +47,8 @@ type Server struct {
 	startTime      time.Time
 	clients        *clientRegistry
 	clientProvider ClientProvider
+	// number of clients being created, they count against maxclients limit
+	pending int
 
 	grMu      sync.Mutex
 	grRunning bool
@@ -60,6 +72,58 @@ func (server *Server) GenerateClientID() uint64 {
 	return atomic.AddUint64(&server.cid, 1)
 }
 
+// StartTime returns time when server was created
+func (server *Server) StartTime() time.Time {
+	return server.startTime
+}
+
+// ClientsCount returns number of connected clients
+func (server *Server) ClientsCount() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.clients.len()
+}
+
+// ConnectionsReceived returns total number of accepted connections
+func (server *Server) ConnectionsReceived() uint64 {
+	return atomic.LoadUint64(&server.connections)
+}
+
+// Clients returns snapshot of connected clients
+func (server *Server) Clients() []Client {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	return server.clients.list()
+}
+
+// RejectedConnections returns number of connections rejected due to maxclients limit
+func (server *Server) RejectedConnections() uint64 {
+	return atomic.LoadUint64(&server.rejected)
+}
+
+// ResetStats resets counters of accepted and rejected connections
+func (server *Server) ResetStats() {
+	atomic.StoreUint64(&server.connections, 0)
+	atomic.StoreUint64(&server.rejected, 0)
+}
+
+// SetMaxClients changes maximum number of connected clients, connected clients
+// above new limit are kept
+func (server *Server) SetMaxClients(maxClients int) {
+	server.mu.Lock()
+	server.opts.MaxClients = maxClients
+	server.mu.Unlock()
+}
+
+// SetKeepAlive changes period of TCP keepalive probes of new connections
+func (server *Server) SetKeepAlive(period time.Duration) {
+	server.mu.Lock()
+	server.opts.KeepAlive = period
+	server.mu.Unlock()
+}
+
 // Start begins to listen for incoming connections
 func (server *Server) Start() {
 	server.mu.Lock()
@@ -128,6 +192,8 @@ func (server *Server) acceptLoop() {
 		}
 
 		tmpDelay = acceptMinSleep
+		atomic.AddUint64(&server.connections, 1)
+		server.keepAlive(conn)
 		server.startGoRoutine(func() {
 			log.Printf("Server accepted connection on %s", conn.RemoteAddr())
 
@@ -143,13 +209,55 @@ func (server *Server) isRunning() bool {
 	return server.running
 }
 
+func (server *Server) keepAlive(conn net.Conn) {
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+
+	server.mu.Lock()
+	period := server.opts.KeepAlive
+	server.mu.Unlock()
+
+	if period > 0 {
+		tcp.SetKeepAlive(true)
+		tcp.SetKeepAlivePeriod(period)
+	} else {
+		tcp.SetKeepAlive(false)
+	}
+}
+
+// reject replies with error to connection exceeding maxclients limit and closes it
+func (server *Server) reject(conn net.Conn) error {
+	atomic.AddUint64(&server.rejected, 1)
+	log.Printf("Server rejected connection on %s: max number of clients reached", conn.RemoteAddr())
+
+	conn.SetWriteDeadline(time.Now().Add(rejectDeadline))
+	conn.Write([]byte("-" + errMaxClients.Error() + "\r\n"))
+	conn.Close()
+	return errMaxClients
+}
+
 func (server *Server) createClient(conn net.Conn) (Client, error) {
+	// limit is checked before client is created so rejected connection costs
+	// nothing, clients being created hold their slots until they are added
+	server.mu.Lock()
+	maxClients := server.opts.MaxClients
+	if server.running && maxClients > 0 && server.clients.len()+server.pending >= maxClients {
+		server.mu.Unlock()
+		return nil, server.reject(conn)
+	}
+	server.pending++
+	server.mu.Unlock()
+
 	client, err := server.clientProvider.CreateClient(server, conn)
+
+	server.mu.Lock()
+	server.pending--
 	if err != nil {
+		server.mu.Unlock()
 		return nil, err
 	}
-
-	server.mu.Lock()
 	if !server.running {
 		server.mu.Unlock()
 		return client, nil
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+type testClient struct {
+	id   uint64
+	conn net.Conn
+}
+
+func (client *testClient) ID() uint64 {
+	return client.id
+}
+
+func (client *testClient) Loop() {}
+
+func (client *testClient) CloseConnection() {
+	client.conn.Close()
+}
+
+// testClientProvider counts created clients and fails once err is set
+type testClientProvider struct {
+	mu      sync.Mutex
+	created int
+	err     error
+}
+
+func (cp *testClientProvider) CreateClient(server *Server, conn net.Conn) (Client, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.err != nil {
+		return nil, cp.err
+	}
+	cp.created++
+	return &testClient{id: server.GenerateClientID(), conn: conn}, nil
+}
+
+func (cp *testClientProvider) count() int {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.created
+}
+
+// newTestServer returns running server which does not start loops of clients
+func newTestServer(maxClients int) (*Server, *testClientProvider) {
+	cp := &testClientProvider{}
+	server := NewServer(&Options{MaxClients: maxClients}, cp)
+	server.running = true
+	return server, cp
+}
+
+func TestMaxClients(t *testing.T) {
+	RegisterTestingT(t)
+
+	server, cp := newTestServer(2)
+	for i := 0; i < 2; i++ {
+		conn, _ := net.Pipe()
+		client, err := server.createClient(conn)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(client.ID()).To(Equal(uint64(i + 1)))
+	}
+	Expect(server.ClientsCount()).To(Equal(2))
+
+	// connection above limit gets error and is closed without creating client
+	conn, peer := net.Pipe()
+	reply := make(chan string)
+	go func() {
+		data, _ := ioutil.ReadAll(peer)
+		reply <- string(data)
+	}()
+	client, err := server.createClient(conn)
+	Expect(err).To(Equal(errMaxClients))
+	Expect(client).To(BeNil())
+	Expect(<-reply).To(Equal("-ERR max number of clients reached\r\n"))
+	Expect(cp.count()).To(Equal(2))
+	Expect(server.ClientsCount()).To(Equal(2))
+	Expect(server.RejectedConnections()).To(Equal(uint64(1)))
+
+	server.ResetStats()
+	Expect(server.RejectedConnections()).To(BeZero())
+
+	// zero means no limit
+	server.SetMaxClients(0)
+	conn, _ = net.Pipe()
+	_, err = server.createClient(conn)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(server.ClientsCount()).To(Equal(3))
+}
+
+func TestMaxClientsFailedCreate(t *testing.T) {
+	RegisterTestingT(t)
+
+	// slot of client which failed to be created is released
+	server, cp := newTestServer(1)
+	cp.err = errors.New("failed")
+	conn, _ := net.Pipe()
+	_, err := server.createClient(conn)
+	Expect(err).To(Equal(cp.err))
+	Expect(server.pending).To(BeZero())
+
+	cp.err = nil
+	conn, _ = net.Pipe()
+	_, err = server.createClient(conn)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(server.RejectedConnections()).To(BeZero())
+}
+
+func TestMaxClientsConcurrent(t *testing.T) {
+	RegisterTestingT(t)
+
+	server, cp := newTestServer(5)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		conn, peer := net.Pipe()
+		go ioutil.ReadAll(peer)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			server.createClient(conn)
+		}()
+	}
+	wg.Wait()
+
+	Expect(server.ClientsCount()).To(Equal(5))
+	Expect(cp.count()).To(Equal(5))
+	Expect(server.RejectedConnections()).To(Equal(uint64(15)))
+}